@@ -36,6 +36,7 @@ if [ -z "${BUILD_DATE}" ]; then
 fi
 
 export CGO_ENABLED=0
+export GO111MODULE=off
 export GOARCH="${ARCH}"
 
 export PKGS=$(go list ./... | grep -v /vendor/)
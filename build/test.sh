@@ -19,11 +19,11 @@ set -o nounset
 set -o pipefail
 
 export CGO_ENABLED=0
+export GO111MODULE=off
 
 TARGETS=$(go list ./... | grep -v /vendor/)
 
 echo "Running tests:"
-go test -i -installsuffix "static" ${TARGETS}
 go test -cover -installsuffix "static" ${TARGETS}
 echo
 
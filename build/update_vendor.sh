@@ -14,5 +14,7 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+export GO111MODULE=off
+
 command -v dep >/dev/null 2>&1 || go get github.com/golang/dep/cmd/dep
 dep ensure -v
@@ -0,0 +1,81 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/webhook"
+)
+
+// devCmd handles deliveries forwarded by a webhook proxy channel
+var devCmd = &cobra.Command{
+	Use:   "dev --channel <url>",
+	Short: "Handles webhook deliveries of a proxy channel for local development",
+	Long: `Subscribes to a webhook proxy channel like https://smee.io/<channel> and
+feeds the forwarded deliveries through the handlers, so that handlers can be
+developed without exposing a public endpoint. Configure the channel URL as
+webhook URL of a development GitHub App. With --token (or GITHUB_TOKEN) the
+bot calls GitHub with this personal access token instead of as the app
+installation. Combine with --dry-run to only log what the bot would do.
+
+Payload signatures can't be verified, so don't use this in production.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			botConfig.DryRun = true
+		}
+		channel, _ := cmd.Flags().GetString("channel")
+		if channel == "" {
+			logger.Fatal("--channel is required")
+		}
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("GITHUB_TOKEN")
+		}
+
+		ctx, stop := context.WithCancel(context.Background())
+		c := make(chan os.Signal, 2)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-c
+			stop()
+		}()
+		if err := webhook.ListenChannel(ctx, channel, token, botConfig, logger.Named("dev")); err != nil {
+			logger.Fatal("failed to listen to channel", zap.Error(err))
+		}
+
+		logger.Info("shutting down, waiting for scheduled actions", zap.Duration("timeout", botConfig.HTTP.DrainTimeout))
+		drainCtx, cancel := context.WithTimeout(context.Background(), botConfig.HTTP.DrainTimeout)
+		defer cancel()
+		if err := webhook.Shutdown(drainCtx); err != nil {
+			logger.Error("failed to drain webhook handlers", zap.Error(err))
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(devCmd)
+
+	devCmd.Flags().String("channel", "", "URL of the webhook proxy channel, e.g. https://smee.io/<channel>")
+	devCmd.Flags().String("token", "", "GitHub personal access token, defaults to $GITHUB_TOKEN")
+	devCmd.Flags().Bool("dry-run", false, "Log mutating GitHub requests instead of sending them")
+}
@@ -0,0 +1,51 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/webhook"
+)
+
+// replayCmd feeds a recorded webhook delivery through the handlers
+var replayCmd = &cobra.Command{
+	Use:   "replay <file|delivery-id>",
+	Short: "Replays a recorded webhook delivery",
+	Long: `Replays a webhook delivery recorded to the webhook.recordDir directory, for
+debugging why the bot did or didn't act on it. Combine with --dry-run to only
+log what the bot would do.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			botConfig.DryRun = true
+		}
+
+		rec, err := webhook.LoadRecording(args[0], botConfig.Webhook.RecordDir)
+		if err != nil {
+			logger.Fatal("failed to load recording", zap.Error(err))
+		}
+		if err := webhook.Replay(rec, botConfig, logger.Named("replay")); err != nil {
+			logger.Fatal("replay failed", zap.Error(err))
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().Bool("dry-run", false, "Log mutating GitHub requests instead of sending them")
+}
@@ -17,6 +17,7 @@ package cmd
 import (
 	"flag"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -25,6 +26,7 @@ import (
 	"github.com/coreos/etcd/pkg/osutil"
 	"github.com/syndesisio/pure-bot/pkg/config"
 	"github.com/syndesisio/pure-bot/pkg/version"
+	"github.com/syndesisio/pure-bot/pkg/webhook"
 )
 
 var (
@@ -115,3 +117,14 @@ func initConfig() {
 
 	logger.Debug("Using config", zap.Reflect("config", botConfig))
 }
+
+// readConfig unmarshals the config file read by viper, starting from the
+// defaults so that removed settings are reset. Unknown keys and invalid
+// settings are rejected.
+func readConfig() (config.Config, error) {
+	cfg := config.NewWithDefaults()
+	if err := v.UnmarshalExact(&cfg); err != nil {
+		return cfg, errors.Wrap(err, "failed to unmarshal config file")
+	}
+	return cfg, webhook.ValidateConfig(cfg)
+}
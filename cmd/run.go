@@ -15,12 +15,16 @@
 package cmd
 
 import (
+	"context"
+	"expvar"
 	gohttp "net/http"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -29,17 +33,36 @@ import (
 	"github.com/syndesisio/pure-bot/pkg/webhook"
 )
 
+// How long open connections may take to finish once the webhook handlers
+// are drained
+const serverStopTimeout = 5 * time.Second
+
 // runCmd represents the run command
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Runs pure-bot",
 	Long:  `Runs pure-bot.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := readConfig()
+		if err != nil {
+			logger.Fatal("Invalid config, check it with 'pure-bot validate'", zap.Error(err))
+		}
+		botConfig = cfg
+
 		githubHandler, err := webhook.NewGithubHTTPHandler(botConfig.Webhook, botConfig, logger.Named("github"))
 		if err != nil {
 			logger.Fatal("failed to create webhook handler", zap.Error(err))
 		}
 
+		watchConfig(cmd, logger.Named("config"))
+
+		schedulerCtx, stopSchedulers := context.WithCancel(context.Background())
+		defer stopSchedulers()
+		go webhook.Reconcile(schedulerCtx, botConfig, logger.Named("reconciler"))
+		go webhook.SendDigests(schedulerCtx, botConfig, logger.Named("digest"))
+		go webhook.MarkStale(schedulerCtx, botConfig, logger.Named("stale"))
+		go webhook.SyncLabels(schedulerCtx, botConfig, logger.Named("labelSync"))
+
 		zenhubHandler, err := webhook.NewZenhubHTTPHandler(botConfig.Webhook, botConfig, logger.Named("zenhub"))
 		if err != nil {
 			logger.Fatal("failed to create webhook handler", zap.Error(err))
@@ -49,6 +72,13 @@ var runCmd = &cobra.Command{
 		mux := gohttp.NewServeMux()
 		mux.HandleFunc("/", githubHandler)
 		mux.HandleFunc("/zenhub", zenhubHandler)
+		mux.Handle("/debug/vars", expvar.Handler())
+		mux.Handle("/metrics", webhook.MetricsHandler())
+		mux.HandleFunc("/healthz", webhook.Healthz)
+		mux.Handle("/readyz", webhook.NewReadinessHandler(logger.Named("readiness")))
+		if botConfig.HTTP.AdminToken != "" {
+			mux.Handle("/admin/", webhook.NewAdminHandler(botConfig.HTTP.AdminToken, logger.Named("admin")))
+		}
 
 		// server
 		srv := http.New(botConfig.HTTP, mux)
@@ -65,16 +95,68 @@ var runCmd = &cobra.Command{
 				}
 			}
 		}()
+		// Stopping the server makes it return right away, the shutdown is
+		// waited for to stop it completely
+		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			<-c
-			if err := srv.Stop(); err != nil {
-				logger.Fatal("failed to stop web server", zap.Error(err))
+			stopSchedulers()
+			logger.Info("shutting down, waiting for in-flight webhook handlers", zap.Duration("timeout", botConfig.HTTP.DrainTimeout))
+			ctx, cancel := context.WithTimeout(context.Background(), botConfig.HTTP.DrainTimeout)
+			defer cancel()
+			if err := webhook.Shutdown(ctx); err != nil {
+				logger.Error("failed to drain webhook handlers", zap.Error(err))
+			}
+			// The drain may have used up the whole timeout
+			stopCtx, cancelStop := context.WithTimeout(context.Background(), serverStopTimeout)
+			defer cancelStop()
+			if err := srv.Stop(stopCtx); err != nil {
+				logger.Error("failed to stop web server", zap.Error(err))
 			}
 		}()
 		wg.Wait()
 	},
 }
 
+// watchConfig reloads the config file on SIGHUP and, if requested, whenever
+// it changes. Server settings like the listen address, webhook secrets or the
+// queue still need a restart.
+func watchConfig(cmd *cobra.Command, logger *zap.Logger) {
+	var mu sync.Mutex
+	reload := func(read bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if read {
+			if err := v.ReadInConfig(); err != nil {
+				logger.Error("failed to read config file, keeping current config", zap.Error(err))
+				return
+			}
+		}
+		cfg, err := readConfig()
+		if err != nil {
+			logger.Error("failed to reload config, keeping current config", zap.Error(err))
+			return
+		}
+		webhook.UpdateConfig(cfg)
+		logger.Info("Reloaded config file", zap.String("file", v.ConfigFileUsed()))
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reload(true)
+		}
+	}()
+
+	if watch, _ := cmd.Flags().GetBool("watch-config"); watch && v.ConfigFileUsed() != "" {
+		// viper has read the changed file already
+		v.OnConfigChange(func(fsnotify.Event) { reload(false) })
+		v.WatchConfig()
+	}
+}
+
 func init() {
 	RootCmd.AddCommand(runCmd)
 
@@ -88,8 +170,17 @@ func init() {
 	v.BindPFlag("http.tlsCert", runCmd.Flags().Lookup("tls-cert"))
 	runCmd.Flags().String("tls-key", "", "TLS key file")
 	v.BindPFlag("http.tlsKey", runCmd.Flags().Lookup("tls-key"))
+	runCmd.Flags().Duration("drain-timeout", 30*time.Second, "Maximum time to wait for in-flight webhook handlers on shutdown")
+	v.BindPFlag("http.drainTimeout", runCmd.Flags().Lookup("drain-timeout"))
+	runCmd.Flags().Bool("watch-config", false, "Reload the config file when it changes, besides on SIGHUP")
+	runCmd.Flags().Bool("dry-run", false, "Log mutating GitHub requests instead of sending them")
+	v.BindPFlag("dryRun", runCmd.Flags().Lookup("dry-run"))
 	runCmd.Flags().Int("github-app-id", 0, "GitHub App ID")
 	v.BindPFlag("github.appId", runCmd.Flags().Lookup("github-app-id"))
 	runCmd.Flags().String("github-app-private-key", "", "GitHub app private key file")
 	v.BindPFlag("github.privateKey", runCmd.Flags().Lookup("github-app-private-key"))
+	runCmd.Flags().String("github-base-url", "", "API URL of a GitHub Enterprise Server")
+	v.BindPFlag("github.baseUrl", runCmd.Flags().Lookup("github-base-url"))
+	runCmd.Flags().String("github-upload-url", "", "Upload URL of a GitHub Enterprise Server")
+	v.BindPFlag("github.uploadUrl", runCmd.Flags().Lookup("github-upload-url"))
 }
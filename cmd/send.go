@@ -0,0 +1,66 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/webhook"
+)
+
+// sendCmd feeds a local webhook payload through the handlers
+var sendCmd = &cobra.Command{
+	Use:   "send --event <type> <payload.json>",
+	Short: "Sends a local webhook payload through the handlers",
+	Long: `Sends a webhook payload from a file through the handlers, as if GitHub had
+delivered it, for testing handler changes before deploying them. With --token
+(or GITHUB_TOKEN) the bot calls GitHub with this personal access token instead
+of as the app installation of the payload. Combine with --dry-run to only log
+what the bot would do.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			botConfig.DryRun = true
+		}
+		event, _ := cmd.Flags().GetString("event")
+		if event == "" {
+			logger.Fatal("--event is required")
+		}
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("GITHUB_TOKEN")
+		}
+
+		payload, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			logger.Fatal("failed to read payload", zap.Error(err))
+		}
+		if err := webhook.Send(event, payload, token, botConfig, logger.Named("send")); err != nil {
+			logger.Fatal("send failed", zap.Error(err))
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(sendCmd)
+
+	sendCmd.Flags().String("event", "", "Webhook event type of the payload, e.g. pull_request")
+	sendCmd.Flags().String("token", "", "GitHub personal access token, defaults to $GITHUB_TOKEN")
+	sendCmd.Flags().Bool("dry-run", false, "Log mutating GitHub requests instead of sending them")
+}
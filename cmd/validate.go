@@ -0,0 +1,51 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// validateCmd checks a config file, e.g. in CI of the repository holding it
+var validateCmd = &cobra.Command{
+	Use:   "validate [config-file]",
+	Short: "Validates a config file",
+	Long: `Validates a config file, defaulting to the one given with --config. Unknown
+keys, unknown handler names, invalid label names and the like are reported
+and make the command exit with status 1.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			v.SetConfigFile(args[0])
+			if err := v.ReadInConfig(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+				os.Exit(1)
+			}
+		}
+
+		if _, err := readConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", v.ConfigFileUsed(), err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: valid\n", v.ConfigFileUsed())
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(validateCmd)
+}
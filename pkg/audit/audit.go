@@ -0,0 +1,188 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+const defaultBufferSize = 1000
+
+// Record describes a single mutating action of the bot
+type Record struct {
+	Time         time.Time              `json:"time"`
+	Action       string                 `json:"action"`
+	Repo         string                 `json:"repo,omitempty"`
+	Number       int                    `json:"number,omitempty"`
+	Installation int64                  `json:"installation,omitempty"`
+	Delivery     string                 `json:"delivery,omitempty"`
+	Event        string                 `json:"event,omitempty"`
+	Actor        string                 `json:"actor,omitempty"`
+	Method       string                 `json:"method"`
+	Path         string                 `json:"path"`
+	Status       int                    `json:"status,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	Inputs       map[string]interface{} `json:"inputs,omitempty"`
+}
+
+// Sink persists audit records
+type Sink interface {
+	Write(record Record) error
+	Close() error
+}
+
+// Log writes audit records asynchronously to a sink. Records are dropped
+// when the buffer is full, so that auditing never blocks the bot. A nil Log
+// discards all records.
+type Log struct {
+	mu      sync.RWMutex
+	closed  bool
+	records chan Record
+	dropped uint64
+	sink    Sink
+	done    chan struct{}
+	logger  *zap.Logger
+}
+
+// New creates an audit log for the configured sink or returns nil if
+// auditing is not configured.
+func New(cfg config.AuditConfig, logger *zap.Logger) (*Log, error) {
+	var sink Sink
+	var err error
+	switch {
+	case cfg.File != "":
+		sink, err = newFileSink(cfg.File)
+	case cfg.URL != "":
+		sink = newHTTPSink(cfg.URL)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create audit sink")
+	}
+
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	l := &Log{
+		records: make(chan Record, bufferSize),
+		sink:    sink,
+		done:    make(chan struct{}),
+		logger:  logger,
+	}
+	go l.run()
+	return l, nil
+}
+
+func (l *Log) run() {
+	defer close(l.done)
+	for record := range l.records {
+		if err := l.sink.Write(record); err != nil {
+			l.logger.Error("failed to write audit record", zap.Error(err), zap.Reflect("record", record))
+		}
+	}
+}
+
+// Record queues a record for writing without blocking
+func (l *Log) Record(record Record) {
+	if l == nil {
+		return
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		l.logger.Warn("audit log closed, dropping record", zap.String("action", record.Action))
+		return
+	}
+	select {
+	case l.records <- record:
+	default:
+		dropped := atomic.AddUint64(&l.dropped, 1)
+		l.logger.Warn("audit buffer full, dropping record", zap.String("action", record.Action), zap.Uint64("dropped", dropped))
+	}
+}
+
+// Dropped returns the number of records dropped because of a full buffer
+func (l *Log) Dropped() uint64 {
+	if l == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&l.dropped)
+}
+
+// Close writes all buffered records and closes the sink. Records added
+// afterwards are dropped.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	l.closed = true
+	close(l.records)
+	l.mu.Unlock()
+
+	<-l.done
+	return l.sink.Close()
+}
+
+// Trigger is the webhook event the bot acts upon
+type Trigger struct {
+	// Event type and action, e.g. "pull_request.labeled"
+	Event string
+	// Login of the user who caused the event
+	Actor string
+}
+
+type triggerKey struct{}
+
+// WithTrigger attaches the triggering event to a context. It is added to the
+// audit records of all requests made with this context.
+func WithTrigger(ctx context.Context, trigger Trigger) context.Context {
+	return context.WithValue(ctx, triggerKey{}, trigger)
+}
+
+// TriggerFromContext returns the trigger attached by WithTrigger
+func TriggerFromContext(ctx context.Context) Trigger {
+	trigger, _ := ctx.Value(triggerKey{}).(Trigger)
+	return trigger
+}
+
+type inputsKey struct{}
+
+// WithInputs attaches the inputs of a decision to a context. They are added
+// to the audit record of the request made with this context.
+func WithInputs(ctx context.Context, inputs map[string]interface{}) context.Context {
+	return context.WithValue(ctx, inputsKey{}, inputs)
+}
+
+// InputsFromContext returns the inputs attached by WithInputs, nil if none
+// are attached
+func InputsFromContext(ctx context.Context) map[string]interface{} {
+	inputs, _ := ctx.Value(inputsKey{}).(map[string]interface{})
+	return inputs
+}
@@ -0,0 +1,77 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fileSink appends records as JSON lines to a file
+type fileSink struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open audit log %s", path)
+	}
+	return &fileSink{file, json.NewEncoder(file)}, nil
+}
+
+func (s *fileSink) Write(record Record) error {
+	return s.encoder.Encode(record)
+}
+
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
+
+// httpSink posts each record as JSON to an URL
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPSink(url string) *httpSink {
+	return &httpSink{url, &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *httpSink) Write(record Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit record")
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "failed to post audit record to %s", s.url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("received non 2xx response status %d when posting audit record to %s", resp.StatusCode, s.url)
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Recorder receives a record of each mutating request
+type Recorder interface {
+	Record(record Record)
+}
+
+// Transport records every mutating GitHub API request passing through it
+type Transport struct {
+	recorder       Recorder
+	tr             http.RoundTripper
+	installationID int64
+	deliveryID     string
+}
+
+var _ http.RoundTripper = &Transport{}
+
+// NewTransport returns a Transport passing the records of requests made on
+// behalf of the given installation and webhook delivery to the recorder.
+func NewTransport(recorder Recorder, tr http.RoundTripper, installationID int64, deliveryID string) *Transport {
+	return &Transport{recorder, tr, installationID, deliveryID}
+}
+
+// Wrap returns a function wrapping a transport so that it records mutating
+// requests made on behalf of the given installation and webhook delivery.
+func (l *Log) Wrap(installationID int64, deliveryID string) func(http.RoundTripper) http.RoundTripper {
+	return func(tr http.RoundTripper) http.RoundTripper {
+		if l == nil {
+			return tr
+		}
+		return NewTransport(l, tr, installationID, deliveryID)
+	}
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return t.tr.RoundTrip(req)
+	}
+
+	state := requestedState(req)
+	resp, err := t.tr.RoundTrip(req)
+
+	repo, number, action := classify(req.Method, req.URL.Path, state)
+	trigger := TriggerFromContext(req.Context())
+	record := Record{
+		Action:       action,
+		Repo:         repo,
+		Number:       number,
+		Installation: t.installationID,
+		Delivery:     t.deliveryID,
+		Event:        trigger.Event,
+		Actor:        trigger.Actor,
+		Method:       req.Method,
+		Path:         req.URL.Path,
+		Inputs:       InputsFromContext(req.Context()),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record.Status = resp.StatusCode
+	}
+	t.recorder.Record(record)
+
+	return resp, err
+}
+
+// requestedState returns the state an issue or pull request is edited to,
+// e.g. "closed", read from a copy of the request body
+func requestedState(req *http.Request) string {
+	if req.Method != http.MethodPatch || req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return ""
+	}
+	var edit struct {
+		State string `json:"state"`
+	}
+	json.Unmarshal(data, &edit)
+	return edit.State
+}
+
+// classify extracts the repository and issue number from a GitHub API path
+// and names the action performed by the request. The state is the one an
+// issue or pull request is edited to, if any.
+func classify(method, path string, state string) (repo string, number int, action string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range parts {
+		if part == "repos" && i+2 < len(parts) {
+			parts = parts[i+1:]
+			break
+		}
+	}
+	if len(parts) < 2 {
+		return "", 0, strings.ToLower(method) + " " + path
+	}
+	repo = parts[0] + "/" + parts[1]
+	rest := parts[2:]
+	if len(rest) >= 2 && (rest[0] == "issues" || rest[0] == "pulls") {
+		number, _ = strconv.Atoi(rest[1])
+	}
+
+	resource := strings.Join(rest, "/")
+	switch {
+	case len(rest) == 3 && rest[0] == "pulls" && rest[2] == "merge":
+		action = "merge"
+	case len(rest) >= 3 && rest[0] == "issues" && rest[2] == "labels":
+		if method == http.MethodDelete {
+			action = "label-remove"
+		} else {
+			action = "label-add"
+		}
+	case len(rest) == 3 && rest[0] == "issues" && rest[2] == "comments":
+		action = "comment"
+	case len(rest) >= 3 && rest[0] == "pulls" && rest[2] == "requested_reviewers":
+		action = "review-request"
+	case len(rest) >= 3 && rest[0] == "git" && rest[1] == "refs" && rest[2] == "heads" && method == http.MethodDelete:
+		action = "branch-delete"
+	case len(rest) == 2 && (rest[0] == "issues" || rest[0] == "pulls") && method == http.MethodPatch && state == "closed":
+		action = "close"
+	case len(rest) == 2 && (rest[0] == "issues" || rest[0] == "pulls") && method == http.MethodPatch && state == "open":
+		action = "reopen"
+	default:
+		action = strings.ToLower(method) + " " + resource
+	}
+	return repo, number, action
+}
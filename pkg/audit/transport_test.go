@@ -0,0 +1,31 @@
+package audit
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		method, path string
+		state        string
+		repo         string
+		number       int
+		action       string
+	}{
+		{"PUT", "/repos/syndesisio/pure-bot/pulls/12/merge", "", "syndesisio/pure-bot", 12, "merge"},
+		{"POST", "/repos/syndesisio/pure-bot/issues/3/labels", "", "syndesisio/pure-bot", 3, "label-add"},
+		{"DELETE", "/repos/syndesisio/pure-bot/issues/3/labels/approved", "", "syndesisio/pure-bot", 3, "label-remove"},
+		{"POST", "/api/v3/repos/syndesisio/pure-bot/issues/3/comments", "", "syndesisio/pure-bot", 3, "comment"},
+		{"POST", "/repos/syndesisio/pure-bot/pulls/7/requested_reviewers", "", "syndesisio/pure-bot", 7, "review-request"},
+		{"DELETE", "/repos/syndesisio/pure-bot/git/refs/heads/feature", "", "syndesisio/pure-bot", 0, "branch-delete"},
+		{"POST", "/repos/syndesisio/pure-bot/statuses/abc", "", "syndesisio/pure-bot", 0, "post statuses/abc"},
+		{"POST", "/graphql", "", "", 0, "post /graphql"},
+		{"PATCH", "/repos/syndesisio/pure-bot/issues/5", "closed", "syndesisio/pure-bot", 5, "close"},
+		{"PATCH", "/repos/syndesisio/pure-bot/pulls/5", "open", "syndesisio/pure-bot", 5, "reopen"},
+		{"PATCH", "/repos/syndesisio/pure-bot/issues/5", "", "syndesisio/pure-bot", 5, "patch issues/5"},
+	}
+	for _, test := range tests {
+		repo, number, action := classify(test.method, test.path, test.state)
+		if repo != test.repo || number != test.number || action != test.action {
+			t.Errorf("%s %s: got (%s, %d, %s)", test.method, test.path, repo, number, action)
+		}
+	}
+}
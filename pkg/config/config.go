@@ -14,32 +14,89 @@
 
 package config
 
+import "time"
+
 func NewWithDefaults() Config {
 	return Config{
-		HTTPConfig{
-			Address: "",
-			Port:    8080,
+		HTTP: HTTPConfig{
+			Address:      "",
+			Port:         8080,
+			DrainTimeout: 30 * time.Second,
 		},
-		WebhookConfig{},
-		GitHubAppConfig{},
-		RepoConfig{
+		DefaultRepo: RepoConfig{
 			Labels: LabelConfig{
 				Approved: "approved",
 			},
+			// Matches "WIP" and "[WIP]"
+			WipPatterns: []string{"wip"},
 			Board: Board{
-				"<token>", "<repo>", []Column{},
+				ZenhubToken: "<token>",
+				GithubRepo:  "<repo>",
+				Columns:     []Column{},
 			},
 		},
-		nil,
+		HandlerTimeout: time.Minute,
 	}
 }
 
 type Config struct {
-	HTTP        HTTPConfig            `mapstructure:"http"`
-	Webhook     WebhookConfig         `mapstructure:"webhook"`
-	GitHubApp   GitHubAppConfig       `mapstructure:"github"`
-	DefaultRepo RepoConfig            `mapstructure:"defaults"`
-	Repos       map[string]RepoConfig `mapstructure:"repos"`
+	HTTP      HTTPConfig      `mapstructure:"http"`
+	Webhook   WebhookConfig   `mapstructure:"webhook"`
+	GitHubApp GitHubAppConfig `mapstructure:"github"`
+	// Further GitHub Apps, e.g. one per organisation. Events are handled
+	// with the credentials of the app the installation belongs to.
+	GitHubApps  []GitHubAppConfig `mapstructure:"githubApps"`
+	DefaultRepo RepoConfig        `mapstructure:"defaults"`
+	// Defaults per organisation, overriding the global defaults
+	Orgs map[string]RepoConfig `mapstructure:"orgs"`
+	// Configuration per repository, keyed by name or full name
+	// ("owner/name"), overriding the defaults of its organisation
+	Repos map[string]RepoConfig `mapstructure:"repos"`
+	Audit AuditConfig           `mapstructure:"audit"`
+
+	Tracing TracingConfig `mapstructure:"tracing"`
+
+	// Endpoints notified about merges, label changes and closes of the bot
+	Notifications []NotificationConfig `mapstructure:"notifications"`
+
+	// Slack workspace receiving messages about merges and failures in the
+	// channels of the repositories
+	Slack SlackConfig `mapstructure:"slack"`
+
+	// Matrix account posting to the rooms of the repositories
+	Matrix MatrixConfig `mapstructure:"matrix"`
+
+	// Number of consecutive failures of a handler for a repository before
+	// they are reported to the repository's chat, defaults to 3
+	FailureThreshold int `mapstructure:"failureThreshold"`
+
+	// Email digests of the bot's activity per organisation
+	Digest DigestConfig `mapstructure:"digest"`
+
+	// Log mutating GitHub and ZenHub requests instead of sending them
+	DryRun bool `mapstructure:"dryRun"`
+
+	// Interval in which all approved PRs of all installations are evaluated
+	// for merging, in case webhook deliveries got lost. 0 disables it.
+	ReconcileInterval time.Duration `mapstructure:"reconcileInterval"`
+
+	// Interval in which the open issues and pull requests of all
+	// installations are checked for inactivity, e.g. 24h. 0 disables it.
+	StaleInterval time.Duration `mapstructure:"staleInterval"`
+
+	// Interval in which the label definitions are applied to all
+	// repositories of all installations, e.g. 24h. 0 disables it.
+	LabelSyncInterval time.Duration `mapstructure:"labelSyncInterval"`
+
+	// Maximum time a handler may take for an event, including its GitHub
+	// requests. Actions it schedules for later aren't limited by it. 0
+	// disables the limit.
+	HandlerTimeout time.Duration `mapstructure:"handlerTimeout"`
+
+	Queue QueueConfig `mapstructure:"queue"`
+
+	// Starlark scripts reacting to webhook events
+	Scripts []ScriptConfig `mapstructure:"scripts"`
 }
 
 type HTTPConfig struct {
@@ -47,15 +104,159 @@ type HTTPConfig struct {
 	Port    int    `mapstructure:"port"`
 	TLSCert string `mapstructure:"tlsCert"`
 	TLSKey  string `mapstructure:"tlsKey"`
+
+	// DrainTimeout is the maximum time to wait on shutdown for in-flight
+	// webhook handlers to finish
+	DrainTimeout time.Duration `mapstructure:"drainTimeout"`
+
+	// Bearer token for the /admin endpoints, which are disabled without one
+	AdminToken string `mapstructure:"adminToken"`
 }
 
 type WebhookConfig struct {
 	Secret string `mapstructure:"secret"`
+	// Additional secrets accepted when validating payloads, e.g. the old
+	// and the new one while rotating the secret
+	Secrets []string `mapstructure:"secrets"`
+
+	// Directory to which all deliveries are written for replaying them
+	RecordDir string `mapstructure:"recordDir"`
+
+	// Number of recent delivery IDs remembered for detecting redeliveries
+	DedupCacheSize int `mapstructure:"dedupCacheSize"`
+
+	// Number of processed deliveries kept for the admin API
+	HistorySize int `mapstructure:"historySize"`
+}
+
+// TracingConfig defines where trace spans of the webhook processing are
+// exported to with OTLP over HTTP. Tracing is disabled without an endpoint.
+type TracingConfig struct {
+	// Base URL of the collector, e.g. http://otel-collector:4318. Spans are
+	// posted to its /v1/traces path.
+	Endpoint string `mapstructure:"endpoint"`
+	// Additional headers sent with each export, e.g. for authentication
+	Headers map[string]string `mapstructure:"headers"`
+	// Reported as service.name, "pure-bot" by default
+	ServiceName string `mapstructure:"serviceName"`
+	// Number of finished spans buffered before dropping them
+	BufferSize int `mapstructure:"bufferSize"`
+}
+
+// NotificationConfig defines an endpoint to which the bot posts a signed JSON
+// notification after each of the given actions
+type NotificationConfig struct {
+	URL string `mapstructure:"url"`
+	// Key of the HMAC-SHA256 signature in the X-Pure-Bot-Signature-256 header
+	Secret string `mapstructure:"secret"`
+	// Audit actions notified about, by default "merge", "label-add",
+	// "label-remove" and "close"
+	Actions []string `mapstructure:"actions"`
+}
+
+// SlackConfig defines the Slack app posting to the channels given by the
+// repositories' slackChannel. Slack is disabled without a token.
+type SlackConfig struct {
+	// Bot token of the Slack app, "xoxb-..."
+	Token string `mapstructure:"token"`
+	// Base URL of the Slack Web API, defaults to https://slack.com/api/
+	APIURL string `mapstructure:"apiUrl"`
+}
+
+// MatrixConfig defines the Matrix account posting to the rooms given by the
+// repositories' matrixRoom. Matrix is disabled without an access token.
+type MatrixConfig struct {
+	// Base URL of the homeserver, e.g. https://matrix.org
+	Homeserver  string `mapstructure:"homeserver"`
+	AccessToken string `mapstructure:"accessToken"`
+}
+
+// DigestConfig defines the email digests summarizing the PRs merged by the
+// bot, the PRs blocked and the stale approved PRs of an organisation.
+// Digests are disabled without a schedule.
+type DigestConfig struct {
+	// "daily" or "weekly" (sent on Mondays)
+	Schedule string `mapstructure:"schedule"`
+	// Hour of the day in UTC at which digests are sent
+	Hour int `mapstructure:"hour"`
+	// Approved PRs blocked for longer are listed as stale, defaults to 72h
+	StaleAfter time.Duration `mapstructure:"staleAfter"`
+	// Email addresses per organisation
+	Recipients map[string][]string `mapstructure:"recipients"`
+	SMTP       SMTPConfig          `mapstructure:"smtp"`
+}
+
+// SMTPConfig defines the mail server sending digests
+type SMTPConfig struct {
+	Host string `mapstructure:"host"`
+	// Defaults to 587
+	Port int `mapstructure:"port"`
+	// Authenticates with PLAIN if given
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+}
+
+// AuditConfig defines where the audit log of all mutating actions is
+// written to. Either a file or an URL can be given.
+type AuditConfig struct {
+	File string `mapstructure:"file"`
+	URL  string `mapstructure:"url"`
+	// Number of records buffered before dropping them
+	BufferSize int `mapstructure:"bufferSize"`
+}
+
+// QueueConfig defines where webhook deliveries are kept until they are
+// processed. Only with a persistent backend they survive restarts.
+type QueueConfig struct {
+	// "memory" (default) or "postgres"
+	Backend string `mapstructure:"backend"`
+	// Connection string, e.g. "postgres://pure-bot@db/pure-bot?sslmode=disable"
+	URL string `mapstructure:"url"`
+	// How long processed events are kept, defaults to 24h
+	Retention time.Duration `mapstructure:"retention"`
+	// Attempts to process an event before it becomes a dead letter,
+	// defaults to 5
+	MaxAttempts int `mapstructure:"maxAttempts"`
+	// Number of events processed concurrently, defaults to 4. Events of
+	// one repository are processed one after another.
+	Workers int `mapstructure:"workers"`
+}
+
+// ScriptConfig attaches a Starlark script or a WebAssembly module to webhook
+// events. It gets the payload and requests actions with built-in functions.
+type ScriptConfig struct {
+	Name string `mapstructure:"name"`
+	// Event types, e.g. "issues" or "pull_request"
+	Events []string `mapstructure:"events"`
+	// Starlark file of the script or a WebAssembly module ending in .wasm,
+	// read for every event
+	File string `mapstructure:"file"`
+	// Names or full names of the repositories, all if empty
+	Repos []string `mapstructure:"repos"`
+	// Defaults to 10s
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Run only for repositories enabling the script with
+	// "script:<name>: true" in their handlers config
+	OptIn bool `mapstructure:"optIn"`
 }
 
 type GitHubAppConfig struct {
 	AppID          int64  `mapstructure:"appId"`
 	PrivateKeyFile string `mapstructure:"privateKey"`
+
+	// API and upload URL of a GitHub Enterprise Server, github.com if empty
+	BaseURL   string `mapstructure:"baseUrl"`
+	UploadURL string `mapstructure:"uploadUrl"`
+}
+
+// Apps returns all configured GitHub Apps
+func (c Config) Apps() []GitHubAppConfig {
+	var apps []GitHubAppConfig
+	if c.GitHubApp.AppID != 0 {
+		apps = append(apps, c.GitHubApp)
+	}
+	return append(apps, c.GitHubApps...)
 }
 
 type RepoConfig struct {
@@ -63,6 +264,136 @@ type RepoConfig struct {
 	Labels      LabelConfig `mapstructure:"labels"`
 	WipPatterns []string    `mapstructure:"wipPatterns"`
 	Board       Board       `mapstructure:"board"`
+
+	// Switches single handlers on or off by name, e.g. "autoMerge" or
+	// "labelSync". Handlers not listed are enabled.
+	Handlers map[string]bool `mapstructure:"handlers"`
+
+	// "merge" (default), "squash" or "rebase"
+	MergeMethod string `mapstructure:"mergeMethod"`
+
+	// "bot" (default) lets the bot evaluate and merge approved PRs, "native"
+	// only enables GitHub's auto-merge for them
+	MergeStrategy string `mapstructure:"mergeStrategy"`
+
+	// Templates for the commit created by merging
+	MergeCommit MergeCommitConfig `mapstructure:"mergeCommit"`
+
+	// Weekly time spans in which merging is allowed, e.g.
+	// "Mon-Thu 08:00-18:00 Europe/Berlin". Merging is always allowed if
+	// empty.
+	MergeWindows []string `mapstructure:"mergeWindows"`
+
+	// Statuses and checks which must be present and passing before merging,
+	// in addition to the ones required by branch protection
+	ExpectedChecks []string `mapstructure:"expectedChecks"`
+
+	// Reported statuses and checks which never gate merging, e.g. coverage
+	// deltas. Required contexts of branch protection still apply.
+	IgnoredChecks []string `mapstructure:"ignoredChecks"`
+
+	// If given, only these statuses and checks gate merging. They must be
+	// present and passing, all other reported ones are ignored.
+	RequiredChecks []string `mapstructure:"requiredChecks"`
+
+	// Explain in a PR comment which statuses and checks prevent merging an
+	// approved PR
+	ExplainBlockedMerge bool `mapstructure:"explainBlockedMerge"`
+
+	// Conclusions of check runs which allow merging, defaults to "success".
+	// Add e.g. "neutral" and "skipped" for optional checks.
+	PassingConclusions []string `mapstructure:"passingConclusions"`
+
+	// Follow-up actions after automerging a PR
+	PostMerge PostMergeConfig `mapstructure:"postMerge"`
+
+	// Deviating automerge rules for PRs of specific authors, e.g. to merge
+	// dependency updates of bots without approval. The first matching
+	// policy applies.
+	AuthorPolicies []AuthorMergePolicy `mapstructure:"authorPolicies"`
+
+	// Minimum number of approving reviews before merging, in addition to
+	// the reviews required by branch protection. Merging is blocked as well
+	// while a reviewer requests changes.
+	RequiredApprovals int `mapstructure:"requiredApprovals"`
+
+	// Maximum number of merge attempts when GitHub refuses a merge,
+	// defaults to 3
+	MergeAttempts int `mapstructure:"mergeAttempts"`
+
+	// Merge the base branch into PR branches which are behind before
+	// merging them
+	AutoUpdateBranch bool `mapstructure:"autoUpdateBranch"`
+
+	// Apply the approved label once all touched directories are approved
+	// by their OWNERS instead of relying on a human to apply it
+	OwnersApproval bool `mapstructure:"ownersApproval"`
+
+	// Teams of the repository's organisation, by slug, whose members may
+	// approve with "/lgtm" besides users with write access
+	ApproverTeams []string `mapstructure:"approverTeams"`
+
+	// Labels which users with read access may add with "/label" and remove
+	// with "/remove-label". Entries ending with "/" allow all labels with
+	// this prefix, e.g. "kind/".
+	TriageLabels []string `mapstructure:"triageLabels"`
+
+	// Labels applied to pull requests by the paths of their changed files
+	PathLabels []PathLabel `mapstructure:"pathLabels"`
+	// File in the repository's default branch with further path labels,
+	// e.g. ".github/labeler.yml", mapping labels to lists of globs
+	LabelerFile string `mapstructure:"labelerFile"`
+
+	// Milestones assigned to pull requests without one by their base
+	// branch, the first matching entry applies
+	BranchMilestones []BranchMilestone `mapstructure:"branchMilestones"`
+
+	// Policy deciding whether an approved PR which passes all other rules
+	// gets merged
+	MergePolicy MergePolicyConfig `mapstructure:"mergePolicy"`
+
+	// Don't auto-merge while review conversations are unresolved
+	RequireResolvedConversations bool `mapstructure:"requireResolvedConversations"`
+
+	// Publish the conclusion of GitHub Actions workflow runs as commit status
+	MirrorWorkflowStatus bool `mapstructure:"mirrorWorkflowStatus"`
+
+	// Status and workflow run events for the same commit arriving within
+	// this window are evaluated together. Defaults to 10s, negative
+	// durations disable debouncing.
+	DebounceWindow time.Duration `mapstructure:"debounceWindow"`
+
+	// Slack channel notified about merges, failed merges and repeatedly
+	// failing handlers, e.g. "#syndesis-ci". Requires slack.token.
+	SlackChannel string `mapstructure:"slackChannel"`
+
+	// Matrix room notified like the Slack channel, given by ID
+	// ("!abc:matrix.org") or alias ("#syndesis:matrix.org"). Requires
+	// matrix.accessToken.
+	MatrixRoom string `mapstructure:"matrixRoom"`
+
+	LabelSync          LabelSyncConfig          `mapstructure:"labelSync"`
+	ReviewerAssignment ReviewerAssignmentConfig `mapstructure:"reviewerAssignment"`
+	AutoRetest         AutoRetestConfig         `mapstructure:"autoRetest"`
+	Description        DescriptionConfig        `mapstructure:"description"`
+	DependencyUpdates  DependencyUpdatesConfig  `mapstructure:"dependencyUpdates"`
+	Welcome            WelcomeConfig            `mapstructure:"welcome"`
+	Stale              StaleConfig              `mapstructure:"stale"`
+	SizeLabels         SizeLabelsConfig         `mapstructure:"sizeLabels"`
+	DCO                DCOConfig                `mapstructure:"dco"`
+	CLA                CLAConfig                `mapstructure:"cla"`
+	CommitLint         CommitLintConfig         `mapstructure:"commitLint"`
+	TitleLint          TitleLintConfig          `mapstructure:"titleLint"`
+	BranchCleanup      BranchCleanupConfig      `mapstructure:"branchCleanup"`
+	Backport           BackportConfig           `mapstructure:"backport"`
+	ReleaseNotes       ReleaseNotesConfig       `mapstructure:"releaseNotes"`
+	Release            ReleaseConfig            `mapstructure:"release"`
+	Project            ProjectConfig            `mapstructure:"project"`
+
+	// Actions run when the app gets installed on the repository, any of
+	// "labelSync" and "branchProtection"
+	Bootstrap        []string               `mapstructure:"bootstrap"`
+	BranchProtection BranchProtectionConfig `mapstructure:"branchProtection"`
 }
 
 type LabelConfig struct {
@@ -70,6 +401,330 @@ type LabelConfig struct {
 	Wip             []string `mapstructure:"wip"`
 	ReviewRequested string   `mapstructure:"reviewRequested"`
 	Approved        string   `mapstructure:"approved"`
+	Hold            string   `mapstructure:"hold"`
+	NeedsRebase     string   `mapstructure:"needsRebase"`
+}
+
+// LabelSyncConfig defines the canonical set of labels of a repository
+type LabelSyncConfig struct {
+	Labels []LabelDefinition `mapstructure:"labels"`
+	// Delete labels which are not defined
+	Prune bool `mapstructure:"prune"`
+}
+
+type LabelDefinition struct {
+	Name        string `mapstructure:"name"`
+	Color       string `mapstructure:"color"`
+	Description string `mapstructure:"description"`
+	// Existing labels which get renamed to this label
+	Aliases []string `mapstructure:"aliases"`
+}
+
+// ReviewerAssignmentConfig configures which reviewers are requested for new
+// pull requests
+type ReviewerAssignmentConfig struct {
+	Candidates []string `mapstructure:"candidates"`
+	// Number of reviewers to request, defaults to 1
+	Count int `mapstructure:"count"`
+	// "round-robin" (default) or "load-balanced"
+	Strategy string `mapstructure:"strategy"`
+	// Candidates which are currently not available, e.g. on vacation
+	Unavailable []string `mapstructure:"unavailable"`
+	// Request reviews from the owners of the changed files given in the
+	// CODEOWNERS file of the repository
+	CodeOwners bool `mapstructure:"codeOwners"`
+	// Reviewer pools for repositories without CODEOWNERS file. The least
+	// recently assigned reviewer of each pool matching the changed files is
+	// requested.
+	Pools []ReviewerPool `mapstructure:"pools"`
+}
+
+// ReviewerPool is a group of reviewers sharing the reviews of some paths
+type ReviewerPool struct {
+	// Path prefixes of the changed files, the pool applies to all pull
+	// requests if there are none
+	Paths     []string `mapstructure:"paths"`
+	Reviewers []string `mapstructure:"reviewers"`
+}
+
+// AutoRetestConfig configures re-running failed checks of approved pull
+// requests
+type AutoRetestConfig struct {
+	// Patterns of check names which may be re-run
+	Checks []string `mapstructure:"checks"`
+	// Maximum number of re-runs per commit
+	MaxRetries int `mapstructure:"maxRetries"`
+	// Label applied when the maximum number of re-runs is exceeded
+	FlakeLabel string `mapstructure:"flakeLabel"`
+}
+
+// DescriptionConfig defines rules the description of a pull request must
+// comply with
+type DescriptionConfig struct {
+	// Headings which must be present, e.g. "## Motivation"
+	RequiredHeadings []string `mapstructure:"requiredHeadings"`
+	MinLength        int      `mapstructure:"minLength"`
+	// Checkboxes containing one of these texts must be checked
+	RequiredCheckboxes []string `mapstructure:"requiredCheckboxes"`
+	// Placeholders which must have been replaced, e.g. "TODO"
+	ForbiddenStrings []string `mapstructure:"forbiddenStrings"`
+	// Authors whose pull requests are not checked, e.g. "dependabot[bot]"
+	ExemptAuthors []string `mapstructure:"exemptAuthors"`
+}
+
+// HandlerEnabled returns false if the named handler is switched off
+func (c RepoConfig) HandlerEnabled(name string) bool {
+	enabled, found := c.Handlers[name]
+	return !found || enabled
+}
+
+// Enabled returns true if any rule is configured
+func (c DescriptionConfig) Enabled() bool {
+	return len(c.RequiredHeadings) > 0 || c.MinLength > 0 || len(c.RequiredCheckboxes) > 0 || len(c.ForbiddenStrings) > 0
+}
+
+// DependencyUpdatesConfig configures the automatic approval of pull requests
+// opened by dependency update bots
+type DependencyUpdatesConfig struct {
+	// Bot accounts, e.g. "dependabot[bot]" or "renovate[bot]"
+	Authors []string `mapstructure:"authors"`
+	// Update types which get approved, any of "major", "minor" and "patch"
+	AllowedUpdateTypes []string `mapstructure:"allowedUpdateTypes"`
+	// Label applied to all other updates
+	NeedsReviewLabel string `mapstructure:"needsReviewLabel"`
+	// Also submit an approving review, e.g. when required by branch protection
+	SubmitReview bool `mapstructure:"submitReview"`
+}
+
+// PostMergeConfig defines what is done with automerged PRs
+type PostMergeConfig struct {
+	RemoveApprovedLabel bool `mapstructure:"removeApprovedLabel"`
+	// Label added to merged PRs
+	Label string `mapstructure:"label"`
+	// Assign the open milestone which is due next, unless the PR already
+	// has a milestone
+	AssignMilestone bool `mapstructure:"assignMilestone"`
+	// Go template of a comment, using the PR's .Title, .Number, .Body and
+	// .Author
+	Comment string `mapstructure:"comment"`
+}
+
+// WelcomeConfig configures the comment greeting authors of their first pull
+// request in a repository
+type WelcomeConfig struct {
+	// Go template of the comment, using the PR's .Title, .Number, .Body and
+	// .Author. No comment is posted if empty.
+	Comment string `mapstructure:"comment"`
+}
+
+// StaleConfig configures labeling and closing inactive issues and pull
+// requests
+type StaleConfig struct {
+	// Days without activity after which an issue or pull request is marked
+	// as stale. 0 disables it.
+	DaysUntilStale int `mapstructure:"daysUntilStale"`
+	// Days without activity after which a stale issue or pull request is
+	// closed. 0 never closes them.
+	DaysUntilClose int `mapstructure:"daysUntilClose"`
+	// Label marking stale issues and pull requests, defaults to "stale"
+	Label string `mapstructure:"label"`
+	// Issues and pull requests with one of these labels never become stale
+	ExemptLabels []string `mapstructure:"exemptLabels"`
+	// Go templates of the comments when marking as stale and when closing,
+	// using .Author, .Title, .Number, .DaysUntilStale and .DaysUntilClose.
+	// Empty templates use a default comment.
+	Comment      string `mapstructure:"comment"`
+	CloseComment string `mapstructure:"closeComment"`
+}
+
+// PathLabel applies a label to pull requests changing files which match one
+// of the globs. "*" matches within a path segment, "**" across segments,
+// e.g. "docs/**" or "pkg/**/*_test.go".
+type PathLabel struct {
+	Label string   `mapstructure:"label"`
+	Paths []string `mapstructure:"paths"`
+}
+
+// BranchMilestone maps base branches to the milestone of their pull requests
+type BranchMilestone struct {
+	// Pattern of the base branches, e.g. `release-(\d+)\.x`
+	Branch string `mapstructure:"branch"`
+	// Title of an open milestone. Groups of the branch pattern can be
+	// referenced, e.g. "$1.x.z".
+	Milestone string `mapstructure:"milestone"`
+}
+
+// SizeLabelsConfig configures labeling pull requests by the size of their
+// diff
+type SizeLabelsConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Prefix of the labels, defaults to "size/" for "size/XS" to "size/XXL"
+	Prefix string `mapstructure:"prefix"`
+	// Upper bounds of changed lines (additions plus deletions) for the sizes
+	// XS, S, M, L and XL, larger PRs are XXL. Defaults to 10, 30, 100, 500
+	// and 1000.
+	Lines []int `mapstructure:"lines"`
+	// Upper bounds of changed files for the same sizes, if given. The
+	// larger of both sizes applies.
+	Files []int `mapstructure:"files"`
+}
+
+// DCOConfig configures checking the Developer Certificate of Origin sign-off
+// of all commits of a pull request
+type DCOConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Authors whose pull requests are not checked, e.g. "dependabot[bot]"
+	ExemptAuthors []string `mapstructure:"exemptAuthors"`
+}
+
+// CLAConfig configures checking that all commit authors of a pull request
+// signed the Contributor License Agreement. Signers are looked up at the URL
+// or, without URL, in the allowlist file.
+type CLAConfig struct {
+	// Endpoint receiving a POST request per author with the JSON document
+	// {"repository": ..., "login": ..., "email": ...} and answering with
+	// {"signed": true} or {"signed": false}
+	URL string `mapstructure:"url"`
+	// Defaults to 10s
+	Timeout time.Duration `mapstructure:"timeout"`
+	// File in the repository's default branch listing the logins or emails
+	// of signers, one per line
+	AllowlistFile string `mapstructure:"allowlistFile"`
+	// Where the CLA can be signed, linked in the comment asking to sign it
+	SignURL string `mapstructure:"signURL"`
+	// Authors which need not sign, e.g. "dependabot[bot]"
+	ExemptAuthors []string `mapstructure:"exemptAuthors"`
+}
+
+// CommitLintConfig configures linting commit messages against the
+// Conventional Commits specification, like "feat(webhook): add size labels"
+type CommitLintConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// "commits" lints the messages of all commits, "title" the title of the
+	// PR, which becomes the commit message when squash merging. Defaults to
+	// "title" for the squash merge method and to "commits" otherwise.
+	Target string `mapstructure:"target"`
+	// Allowed types, defaulting to build, chore, ci, docs, feat, fix, perf,
+	// refactor, revert, style and test
+	Types []string `mapstructure:"types"`
+	// Allowed scopes, any scope is allowed if empty
+	Scopes       []string `mapstructure:"scopes"`
+	RequireScope bool     `mapstructure:"requireScope"`
+}
+
+// TitleLintConfig defines a pattern the titles of pull requests must match
+type TitleLintConfig struct {
+	// Regular expression, e.g. "^ENTESB-[0-9]+: " for a JIRA ticket prefix
+	Pattern string `mapstructure:"pattern"`
+	// Explains the pattern in the failing status, e.g. "must start with a
+	// JIRA ticket"
+	Hint string `mapstructure:"hint"`
+}
+
+// BranchCleanupConfig configures deleting the head branches of merged pull
+// requests, also of those merged by hand
+type BranchCleanupConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Patterns of branches which are never deleted, e.g. "release-.*".
+	// The default branch and protected branches are always kept.
+	Exclude []string `mapstructure:"exclude"`
+}
+
+// BackportConfig configures cherry-picking merged pull requests onto other
+// branches
+type BackportConfig struct {
+	// Prefix of labels requesting a backport to the branch named by the
+	// rest of the label, defaults to "backport/"
+	LabelPrefix string `mapstructure:"labelPrefix"`
+	// Patterns of the branches which may be backported to, any branch if
+	// empty
+	Branches []string `mapstructure:"branches"`
+}
+
+// ReleaseNotesConfig configures the draft release listing the pull requests
+// merged into the default branch since the last release
+type ReleaseNotesConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Name of the draft release, and its tag until a release gets
+	// published. Defaults to "Next release".
+	Name string `mapstructure:"name"`
+	// Sections of the release notes, in order. Pull requests are listed
+	// under the first category with one of their labels, the others under
+	// "Other changes". Defaults to features, bug fixes and chores.
+	Categories []ReleaseNotesCategory `mapstructure:"categories"`
+	// Go template of a pull request's entry, using the PR's .Title,
+	// .Number, .Body and .Author
+	Template string `mapstructure:"template"`
+	// Pull requests with any of these labels are left out
+	ExcludeLabels []string `mapstructure:"excludeLabels"`
+}
+
+// ReleaseNotesCategory is a section of the release notes
+type ReleaseNotesCategory struct {
+	Title  string   `mapstructure:"title"`
+	Labels []string `mapstructure:"labels"`
+}
+
+// ReleaseConfig configures releasing a new version when a pull request
+// labeled with the kind of version bump gets merged into the default branch
+type ReleaseConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Prefix of the labels "major", "minor" and "patch", defaults to
+	// "semver/"
+	LabelPrefix string `mapstructure:"labelPrefix"`
+	// Prefix of the version tags, defaults to "v"
+	TagPrefix string `mapstructure:"tagPrefix"`
+}
+
+// ProjectConfig moves pull requests through the stages of a GitHub project
+// (Projects v2) by setting a single select field of their project item
+type ProjectConfig struct {
+	// Login of the organization or user owning the project
+	Owner string `mapstructure:"owner"`
+	// Number of the project, as shown in its URL
+	Number int `mapstructure:"number"`
+	// Name of the single select field, defaults to "Status"
+	Field string `mapstructure:"field"`
+	// Options of the field for opened, approved, merged and released pull
+	// requests. A stage without option leaves the item where it is.
+	Opened   string `mapstructure:"opened"`
+	Approved string `mapstructure:"approved"`
+	Merged   string `mapstructure:"merged"`
+	Released string `mapstructure:"released"`
+}
+
+// AuthorMergePolicy overrides the automerge rules for PRs of some authors
+type AuthorMergePolicy struct {
+	// Logins, e.g. "dependabot[bot]" or "renovate[bot]"
+	Authors []string `mapstructure:"authors"`
+	// Label which triggers the merge instead of the approved label
+	Label string `mapstructure:"label"`
+	// Merge without any label once all checks pass
+	NoLabel bool `mapstructure:"noLabel"`
+	// Overrides the merge method of the repository
+	MergeMethod string `mapstructure:"mergeMethod"`
+}
+
+// MergePolicyConfig points to a merge policy decision of an Open Policy Agent
+// server. It gets the pull request, its labels, reviews, checks and statuses
+// as input and returns whether the pull request may be merged.
+type MergePolicyConfig struct {
+	// URL of the decision, e.g.
+	// http://localhost:8181/v1/data/purebot/merge
+	URL string `mapstructure:"url"`
+	// Defaults to 10s
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// BranchProtectionConfig defines the protection applied by the
+// "branchProtection" bootstrap action
+type BranchProtectionConfig struct {
+	// Defaults to the default branch of the repository
+	Branch           string   `mapstructure:"branch"`
+	RequiredContexts []string `mapstructure:"requiredContexts"`
+	// Require branches to be up to date before merging
+	Strict            bool `mapstructure:"strict"`
+	RequiredApprovals int  `mapstructure:"requiredApprovals"`
 }
 
 type Board struct {
@@ -85,3 +740,11 @@ type Column struct {
 	PostMergePipeline bool     `mapstructure:"postMergePipeline"`
 	IsInbox           bool     `mapstructure:"isInbox"`
 }
+
+// MergeCommitConfig holds Go templates for the title and message of merge and
+// squash commits. Templates can use .Title, .Number, .Body and .Author of the
+// pull request. Empty templates use GitHub's default.
+type MergeCommitConfig struct {
+	Title   string `mapstructure:"title"`
+	Message string `mapstructure:"message"`
+}
@@ -0,0 +1,201 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// RepoConfigFile is the name of the file in the root directory of a
+// repository holding its own configuration
+const RepoConfigFile = ".pure-bot.yml"
+
+// Settings of a RepoConfig which a repository's own configuration file must
+// not change: they hold credentials, point the bot to other hosts or belong
+// to the operators of the bot
+var serverOnlySettings = []string{"board", "mergePolicy", "cla.url", "cla.timeout", "slackChannel", "matrixRoom", "bootstrap", "branchProtection"}
+
+// repoFileConfig lists the settings a repository's own configuration file
+// may change. Anyone with push access to the default branch can change the
+// file, so new RepoConfig settings have to be added here explicitly.
+type repoFileConfig struct {
+	Disabled                     bool                     `mapstructure:"disabled"`
+	Labels                       LabelConfig              `mapstructure:"labels"`
+	WipPatterns                  []string                 `mapstructure:"wipPatterns"`
+	Handlers                     map[string]bool          `mapstructure:"handlers"`
+	MergeMethod                  string                   `mapstructure:"mergeMethod"`
+	MergeStrategy                string                   `mapstructure:"mergeStrategy"`
+	MergeCommit                  MergeCommitConfig        `mapstructure:"mergeCommit"`
+	MergeWindows                 []string                 `mapstructure:"mergeWindows"`
+	ExpectedChecks               []string                 `mapstructure:"expectedChecks"`
+	IgnoredChecks                []string                 `mapstructure:"ignoredChecks"`
+	RequiredChecks               []string                 `mapstructure:"requiredChecks"`
+	ExplainBlockedMerge          bool                     `mapstructure:"explainBlockedMerge"`
+	PassingConclusions           []string                 `mapstructure:"passingConclusions"`
+	PostMerge                    PostMergeConfig          `mapstructure:"postMerge"`
+	AuthorPolicies               []AuthorMergePolicy      `mapstructure:"authorPolicies"`
+	RequiredApprovals            int                      `mapstructure:"requiredApprovals"`
+	MergeAttempts                int                      `mapstructure:"mergeAttempts"`
+	AutoUpdateBranch             bool                     `mapstructure:"autoUpdateBranch"`
+	OwnersApproval               bool                     `mapstructure:"ownersApproval"`
+	ApproverTeams                []string                 `mapstructure:"approverTeams"`
+	TriageLabels                 []string                 `mapstructure:"triageLabels"`
+	PathLabels                   []PathLabel              `mapstructure:"pathLabels"`
+	LabelerFile                  string                   `mapstructure:"labelerFile"`
+	BranchMilestones             []BranchMilestone        `mapstructure:"branchMilestones"`
+	RequireResolvedConversations bool                     `mapstructure:"requireResolvedConversations"`
+	MirrorWorkflowStatus         bool                     `mapstructure:"mirrorWorkflowStatus"`
+	DebounceWindow               time.Duration            `mapstructure:"debounceWindow"`
+	LabelSync                    LabelSyncConfig          `mapstructure:"labelSync"`
+	ReviewerAssignment           ReviewerAssignmentConfig `mapstructure:"reviewerAssignment"`
+	AutoRetest                   AutoRetestConfig         `mapstructure:"autoRetest"`
+	Description                  DescriptionConfig        `mapstructure:"description"`
+	DependencyUpdates            DependencyUpdatesConfig  `mapstructure:"dependencyUpdates"`
+	Welcome                      WelcomeConfig            `mapstructure:"welcome"`
+	Stale                        StaleConfig              `mapstructure:"stale"`
+	SizeLabels                   SizeLabelsConfig         `mapstructure:"sizeLabels"`
+	DCO                          DCOConfig                `mapstructure:"dco"`
+	CLA                          repoFileCLAConfig        `mapstructure:"cla"`
+	CommitLint                   CommitLintConfig         `mapstructure:"commitLint"`
+	TitleLint                    TitleLintConfig          `mapstructure:"titleLint"`
+	BranchCleanup                BranchCleanupConfig      `mapstructure:"branchCleanup"`
+	Backport                     BackportConfig           `mapstructure:"backport"`
+	ReleaseNotes                 ReleaseNotesConfig       `mapstructure:"releaseNotes"`
+	Release                      ReleaseConfig            `mapstructure:"release"`
+	Project                      ProjectConfig            `mapstructure:"project"`
+}
+
+// repoFileCLAConfig is the CLAConfig without the endpoint looking up signers
+type repoFileCLAConfig struct {
+	AllowlistFile string   `mapstructure:"allowlistFile"`
+	SignURL       string   `mapstructure:"signURL"`
+	ExemptAuthors []string `mapstructure:"exemptAuthors"`
+}
+
+func (c repoFileConfig) repoConfig() RepoConfig {
+	return RepoConfig{
+		Disabled:                     c.Disabled,
+		Labels:                       c.Labels,
+		WipPatterns:                  c.WipPatterns,
+		Handlers:                     c.Handlers,
+		MergeMethod:                  c.MergeMethod,
+		MergeStrategy:                c.MergeStrategy,
+		MergeCommit:                  c.MergeCommit,
+		MergeWindows:                 c.MergeWindows,
+		ExpectedChecks:               c.ExpectedChecks,
+		IgnoredChecks:                c.IgnoredChecks,
+		RequiredChecks:               c.RequiredChecks,
+		ExplainBlockedMerge:          c.ExplainBlockedMerge,
+		PassingConclusions:           c.PassingConclusions,
+		PostMerge:                    c.PostMerge,
+		AuthorPolicies:               c.AuthorPolicies,
+		RequiredApprovals:            c.RequiredApprovals,
+		MergeAttempts:                c.MergeAttempts,
+		AutoUpdateBranch:             c.AutoUpdateBranch,
+		OwnersApproval:               c.OwnersApproval,
+		ApproverTeams:                c.ApproverTeams,
+		TriageLabels:                 c.TriageLabels,
+		PathLabels:                   c.PathLabels,
+		LabelerFile:                  c.LabelerFile,
+		BranchMilestones:             c.BranchMilestones,
+		RequireResolvedConversations: c.RequireResolvedConversations,
+		MirrorWorkflowStatus:         c.MirrorWorkflowStatus,
+		DebounceWindow:               c.DebounceWindow,
+		LabelSync:                    c.LabelSync,
+		ReviewerAssignment:           c.ReviewerAssignment,
+		AutoRetest:                   c.AutoRetest,
+		Description:                  c.Description,
+		DependencyUpdates:            c.DependencyUpdates,
+		Welcome:                      c.Welcome,
+		Stale:                        c.Stale,
+		SizeLabels:                   c.SizeLabels,
+		DCO:                          c.DCO,
+		CLA: CLAConfig{
+			AllowlistFile: c.CLA.AllowlistFile,
+			SignURL:       c.CLA.SignURL,
+			ExemptAuthors: c.CLA.ExemptAuthors,
+		},
+		CommitLint:    c.CommitLint,
+		TitleLint:     c.TitleLint,
+		BranchCleanup: c.BranchCleanup,
+		Backport:      c.Backport,
+		ReleaseNotes:  c.ReleaseNotes,
+		Release:       c.Release,
+		Project:       c.Project,
+	}
+}
+
+// ParseRepoConfig parses the content of a repository's own configuration
+// file, which has the layout of an entry of "repos". Settings reserved to
+// the bot's configuration are rejected.
+func ParseRepoConfig(content []byte) (RepoConfig, error) {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return RepoConfig{}, errors.Wrap(err, "invalid YAML")
+	}
+	for _, setting := range serverOnlySettings {
+		if hasSetting(raw, setting) {
+			return RepoConfig{}, errors.Errorf("%s can only be set in the bot's configuration", setting)
+		}
+	}
+
+	var fileConfig repoFileConfig
+	// Decoded like viper decodes the bot's own configuration
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		// Typos and settings not allowed in the file shouldn't go
+		// unnoticed
+		ErrorUnused: true,
+		Result:      &fileConfig,
+	})
+	if err != nil {
+		return RepoConfig{}, errors.Wrap(err, "failed to create decoder")
+	}
+	if err := decoder.Decode(raw); err != nil {
+		return RepoConfig{}, errors.Wrap(err, "invalid configuration")
+	}
+	return fileConfig.repoConfig(), nil
+}
+
+// hasSetting tells whether the parsed YAML contains a dotted path like
+// "cla.url"
+func hasSetting(raw map[string]interface{}, path string) bool {
+	parts := strings.SplitN(path, ".", 2)
+	value, found := raw[parts[0]]
+	if !found || len(parts) == 1 {
+		return found
+	}
+	switch nested := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(nested))
+		for key, value := range nested {
+			converted[fmt.Sprint(key)] = value
+		}
+		return hasSetting(converted, parts[1])
+	case map[string]interface{}:
+		return hasSetting(nested, parts[1])
+	}
+	return false
+}
@@ -108,17 +108,9 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 }
 
 func (t *Transport) refreshToken() error {
-	// TODO these claims could probably be reused between installations before expiry
-	claims := &jwt.StandardClaims{
-		IssuedAt:  time.Now().Unix(),
-		ExpiresAt: time.Now().Add(time.Minute).Unix(),
-		Issuer:    strconv.FormatInt(t.appID, 10),
-	}
-	bearer := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-
-	ss, err := bearer.SignedString(t.key)
+	ss, err := signJWT(t.appID, t.key)
 	if err != nil {
-		return errors.Wrap(err, "could not sign jwt")
+		return err
 	}
 
 	req, err := http.NewRequest("POST", fmt.Sprintf("%s/installations/%d/access_tokens", t.BaseURL, t.installationID), nil)
@@ -146,3 +138,66 @@ func (t *Transport) refreshToken() error {
 
 	return nil
 }
+
+// signJWT creates a short-lived token authenticating as the app itself
+func signJWT(appID int64, key *rsa.PrivateKey) (string, error) {
+	// TODO these claims could probably be reused between installations before expiry
+	claims := &jwt.StandardClaims{
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		Issuer:    strconv.FormatInt(appID, 10),
+	}
+	bearer := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+
+	ss, err := bearer.SignedString(key)
+	if err != nil {
+		return "", errors.Wrap(err, "could not sign jwt")
+	}
+	return ss, nil
+}
+
+// AppTransport provides a http.RoundTripper authenticating as the GitHub App
+// itself, which is required e.g. for listing its installations.
+type AppTransport struct {
+	tr    http.RoundTripper
+	key   *rsa.PrivateKey
+	appID int64
+}
+
+var _ http.RoundTripper = &AppTransport{}
+
+// NewAppTransport returns an AppTransport using private key.
+func NewAppTransport(tr http.RoundTripper, appID int64, privateKey []byte) (*AppTransport, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse private key")
+	}
+	return &AppTransport{tr, key, appID}, nil
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *AppTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ss, err := signJWT(t.appID, t.key)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", ss))
+	req.Header.Set("Accept", appsAcceptHeader)
+	return t.tr.RoundTrip(req)
+}
+
+// TokenTransport provides a http.RoundTripper authenticating with a personal
+// access token, e.g. for running the bot locally without an installation.
+type TokenTransport struct {
+	tr    http.RoundTripper
+	token string
+}
+
+var _ http.RoundTripper = &TokenTransport{}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *TokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "token "+t.token)
+	return t.tr.RoundTrip(req)
+}
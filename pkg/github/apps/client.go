@@ -16,6 +16,8 @@ package apps
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
@@ -24,11 +26,101 @@ import (
 // Shared transport to reuse TCP connections.
 var tr = &http.Transport{}
 
-func Client(appID, installationID int64, privateKey []byte) (*github.Client, error) {
+// Endpoint holds the URLs of the GitHub API. The zero value points to
+// github.com.
+type Endpoint struct {
+	// API base URL of a GitHub Enterprise Server, e.g.
+	// https://github.example.com/api/v3/. The /api/v3/ suffix is added if
+	// missing.
+	BaseURL string
+
+	// Upload URL of a GitHub Enterprise Server, defaults to /api/uploads/ on
+	// the host of BaseURL
+	UploadURL string
+}
+
+// urls returns the parsed API and upload URLs, which are nil for github.com
+func (e Endpoint) urls() (*url.URL, *url.URL, error) {
+	if e.BaseURL == "" {
+		return nil, nil, nil
+	}
+	baseURL, err := url.Parse(withTrailingSlash(e.BaseURL))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid GitHub API URL %q", e.BaseURL)
+	}
+	if !strings.HasSuffix(baseURL.Path, "/api/v3/") {
+		baseURL.Path += "api/v3/"
+	}
+
+	uploadURL := &url.URL{Scheme: baseURL.Scheme, Host: baseURL.Host, Path: "/api/uploads/"}
+	if e.UploadURL != "" {
+		if uploadURL, err = url.Parse(withTrailingSlash(e.UploadURL)); err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid GitHub upload URL %q", e.UploadURL)
+		}
+	}
+	return baseURL, uploadURL, nil
+}
+
+func withTrailingSlash(u string) string {
+	if strings.HasSuffix(u, "/") {
+		return u
+	}
+	return u + "/"
+}
+
+// newClient creates a GitHub client for the endpoint using the given transport
+func (e Endpoint) newClient(rt http.RoundTripper) (*github.Client, error) {
+	baseURL, uploadURL, err := e.urls()
+	if err != nil {
+		return nil, err
+	}
+	client := github.NewClient(&http.Client{Transport: rt})
+	if baseURL != nil {
+		client.BaseURL = baseURL
+		client.UploadURL = uploadURL
+	}
+	return client, nil
+}
+
+// Client creates a GitHub client authenticated as installation. The
+// authenticating transport can be decorated by wrappers, which are applied in
+// the given order.
+func Client(endpoint Endpoint, appID, installationID int64, privateKey []byte, wrappers ...func(http.RoundTripper) http.RoundTripper) (*github.Client, error) {
 	itr, err := NewTransport(tr, appID, installationID, privateKey)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create transport from private key file")
 	}
+	baseURL, _, err := endpoint.urls()
+	if err != nil {
+		return nil, err
+	}
+	if baseURL != nil {
+		// Installation tokens have to be requested from the same instance
+		itr.BaseURL = strings.TrimSuffix(baseURL.String(), "/")
+	}
 
-	return github.NewClient(&http.Client{Transport: itr}), nil
+	var rt http.RoundTripper = itr
+	for _, wrap := range wrappers {
+		rt = wrap(rt)
+	}
+	return endpoint.newClient(rt)
+}
+
+// TokenClient creates a GitHub client authenticated with a personal access
+// token. The transport can be decorated like the one of Client.
+func TokenClient(endpoint Endpoint, token string, wrappers ...func(http.RoundTripper) http.RoundTripper) (*github.Client, error) {
+	var rt http.RoundTripper = &TokenTransport{tr, token}
+	for _, wrap := range wrappers {
+		rt = wrap(rt)
+	}
+	return endpoint.newClient(rt)
+}
+
+// AppClient creates a GitHub client authenticated as the app itself.
+func AppClient(endpoint Endpoint, appID int64, privateKey []byte) (*github.Client, error) {
+	atr, err := NewAppTransport(tr, appID, privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create app transport from private key")
+	}
+	return endpoint.newClient(atr)
 }
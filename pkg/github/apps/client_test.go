@@ -0,0 +1,30 @@
+package apps
+
+import "testing"
+
+func TestEndpointURLs(t *testing.T) {
+	tests := []struct {
+		endpoint           Endpoint
+		baseURL, uploadURL string
+	}{
+		{Endpoint{}, "", ""},
+		{Endpoint{BaseURL: "https://github.example.com"}, "https://github.example.com/api/v3/", "https://github.example.com/api/uploads/"},
+		{Endpoint{BaseURL: "https://github.example.com/api/v3"}, "https://github.example.com/api/v3/", "https://github.example.com/api/uploads/"},
+		{Endpoint{BaseURL: "https://github.example.com/api/v3/", UploadURL: "https://uploads.example.com"}, "https://github.example.com/api/v3/", "https://uploads.example.com/"},
+	}
+	for _, test := range tests {
+		baseURL, uploadURL, err := test.endpoint.urls()
+		if err != nil {
+			t.Fatalf("%+v: %v", test.endpoint, err)
+		}
+		if baseURL == nil {
+			if test.baseURL != "" {
+				t.Errorf("%+v: expected base URL %s", test.endpoint, test.baseURL)
+			}
+			continue
+		}
+		if baseURL.String() != test.baseURL || uploadURL.String() != test.uploadURL {
+			t.Errorf("%+v: expected %s and %s, got %s and %s", test.endpoint, test.baseURL, test.uploadURL, baseURL, uploadURL)
+		}
+	}
+}
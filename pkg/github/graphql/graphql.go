@@ -0,0 +1,190 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package graphql gives handlers access to GitHub's GraphQL API, for
+// features which the REST API doesn't offer, like toggling auto-merge.
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+)
+
+// New creates a GraphQL client which sends its requests through a REST
+// client, so that they are authenticated, audited and throttled like all
+// other requests of a handler
+func New(rest *github.Client) *githubv4.Client {
+	return githubv4.NewEnterpriseClient(endpoint(rest.BaseURL), &http.Client{Transport: restTransport{rest}})
+}
+
+// endpoint returns the GraphQL URL next to the REST base URL. GitHub
+// Enterprise Server serves it at /api/graphql next to /api/v3/.
+func endpoint(baseURL *url.URL) string {
+	path := "graphql"
+	if strings.HasSuffix(baseURL.Path, "/api/v3/") {
+		path = "../graphql"
+	}
+	return baseURL.ResolveReference(&url.URL{Path: path}).String()
+}
+
+// restTransport sends requests with the HTTP client of a REST client
+type restTransport struct {
+	rest *github.Client
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t restTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body bytes.Buffer
+	resp, err := t.rest.Do(req.Context(), req, &body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(&body)
+	return resp.Response, nil
+}
+
+// IsMutation returns true if the body of a GraphQL request contains a
+// mutation. Bodies which can't be parsed are regarded as mutations.
+func IsMutation(body []byte) bool {
+	var req struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return true
+	}
+	operations, err := operationTypes(req.Query)
+	if err != nil {
+		return true
+	}
+	for _, operation := range operations {
+		if operation != "query" {
+			return true
+		}
+	}
+	return false
+}
+
+// operationTypes returns the types of the operations defined in a GraphQL
+// document: "query", "mutation" or "subscription". Fragment definitions are
+// skipped, as are comments, strings and everything within selection sets,
+// argument lists and values.
+func operationTypes(document string) ([]string, error) {
+	var operations []string
+	// Nesting of braces, brackets and parentheses
+	depth := 0
+	// Whether the next top level token starts a definition
+	definition := true
+	for i := 0; i < len(document); {
+		c := document[i]
+		switch {
+		case c == '#':
+			for i < len(document) && document[i] != '\n' && document[i] != '\r' {
+				i++
+			}
+			continue
+		case c == '"':
+			end, err := skipString(document, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			continue
+		case isNameStart(c):
+			start := i
+			for i < len(document) && isNameContinue(document[i]) {
+				i++
+			}
+			if depth > 0 || !definition {
+				continue
+			}
+			definition = false
+			switch name := document[start:i]; name {
+			case "query", "mutation", "subscription":
+				operations = append(operations, name)
+			case "fragment":
+			default:
+				return nil, errors.Errorf("unexpected %q at offset %d", name, start)
+			}
+			continue
+		case c == '{':
+			if depth == 0 && definition {
+				// Query shorthand without operation type
+				operations = append(operations, "query")
+				definition = false
+			}
+			depth++
+		case c == '(' || c == '[':
+			depth++
+		case c == '}' || c == ')' || c == ']':
+			if depth == 0 {
+				return nil, errors.Errorf("unbalanced %q at offset %d", c, i)
+			}
+			depth--
+			if depth == 0 && c == '}' {
+				definition = true
+			}
+		}
+		i++
+	}
+	if depth > 0 {
+		return nil, errors.New("unexpected end of document")
+	}
+	if len(operations) == 0 {
+		return nil, errors.New("no operation defined")
+	}
+	return operations, nil
+}
+
+// skipString returns the offset behind the string or block string starting
+// at offset start
+func skipString(document string, start int) (int, error) {
+	if strings.HasPrefix(document[start:], `"""`) {
+		for i := start + 3; i < len(document); i++ {
+			switch {
+			case strings.HasPrefix(document[i:], `\"""`):
+				i += 3
+			case strings.HasPrefix(document[i:], `"""`):
+				return i + 3, nil
+			}
+		}
+		return 0, errors.Errorf("unterminated block string at offset %d", start)
+	}
+	for i := start + 1; i < len(document); i++ {
+		switch document[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		case '\n', '\r':
+			return 0, errors.Errorf("unterminated string at offset %d", start)
+		}
+	}
+	return 0, errors.Errorf("unterminated string at offset %d", start)
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameContinue(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
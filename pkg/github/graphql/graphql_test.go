@@ -0,0 +1,115 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/shurcooL/githubv4"
+)
+
+func TestQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" || r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var req struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(req.Query, "foo"):
+			w.Write([]byte(`{"errors":[{"message":"Field 'foo' doesn't exist"}]}`))
+		case strings.Contains(req.Query, "rateLimit"):
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Write([]byte(`{"data":{"viewer":{"login":"pure-bot"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	rest := github.NewClient(nil)
+	rest.BaseURL, _ = url.Parse(server.URL + "/")
+	client := New(rest)
+
+	var query struct {
+		Viewer struct {
+			Login string
+		}
+	}
+	if err := client.Query(context.Background(), &query, nil); err != nil {
+		t.Fatal(err)
+	}
+	if query.Viewer.Login != "pure-bot" {
+		t.Errorf("unexpected result %+v", query)
+	}
+
+	var failing struct {
+		Foo string
+	}
+	if err := client.Query(context.Background(), &failing, nil); err == nil {
+		t.Error("expected GraphQL errors to fail the query")
+	}
+
+	var unavailable struct {
+		RateLimit struct {
+			Remaining int
+		}
+	}
+	if err := client.Query(context.Background(), &unavailable, nil); err == nil {
+		t.Error("expected a failing response to fail the query")
+	}
+}
+
+func TestIsMutation(t *testing.T) {
+	tests := map[string]bool{
+		`{"query":"query { viewer { login } }"}`:                                              false,
+		`{"query":"{ viewer { login } }"}`:                                                    false,
+		`{"query":"query($q: String = \"mutation\") { search(query: $q) { issueCount } }"}`:   false,
+		`{"query":"query { viewer { ...user } } fragment user on User { login }"}`:            false,
+		`{"query":" mutation { enablePullRequestAutoMerge }"}`:                                true,
+		`{"query":"# toggles auto-merge\nmutation { enablePullRequestAutoMerge }"}`:           true,
+		`{"query":"fragment id on Node { id } mutation { addStar { starrable { ...id } } }"}`: true,
+		`{"query":"query { viewer { login } } mutation { addStar }"}`:                         true,
+		`{"query":"\"\"\"query\"\"\" mutation { addStar }"}`:                                  true,
+		`{"query":"query { viewer { login }"}`:                                                true,
+		`{"query":"merge { addStar }"}`:                                                       true,
+		`{"query":""}`:                                                                        true,
+		`not json`:                                                                            true,
+	}
+	for body, expected := range tests {
+		if IsMutation([]byte(body)) != expected {
+			t.Errorf("expected IsMutation(%s) to be %v", body, expected)
+		}
+	}
+}
+
+func TestEnterpriseEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/graphql" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer server.Close()
+
+	rest := github.NewClient(nil)
+	rest.BaseURL, _ = url.Parse(server.URL + "/api/v3/")
+	var mutation struct {
+		AddStar struct {
+			ClientMutationID string
+		} `graphql:"addStar(input: $input)"`
+	}
+	if err := New(rest).Mutate(context.Background(), &mutation, githubv4.AddStarInput{StarrableID: "1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
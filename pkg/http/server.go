@@ -19,7 +19,6 @@ import (
 	"net"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/pkg/errors"
 
@@ -56,9 +55,9 @@ func (s *Server) Start() error {
 	return nil
 }
 
-func (s *Server) Stop() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// Stop gracefully shuts down the server, waiting for active connections
+// until ctx expires.
+func (s *Server) Stop(ctx context.Context) error {
 	if err := s.http.Shutdown(ctx); err != nil {
 		return errors.Wrap(err, "failed to cleanly shutdown web server")
 	}
@@ -0,0 +1,70 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+const defaultSMTPPort = 587
+
+// Replaced by tests
+var sendMail = smtp.SendMail
+
+// Mailer sends plain text emails with SMTP
+type Mailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewMailer creates a mailer for the configured server
+func NewMailer(cfg config.SMTPConfig) *Mailer {
+	port := cfg.Port
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	m := &Mailer{addr: net.JoinHostPort(cfg.Host, strconv.Itoa(port)), from: cfg.From}
+	if cfg.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return m
+}
+
+// Send sends an email to the recipients
+func (m *Mailer) Send(to []string, subject string, body string) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+
+	return errors.Wrapf(sendMail(m.addr, m.auth, m.from, to, msg.Bytes()), "failed to send email to %s", strings.Join(to, ", "))
+}
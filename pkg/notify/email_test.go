@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+func TestMailer(t *testing.T) {
+	defer func(send func(string, smtp.Auth, string, []string, []byte) error) { sendMail = send }(sendMail)
+	var addr, msg string
+	sendMail = func(a string, auth smtp.Auth, from string, to []string, m []byte) error {
+		addr, msg = a, string(m)
+		if auth == nil || from != "bot@example.com" || len(to) != 2 {
+			t.Errorf("unexpected envelope %v %s %v", auth, from, to)
+		}
+		return nil
+	}
+
+	m := NewMailer(config.SMTPConfig{Host: "smtp.example.com", Username: "bot", Password: "secret", From: "bot@example.com"})
+	if err := m.Send([]string{"a@example.com", "b@example.com"}, "Digest für syndesisio", "Merged:\n* #12"); err != nil {
+		t.Fatal(err)
+	}
+	if addr != "smtp.example.com:587" {
+		t.Errorf("expected default port, got %s", addr)
+	}
+	for _, expected := range []string{"To: a@example.com, b@example.com\r\n", "Subject: =?utf-8?q?Digest_f=C3=BCr_syndesisio?=\r\n", "\r\n\r\nMerged:\r\n* #12"} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("expected message to contain %q, got\n%s", expected, msg)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+var matrixIcons = map[Kind]string{
+	Success: "✅",
+	Failure: "❌",
+	Warning: "⚠️",
+}
+
+// matrixMessage is the content of an m.room.message event
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+// Matrix posts messages to Matrix rooms with the client-server API.
+// Messages are sent asynchronously and dropped when the buffer is full. A
+// nil Matrix discards all messages.
+type Matrix struct {
+	homeserver string
+	token      string
+	client     *http.Client
+	outbox     *outbox
+
+	// Room IDs by alias
+	mu    sync.Mutex
+	rooms map[string]string
+}
+
+var _ Chat = &Matrix{}
+
+// NewMatrix creates a Matrix backend or returns nil if no access token is
+// configured
+func NewMatrix(cfg config.MatrixConfig, logger *zap.Logger) *Matrix {
+	if cfg.AccessToken == "" {
+		return nil
+	}
+	return &Matrix{
+		homeserver: strings.TrimSuffix(cfg.Homeserver, "/"),
+		token:      cfg.AccessToken,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		outbox:     newOutbox(logger),
+		rooms:      make(map[string]string),
+	}
+}
+
+// Post queues a message for a room, given by its ID ("!abc:matrix.org") or
+// alias ("#syndesis:matrix.org")
+func (m *Matrix) Post(room string, message Message) {
+	if m == nil || room == "" {
+		return
+	}
+	content := matrixMessage{
+		// Notices are meant for bots and don't trigger other bots
+		MsgType:       "m.notice",
+		Body:          matrixIcons[message.Kind] + " " + message.Plain(),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: matrixHTML(message),
+	}
+	m.outbox.add(func() error { return m.send(room, content) }, zap.String("room", room))
+}
+
+func matrixHTML(m Message) string {
+	text := matrixIcons[m.Kind] + " " + html.EscapeString(m.Text)
+	if m.LinkURL != "" {
+		text += ` <a href="` + html.EscapeString(m.LinkURL) + `">` + html.EscapeString(m.LinkText) + "</a>"
+	} else if m.LinkText != "" {
+		text += " " + html.EscapeString(m.LinkText)
+	}
+	if m.Detail != "" {
+		text += ": " + html.EscapeString(m.Detail)
+	}
+	return text
+}
+
+func (m *Matrix) send(room string, content matrixMessage) error {
+	roomID, err := m.roomID(room)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(content)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal Matrix message")
+	}
+	// The transaction ID makes retries idempotent
+	path := "/rooms/" + url.PathEscape(roomID) + "/send/m.room.message/" + newID()
+	return m.do(http.MethodPut, path, body, nil)
+}
+
+// roomID resolves a room alias, room IDs are returned as they are
+func (m *Matrix) roomID(room string) (string, error) {
+	if !strings.HasPrefix(room, "#") {
+		return room, nil
+	}
+	m.mu.Lock()
+	roomID, found := m.rooms[room]
+	m.mu.Unlock()
+	if found {
+		return roomID, nil
+	}
+
+	var result struct {
+		RoomID string `json:"room_id"`
+	}
+	if err := m.do(http.MethodGet, "/directory/room/"+url.PathEscape(room), nil, &result); err != nil {
+		return "", errors.Wrapf(err, "failed to resolve room alias %s", room)
+	}
+	m.mu.Lock()
+	m.rooms[room] = result.RoomID
+	m.mu.Unlock()
+	return result.RoomID, nil
+}
+
+// do calls the client-server API and decodes the response into result
+func (m *Matrix) do(method string, path string, body []byte, result interface{}) error {
+	req, err := http.NewRequest(method, m.homeserver+"/_matrix/client/v3"+path, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "invalid Matrix homeserver URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.token)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to call Matrix homeserver")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var matrixErr struct {
+			ErrCode string `json:"errcode"`
+			Error   string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&matrixErr)
+		return errors.Errorf("Matrix homeserver responded with status %d: %s %s", resp.StatusCode, matrixErr.ErrCode, matrixErr.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(result), "failed to decode Matrix response")
+}
+
+// Close sends all queued messages. Messages posted afterwards are dropped.
+func (m *Matrix) Close() {
+	if m == nil {
+		return
+	}
+	m.outbox.close()
+}
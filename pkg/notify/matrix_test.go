@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestMatrix(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+		messages []matrixMessage
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath())
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"room_id":"!abc:matrix.org"}`))
+			return
+		}
+		var message matrixMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Error(err)
+		}
+		messages = append(messages, message)
+		w.Write([]byte(`{"event_id":"$1"}`))
+	}))
+	defer server.Close()
+
+	m := NewMatrix(config.MatrixConfig{Homeserver: server.URL + "/", AccessToken: "secret"}, zap.NewNop())
+	message := Message{Kind: Failure, Text: "Failed to merge", LinkText: "syndesisio/syndesis#12", LinkURL: "https://github.com/syndesisio/syndesis/pull/12", Detail: "<conflict>"}
+	m.Post("#syndesis:matrix.org", message)
+	m.Post("#syndesis:matrix.org", message)
+	m.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	// The alias is resolved once
+	if len(requests) != 3 || requests[0] != "GET /_matrix/client/v3/directory/room/%23syndesis:matrix.org" {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+	if len(messages) != 2 || messages[0].MsgType != "m.notice" ||
+		messages[0].Body != "❌ Failed to merge syndesisio/syndesis#12 (https://github.com/syndesisio/syndesis/pull/12): <conflict>" ||
+		messages[0].FormattedBody != `❌ Failed to merge <a href="https://github.com/syndesisio/syndesis/pull/12">syndesisio/syndesis#12</a>: &lt;conflict&gt;` {
+		t.Errorf("unexpected messages %+v", messages)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+// Kind tells how a message is decorated, e.g. by an icon
+type Kind int
+
+const (
+	Success Kind = iota
+	Failure
+	Warning
+)
+
+// Message tells people in a chat about an action of the bot, like
+// "Merged syndesisio/syndesis#12: Fix the build", with the pull request
+// linked. Each chat backend renders it in its own markup.
+type Message struct {
+	Kind Kind
+	Text string
+	// Optional link following the text
+	LinkText string
+	LinkURL  string
+	// Optional details following a colon, e.g. a title or an error
+	Detail string
+}
+
+// Chat posts messages to rooms of a chat like Slack channels or Matrix
+// rooms
+type Chat interface {
+	Post(room string, message Message)
+	Close()
+}
+
+// Plain renders a message as plain text, with the URL instead of a link
+func (m Message) Plain() string {
+	text := m.Text
+	if m.LinkURL != "" {
+		text += " " + m.LinkText + " (" + m.LinkURL + ")"
+	} else if m.LinkText != "" {
+		text += " " + m.LinkText
+	}
+	if m.Detail != "" {
+		text += ": " + m.Detail
+	}
+	return text
+}
@@ -0,0 +1,179 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify tells downstream systems and people about the bot's
+// actions: signed JSON notifications for e.g. deploy pipelines reacting to
+// merges, and Slack messages.
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/audit"
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the body, as "sha256=<hex>"
+	SignatureHeader = "X-Pure-Bot-Signature-256"
+	// ActionHeader names the action, e.g. "merge"
+	ActionHeader = "X-Pure-Bot-Action"
+	// DeliveryHeader identifies a notification, it stays the same on retries
+	DeliveryHeader = "X-Pure-Bot-Delivery"
+)
+
+// Actions notified about if an endpoint doesn't name any
+var defaultActions = []string{"merge", "label-add", "label-remove", "close"}
+
+type endpoint struct {
+	url     string
+	secret  string
+	actions map[string]bool
+}
+
+type notification struct {
+	endpoint *endpoint
+	id       string
+	action   string
+	body     []byte
+}
+
+// Notifier posts the records of successful actions as JSON to the
+// configured endpoints. Notifications are sent asynchronously and dropped
+// when the buffer is full. A nil Notifier discards all records.
+type Notifier struct {
+	endpoints []*endpoint
+	client    *http.Client
+	logger    *zap.Logger
+	outbox    *outbox
+}
+
+var _ audit.Recorder = &Notifier{}
+
+// New creates a notifier for the configured endpoints or returns nil if
+// none are configured.
+func New(cfgs []config.NotificationConfig, logger *zap.Logger) *Notifier {
+	if len(cfgs) == 0 {
+		return nil
+	}
+	n := &Notifier{
+		client: &http.Client{Timeout: 10 * time.Second},
+		logger: logger,
+		outbox: newOutbox(logger),
+	}
+	for _, cfg := range cfgs {
+		actions := cfg.Actions
+		if len(actions) == 0 {
+			actions = defaultActions
+		}
+		e := &endpoint{url: cfg.URL, secret: cfg.Secret, actions: make(map[string]bool, len(actions))}
+		for _, action := range actions {
+			e.actions[action] = true
+		}
+		n.endpoints = append(n.endpoints, e)
+	}
+	return n
+}
+
+// Wrap returns a function wrapping a transport so that successful mutating
+// requests made on behalf of the given installation and webhook delivery
+// are notified about.
+func (n *Notifier) Wrap(installationID int64, deliveryID string) func(http.RoundTripper) http.RoundTripper {
+	return func(tr http.RoundTripper) http.RoundTripper {
+		if n == nil {
+			return tr
+		}
+		return audit.NewTransport(n, tr, installationID, deliveryID)
+	}
+}
+
+// Record queues notifications about a successful action for all endpoints
+// interested in it
+func (n *Notifier) Record(record audit.Record) {
+	if n == nil || record.Error != "" || record.Status/100 != 2 {
+		return
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	body, err := json.Marshal(record)
+	if err != nil {
+		n.logger.Error("failed to marshal notification", zap.Error(err))
+		return
+	}
+
+	for _, e := range n.endpoints {
+		if !e.actions[record.Action] {
+			continue
+		}
+		notification := notification{e, newID(), record.Action, body}
+		n.outbox.add(func() error { return n.send(notification) }, zap.String("action", record.Action), zap.String("url", e.url))
+	}
+}
+
+func (n *Notifier) send(notification notification) error {
+	req, err := http.NewRequest(http.MethodPost, notification.endpoint.url, bytes.NewReader(notification.body))
+	if err != nil {
+		return errors.Wrap(err, "invalid notification URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ActionHeader, notification.action)
+	req.Header.Set(DeliveryHeader, notification.id)
+	if notification.endpoint.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(notification.endpoint.secret, notification.body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to post notification to %s", notification.endpoint.url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("received non 2xx response status %d when posting notification to %s", resp.StatusCode, notification.endpoint.url)
+	}
+	return nil
+}
+
+// Close sends all queued notifications. Records added afterwards are
+// dropped.
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+	n.outbox.close()
+}
+
+// Sign returns the signature of a notification body, for verifying it on
+// the receiving side
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
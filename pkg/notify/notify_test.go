@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/syndesisio/pure-bot/pkg/audit"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestNotifier(t *testing.T) {
+	defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+	retryDelay = time.Millisecond
+
+	var (
+		mu       sync.Mutex
+		received []audit.Record
+		requests int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		// The first attempt fails and is retried
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign("secret", body) {
+			t.Errorf("invalid signature %q", r.Header.Get(SignatureHeader))
+		}
+		var record audit.Record
+		if err := json.Unmarshal(body, &record); err != nil {
+			t.Error(err)
+		}
+		if r.Header.Get(ActionHeader) != record.Action || r.Header.Get(DeliveryHeader) == "" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		received = append(received, record)
+	}))
+	defer server.Close()
+
+	n := New([]config.NotificationConfig{{URL: server.URL, Secret: "secret"}}, zap.NewNop())
+	n.Record(audit.Record{Action: "merge", Repo: "syndesisio/pure-bot", Number: 12, Status: http.StatusOK})
+	n.Record(audit.Record{Action: "comment", Repo: "syndesisio/pure-bot", Number: 12, Status: http.StatusCreated})
+	n.Record(audit.Record{Action: "merge", Repo: "syndesisio/pure-bot", Number: 13, Status: http.StatusMethodNotAllowed})
+	n.Record(audit.Record{Action: "close", Repo: "syndesisio/pure-bot", Number: 14, Status: http.StatusOK})
+	n.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[0].Number != 12 || received[1].Action != "close" {
+		t.Errorf("expected successful merge and close to be notified, got %+v", received)
+	}
+
+	var nilNotifier *Notifier
+	nilNotifier.Record(audit.Record{Action: "merge", Status: http.StatusOK})
+	nilNotifier.Close()
+}
@@ -0,0 +1,101 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	bufferSize = 1000
+	attempts   = 3
+)
+
+// Delay before retrying a failed delivery, doubled on each attempt
+var retryDelay = time.Second
+
+// delivery sends a single notification or message
+type delivery struct {
+	send func() error
+	// Describes the delivery in logs
+	fields []zap.Field
+}
+
+// outbox sends deliveries one after the other in the background, retrying
+// failed ones. Deliveries are dropped when the buffer is full.
+type outbox struct {
+	logger *zap.Logger
+
+	mu         sync.RWMutex
+	closed     bool
+	deliveries chan delivery
+	done       chan struct{}
+}
+
+func newOutbox(logger *zap.Logger) *outbox {
+	o := &outbox{
+		logger:     logger,
+		deliveries: make(chan delivery, bufferSize),
+		done:       make(chan struct{}),
+	}
+	go o.run()
+	return o
+}
+
+// add queues a delivery without blocking
+func (o *outbox) add(send func() error, fields ...zap.Field) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if o.closed {
+		return
+	}
+	select {
+	case o.deliveries <- delivery{send, fields}:
+	default:
+		o.logger.Warn("buffer full, dropping delivery", fields...)
+	}
+}
+
+func (o *outbox) run() {
+	defer close(o.done)
+	for d := range o.deliveries {
+		delay := retryDelay
+		for attempt := 1; ; attempt++ {
+			err := d.send()
+			if err == nil {
+				break
+			}
+			if attempt == attempts {
+				o.logger.Error("failed to deliver", append(d.fields, zap.Error(err))...)
+				break
+			}
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+}
+
+// close sends all queued deliveries. Deliveries added afterwards are
+// dropped.
+func (o *outbox) close() {
+	o.mu.Lock()
+	o.closed = true
+	close(o.deliveries)
+	o.mu.Unlock()
+	<-o.done
+}
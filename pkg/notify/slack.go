@@ -0,0 +1,143 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+const defaultSlackAPIURL = "https://slack.com/api/"
+
+var slackIcons = map[Kind]string{
+	Success: ":white_check_mark:",
+	Failure: ":x:",
+	Warning: ":warning:",
+}
+
+type slackMessage struct {
+	Channel string `json:"channel"`
+	Text    string `json:"text"`
+}
+
+// Slack posts messages to Slack channels with the Web API. Messages are
+// sent asynchronously and dropped when the buffer is full. A nil Slack
+// discards all messages.
+type Slack struct {
+	url    string
+	token  string
+	client *http.Client
+	outbox *outbox
+}
+
+var _ Chat = &Slack{}
+
+// NewSlack creates a Slack backend or returns nil if no token is configured
+func NewSlack(cfg config.SlackConfig, logger *zap.Logger) *Slack {
+	if cfg.Token == "" {
+		return nil
+	}
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = defaultSlackAPIURL
+	}
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	return &Slack{
+		url:    apiURL + "chat.postMessage",
+		token:  cfg.Token,
+		client: &http.Client{Timeout: 10 * time.Second},
+		outbox: newOutbox(logger),
+	}
+}
+
+// Post queues a message for a channel
+func (s *Slack) Post(channel string, message Message) {
+	if s == nil || channel == "" {
+		return
+	}
+	m := slackMessage{channel, slackText(message)}
+	s.outbox.add(func() error { return s.send(m) }, zap.String("channel", channel))
+}
+
+// slackText renders a message with Slack's mrkdwn
+func slackText(m Message) string {
+	text := slackIcons[m.Kind] + " " + slackEscape(m.Text)
+	if m.LinkURL != "" {
+		text += " <" + m.LinkURL + "|" + slackEscape(m.LinkText) + ">"
+	} else if m.LinkText != "" {
+		text += " " + slackEscape(m.LinkText)
+	}
+	if m.Detail != "" {
+		text += ": " + slackEscape(m.Detail)
+	}
+	return text
+}
+
+func (s *Slack) send(message slackMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal Slack message")
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "invalid Slack API URL")
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to post Slack message")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("received non 2xx response status %d when posting Slack message", resp.StatusCode)
+	}
+	// Slack reports most errors with a 200 response
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return errors.Wrap(err, "failed to decode Slack response")
+	}
+	if !result.OK {
+		return errors.Errorf("Slack refused message: %s", result.Error)
+	}
+	return nil
+}
+
+// Close sends all queued messages. Messages posted afterwards are dropped.
+func (s *Slack) Close() {
+	if s == nil {
+		return
+	}
+	s.outbox.close()
+}
+
+// slackEscape escapes the characters Slack interprets as control sequences
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
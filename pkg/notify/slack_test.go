@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestSlack(t *testing.T) {
+	defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+	retryDelay = time.Millisecond
+
+	var (
+		messages []slackMessage
+		requests int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/api/chat.postMessage" || r.Header.Get("Authorization") != "Bearer xoxb-token" {
+			t.Errorf("unexpected request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		// Slack reports errors with a 200 response, the first one is retried
+		if requests == 1 {
+			w.Write([]byte(`{"ok":false,"error":"ratelimited"}`))
+			return
+		}
+		var message slackMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Error(err)
+		}
+		messages = append(messages, message)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	if NewSlack(config.SlackConfig{}, zap.NewNop()) != nil {
+		t.Error("expected Slack to be disabled without a token")
+	}
+	s := NewSlack(config.SlackConfig{Token: "xoxb-token", APIURL: server.URL + "/api"}, zap.NewNop())
+	s.Post("#syndesis", Message{Kind: Success, Text: "Merged", LinkText: "syndesisio/syndesis#12", LinkURL: "https://github.com/syndesisio/syndesis/pull/12", Detail: "<b> & co"})
+	s.Post("", Message{Text: "dropped without channel"})
+	s.Close()
+
+	expected := ":white_check_mark: Merged <https://github.com/syndesisio/syndesis/pull/12|syndesisio/syndesis#12>: &lt;b&gt; &amp; co"
+	if len(messages) != 1 || messages[0].Channel != "#syndesis" || messages[0].Text != expected {
+		t.Errorf("unexpected messages %+v", messages)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryEvent struct {
+	Event
+	availableAt time.Time
+	// End of the lease while the event is being processed
+	claimedUntil time.Time
+	processedAt  time.Time
+	failed       bool
+}
+
+// memoryStore keeps events in memory, so pending events are lost when the
+// bot stops before processing them
+type memoryStore struct {
+	mu     sync.Mutex
+	nextID int64
+	events []*memoryEvent
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{}
+}
+
+func (s *memoryStore) Enqueue(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	event.ID = s.nextID
+	s.events = append(s.events, &memoryEvent{Event: event, availableAt: time.Now()})
+	return nil
+}
+
+func (s *memoryStore) Claim(lease time.Duration) (*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	busy := make(map[string]bool)
+	for _, e := range s.events {
+		if e.claimedUntil.After(now) {
+			busy[e.Repository] = true
+		}
+	}
+	for _, e := range s.events {
+		if e.processedAt.IsZero() && !e.failed && !e.availableAt.After(now) && !busy[e.Repository] {
+			e.Attempts++
+			e.availableAt = now.Add(lease)
+			e.claimedUntil = e.availableAt
+			event := e.Event
+			return &event, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) Done(id int64) error {
+	return s.update(id, func(e *memoryEvent) {
+		e.processedAt = time.Now()
+		e.claimedUntil = time.Time{}
+		e.Payload = nil
+	})
+}
+
+func (s *memoryStore) Retry(id int64, delay time.Duration, reason string, handlers []string) error {
+	return s.update(id, func(e *memoryEvent) {
+		e.availableAt = time.Now().Add(delay)
+		e.claimedUntil = time.Time{}
+		e.Error = reason
+		e.Handlers = handlers
+	})
+}
+
+func (s *memoryStore) Fail(id int64, reason string, handlers []string) error {
+	return s.update(id, func(e *memoryEvent) {
+		e.failed = true
+		e.claimedUntil = time.Time{}
+		e.Error = reason
+		e.Handlers = handlers
+	})
+}
+
+func (s *memoryStore) DeadLetters() ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var dead []Event
+	for _, e := range s.events {
+		if e.failed {
+			dead = append(dead, e.Event)
+		}
+	}
+	return dead, nil
+}
+
+func (s *memoryStore) Redrive(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.events {
+		if e.ID == id && e.failed {
+			e.failed = false
+			e.Attempts = 0
+			e.availableAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryStore) Purge(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.events[:0]
+	for _, e := range s.events {
+		if e.processedAt.IsZero() || !e.processedAt.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+	s.events = kept
+	return nil
+}
+
+func (s *memoryStore) Pending() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := 0
+	for _, e := range s.events {
+		if e.processedAt.IsZero() && !e.failed {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+func (s *memoryStore) update(id int64, change func(e *memoryEvent)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.events {
+		if e.ID == id {
+			change(e)
+			return nil
+		}
+	}
+	return nil
+}
+
+// memoryAssignments keeps review assignments in memory, so they are lost
+// when the bot stops
+type memoryAssignments struct {
+	mu sync.Mutex
+	// last review assignment per repository and reviewer
+	assignments map[string]map[string]time.Time
+}
+
+func newMemoryAssignments() *memoryAssignments {
+	return &memoryAssignments{assignments: make(map[string]map[string]time.Time)}
+}
+
+func (s *memoryAssignments) RecordAssignment(repository string, reviewer string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.assignments[repository] == nil {
+		s.assignments[repository] = make(map[string]time.Time)
+	}
+	s.assignments[repository][strings.ToLower(reviewer)] = at
+	return nil
+}
+
+func (s *memoryAssignments) LastAssignments(repository string) (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := make(map[string]time.Time, len(s.assignments[repository]))
+	for reviewer, at := range s.assignments[repository] {
+		last[reviewer] = at
+	}
+	return last, nil
+}
+
+func (s *memoryAssignments) Close() error {
+	return nil
+}
@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := newMemoryStore()
+	s.Enqueue(Event{DeliveryID: "a", Type: "status", Repository: "syndesisio/syndesis-rest", Payload: []byte("{}")})
+	s.Enqueue(Event{DeliveryID: "b", Type: "status", Repository: "syndesisio/syndesis-ui"})
+
+	first, _ := s.Claim(time.Minute)
+	if first == nil || first.DeliveryID != "a" || first.Attempts != 1 {
+		t.Fatalf("expected first attempt of a, got %+v", first)
+	}
+	// a is leased now
+	second, _ := s.Claim(time.Minute)
+	if second == nil || second.DeliveryID != "b" {
+		t.Fatalf("expected b, got %+v", second)
+	}
+	if event, _ := s.Claim(time.Minute); event != nil {
+		t.Fatalf("expected no claimable event, got %+v", event)
+	}
+
+	s.Done(first.ID)
+	s.Retry(second.ID, 0, "boom", []string{"autoMerge", "wip"})
+	retried, _ := s.Claim(time.Minute)
+	if retried == nil || retried.DeliveryID != "b" || retried.Attempts != 2 || retried.Error != "boom" {
+		t.Fatalf("expected second attempt of b, got %+v", retried)
+	}
+	if !reflect.DeepEqual(retried.Handlers, []string{"autoMerge", "wip"}) {
+		t.Errorf("expected the failed handlers to be retried, got %v", retried.Handlers)
+	}
+
+	s.Fail(retried.ID, "boom again", []string{"wip"})
+	dead, _ := s.DeadLetters()
+	if len(dead) != 1 || dead[0].DeliveryID != "b" || dead[0].Error != "boom again" || !reflect.DeepEqual(dead[0].Handlers, []string{"wip"}) {
+		t.Fatalf("expected b to be a dead letter, got %+v", dead)
+	}
+	if event, _ := s.Claim(time.Minute); event != nil {
+		t.Fatalf("expected dead letter not to be claimable, got %+v", event)
+	}
+
+	if err := s.Redrive(first.ID); err != ErrNotFound {
+		t.Errorf("expected redriving a processed event to fail, got %v", err)
+	}
+	if err := s.Redrive(retried.ID); err != nil {
+		t.Fatal(err)
+	}
+	redriven, _ := s.Claim(time.Minute)
+	if redriven == nil || redriven.DeliveryID != "b" || redriven.Attempts != 1 || !reflect.DeepEqual(redriven.Handlers, []string{"wip"}) {
+		t.Fatalf("expected redriven b, got %+v", redriven)
+	}
+	if pending, _ := s.Pending(); pending != 1 {
+		t.Errorf("expected redriven event to be pending, got %d pending events", pending)
+	}
+
+	if payload := s.events[0].Payload; payload != nil {
+		t.Errorf("expected payload of processed event to be dropped, got %s", payload)
+	}
+
+	s.Purge(time.Now().Add(time.Second))
+	if len(s.events) != 1 {
+		t.Errorf("expected processed event to be purged, %d events left", len(s.events))
+	}
+}
+
+func TestMemoryStoreRepositoryInOrder(t *testing.T) {
+	s := newMemoryStore()
+	s.Enqueue(Event{DeliveryID: "a", Repository: "syndesisio/syndesis-rest"})
+	s.Enqueue(Event{DeliveryID: "b", Repository: "syndesisio/syndesis-rest"})
+	s.Enqueue(Event{DeliveryID: "c", Repository: "syndesisio/syndesis-ui"})
+
+	first, _ := s.Claim(time.Minute)
+	// b waits for a of the same repository
+	second, _ := s.Claim(time.Minute)
+	if first == nil || first.DeliveryID != "a" || second == nil || second.DeliveryID != "c" {
+		t.Fatalf("expected a and c, got %+v and %+v", first, second)
+	}
+	if event, _ := s.Claim(time.Minute); event != nil {
+		t.Fatalf("expected no claimable event, got %+v", event)
+	}
+
+	s.Done(first.ID)
+	if next, _ := s.Claim(time.Minute); next == nil || next.DeliveryID != "b" {
+		t.Fatalf("expected b once a is done, got %+v", next)
+	}
+}
+
+func TestMemoryAssignments(t *testing.T) {
+	s := newMemoryAssignments()
+	earlier := time.Date(2018, 3, 7, 10, 0, 0, 0, time.UTC)
+	s.RecordAssignment("syndesisio/syndesis-rest", "Roland", earlier)
+	s.RecordAssignment("syndesisio/syndesis-rest", "roland", earlier.Add(time.Hour))
+	s.RecordAssignment("syndesisio/syndesis-ui", "gashcrumb", earlier)
+
+	last, _ := s.LastAssignments("syndesisio/syndesis-rest")
+	expected := map[string]time.Time{"roland": earlier.Add(time.Hour)}
+	if !reflect.DeepEqual(last, expected) {
+		t.Errorf("expected %v, got %v", expected, last)
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	// Registers the postgres driver
+	_ "github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const createEventsTable = `
+CREATE TABLE IF NOT EXISTS pure_bot_events (
+	id           BIGSERIAL PRIMARY KEY,
+	delivery_id  TEXT NOT NULL,
+	event_type   TEXT NOT NULL,
+	payload      BYTEA NOT NULL,
+	received_at  TIMESTAMPTZ NOT NULL,
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	available_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	processed_at TIMESTAMPTZ,
+	failed_at    TIMESTAMPTZ,
+	last_error   TEXT
+);
+ALTER TABLE pure_bot_events ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ;
+ALTER TABLE pure_bot_events ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE pure_bot_events ADD COLUMN IF NOT EXISTS repository TEXT NOT NULL DEFAULT '';
+ALTER TABLE pure_bot_events ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMPTZ;
+ALTER TABLE pure_bot_events ADD COLUMN IF NOT EXISTS failed_handlers TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS pure_bot_events_claimed ON pure_bot_events (repository) WHERE claimed_until IS NOT NULL;
+CREATE INDEX IF NOT EXISTS pure_bot_events_pending ON pure_bot_events (id) WHERE processed_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS pure_bot_events_delivery ON pure_bot_events (delivery_id) WHERE delivery_id <> '';
+`
+
+const createAssignmentsTable = `
+CREATE TABLE IF NOT EXISTS pure_bot_review_assignments (
+	repository  TEXT NOT NULL,
+	reviewer    TEXT NOT NULL,
+	assigned_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (repository, reviewer)
+);
+`
+
+// Serializes claims of all replicas, so that a claim sees the events of
+// the repositories claimed just before
+const claimLock = `SELECT pg_advisory_xact_lock(hashtext('pure_bot_events'))`
+
+// Skips events of repositories with a claimed event
+const claimEvent = `
+UPDATE pure_bot_events
+SET attempts = attempts + 1, available_at = now() + $1 * interval '1 second', claimed_until = now() + $1 * interval '1 second'
+WHERE id = (
+	SELECT id FROM pure_bot_events e
+	WHERE processed_at IS NULL AND failed_at IS NULL AND available_at <= now()
+	AND NOT EXISTS (
+		SELECT 1 FROM pure_bot_events c
+		WHERE c.repository = e.repository AND c.claimed_until > now()
+	)
+	ORDER BY id
+	LIMIT 1
+)
+RETURNING id, delivery_id, event_type, repository, payload, received_at, attempts, coalesce(last_error, ''), failed_handlers
+`
+
+type postgresStore struct {
+	db *sql.DB
+}
+
+func newPostgresStore(url string) (*postgresStore, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(createEventsTable); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "failed to create events table")
+	}
+	return &postgresStore{db}, nil
+}
+
+func (s *postgresStore) Enqueue(event Event) error {
+	// Replicas receiving the same delivery queue it only once
+	_, err := s.db.Exec(
+		"INSERT INTO pure_bot_events (delivery_id, event_type, repository, payload, received_at, attempts, last_error, failed_handlers) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, nullif($7, ''), $8) ON CONFLICT (delivery_id) WHERE delivery_id <> '' DO NOTHING",
+		event.DeliveryID, event.Type, event.Repository, event.Payload, event.Received, event.Attempts, event.Error, strings.Join(event.Handlers, ","),
+	)
+	return errors.Wrapf(err, "failed to enqueue delivery %s", event.DeliveryID)
+}
+
+func (s *postgresStore) Claim(lease time.Duration) (*Event, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim event")
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(claimLock); err != nil {
+		return nil, errors.Wrap(err, "failed to lock event queue")
+	}
+
+	var event Event
+	var handlers string
+	err = tx.QueryRow(claimEvent, lease.Seconds()).Scan(
+		&event.ID, &event.DeliveryID, &event.Type, &event.Repository, &event.Payload, &event.Received, &event.Attempts, &event.Error, &handlers,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim event")
+	}
+	event.Handlers = splitHandlers(handlers)
+	return &event, errors.Wrap(tx.Commit(), "failed to claim event")
+}
+
+func (s *postgresStore) Done(id int64) error {
+	_, err := s.db.Exec("UPDATE pure_bot_events SET processed_at = now(), claimed_until = NULL, payload = '' WHERE id = $1", id)
+	return errors.Wrapf(err, "failed to mark event %d as processed", id)
+}
+
+func (s *postgresStore) Retry(id int64, delay time.Duration, reason string, handlers []string) error {
+	_, err := s.db.Exec(
+		"UPDATE pure_bot_events SET available_at = now() + $2 * interval '1 second', claimed_until = NULL, last_error = $3, failed_handlers = $4 WHERE id = $1",
+		id, delay.Seconds(), reason, strings.Join(handlers, ","),
+	)
+	return errors.Wrapf(err, "failed to reschedule event %d", id)
+}
+
+func (s *postgresStore) Fail(id int64, reason string, handlers []string) error {
+	_, err := s.db.Exec(
+		"UPDATE pure_bot_events SET failed_at = now(), claimed_until = NULL, last_error = $2, failed_handlers = $3 WHERE id = $1",
+		id, reason, strings.Join(handlers, ","),
+	)
+	return errors.Wrapf(err, "failed to move event %d to the dead letters", id)
+}
+
+func (s *postgresStore) DeadLetters() ([]Event, error) {
+	rows, err := s.db.Query(
+		"SELECT id, delivery_id, event_type, received_at, attempts, coalesce(last_error, ''), failed_handlers FROM pure_bot_events WHERE failed_at IS NOT NULL ORDER BY id",
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query dead letters")
+	}
+	defer rows.Close()
+
+	var dead []Event
+	for rows.Next() {
+		var event Event
+		var handlers string
+		if err := rows.Scan(&event.ID, &event.DeliveryID, &event.Type, &event.Received, &event.Attempts, &event.Error, &handlers); err != nil {
+			return nil, errors.Wrap(err, "failed to read dead letter")
+		}
+		event.Handlers = splitHandlers(handlers)
+		dead = append(dead, event)
+	}
+	return dead, errors.Wrap(rows.Err(), "failed to read dead letters")
+}
+
+func (s *postgresStore) Redrive(id int64) error {
+	result, err := s.db.Exec(
+		"UPDATE pure_bot_events SET failed_at = NULL, attempts = 0, available_at = now() WHERE id = $1 AND failed_at IS NOT NULL", id,
+	)
+	if err != nil {
+		return errors.Wrapf(err, "failed to redrive event %d", id)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *postgresStore) Purge(before time.Time) error {
+	_, err := s.db.Exec("DELETE FROM pure_bot_events WHERE processed_at < $1", before)
+	return errors.Wrap(err, "failed to purge processed events")
+}
+
+func (s *postgresStore) Pending() (int, error) {
+	var pending int
+	err := s.db.QueryRow("SELECT count(*) FROM pure_bot_events WHERE processed_at IS NULL AND failed_at IS NULL").Scan(&pending)
+	return pending, errors.Wrap(err, "failed to count pending events")
+}
+
+func (s *postgresStore) Close() error {
+	return s.db.Close()
+}
+
+type postgresAssignments struct {
+	db *sql.DB
+}
+
+func newPostgresAssignments(url string) (*postgresAssignments, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(createAssignmentsTable); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "failed to create review assignments table")
+	}
+	return &postgresAssignments{db}, nil
+}
+
+func (s *postgresAssignments) RecordAssignment(repository string, reviewer string, at time.Time) error {
+	_, err := s.db.Exec(
+		"INSERT INTO pure_bot_review_assignments (repository, reviewer, assigned_at) VALUES ($1, $2, $3) "+
+			"ON CONFLICT (repository, reviewer) DO UPDATE SET assigned_at = excluded.assigned_at",
+		repository, strings.ToLower(reviewer), at,
+	)
+	return errors.Wrapf(err, "failed to record review assignment of %s", reviewer)
+}
+
+func (s *postgresAssignments) LastAssignments(repository string) (map[string]time.Time, error) {
+	rows, err := s.db.Query("SELECT reviewer, assigned_at FROM pure_bot_review_assignments WHERE repository = $1", repository)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query review assignments")
+	}
+	defer rows.Close()
+
+	last := make(map[string]time.Time)
+	for rows.Next() {
+		var reviewer string
+		var at time.Time
+		if err := rows.Scan(&reviewer, &at); err != nil {
+			return nil, errors.Wrap(err, "failed to read review assignment")
+		}
+		last[reviewer] = at
+	}
+	return last, errors.Wrap(rows.Err(), "failed to read review assignments")
+}
+
+func (s *postgresAssignments) Close() error {
+	return s.db.Close()
+}
+
+// splitHandlers reads the comma separated handler names of a column
+func splitHandlers(handlers string) []string {
+	if handlers == "" {
+		return nil
+	}
+	return strings.Split(handlers, ",")
+}
@@ -0,0 +1,158 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+const (
+	memoryBackend   = "memory"
+	postgresBackend = "postgres"
+
+	defaultRetention   = 24 * time.Hour
+	defaultMaxAttempts = 5
+	defaultWorkers     = 4
+)
+
+// ErrNotFound is returned when redriving an event which isn't a dead letter
+var ErrNotFound = errors.New("no such dead letter")
+
+// Event is a webhook delivery waiting to be processed
+type Event struct {
+	ID         int64     `json:"id"`
+	DeliveryID string    `json:"deliveryId"`
+	Type       string    `json:"type"`
+	Payload    []byte    `json:"-"`
+	Received   time.Time `json:"received"`
+	// Full name of the repository, empty for events concerning several
+	// repositories. Events of one repository are processed one at a time.
+	Repository string `json:"repository,omitempty"`
+	// Number of times the event has been claimed, including the current one
+	Attempts int `json:"attempts"`
+	// Error of the last failed attempt
+	Error string `json:"error,omitempty"`
+	// Handlers which failed on the last attempt and are called again on the
+	// next one, all handlers are called if empty
+	Handlers []string `json:"handlers,omitempty"`
+}
+
+// Store persists webhook deliveries until they are processed. Events are
+// processed at least once: a claimed event which is neither done, retried
+// nor failed becomes claimable again when its lease expires.
+type Store interface {
+	// Enqueue persists a received event. Events queued again keep their
+	// attempts and the handlers to call.
+	Enqueue(event Event) error
+	// Claim returns the oldest pending event of a repository without
+	// another claimed event and hides it from other consumers for the
+	// lease duration. It returns nil if there is none.
+	Claim(lease time.Duration) (*Event, error)
+	// Done marks an event as processed and drops its payload
+	Done(id int64) error
+	// Retry makes a failed event claimable again after the delay, to call
+	// the handlers which failed
+	Retry(id int64, delay time.Duration, reason string, handlers []string) error
+	// Fail moves an event which keeps failing to the dead letters,
+	// remembering the handlers which failed
+	Fail(id int64, reason string, handlers []string) error
+	// DeadLetters returns all failed events, oldest first
+	DeadLetters() ([]Event, error)
+	// Redrive makes a dead letter pending again, with fresh attempts for
+	// the handlers which failed
+	Redrive(id int64) error
+	// Purge deletes the events processed before the given time
+	Purge(before time.Time) error
+	// Pending returns the number of events not processed yet, including
+	// those waiting for a retry, but not the dead letters
+	Pending() (int, error)
+	Close() error
+}
+
+// AssignmentStore remembers when reviewers got assigned, so that reviewer
+// pools can be balanced. It is kept in the backend of the event queue.
+type AssignmentStore interface {
+	// RecordAssignment remembers when a reviewer got assigned to a pull
+	// request of the repository
+	RecordAssignment(repository string, reviewer string, at time.Time) error
+	// LastAssignments returns when reviewers were last assigned in the
+	// repository, keyed by lower case login
+	LastAssignments(repository string) (map[string]time.Time, error)
+	Close() error
+}
+
+// Options of processing the events of a store
+type Options struct {
+	// How long processed events are kept
+	Retention time.Duration
+	// Number of attempts before an event becomes a dead letter
+	MaxAttempts int
+	// Whether events survive a restart. Otherwise pending events must be
+	// processed before shutting down.
+	Durable bool
+	// Number of events processed concurrently
+	Workers int
+}
+
+// New creates the configured store, an in-memory one by default
+func New(cfg config.QueueConfig, logger *zap.Logger) (Store, Options, error) {
+	opts := Options{Retention: cfg.Retention, MaxAttempts: cfg.MaxAttempts, Workers: cfg.Workers}
+	if opts.Retention == 0 {
+		opts.Retention = defaultRetention
+	}
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = defaultWorkers
+	}
+
+	switch cfg.Backend {
+	case "", memoryBackend:
+		return newMemoryStore(), opts, nil
+	case postgresBackend:
+		store, err := newPostgresStore(cfg.URL)
+		if err != nil {
+			return nil, opts, errors.Wrap(err, "failed to create postgres event queue")
+		}
+		opts.Durable = true
+		logger.Info("Queueing webhook events", zap.String("backend", cfg.Backend), zap.Duration("retention", opts.Retention))
+		return store, opts, nil
+	default:
+		return nil, opts, errors.Errorf("unknown event queue backend %q", cfg.Backend)
+	}
+}
+
+// NewAssignmentStore creates the assignment store of the configured event
+// queue backend, an in-memory one by default
+func NewAssignmentStore(cfg config.QueueConfig) (AssignmentStore, error) {
+	switch cfg.Backend {
+	case "", memoryBackend:
+		return newMemoryAssignments(), nil
+	case postgresBackend:
+		store, err := newPostgresAssignments(cfg.URL)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create postgres assignment store")
+		}
+		return store, nil
+	default:
+		return nil, errors.Errorf("unknown event queue backend %q", cfg.Backend)
+	}
+}
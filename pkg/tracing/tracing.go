@@ -0,0 +1,200 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing records spans of the webhook processing with OpenTelemetry
+// and exports them with the OpenTelemetry protocol (OTLP) over HTTP.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/version"
+)
+
+const (
+	defaultBufferSize  = 2048
+	defaultServiceName = "pure-bot"
+	tracesPath         = "/v1/traces"
+	scopeName          = "github.com/syndesisio/pure-bot"
+	// Spans are exported in batches of at most this size ...
+	maxBatchSize = 512
+	// ... or after this interval
+	exportInterval = 5 * time.Second
+)
+
+// Tracer records spans and exports them asynchronously. Spans are dropped
+// when the buffer is full, so that tracing never blocks the bot. A nil
+// Tracer doesn't record anything.
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// New creates a tracer exporting to the configured endpoint or returns nil
+// if tracing is not configured.
+func New(cfg config.TracingConfig, logger *zap.Logger) (*Tracer, error) {
+	if cfg.Endpoint == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || !u.IsAbs() {
+		return nil, errors.Errorf("tracing endpoint %q is not an absolute URL", cfg.Endpoint)
+	}
+	if !strings.HasSuffix(u.Path, tracesPath) {
+		u.Path = strings.TrimSuffix(u.Path, "/") + tracesPath
+	}
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(u.String()),
+		otlptracehttp.WithHeaders(cfg.Headers),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create span exporter")
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Error("failed to export spans", zap.Error(err))
+	}))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter,
+			sdktrace.WithMaxQueueSize(bufferSize),
+			sdktrace.WithMaxExportBatchSize(maxBatchSize),
+			sdktrace.WithBatchTimeout(exportInterval),
+		),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(version.AppVersion),
+		)),
+		sdktrace.WithIDGenerator(idGenerator{}),
+	)
+	return &Tracer{
+		provider: provider,
+		tracer:   provider.Tracer(scopeName, trace.WithInstrumentationVersion(version.AppVersion)),
+	}, nil
+}
+
+// Start starts a span below the span active in ctx and returns a context in
+// which it is the active one. The span must be ended by the caller.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if t == nil {
+		return ctx, noop.Span{}
+	}
+	return t.tracer.Start(ctx, name, opts...)
+}
+
+type deliveryIDKey struct{}
+
+// StartDelivery starts the root span of a webhook delivery, see
+// DeliveryContext. Without a delivery ID a new trace is started.
+func (t *Tracer) StartDelivery(ctx context.Context, deliveryID, name string) (context.Context, trace.Span) {
+	if t == nil || deliveryID == "" {
+		return t.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+	}
+	// The ID generator picks the delivery ID up from the context
+	_, span := t.tracer.Start(context.WithValue(ctx, deliveryIDKey{}, deliveryID), name,
+		trace.WithNewRoot(), trace.WithSpanKind(trace.SpanKindServer))
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// Close exports all buffered spans. Spans ended afterwards are dropped.
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	return t.provider.Shutdown(context.Background())
+}
+
+// SetError marks the span as failed, a nil error is ignored
+func SetError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// DeliveryContext returns a copy of ctx in which spans are started below the
+// span a Tracer started with StartDelivery for the same delivery. The IDs of
+// that span are derived from the delivery ID, so that receiving and
+// processing a delivery end up in the same trace, even when the delivery was
+// queued in between.
+func DeliveryContext(ctx context.Context, deliveryID string) context.Context {
+	if deliveryID == "" {
+		return ctx
+	}
+	traceID, spanID := deliveryIDs(deliveryID)
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+}
+
+func deliveryIDs(deliveryID string) (traceID trace.TraceID, spanID trace.SpanID) {
+	sum := sha256.Sum256([]byte(deliveryID))
+	copy(traceID[:], sum[:16])
+	copy(spanID[:], sum[16:24])
+	return traceID, spanID
+}
+
+// idGenerator derives the IDs of the root span of a delivery from its
+// delivery ID and generates random IDs for all other spans
+type idGenerator struct{}
+
+var _ sdktrace.IDGenerator = idGenerator{}
+
+func (g idGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	if deliveryID, ok := ctx.Value(deliveryIDKey{}).(string); ok {
+		return deliveryIDs(deliveryID)
+	}
+	var traceID trace.TraceID
+	randomID(traceID[:])
+	return traceID, g.NewSpanID(ctx, traceID)
+}
+
+func (idGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	var spanID trace.SpanID
+	randomID(spanID[:])
+	return spanID
+}
+
+func randomID(id []byte) {
+	// Errors can't be handled sensibly, the ID stays zero in the worst case
+	rand.Read(id)
+}
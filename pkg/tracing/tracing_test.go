@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+func TestDeliveryTrace(t *testing.T) {
+	var exported coltracepb.ExportTraceServiceRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "secret" {
+			t.Errorf("unexpected export request %s %v", r.URL, r.Header)
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read export request: %v", err)
+		}
+		if err := proto.Unmarshal(body, &exported); err != nil {
+			t.Errorf("failed to decode export request: %v", err)
+		}
+	}))
+	defer collector.Close()
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer github.Close()
+
+	tracer, err := New(config.TracingConfig{Endpoint: collector.URL, Headers: map[string]string{"Authorization": "secret"}}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Receiving and processing are connected by the delivery ID only
+	_, received := tracer.StartDelivery(context.Background(), "delivery-1", "webhook status")
+	received.End()
+	ctx, processed := tracer.Start(DeliveryContext(context.Background(), "delivery-1"), "process status")
+	req, _ := http.NewRequest(http.MethodGet, github.URL+"/repos/syndesisio/pure-bot", nil)
+	client := &http.Client{Transport: tracer.Wrap()(http.DefaultTransport)}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	processed.End()
+
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exported.ResourceSpans) != 1 || len(exported.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected export request %v", &exported)
+	}
+	if name := exported.ResourceSpans[0].Resource.Attributes[0]; name.Key != "service.name" || name.Value.GetStringValue() != "pure-bot" {
+		t.Errorf("expected default service name, got %+v", name)
+	}
+	spans := exported.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %+v", spans)
+	}
+	root, request, process := spans[0], spans[1], spans[2]
+	if len(root.ParentSpanId) != 0 || !bytes.Equal(process.ParentSpanId, root.SpanId) || !bytes.Equal(request.ParentSpanId, process.SpanId) {
+		t.Errorf("expected spans to be nested, got %+v", spans)
+	}
+	if !bytes.Equal(process.TraceId, root.TraceId) || !bytes.Equal(request.TraceId, root.TraceId) {
+		t.Errorf("expected spans in one trace, got %+v", spans)
+	}
+	if request.Kind != tracepb.Span_SPAN_KIND_CLIENT || request.Status.GetCode() != tracepb.Status_STATUS_CODE_ERROR {
+		t.Errorf("expected failed client span, got %+v", request)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	tracer, err := New(config.TracingConfig{}, zap.NewNop())
+	if err != nil || tracer != nil {
+		t.Fatalf("expected no tracer without endpoint, got %v, %v", tracer, err)
+	}
+	ctx, span := tracer.Start(context.Background(), "noop")
+	SetError(span, errors.New("ignored"))
+	span.End()
+	if ctx != context.Background() || tracer.Close() != nil {
+		t.Error("expected disabled tracer to do nothing")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport records a client span for every request passing through it
+type Transport struct {
+	tracer *Tracer
+	tr     http.RoundTripper
+}
+
+var _ http.RoundTripper = &Transport{}
+
+// Wrap returns a function wrapping a transport so that its requests are
+// traced as part of the span active in their context.
+func (t *Tracer) Wrap() func(http.RoundTripper) http.RoundTripper {
+	return func(tr http.RoundTripper) http.RoundTripper {
+		if t == nil {
+			return tr
+		}
+		return &Transport{t, tr}
+	}
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), "HTTP "+req.Method, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.URLFull(req.URL.String()),
+	)
+
+	resp, err := t.tr.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		SetError(span, err)
+		return resp, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		SetError(span, errors.Errorf("response status %d", resp.StatusCode))
+	}
+	return resp, nil
+}
@@ -21,12 +21,14 @@ import (
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
 	"github.com/syndesisio/pure-bot/pkg/config"
 	"go.uber.org/zap"
 )
 
 const (
-	approvedReviewState = "approved"
+	approvedReviewState         = "approved"
+	changesRequestedReviewState = "changes_requested"
 )
 
 type addLabelOnReviewApproval struct{}
@@ -35,7 +37,7 @@ func (h *addLabelOnReviewApproval) EventTypesHandled() []string {
 	return []string{"pull_request_review"}
 }
 
-func (h *addLabelOnReviewApproval) HandleEvent(eventObject interface{}, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *addLabelOnReviewApproval) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
 	event, ok := eventObject.(*github.PullRequestReviewEvent)
 	if !ok {
 		return errors.New("wrong event eventObject type")
@@ -53,7 +55,7 @@ func (h *addLabelOnReviewApproval) HandleEvent(eventObject interface{}, gh *gith
 
 	owner, repo, prNumber, prURL := event.Repo.Owner.GetLogin(), event.Repo.GetName(), event.PullRequest.GetNumber(), event.PullRequest.GetHTMLURL()
 
-	pr, _, err := gh.Issues.Get(context.Background(), owner, repo, prNumber)
+	pr, _, err := gh.Issues.Get(ctx, owner, repo, prNumber)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get PR %s", prURL)
 	}
@@ -64,14 +66,14 @@ func (h *addLabelOnReviewApproval) HandleEvent(eventObject interface{}, gh *gith
 	}
 
 	message := fmt.Sprintf("Pull request [approved](%s) by @%s - applying _%s_ label", event.Review.GetHTMLURL(), event.Review.User.GetLogin(), approvedLabel)
-	_, _, err = gh.Issues.CreateComment(context.Background(), owner, repo, prNumber, &github.IssueComment{
+	_, _, err = gh.Issues.CreateComment(ctx, owner, repo, prNumber, &github.IssueComment{
 		Body: &message,
 	})
 	if err != nil {
 		return errors.Wrapf(err, "failed to add comment '%s' to PR %s", message, prURL)
 	}
 
-	_, _, err = gh.Issues.AddLabelsToIssue(context.Background(), owner, repo, prNumber, []string{approvedLabel})
+	_, _, err = gh.Issues.AddLabelsToIssue(ctx, owner, repo, prNumber, []string{approvedLabel})
 	if err != nil {
 		return errors.Wrapf(err, "failed to add label '%s' to PR %s", approvedLabel, prURL)
 	}
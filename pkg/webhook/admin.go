@@ -0,0 +1,209 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/queue"
+)
+
+const (
+	deadLettersPath = "/admin/dead-letters"
+	deliveriesPath  = "/admin/deliveries"
+	labelSyncPath   = "/admin/label-sync"
+	// Deliveries listed if no limit is given
+	defaultDeliveriesLimit = 20
+)
+
+// NewAdminHandler serves the admin endpoints, which require the token as
+// bearer token or, for browsers, as basic auth password:
+//
+//	GET  /admin/dashboard                 shows merges, errors and rate limits
+//	GET  /admin/dead-letters              lists the events which kept failing
+//	POST /admin/dead-letters/<id>/redrive queues a dead letter again
+//	GET  /admin/deliveries?limit=<n>      lists recently processed deliveries
+//	GET  /admin/deliveries/<id>           shows a delivery with its payload
+//	POST /admin/deliveries/<id>/rerun     queues a delivery again
+//	GET  /admin/label-sync                shows the last label sync of each repository
+//	POST /admin/label-sync?repo=<name>    syncs the labels of all or one repository now
+func NewAdminHandler(token string, logger *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if _, password, ok := r.BasicAuth(); ok {
+			provided = password
+		}
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="pure-bot"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == dashboardPath && r.Method == http.MethodGet {
+			showDashboard(w, logger)
+			return
+		}
+		if r.URL.Path == labelSyncPath && r.Method == http.MethodGet {
+			writeJSON(w, lastLabelSyncs(), logger)
+			return
+		}
+		if r.URL.Path == labelSyncPath && r.Method == http.MethodPost {
+			runLabelSync(w, r, logger)
+			return
+		}
+		if eventQueue == nil {
+			http.Error(w, "event queue not running", http.StatusServiceUnavailable)
+			return
+		}
+
+		switch {
+		case r.URL.Path == deadLettersPath && r.Method == http.MethodGet:
+			listDeadLetters(w, logger)
+		case strings.HasPrefix(r.URL.Path, deadLettersPath+"/") && strings.HasSuffix(r.URL.Path, "/redrive") && r.Method == http.MethodPost:
+			id, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, deadLettersPath+"/"), "/redrive"), 10, 64)
+			if err != nil {
+				http.Error(w, "invalid dead letter id", http.StatusBadRequest)
+				return
+			}
+			redriveDeadLetter(w, id, logger)
+		case r.URL.Path == deliveriesPath && r.Method == http.MethodGet:
+			listDeliveries(w, r, logger)
+		case strings.HasPrefix(r.URL.Path, deliveriesPath+"/") && strings.HasSuffix(r.URL.Path, "/rerun") && r.Method == http.MethodPost:
+			rerunDelivery(w, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, deliveriesPath+"/"), "/rerun"), logger)
+		case strings.HasPrefix(r.URL.Path, deliveriesPath+"/") && r.Method == http.MethodGet:
+			showDelivery(w, strings.TrimPrefix(r.URL.Path, deliveriesPath+"/"), logger)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func listDeadLetters(w http.ResponseWriter, logger *zap.Logger) {
+	dead, err := eventQueue.DeadLetters()
+	if err != nil {
+		logger.Error("failed to list dead letters", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if dead == nil {
+		dead = []queue.Event{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(dead); err != nil {
+		logger.Error("failed to encode dead letters", zap.Error(err))
+	}
+}
+
+func redriveDeadLetter(w http.ResponseWriter, id int64, logger *zap.Logger) {
+	err := eventQueue.Redrive(id)
+	if err == queue.ErrNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("failed to redrive dead letter", zap.Int64("id", id), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Redriving dead letter", zap.Int64("id", id))
+	select {
+	case queueWakeup <- struct{}{}:
+	default:
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func listDeliveries(w http.ResponseWriter, r *http.Request, logger *zap.Logger) {
+	limit := defaultDeliveriesLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	writeJSON(w, recentDeliveries.recent(limit), logger)
+}
+
+func showDelivery(w http.ResponseWriter, deliveryID string, logger *zap.Logger) {
+	delivery, found := recentDeliveries.get(deliveryID)
+	if !found {
+		http.Error(w, "delivery not found", http.StatusNotFound)
+		return
+	}
+	delivery.Payload = delivery.payload
+	writeJSON(w, delivery, logger)
+}
+
+// rerunDelivery queues a recently processed delivery again. It is queued under
+// a delivery ID derived from the original one, as stores drop deliveries
+// they have already queued.
+func rerunDelivery(w http.ResponseWriter, deliveryID string, logger *zap.Logger) {
+	delivery, found := recentDeliveries.get(deliveryID)
+	if !found {
+		http.Error(w, "delivery not found", http.StatusNotFound)
+		return
+	}
+	rerunID := fmt.Sprintf("%s/rerun/%d", delivery.DeliveryID, time.Now().UnixNano())
+	if err := enqueueDelivery(delivery.Type, rerunID, delivery.payload); err != nil {
+		logger.Error("failed to queue delivery again", zap.String("delivery", deliveryID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Running delivery again", zap.String("delivery", deliveryID), zap.String("rerun", rerunID))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// runLabelSync synchronizes labels right away and answers with the changes
+// applied to each repository. Failures are reported per repository, the
+// status is 500 if any repository failed.
+func runLabelSync(w http.ResponseWriter, r *http.Request, logger *zap.Logger) {
+	if !inFlight.begin() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer inFlight.done()
+
+	results, err := syncAllLabels(r.Context(), currentConfig(), r.URL.Query().Get("repo"), logger)
+	if err != nil {
+		logger.Error("label sync failed", zap.Error(err))
+		if len(results) == 0 {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			logger.Error("failed to encode response", zap.Error(err))
+		}
+		return
+	}
+	writeJSON(w, results, logger)
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}, logger *zap.Logger) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logger.Error("failed to encode response", zap.Error(err))
+	}
+}
@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/queue"
+	"go.uber.org/zap"
+)
+
+func TestAdminDeadLetters(t *testing.T) {
+	defer func(store queue.Store) { eventQueue = store }(eventQueue)
+	var err error
+	if eventQueue, _, err = queue.New(config.QueueConfig{}, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	eventQueue.Enqueue(queue.Event{DeliveryID: "72d3162e", Type: "status"})
+	event, _ := eventQueue.Claim(time.Minute)
+	eventQueue.Fail(event.ID, "boom", nil)
+
+	handler := NewAdminHandler("secret", zap.NewNop())
+	request := func(method string, path string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request(http.MethodGet, "/admin/dead-letters", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected wrong token to be rejected, got %d", w.Code)
+	}
+
+	w := request(http.MethodGet, "/admin/dead-letters", "secret")
+	var dead []queue.Event
+	if err := json.NewDecoder(w.Body).Decode(&dead); err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].DeliveryID != "72d3162e" || dead[0].Error != "boom" {
+		t.Errorf("unexpected dead letters %+v", dead)
+	}
+
+	if w := request(http.MethodPost, "/admin/dead-letters/42/redrive", "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("expected unknown dead letter to be not found, got %d", w.Code)
+	}
+	if w := request(http.MethodPost, "/admin/dead-letters/1/redrive", "secret"); w.Code != http.StatusAccepted {
+		t.Errorf("expected dead letter to be redriven, got %d", w.Code)
+	}
+	if event, _ := eventQueue.Claim(time.Minute); event == nil || event.DeliveryID != "72d3162e" {
+		t.Errorf("expected redriven event to be pending, got %+v", event)
+	}
+}
+
+// dedupStore drops deliveries which were queued before, like the postgres
+// store does
+type dedupStore struct {
+	queue.Store
+	queued map[string]bool
+}
+
+func (s *dedupStore) Enqueue(event queue.Event) error {
+	if s.queued[event.DeliveryID] {
+		return nil
+	}
+	s.queued[event.DeliveryID] = true
+	return s.Store.Enqueue(event)
+}
+
+func TestAdminDeliveries(t *testing.T) {
+	defer func(store queue.Store) { eventQueue = store }(eventQueue)
+	store, _, _ := queue.New(config.QueueConfig{}, zap.NewNop())
+	// The deliveries were queued when they were received
+	eventQueue = &dedupStore{store, map[string]bool{"1": true, "2": true, "3": true}}
+	defer func(history *deliveryHistory) { recentDeliveries = history }(recentDeliveries)
+	recentDeliveries = newDeliveryHistory(2)
+	for _, id := range []string{"1", "2", "3"} {
+		recentDeliveries.add(processedDelivery{
+			DeliveryID: id,
+			Type:       "status",
+			Handlers:   []handlerOutcome{{Name: "autoMerge", Error: "boom " + id}},
+			payload:    []byte(`{"sha":"` + id + `"}`),
+		})
+	}
+
+	handler := NewAdminHandler("secret", zap.NewNop())
+	request := func(method string, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	var deliveries []processedDelivery
+	if err := json.NewDecoder(request(http.MethodGet, "/admin/deliveries").Body).Decode(&deliveries); err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 2 || deliveries[0].DeliveryID != "3" || deliveries[1].DeliveryID != "2" || deliveries[0].Payload != nil {
+		t.Errorf("expected the two most recent deliveries without payload, got %+v", deliveries)
+	}
+	if err := json.NewDecoder(request(http.MethodGet, "/admin/deliveries?limit=1").Body).Decode(&deliveries); err != nil || len(deliveries) != 1 {
+		t.Errorf("expected a single delivery, got %+v (%v)", deliveries, err)
+	}
+
+	var delivery processedDelivery
+	if err := json.NewDecoder(request(http.MethodGet, "/admin/deliveries/2").Body).Decode(&delivery); err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Handlers[0].Error != "boom 2" || string(delivery.Payload) != `{"sha":"2"}` {
+		t.Errorf("unexpected delivery %+v", delivery)
+	}
+	if w := request(http.MethodGet, "/admin/deliveries/1"); w.Code != http.StatusNotFound {
+		t.Errorf("expected forgotten delivery to be not found, got %d", w.Code)
+	}
+
+	// Each rerun is queued, although the delivery was queued before
+	var rerunIDs []string
+	for i := 0; i < 2; i++ {
+		if w := request(http.MethodPost, "/admin/deliveries/3/rerun"); w.Code != http.StatusAccepted {
+			t.Errorf("expected delivery to be queued again, got %d", w.Code)
+		}
+		event, _ := eventQueue.Claim(time.Minute)
+		if event == nil || !strings.HasPrefix(event.DeliveryID, "3/rerun/") || string(event.Payload) != `{"sha":"3"}` {
+			t.Fatalf("expected delivery to be pending, got %+v", event)
+		}
+		eventQueue.Done(event.ID)
+		rerunIDs = append(rerunIDs, event.DeliveryID)
+	}
+	if rerunIDs[0] == rerunIDs[1] {
+		t.Errorf("expected reruns to have their own delivery IDs, got %v", rerunIDs)
+	}
+}
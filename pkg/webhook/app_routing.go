@@ -0,0 +1,95 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/github/apps"
+)
+
+// appCache remembers which of the configured GitHub Apps an installation
+// belongs to, so that it has to be looked up only once
+type appCache struct {
+	mu   sync.Mutex
+	apps map[int64]config.GitHubAppConfig
+}
+
+var installationApps = &appCache{apps: make(map[int64]config.GitHubAppConfig)}
+
+func (c *appCache) lookup(installationID int64) (config.GitHubAppConfig, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	appCfg, found := c.apps[installationID]
+	return appCfg, found
+}
+
+func (c *appCache) remember(installationID int64, appCfg config.GitHubAppConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apps[installationID] = appCfg
+}
+
+// appForInstallation returns the credentials of the GitHub App an
+// installation belongs to. With more than one app configured, each app is
+// asked for the installation until one knows it.
+func appForInstallation(ctx context.Context, cfg config.Config, installationID int64) (config.GitHubAppConfig, error) {
+	configured := cfg.Apps()
+	switch len(configured) {
+	case 0:
+		return config.GitHubAppConfig{}, errors.New("no GitHub App configured")
+	case 1:
+		return configured[0], nil
+	}
+
+	if appCfg, found := installationApps.lookup(installationID); found {
+		return appCfg, nil
+	}
+	for _, appCfg := range configured {
+		appClient, err := newAppClient(appCfg)
+		if err != nil {
+			return config.GitHubAppConfig{}, err
+		}
+		_, resp, err := appClient.Apps.GetInstallation(ctx, installationID)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				continue
+			}
+			return config.GitHubAppConfig{}, errors.Wrapf(err, "failed to look up installation %d of app %d", installationID, appCfg.AppID)
+		}
+		installationApps.remember(installationID, appCfg)
+		return appCfg, nil
+	}
+	return config.GitHubAppConfig{}, errors.Errorf("installation %d belongs to none of the configured GitHub Apps", installationID)
+}
+
+// newAppClient creates a GitHub client authenticated as the app itself
+func newAppClient(appCfg config.GitHubAppConfig) (*github.Client, error) {
+	key, err := ioutil.ReadFile(appCfg.PrivateKeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read private key file")
+	}
+	return apps.AppClient(appEndpoint(appCfg), appCfg.AppID, key)
+}
+
+func appEndpoint(appCfg config.GitHubAppConfig) apps.Endpoint {
+	return apps.Endpoint{BaseURL: appCfg.BaseURL, UploadURL: appCfg.UploadURL}
+}
@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+func writePrivateKey(t *testing.T, dir string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "private-key")
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// fakeApp serves the given installation for app lookups
+func fakeApp(installationID string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/app/installations/"+installationID {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":` + installationID + `}`))
+	}))
+}
+
+func TestAppForInstallation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pure-bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key := writePrivateKey(t, dir)
+	defer func(cache *appCache) { installationApps = cache }(installationApps)
+	installationApps = &appCache{apps: make(map[int64]config.GitHubAppConfig)}
+
+	first, second := fakeApp("1"), fakeApp("2")
+	defer first.Close()
+	defer second.Close()
+	cfg := config.Config{
+		GitHubApp:  config.GitHubAppConfig{AppID: 10, PrivateKeyFile: key, BaseURL: first.URL},
+		GitHubApps: []config.GitHubAppConfig{{AppID: 20, PrivateKeyFile: key, BaseURL: second.URL}},
+	}
+
+	for installationID, appID := range map[int64]int64{1: 10, 2: 20} {
+		appCfg, err := appForInstallation(context.Background(), cfg, installationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if appCfg.AppID != appID {
+			t.Errorf("expected installation %d to belong to app %d, got %d", installationID, appID, appCfg.AppID)
+		}
+	}
+	if _, err := appForInstallation(context.Background(), cfg, 3); err == nil {
+		t.Error("expected unknown installation to fail")
+	}
+
+	// Looked up installations are cached
+	second.Close()
+	if appCfg, err := appForInstallation(context.Background(), cfg, 2); err != nil || appCfg.AppID != 20 {
+		t.Errorf("expected cached app 20, got %d (%v)", appCfg.AppID, err)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+// Commands for assignees and reviewers, working like the ones of Prow. They
+// default to the commenter when no user is given.
+var (
+	assignCommand = Command{
+		Help:       "Assigns the users, or yourself",
+		Usage:      "[@user ...]",
+		Permission: "read",
+		Run:        runAssign,
+	}
+	unassignCommand = Command{
+		Help:       "Removes the users, or yourself, from the assignees",
+		Usage:      "[@user ...]",
+		Permission: "read",
+		Run:        runUnassign,
+	}
+	ccCommand = Command{
+		Help:            "Requests a review from the users or teams, or yourself",
+		Usage:           "[@user|@org/team ...]",
+		Permission:      "read",
+		PullRequestOnly: true,
+		Run:             runCc,
+	}
+	unccCommand = Command{
+		Help:            "Removes the review requests of the users or teams, or yourself",
+		Usage:           "[@user|@org/team ...]",
+		Permission:      "read",
+		PullRequestOnly: true,
+		Run:             runUncc,
+	}
+)
+
+// commandUsers returns the users given as arguments without their "@" and
+// the commenter if there are none
+func commandUsers(cmd CommandEvent) []string {
+	if len(cmd.Args) == 0 {
+		return []string{cmd.Commenter()}
+	}
+	users := make([]string, 0, len(cmd.Args))
+	for _, arg := range cmd.Args {
+		if user := strings.TrimPrefix(arg, "@"); user != "" {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+// reviewersRequest splits the arguments into users and teams, given as
+// "org/team"
+func reviewersRequest(cmd CommandEvent) github.ReviewersRequest {
+	var request github.ReviewersRequest
+	for _, user := range commandUsers(cmd) {
+		if i := strings.Index(user, "/"); i >= 0 {
+			request.TeamReviewers = append(request.TeamReviewers, user[i+1:])
+		} else {
+			request.Reviewers = append(request.Reviewers, user)
+		}
+	}
+	return request
+}
+
+func runAssign(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	users := commandUsers(cmd)
+	issue, _, err := gh.Issues.AddAssignees(ctx, cmd.Owner(), cmd.Repo(), cmd.Number(), users)
+	if err != nil {
+		return rejectedByGitHub(ctx, gh, cmd, errors.Wrapf(err, "failed to assign %s to %s", strings.Join(users, ", "), cmd.Event.Issue.GetHTMLURL()))
+	}
+
+	// GitHub silently skips users who can't be assigned
+	var skipped []string
+	for _, user := range users {
+		if !assigned(issue, user) {
+			skipped = append(skipped, user)
+		}
+	}
+	if len(skipped) > 0 {
+		return cmd.Reply(ctx, gh, "GitHub didn't assign "+strings.Join(skipped, ", ")+". Only collaborators of the repository and users who commented can be assigned.")
+	}
+	return nil
+}
+
+func runUnassign(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	users := commandUsers(cmd)
+	_, _, err := gh.Issues.RemoveAssignees(ctx, cmd.Owner(), cmd.Repo(), cmd.Number(), users)
+	return errors.Wrapf(err, "failed to unassign %s from %s", strings.Join(users, ", "), cmd.Event.Issue.GetHTMLURL())
+}
+
+func runCc(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	request := reviewersRequest(cmd)
+	_, _, err := gh.PullRequests.RequestReviewers(ctx, cmd.Owner(), cmd.Repo(), cmd.Number(), request)
+	return rejectedByGitHub(ctx, gh, cmd, errors.Wrapf(err, "failed to request reviews on %s", cmd.Event.Issue.GetHTMLURL()))
+}
+
+func runUncc(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	request := reviewersRequest(cmd)
+	_, err := gh.PullRequests.RemoveReviewers(ctx, cmd.Owner(), cmd.Repo(), cmd.Number(), request)
+	return errors.Wrapf(err, "failed to remove review requests from %s", cmd.Event.Issue.GetHTMLURL())
+}
+
+func assigned(issue *github.Issue, user string) bool {
+	for _, assignee := range issue.Assignees {
+		if strings.EqualFold(assignee.GetLogin(), user) {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectedByGitHub answers requests GitHub rejects as invalid, e.g. a review
+// request of the author, with GitHub's message instead of failing
+func rejectedByGitHub(ctx context.Context, gh *github.Client, cmd CommandEvent, err error) error {
+	if errResp, ok := errors.Cause(err).(*github.ErrorResponse); ok && errResp.Response.StatusCode == http.StatusUnprocessableEntity {
+		return cmd.Reply(ctx, gh, "GitHub rejected `/"+cmd.Name+"`: "+errResp.Message)
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func assigneesJSON(logins ...string) map[string]interface{} {
+	issue := issueJSON(fixturePR)
+	users := make([]map[string]interface{}, 0, len(logins))
+	for _, login := range logins {
+		users = append(users, map[string]interface{}{"login": login})
+	}
+	issue["assignees"] = users
+	return issue
+}
+
+func TestAssignCommands(t *testing.T) {
+	assignees := "POST " + fixtureRepo + "/issues/276/assignees"
+	reviewers := "POST " + fixtureRepo + "/pulls/276/requested_reviewers"
+
+	tests := []struct {
+		name          string
+		comment       string
+		responses     map[string]interface{}
+		expectedCalls []string
+		// Body of the last call
+		expectedBody interface{}
+		reply        string
+	}{
+		{
+			name:          "assign users",
+			comment:       "/assign @alice bob",
+			responses:     map[string]interface{}{assignees: assigneesJSON("alice", "bob")},
+			expectedCalls: []string{assignees},
+			expectedBody:  map[string]interface{}{"assignees": []interface{}{"alice", "bob"}},
+		},
+		{
+			name:          "assign yourself",
+			comment:       "/assign",
+			responses:     map[string]interface{}{assignees: assigneesJSON("roland")},
+			expectedCalls: []string{assignees},
+			expectedBody:  map[string]interface{}{"assignees": []interface{}{"roland"}},
+		},
+		{
+			name:          "assign outsider",
+			comment:       "/assign @alice @mallory",
+			responses:     map[string]interface{}{assignees: assigneesJSON("alice")},
+			expectedCalls: []string{assignees, createComment},
+			reply:         "@roland GitHub didn't assign mallory. Only collaborators of the repository and users who commented can be assigned.",
+		},
+		{
+			name:          "request reviews",
+			comment:       "/cc @bob @syndesisio/reviewers",
+			expectedCalls: []string{reviewers},
+			expectedBody:  map[string]interface{}{"reviewers": []interface{}{"bob"}, "team_reviewers": []interface{}{"reviewers"}},
+		},
+		{
+			name:    "review of author",
+			comment: "/cc @kurt",
+			responses: map[string]interface{}{reviewers: fakeResponse{
+				status: http.StatusUnprocessableEntity,
+				body:   map[string]string{"message": "Review cannot be requested from pull request author."},
+			}},
+			expectedCalls: []string{reviewers, createComment},
+			reply:         "@roland GitHub rejected `/cc`: Review cannot be requested from pull request author.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			responses := map[string]interface{}{"GET " + fixtureRepo + "/collaborators/roland/permission": permissionJSON("read")}
+			for call, response := range test.responses {
+				responses[call] = response
+			}
+			fake := newFakeGitHub(t, responses)
+			defer fake.close()
+
+			event := commentEvent(test.comment, "roland", "User", true)
+			if err := (&slashCommands{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), config.RepoConfig{}, zap.NewNop()); err != nil {
+				t.Fatalf("handler failed: %+v", err)
+			}
+			calls := fake.mutatingCalls()
+			if !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Fatalf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if test.expectedBody != nil {
+				var body map[string]interface{}
+				if err := fake.requestBody(calls[len(calls)-1], &body); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(body, test.expectedBody) {
+					t.Errorf("expected request %v, got %v", test.expectedBody, body)
+				}
+			}
+			if test.reply != "" {
+				var comment github.IssueComment
+				if err := fake.requestBody(createComment, &comment); err != nil {
+					t.Fatal(err)
+				}
+				if comment.GetBody() != test.reply {
+					t.Errorf("expected reply %q, got %q", test.reply, comment.GetBody())
+				}
+			}
+		})
+	}
+}
@@ -17,12 +17,15 @@ package webhook
 import (
 	"context"
 	"fmt"
-	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/audit"
 	"github.com/syndesisio/pure-bot/pkg/config"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
@@ -30,6 +33,9 @@ import (
 
 const (
 	labeledEvent                = "labeled"
+	editedEvent                 = "edited"
+	readyForReviewEvent         = "ready_for_review"
+	closedEvent                 = "closed"
 	statusEventSuccessState     = "success"
 	checkEventSuccessConclusion = "success"
 )
@@ -37,73 +43,114 @@ const (
 type autoMerger struct{}
 
 func (h *autoMerger) EventTypesHandled() []string {
-	return []string{"pull_request", "status", "pull_request_review"}
+	return []string{"pull_request", "status", "pull_request_review", "pull_request_review_thread", "workflow_run", "check_run", "check_suite"}
 }
 
-func (h *autoMerger) HandleEvent(eventObject interface{}, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *autoMerger) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
 
 	approvedLabel := config.Labels.Approved
 	if approvedLabel == "" {
 		return nil
 	}
 
+	if config.MergeStrategy == nativeMergeStrategy {
+		if event, ok := eventObject.(*github.PullRequestEvent); ok {
+			return h.handleNativeAutoMerge(ctx, event, gql, config, logger)
+		}
+		return nil
+	}
+
 	switch event := eventObject.(type) {
 	case *github.PullRequestEvent:
-		return h.handlePullRequestEvent(event, gh, config, logger)
+		return h.handlePullRequestEvent(ctx, event, gh, gql, config, logger)
 	case *github.StatusEvent:
-		return h.handleStatusEvent(event, gh, config, logger)
+		return h.handleStatusEvent(ctx, event, gh, gql, config, logger)
 	case *github.PullRequestReviewEvent:
-		return h.handlePullRequestReviewEvent(event, gh, config, logger)
+		return h.handlePullRequestReviewEvent(ctx, event, gh, gql, config, logger)
+	case *pullRequestReviewThreadEvent:
+		return h.handlePullRequestReviewThreadEvent(ctx, event, gh, gql, config, logger)
+	case *workflowRunEvent:
+		return h.handleWorkflowRunEvent(ctx, event, gh, gql, config, logger)
+	case *github.CheckRunEvent:
+		return h.handleCheckRunEvent(ctx, event, gh, gql, config, logger)
+	case *github.CheckSuiteEvent:
+		return h.handleCheckSuiteEvent(ctx, event, gh, gql, config, logger)
 	default:
 		return nil
 	}
 }
 
-func (h *autoMerger) handlePullRequestReviewEvent(event *github.PullRequestReviewEvent, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *autoMerger) handlePullRequestReviewEvent(ctx context.Context, event *github.PullRequestReviewEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
 	if strings.ToLower(event.Review.GetState()) != approvedReviewState {
 		logger.Debug("skipping PullRequestReview event as its not in approved state", zap.String("state", event.Review.GetState()), zap.Int("pr", event.PullRequest.GetNumber()))
 		return nil
 	}
 
-	return h.mergePRFromPullRequestEvent(event.Installation.GetID(), event.Repo, event.PullRequest, gh, config, logger)
+	return h.mergePRFromPullRequestEvent(ctx, event.Installation.GetID(), event.Repo, event.PullRequest, gh, gql, config, logger)
 }
 
-func (h *autoMerger) handlePullRequestEvent(event *github.PullRequestEvent, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *autoMerger) handlePullRequestEvent(ctx context.Context, event *github.PullRequestEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
 
-	if strings.ToLower(event.GetAction()) != labeledEvent {
-		logger.Debug("skipping PullRequest event as it is not a label event", zap.String("action", event.GetAction()), zap.Int("pr", event.PullRequest.GetNumber()))
+	switch strings.ToLower(event.GetAction()) {
+	case closedEvent:
+		activity.forget(event.Repo.GetFullName(), event.PullRequest.GetNumber())
+		return nil
+	case labeledEvent, readyForReviewEvent:
+	case editedEvent:
+		// Retargeting changes the required contexts and the mergeability.
+		// Scheduled evaluations fetch the PR again, so they pick up the
+		// new base as well.
+		if !baseChanged(event) {
+			return nil
+		}
+		logger.Info("PR base changed, re-evaluating", zap.Int("pr", event.PullRequest.GetNumber()), zap.String("base", event.PullRequest.Base.GetRef()))
+		// What the PR waited for belongs to the old base
+		activity.forget(event.Repo.GetFullName(), event.PullRequest.GetNumber())
+	default:
+		logger.Debug("skipping PullRequest event as it is not a label, ready for review or base change event", zap.String("action", event.GetAction()), zap.Int("pr", event.PullRequest.GetNumber()))
 		return nil
 	}
 
-	return h.mergePRFromPullRequestEvent(event.Installation.GetID(), event.Repo, event.PullRequest, gh, config, logger)
+	return h.mergePRFromPullRequestEvent(ctx, event.Installation.GetID(), event.Repo, event.PullRequest, gh, gql, config, logger)
 }
 
-func (h *autoMerger) handleStatusEvent(event *github.StatusEvent, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *autoMerger) handlePullRequestReviewThreadEvent(ctx context.Context, event *pullRequestReviewThreadEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if !config.RequireResolvedConversations || strings.ToLower(event.GetAction()) != "resolved" {
+		return nil
+	}
+
+	return h.mergePRFromPullRequestEvent(ctx, event.Installation.GetID(), event.Repo, event.PullRequest, gh, gql, config, logger)
+}
+
+func (h *autoMerger) handleStatusEvent(ctx context.Context, event *github.StatusEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
 
 	if strings.ToLower(event.GetState()) != statusEventSuccessState {
 		logger.Debug("skipping status event as it dosn't report success: ", zap.String("state", event.GetState()))
 		return nil
 	}
 
+	metrics.Add(mergeEventsReceived, 1)
 	commitSHA := event.GetSHA()
-	query := fmt.Sprintf("type:pr state:open repo:%s %s", event.Repo.GetFullName(), commitSHA)
-	searchResult, _, err := gh.Search.Issues(context.Background(), query, nil)
+	return debounce(ctx, event.Repo.GetFullName()+"@"+commitSHA, debounceWindow(config), logger, func(ctx context.Context) error {
+		return mergeStatusPRs(ctx, event.Repo, commitSHA, gh, gql, config, logger)
+	})
+}
+
+func mergeStatusPRs(ctx context.Context, repo *github.Repository, commitSHA string, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	prs, err := searchPullRequestsBySHA(ctx, gh, repo, commitSHA)
 	if err != nil {
-		return errors.Wrap(err, "failed to search for open issues")
+		return err
 	}
+	cache := newEvaluationCache()
 	var multiErr error
-	for _, issue := range searchResult.Issues {
-		if issue.PullRequestLinks == nil {
-			continue
-		}
-
-		pr, _, err := gh.PullRequests.Get(context.Background(), event.Repo.Owner.GetLogin(), event.Repo.GetName(), issue.GetNumber())
+	for _, issue := range prs {
+		pr, _, err := gh.PullRequests.Get(ctx, repo.Owner.GetLogin(), repo.GetName(), issue.GetNumber())
 		if err != nil {
 			multiErr = multierr.Combine(multiErr, err)
 			continue
 		}
 
-		err = mergePR(&issue, pr, event.Repo.Owner.GetLogin(), event.Repo.GetName(), gh, commitSHA, config, logger)
+		_, err = mergePR(ctx, &issue, pr, repo.Owner.GetLogin(), repo.GetName(), gh, gql, commitSHA, cache, config, logger)
 		if err != nil {
 			multiErr = multierr.Combine(multiErr, err)
 			continue
@@ -113,77 +160,409 @@ func (h *autoMerger) handleStatusEvent(event *github.StatusEvent, gh *github.Cli
 	return multiErr
 }
 
-func (h *autoMerger) mergePRFromPullRequestEvent(installationID int64, repo *github.Repository, pullRequest *github.PullRequest, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
-	issue, _, err := gh.Issues.Get(context.Background(), repo.Owner.GetLogin(), repo.GetName(), pullRequest.GetNumber())
+func (h *autoMerger) mergePRFromPullRequestEvent(ctx context.Context, installationID int64, repo *github.Repository, pullRequest *github.PullRequest, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	issue, _, err := gh.Issues.Get(ctx, repo.Owner.GetLogin(), repo.GetName(), pullRequest.GetNumber())
 	if err != nil {
 		return errors.Wrapf(err, "failed to get pull request %s", pullRequest.GetHTMLURL())
 	}
 
-	return mergePR(issue, pullRequest, repo.Owner.GetLogin(), repo.GetName(), gh, "", config, logger)
+	_, err = mergePR(ctx, issue, pullRequest, repo.Owner.GetLogin(), repo.GetName(), gh, gql, "", newEvaluationCache(), config, logger)
+	return err
 }
 
-func mergePR(issue *github.Issue, pr *github.PullRequest, owner, repository string, gh *github.Client, commitSHA string, config config.RepoConfig, logger *zap.Logger) error {
-	if !containsLabel(issue.Labels, config.Labels.Approved) {
-		return nil
+func mergePR(ctx context.Context, issue *github.Issue, pr *github.PullRequest, owner, repository string, gh *github.Client, gql *githubv4.Client, commitSHA string, cache *evaluationCache, config config.RepoConfig, logger *zap.Logger) (mergeResult, error) {
+	queue := mergeQueueFor(owner + "/" + repository)
+	waited, err := queue.acquire(ctx)
+	if err != nil {
+		return mergeResult{}, err
+	}
+	defer queue.release()
+
+	if waited {
+		// Other PRs might have been merged or relabeled while waiting
+		logger.Debug("Re-validating PR after waiting in merge queue", zap.Int("pr", issue.GetNumber()))
+		issue, _, err = gh.Issues.Get(ctx, owner, repository, issue.GetNumber())
+		if err != nil {
+			return mergeResult{}, errors.Wrapf(err, "failed to get pull request %s/%s#%d", owner, repository, pr.GetNumber())
+		}
+		pr, _, err = gh.PullRequests.Get(ctx, owner, repository, issue.GetNumber())
+		if err != nil {
+			return mergeResult{}, errors.Wrapf(err, "failed to get pull request %s", issue.GetHTMLURL())
+		}
+	}
+
+	result, err := evaluateAndMergePR(ctx, issue, pr, owner, repository, gh, gql, commitSHA, cache, config, logger)
+	if !isHeadChanged(err) {
+		return result, err
+	}
+
+	// The head moved while we were evaluating, so give the new head one more chance
+	logger.Debug("PR head changed before merge, re-evaluating", zap.Int("pr", issue.GetNumber()), zap.Error(err))
+	pr, _, err = gh.PullRequests.Get(ctx, owner, repository, issue.GetNumber())
+	if err != nil {
+		return mergeResult{}, errors.Wrapf(err, "failed to get pull request %s", issue.GetHTMLURL())
+	}
+	return evaluateAndMergePR(ctx, issue, pr, owner, repository, gh, gql, "", cache, config, logger)
+}
+
+// mergeResult is the outcome of evaluating a pull request for merging
+type mergeResult struct {
+	merged bool
+	// The pull request is evaluated again when the merge window opens
+	deferred bool
+	// Why the pull request isn't merged
+	reason string
+}
+
+func evaluateAndMergePR(ctx context.Context, issue *github.Issue, pr *github.PullRequest, owner, repository string, gh *github.Client, gql *githubv4.Client, commitSHA string, cache *evaluationCache, config config.RepoConfig, logger *zap.Logger) (mergeResult, error) {
+	metrics.Add(pullRequestsEvaluated, 1)
+	approvedLabel := config.Labels.Approved
+	if policy := authorMergePolicy(config, pr.User.GetLogin()); policy != nil {
+		if policy.Label != "" {
+			approvedLabel = policy.Label
+		}
+		if policy.NoLabel {
+			approvedLabel = ""
+		}
+		if policy.MergeMethod != "" {
+			config.MergeMethod = policy.MergeMethod
+		}
+		logger.Debug("applying author merge policy", zap.String("author", pr.User.GetLogin()), zap.String("label", approvedLabel), zap.String("method", config.MergeMethod))
+	}
+	if approvedLabel != "" && !containsLabel(issue.Labels, approvedLabel) && !mergeRequested(ctx) {
+		activity.forget(owner+"/"+repository, issue.GetNumber())
+		return mergeResult{reason: "not labeled " + approvedLabel}, nil
+	}
+	waiting := func(reason string) mergeResult {
+		activity.waiting(owner+"/"+repository, issue, reason)
+		return mergeResult{reason: reason}
+	}
+
+	if config.Labels.NeedsRebase != "" && containsLabel(issue.Labels, config.Labels.NeedsRebase) {
+		logger.Debug("not merging because PR conflicts with its base branch", zap.String("label", config.Labels.NeedsRebase), zap.Int("pr", issue.GetNumber()))
+		return waiting("conflicts with its base branch"), nil
+	}
+	if config.Labels.Hold != "" && containsLabel(issue.Labels, config.Labels.Hold) {
+		logger.Debug("don't merging because PR is on hold", zap.String("label", config.Labels.Hold), zap.Int("pr", issue.GetNumber()))
+		return waiting("on hold"), nil
+	}
+
+	// The approved label might be left over from before the PR was turned
+	// into a draft
+	if isDraft(pr) {
+		logger.Debug("don't merging because PR is a draft", zap.Int("pr", issue.GetNumber()))
+		return waiting("draft"), nil
+	}
+
+	// Also without the wip handler's status being required by the branch
+	// protection
+	if marker := titleMatchesWipExpression(config, pr.GetTitle()); marker != "" {
+		logger.Debug("don't merging because title marks PR as work in progress", zap.String("marker", marker), zap.Int("pr", issue.GetNumber()))
+		return waiting("title marked as work in progress"), nil
+	}
+	if descriptionIncomplete(pr, config) {
+		logger.Debug("don't merging because PR description is incomplete", zap.Int("pr", issue.GetNumber()))
+		return waiting("description incomplete"), nil
 	}
 
 	if commitSHA != "" && pr.Head.GetSHA() != commitSHA {
 		logger.Debug("Commit SHA is unequal PR Head SHA", zap.String("commitSHA", commitSHA), zap.String("prHeadSha", pr.Head.GetSHA()))
-		return nil
+		return mergeResult{reason: "head moved on from " + commitSHA}, nil
 	}
 	commitSHA = pr.Head.GetSHA()
 
-	statuses, _, err := gh.Repositories.GetCombinedStatus(context.Background(), owner, repository, commitSHA, nil)
+	statuses, err := cache.combinedStatus(ctx, gh, owner, repository, commitSHA)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get statuses of pull request %s", issue.GetHTMLURL())
+		return mergeResult{}, errors.Wrapf(err, "failed to get statuses of pull request %s", issue.GetHTMLURL())
 	}
 
 	prStatusMap := make(map[string]bool, len(statuses.Statuses))
+	// Reported states, for explaining why a PR isn't merged
+	prStateMap := make(map[string]string, len(statuses.Statuses))
 	for _, status := range statuses.Statuses {
 		logger.Debug("found PR status", zap.String("context", status.GetContext()), zap.String("state", status.GetState()))
 		prStatusMap[status.GetContext()] = status.GetState() == statusEventSuccessState
+		prStateMap[status.GetContext()] = status.GetState()
 	}
 
-	prChecks, _, err := gh.Checks.ListCheckRunsForRef(context.Background(), owner, repository, commitSHA, nil)
+	prChecks, err := cache.checkRuns(ctx, gh, owner, repository, commitSHA)
 	if err != nil {
-		return errors.Wrapf(err, "failed to retrieve all check for pull request %s", issue.GetHTMLURL())
+		return mergeResult{}, errors.Wrapf(err, "failed to retrieve all check for pull request %s", issue.GetHTMLURL())
 	}
 
 	for _, check := range prChecks.CheckRuns {
 
 		logger.Debug("found PR check", zap.String("name", *check.Name), zap.Any("conclusion", check.Conclusion), zap.String("ref", commitSHA))
-		prStatusMap[*check.Name] = check.Conclusion != nil && *check.Conclusion == checkEventSuccessConclusion
+		prStatusMap[*check.Name] = isPassingConclusion(check.GetConclusion(), config)
+		if check.Conclusion != nil {
+			prStateMap[*check.Name] = check.GetConclusion()
+		} else {
+			prStateMap[*check.Name] = check.GetStatus()
+		}
+	}
+
+	requiredContexts, err := cache.requiredContexts(ctx, gh, owner, repository, pr.Base.GetRef())
+	if err != nil {
+		return mergeResult{}, errors.Wrapf(err, "failed to get target branch protection for pull request %s", issue.GetHTMLURL())
+	}
+
+	for name := range prStatusMap {
+		if !gatesMerge(name, requiredContexts, config) {
+			logger.Debug("ignoring PR status/check", zap.String("context", name))
+			delete(prStatusMap, name)
+		}
+	}
 
+	expectedChecks := append(append([]string{}, config.ExpectedChecks...), config.RequiredChecks...)
+	if blocked := blockingContexts(prStatusMap, prStateMap, requiredContexts, expectedChecks); len(blocked) > 0 {
+		logger.Debug("don't merging because statuses/checks are missing or failed", zap.Any("contexts", blocked), zap.Int("pr", issue.GetNumber()))
+		result := waiting("statuses and checks: " + describeBlockedContexts(blocked))
+		if config.ExplainBlockedMerge {
+			return result, explainBlockedMerge(ctx, gh, owner, repository, issue, commitSHA, blocked)
+		}
+		return result, nil
 	}
 
-	requiredContexts, _, err := gh.Repositories.ListRequiredStatusChecksContexts(context.Background(), owner, repository, pr.Base.GetRef())
+	requiredApprovals, err := cache.requiredApprovals(ctx, gh, owner, repository, pr.Base.GetRef())
 	if err != nil {
-		if errResp, ok := err.(*github.ErrorResponse); !ok || errResp.Response.StatusCode != http.StatusNotFound {
-			return errors.Wrapf(err, "failed to get target branch (%s) protection for pull request %s", pr.Base.GetRef(), issue.GetHTMLURL())
+		return mergeResult{}, errors.Wrapf(err, "failed to get target branch protection for pull request %s", issue.GetHTMLURL())
+	}
+	if config.RequiredApprovals > requiredApprovals {
+		requiredApprovals = config.RequiredApprovals
+	}
+	if requiredApprovals > 0 {
+		approvals, changesRequested, err := countReviewVerdicts(ctx, gh, owner, repository, issue.GetNumber())
+		if err != nil {
+			return mergeResult{}, err
+		}
+		if approvals < requiredApprovals || changesRequested > 0 {
+			logger.Debug("don't merging because of missing approving reviews", zap.Int("approvals", approvals), zap.Int("changesRequested", changesRequested), zap.Int("required", requiredApprovals), zap.Int("pr", issue.GetNumber()))
+			return waiting(fmt.Sprintf("reviews: %d of %d approvals, %d requesting changes", approvals, requiredApprovals, changesRequested)), nil
 		}
 	}
 
-	if len(requiredContexts) == 0 {
-		for _, contextStatus := range prStatusMap {
-			if !contextStatus {
-				return nil
-			}
+	// The approved label may have been applied before further commits
+	// touched directories of other OWNERS
+	if config.OwnersApproval {
+		directories, err := ownersApprovalOf(ctx, gh, owner, repository, pr)
+		if err != nil {
+			return mergeResult{}, err
 		}
-	} else {
-		for _, requiredContext := range requiredContexts {
-			if success, present := prStatusMap[requiredContext]; !present || !success {
-				logger.Debug("don't merging because status/check failed", zap.String("context", requiredContext), zap.Bool("present", present), zap.Bool("success", success))
-				return nil
+		if unapproved := unapprovedDirectories(directories); len(unapproved) > 0 {
+			logger.Debug("don't merging because of missing OWNERS approvals", zap.Strings("directories", unapproved), zap.Int("pr", issue.GetNumber()))
+			return waiting("OWNERS approval missing for " + strings.Join(unapproved, ", ")), nil
+		}
+	}
+
+	if config.RequireResolvedConversations {
+		unresolved, err := unresolvedReviewThreads(ctx, gql, owner, repository, issue.GetNumber())
+		if err != nil {
+			return mergeResult{}, err
+		}
+		if len(unresolved) > 0 {
+			logger.Debug("don't merging because of unresolved review conversations", zap.Int("unresolved", len(unresolved)), zap.Strings("threads", describeReviewThreads(unresolved, 5)))
+			return waiting(fmt.Sprintf("%d unresolved review conversations", len(unresolved))), nil
+		}
+	}
+
+	if config.MergePolicy.URL != "" {
+		verdict, err := evaluateMergePolicy(ctx, config.MergePolicy, gh, owner, repository, issue, pr, statuses, prChecks)
+		if err != nil {
+			return mergeResult{}, err
+		}
+		if !verdict.Allow {
+			logger.Info("not merging because the merge policy denies it", zap.String("reason", verdict.Reason), zap.Int("pr", issue.GetNumber()))
+			result := waiting("merge policy: " + verdict.Reason)
+			if config.ExplainBlockedMerge {
+				return result, explainMergePolicyDenial(ctx, gh, owner, repository, issue, verdict.Reason)
 			}
+			return result, nil
 		}
 	}
 
-	_, _, err = gh.PullRequests.Merge(context.Background(), owner, repository, issue.GetNumber(), "", &github.PullRequestOptions{
-		SHA: commitSHA,
+	// Refetch, as the mergeable state of the event's PR might be outdated,
+	// e.g. because of a merge just before
+	pr, err = getMergeablePullRequest(ctx, gh, owner, repository, issue.GetNumber())
+	if err != nil {
+		return mergeResult{}, err
+	}
+	if pr.GetMergeableState() == behindMergeableState && config.AutoUpdateBranch {
+		// The update creates a new head commit, whose statuses trigger the
+		// merge again
+		logger.Info("Updating PR branch with its base branch before merging", zap.Int("pr", issue.GetNumber()), zap.String("base", pr.Base.GetRef()))
+		return waiting("branch update with " + pr.Base.GetRef()), updatePullRequestBranch(ctx, gh, owner, repository, pr)
+	}
+	if reason := mergeBlocker(pr); reason != "" {
+		logger.Debug("don't merging because "+reason, zap.String("mergeableState", pr.GetMergeableState()), zap.Int("pr", issue.GetNumber()))
+		return waiting(reason), nil
+	}
+
+	opening, err := mergeWindowOpening(config.MergeWindows, timeNow())
+	if err != nil {
+		return mergeResult{}, err
+	}
+	if !opening.IsZero() {
+		result := waiting("merge window opening " + opening.Format(time.RFC1123))
+		result.deferred = true
+		return result, deferMerge(ctx, issue, owner, repository, gh, gql, opening, config, logger)
+	}
+
+	commitTitle, commitMessage, err := renderMergeCommit(config.MergeCommit, pr)
+	if err != nil {
+		return mergeResult{}, errors.Wrapf(err, "failed to create merge commit message for pull request %s", issue.GetHTMLURL())
+	}
+
+	mergeCtx := audit.WithInputs(ctx, map[string]interface{}{
+		"label":    approvedLabel,
+		"sha":      commitSHA,
+		"contexts": prStatusMap,
+		"required": requiredContexts,
+		"method":   config.MergeMethod,
 	})
+	err = mergeWithRetry(mergeCtx, gh, owner, repository, issue, commitMessage, &github.PullRequestOptions{
+		CommitTitle: commitTitle,
+		SHA:         commitSHA,
+		MergeMethod: config.MergeMethod,
+	}, config.MergeAttempts, logger)
 	if err != nil {
-		return errors.Wrapf(err, "failed to merge pull request %s", issue.GetHTMLURL())
+		notifyMergeFailed(config, owner+"/"+repository, issue, err)
+		return mergeResult{}, err
 	}
+	mergesPerformed.Inc()
+	activity.mergedPR(owner+"/"+repository, issue)
+	notifyMerged(config, owner+"/"+repository, issue)
 	logger.Debug("Successfully merged " + owner + "/" + repository + ": " + strconv.Itoa(issue.GetNumber()))
+	return mergeResult{merged: true}, afterMerge(ctx, issue, pr, owner, repository, gh, approvedLabel, config, logger)
+}
+
+// countReviewVerdicts returns the number of reviewers whose latest review
+// approves a pull request and the number of those whose latest review
+// requests changes
+func countReviewVerdicts(ctx context.Context, gh *github.Client, owner, repository string, number int) (approvals int, changesRequested int, err error) {
+	latest, err := latestReviewVerdicts(ctx, gh, owner, repository, number)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, state := range latest {
+		switch state {
+		case approvedReviewState:
+			approvals++
+		case changesRequestedReviewState:
+			changesRequested++
+		}
+	}
+	return approvals, changesRequested, nil
+}
+
+// latestReviewVerdicts returns the state of the latest approving, change
+// requesting or dismissed review per reviewer
+func latestReviewVerdicts(ctx context.Context, gh *github.Client, owner, repository string, number int) (map[string]string, error) {
+	latest := make(map[string]string)
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := gh.PullRequests.ListReviews(ctx, owner, repository, number, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list reviews of pull request %s/%s#%d", owner, repository, number)
+		}
+		// Reviews are listed in chronological order, comments don't change
+		// the verdict of a reviewer
+		for _, review := range reviews {
+			switch state := strings.ToLower(review.GetState()); state {
+			case approvedReviewState, changesRequestedReviewState, "dismissed":
+				latest[review.User.GetLogin()] = state
+			}
+		}
+		if resp.NextPage == 0 {
+			return latest, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// isPassingConclusion returns true if a check run or suite conclusion allows
+// merging
+func isPassingConclusion(conclusion string, config config.RepoConfig) bool {
+	if len(config.PassingConclusions) == 0 {
+		return conclusion == checkEventSuccessConclusion
+	}
+	for _, passing := range config.PassingConclusions {
+		if strings.EqualFold(conclusion, passing) {
+			return true
+		}
+	}
+	return false
+}
+
+// gatesMerge returns false for reported statuses and checks which are
+// configured to not take part in the merge decision: ignored ones and, if
+// required checks are configured, all others. Contexts required by branch
+// protection always take part, as GitHub refuses the merge without them.
+func gatesMerge(name string, requiredContexts []string, config config.RepoConfig) bool {
+	if containsString(requiredContexts, name) {
+		return true
+	}
+	for _, ignored := range config.IgnoredChecks {
+		if name == ignored {
+			return false
+		}
+	}
+	if len(config.RequiredChecks) == 0 {
+		return true
+	}
+	for _, required := range config.RequiredChecks {
+		if name == required {
+			return true
+		}
+	}
+	return false
+}
+
+// authorMergePolicy returns the first merge policy configured for the author
+// of a pull request, nil if there is none
+func authorMergePolicy(cfg config.RepoConfig, author string) *config.AuthorMergePolicy {
+	for i, policy := range cfg.AuthorPolicies {
+		if containsIgnoreCase(policy.Authors, author) {
+			return &cfg.AuthorPolicies[i]
+		}
+	}
 	return nil
 }
+
+// blockedContext is a status or check which prevents merging
+type blockedContext struct {
+	Name string `json:"name"`
+	// Reported state or conclusion, "missing" if not reported at all
+	State string `json:"state"`
+}
+
+// blockingContexts returns the statuses and checks which prevent merging.
+// Without required contexts all reported ones must pass. Expected checks
+// must be present and passing in any case, as otherwise a PR labeled before
+// CI started would be merged untested.
+func blockingContexts(passing map[string]bool, states map[string]string, required []string, expected []string) []blockedContext {
+	var names []string
+	if len(required) == 0 {
+		for name := range passing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	} else {
+		names = append(names, required...)
+	}
+	names = append(names, expected...)
+
+	var blocked []blockedContext
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] || passing[name] {
+			continue
+		}
+		seen[name] = true
+		state, present := states[name]
+		if !present {
+			state = "missing"
+		}
+		blocked = append(blocked, blockedContext{name, state})
+	}
+	return blocked
+}
@@ -0,0 +1,835 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+var (
+	getIssue            = "GET " + fixtureRepo + "/issues/" + strconv.Itoa(fixturePR)
+	getPullRequest      = "GET " + fixtureRepo + "/pulls/" + strconv.Itoa(fixturePR)
+	getCombinedStatus   = "GET " + fixtureRepo + "/commits/" + fixtureSHA + "/status"
+	getCheckRuns        = "GET " + fixtureRepo + "/commits/" + fixtureSHA + "/check-runs"
+	getRequiredContexts = "GET " + fixtureRepo + "/branches/master/protection/required_status_checks/contexts"
+	getRequiredReviews  = "GET " + fixtureRepo + "/branches/master/protection/required_pull_request_reviews"
+	listComments        = "GET " + fixtureRepo + "/issues/" + strconv.Itoa(fixturePR) + "/comments"
+	createComment       = "POST " + fixtureRepo + "/issues/" + strconv.Itoa(fixturePR) + "/comments"
+	listPRReviews       = "GET " + fixtureRepo + "/pulls/" + strconv.Itoa(fixturePR) + "/reviews"
+	searchIssues        = "GET /search/issues"
+	mergePullRequest    = "PUT " + fixtureRepo + "/pulls/" + strconv.Itoa(fixturePR) + "/merge"
+	listCommitPulls     = "GET " + fixtureRepo + "/commits/" + fixtureSHA + "/pulls"
+	createStatus        = "POST " + fixtureRepo + "/statuses/" + fixtureSHA
+)
+
+var autoMergeConfig = config.RepoConfig{
+	Labels: config.LabelConfig{
+		Approved: "approved",
+	},
+	// Evaluate status events right away
+	DebounceWindow: -1,
+}
+
+var ownersMergeConfig = config.RepoConfig{
+	Labels:         autoMergeConfig.Labels,
+	OwnersApproval: true,
+	DebounceWindow: -1,
+}
+
+// ownersResponses describes a pull request changing the README, which only
+// rhuss may approve, approved by the given reviewer
+func ownersResponses(approver string) map[string]interface{} {
+	return map[string]interface{}{
+		"GET " + fixtureRepo + "/pulls/276/files":         []map[string]interface{}{{"filename": "README.md"}},
+		"GET " + fixtureRepo + "/contents/OWNERS_ALIASES": notFound,
+		"GET " + fixtureRepo + "/contents/OWNERS":         fileContentJSON("approvers:\n- rhuss\n"),
+		listPRReviews: []map[string]interface{}{
+			{"state": "APPROVED", "user": map[string]interface{}{"login": approver}, "submitted_at": "2017-04-05T08:00:00Z"},
+		},
+		listComments: []map[string]interface{}{},
+	}
+}
+
+// mergeableResponses describes an approved pull request with all statuses and
+// checks green on an unprotected branch. Changes override single responses.
+func mergeableResponses(changes map[string]interface{}) map[string]interface{} {
+	responses := map[string]interface{}{
+		getIssue:            issueJSON(fixturePR, "approved"),
+		getPullRequest:      pullRequestJSON(fixturePR, fixtureSHA, "clean"),
+		getCombinedStatus:   combinedStatusJSON(map[string]string{"default": "success"}),
+		getCheckRuns:        checkRunsJSON(map[string]string{"build": "success"}),
+		getRequiredContexts: notFound,
+		getRequiredReviews:  notFound,
+		searchIssues:        searchJSON(issueJSON(fixturePR, "approved")),
+	}
+	for call, response := range changes {
+		responses[call] = response
+	}
+	return responses
+}
+
+func TestAutoMerge(t *testing.T) {
+	defer func(backoff time.Duration) { mergeRetryBackoff = backoff }(mergeRetryBackoff)
+	mergeRetryBackoff = time.Millisecond
+	// A Wednesday
+	defer func(clock func() time.Time) { timeNow = clock }(timeNow)
+	timeNow = func() time.Time { return time.Date(2018, 3, 7, 10, 0, 0, 0, time.UTC) }
+	defer cancelScheduled("syndesisio/syndesis-rest#")
+
+	runScenarios(t, []scenario{
+		{
+			name:          "approving review without branch protection",
+			eventType:     "pull_request_review",
+			fixture:       "pull_request_review.json",
+			handler:       &autoMerger{},
+			config:        autoMergeConfig,
+			responses:     mergeableResponses(nil),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "approving review without approved label",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getIssue: issueJSON(fixturePR),
+			}),
+		},
+		{
+			name:      "approved PR on hold",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels: config.LabelConfig{
+					Approved: "approved",
+					Hold:     "do-not-merge/hold",
+				},
+			},
+			responses: map[string]interface{}{
+				getIssue: issueJSON(fixturePR, "approved", "do-not-merge/hold"),
+			},
+		},
+		{
+			name:      "required contexts green",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getCombinedStatus:   combinedStatusJSON(map[string]string{"default": "success", "coverage": "failure"}),
+				getRequiredContexts: []string{"default", "build"},
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "failing check on second page",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getCheckRuns: fakeSequence{
+					nextPage(checkRunsJSON(map[string]string{"build": "success"})),
+					checkRunsJSON(map[string]string{"e2e": "failure"}),
+				},
+				getCombinedStatus: fakeSequence{
+					nextPage(combinedStatusJSON(map[string]string{"default": "success"})),
+					combinedStatusJSON(map[string]string{"docs": "success"}),
+				},
+			}),
+		},
+		{
+			name:      "required context on second page",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getRequiredContexts: []string{"build", "docs"},
+				getCombinedStatus: fakeSequence{
+					nextPage(combinedStatusJSON(map[string]string{"default": "success"})),
+					combinedStatusJSON(map[string]string{"docs": "success"}),
+				},
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "required context missing",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getRequiredContexts: []string{"default", "e2e"},
+			}),
+		},
+		{
+			name:      "failing check without branch protection",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getCheckRuns: checkRunsJSON(map[string]string{"build": "failure"}),
+			}),
+		},
+		{
+			name:      "required approvals missing",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getRequiredReviews: map[string]interface{}{"required_approving_review_count": 2},
+				listPRReviews: []interface{}{
+					reviewJSON("alice", "APPROVED"),
+					reviewJSON("bob", "APPROVED"),
+					reviewJSON("bob", "CHANGES_REQUESTED"),
+					reviewJSON("carol", "COMMENTED"),
+				},
+			}),
+		},
+		{
+			name:      "required approvals present",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getRequiredReviews: map[string]interface{}{"required_approving_review_count": 2},
+				listPRReviews: []interface{}{
+					reviewJSON("alice", "APPROVED"),
+					reviewJSON("bob", "CHANGES_REQUESTED"),
+					reviewJSON("bob", "APPROVED"),
+					reviewJSON("carol", "COMMENTED"),
+				},
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "configured approvals with changes requested",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:            autoMergeConfig.Labels,
+				DebounceWindow:    autoMergeConfig.DebounceWindow,
+				RequiredApprovals: 1,
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				listPRReviews: []interface{}{
+					reviewJSON("alice", "APPROVED"),
+					reviewJSON("bob", "CHANGES_REQUESTED"),
+				},
+			}),
+		},
+		{
+			name:      "configured approvals present",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:            autoMergeConfig.Labels,
+				DebounceWindow:    autoMergeConfig.DebounceWindow,
+				RequiredApprovals: 1,
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				listPRReviews: []interface{}{
+					reviewJSON("alice", "APPROVED"),
+					reviewJSON("bob", "CHANGES_REQUESTED"),
+					reviewJSON("bob", "DISMISSED"),
+				},
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "outside of merge windows",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				MergeWindows:   []string{"Sat-Sun 00:00-24:00"},
+			},
+			responses: mergeableResponses(nil),
+		},
+		{
+			name:      "within merge windows",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				MergeWindows:   []string{"Sat-Sun 00:00-24:00", "Mon-Fri 08:00-18:00"},
+			},
+			responses:     mergeableResponses(nil),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "author policy without label",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				AuthorPolicies: []config.AuthorMergePolicy{
+					{Authors: []string{"kurtstam"}, NoLabel: true, MergeMethod: "squash"},
+				},
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				getIssue: issueJSON(fixturePR),
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "author policy with other label",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				AuthorPolicies: []config.AuthorMergePolicy{
+					{Authors: []string{"dependabot[bot]"}, NoLabel: true},
+					{Authors: []string{"KurtStam"}, Label: "dependencies"},
+				},
+			},
+			responses: mergeableResponses(nil),
+		},
+		{
+			name:      "expected check not yet reported",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				ExpectedChecks: []string{"build", "e2e"},
+			},
+			responses: mergeableResponses(nil),
+		},
+		{
+			name:      "expected checks passing",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				ExpectedChecks: []string{"build", "default"},
+			},
+			responses:     mergeableResponses(nil),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "ignored check failing",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				IgnoredChecks:  []string{"coverage"},
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				getCheckRuns: checkRunsJSON(map[string]string{"build": "success", "coverage": "failure"}),
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "only required checks gate merging",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				RequiredChecks: []string{"build"},
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				getCombinedStatus: combinedStatusJSON(map[string]string{"nightly": "failure"}),
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "required check missing",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				RequiredChecks: []string{"build", "e2e"},
+			},
+			responses: mergeableResponses(nil),
+		},
+		{
+			name:      "ignored check required by branch protection",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				IgnoredChecks:  []string{"coverage"},
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				getCheckRuns:        checkRunsJSON(map[string]string{"build": "success", "coverage": "success"}),
+				getRequiredContexts: []string{"build", "coverage"},
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "branch protection context failing besides required checks",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				RequiredChecks: []string{"build"},
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				getCombinedStatus:   combinedStatusJSON(map[string]string{"default": "failure"}),
+				getRequiredContexts: []string{"build", "default"},
+			}),
+		},
+		{
+			name:      "branch protection context passing besides required checks",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				RequiredChecks: []string{"build"},
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				getCombinedStatus:   combinedStatusJSON(map[string]string{"default": "success"}),
+				getRequiredContexts: []string{"build", "default"},
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "blocked merge explained",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:              autoMergeConfig.Labels,
+				DebounceWindow:      autoMergeConfig.DebounceWindow,
+				ExplainBlockedMerge: true,
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				getCheckRuns: checkRunsJSON(map[string]string{"build": "failure"}),
+				listComments: []interface{}{map[string]interface{}{"id": 1, "body": "LGTM"}},
+			}),
+			expectedCalls: []string{createComment},
+		},
+		{
+			name:      "blocked merge explanation updated",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:              autoMergeConfig.Labels,
+				DebounceWindow:      autoMergeConfig.DebounceWindow,
+				ExplainBlockedMerge: true,
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				getCheckRuns: checkRunsJSON(map[string]string{"build": "failure"}),
+				listComments: []interface{}{map[string]interface{}{"id": 7, "body": blockedMergeMarker + "\nOutdated"}},
+			}),
+			expectedCalls: []string{"PATCH " + fixtureRepo + "/issues/comments/7"},
+		},
+		{
+			name:      "neutral check",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getCheckRuns: checkRunsJSON(map[string]string{"build": "success", "lint": "neutral"}),
+			}),
+		},
+		{
+			name:      "neutral and skipped checks passing",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:             autoMergeConfig.Labels,
+				DebounceWindow:     autoMergeConfig.DebounceWindow,
+				PassingConclusions: []string{"success", "neutral", "skipped"},
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				getCheckRuns: checkRunsJSON(map[string]string{"build": "success", "lint": "neutral", "e2e": "skipped"}),
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "branch behind base",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getPullRequest: pullRequestJSON(fixturePR, fixtureSHA, "behind"),
+			}),
+		},
+		{
+			name:      "branch behind base with automatic update",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:           autoMergeConfig.Labels,
+				AutoUpdateBranch: true,
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				getPullRequest: pullRequestJSON(fixturePR, fixtureSHA, "behind"),
+			}),
+			expectedCalls: []string{"PUT " + fixtureRepo + "/pulls/" + strconv.Itoa(fixturePR) + "/update-branch"},
+		},
+		{
+			name:      "merge retried after refusal",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				mergePullRequest: fakeSequence{
+					fakeResponse{status: http.StatusMethodNotAllowed, body: map[string]string{"message": "Base branch was modified. Review and try the merge again."}},
+					map[string]interface{}{"merged": true},
+				},
+			}),
+			expectedCalls: []string{mergePullRequest, mergePullRequest},
+		},
+		{
+			name:      "post-merge actions",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				DebounceWindow: autoMergeConfig.DebounceWindow,
+				PostMerge: config.PostMergeConfig{
+					RemoveApprovedLabel: true,
+					Label:               "merged",
+					AssignMilestone:     true,
+					Comment:             "Thanks @{{.Author}}!",
+				},
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				"GET " + fixtureRepo + "/milestones": []interface{}{
+					map[string]interface{}{"number": 3, "title": "1.4", "due_on": "2017-06-01T00:00:00Z"},
+					map[string]interface{}{"number": 2, "title": "1.3", "due_on": "2017-05-01T00:00:00Z"},
+					map[string]interface{}{"number": 4, "title": "Backlog"},
+				},
+			}),
+			expectedCalls: []string{
+				mergePullRequest,
+				"DELETE " + fixtureRepo + "/issues/" + strconv.Itoa(fixturePR) + "/labels/approved",
+				"POST " + fixtureRepo + "/issues/" + strconv.Itoa(fixturePR) + "/labels",
+				"PATCH " + fixtureRepo + "/issues/" + strconv.Itoa(fixturePR),
+				createComment,
+			},
+		},
+		{
+			name:          "approved label added",
+			eventType:     "pull_request",
+			fixture:       "pull_request_labeled.json",
+			handler:       &autoMerger{},
+			config:        autoMergeConfig,
+			responses:     mergeableResponses(nil),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:          "draft marked ready for review",
+			eventType:     "pull_request",
+			fixture:       "pull_request_ready_for_review.json",
+			handler:       &autoMerger{},
+			config:        autoMergeConfig,
+			responses:     mergeableResponses(nil),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "successful status of draft",
+			eventType: "status",
+			fixture:   "status.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getPullRequest: draft(pullRequestJSON(fixturePR, fixtureSHA, "clean")),
+			}),
+		},
+		{
+			name:      "successful status of WIP",
+			eventType: "status",
+			fixture:   "status.json",
+			handler:   &autoMerger{},
+			config:    config.RepoConfig{Labels: autoMergeConfig.Labels, WipPatterns: []string{"wip"}, DebounceWindow: -1},
+			responses: mergeableResponses(map[string]interface{}{
+				getPullRequest: titled(pullRequestJSON(fixturePR, fixtureSHA, "clean"), "[WIP] Add size labels"),
+			}),
+		},
+		{
+			name:      "successful status with incomplete description",
+			eventType: "status",
+			fixture:   "status.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:         autoMergeConfig.Labels,
+				Description:    config.DescriptionConfig{RequiredCheckboxes: []string{"Tests added"}},
+				DebounceWindow: -1,
+			},
+			responses: mergeableResponses(nil),
+		},
+		{
+			name:      "successful status without OWNERS approval",
+			eventType: "status",
+			fixture:   "status.json",
+			handler:   &autoMerger{},
+			config:    ownersMergeConfig,
+			responses: mergeableResponses(ownersResponses("jimmidyson")),
+		},
+		{
+			name:          "successful status with OWNERS approval",
+			eventType:     "status",
+			fixture:       "status.json",
+			handler:       &autoMerger{},
+			config:        ownersMergeConfig,
+			responses:     mergeableResponses(ownersResponses("rhuss")),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "native auto-merge enabled by label",
+			eventType: "pull_request",
+			fixture:   "pull_request_labeled.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:        autoMergeConfig.Labels,
+				MergeStrategy: "native",
+			},
+			responses: map[string]interface{}{
+				"POST /graphql": map[string]interface{}{"data": map[string]interface{}{"enablePullRequestAutoMerge": map[string]interface{}{}}},
+			},
+			expectedCalls: []string{"POST /graphql"},
+		},
+		{
+			name:      "unresolved review conversation",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:                       autoMergeConfig.Labels,
+				DebounceWindow:               autoMergeConfig.DebounceWindow,
+				RequireResolvedConversations: true,
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				"POST /graphql": reviewThreadsJSON(reviewThreadJSON(false, "pure-bot[bot]"), reviewThreadJSON(false, "rhuss")),
+			}),
+			expectedCalls: []string{"POST /graphql"},
+		},
+		{
+			name:      "review conversations resolved",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:                       autoMergeConfig.Labels,
+				DebounceWindow:               autoMergeConfig.DebounceWindow,
+				RequireResolvedConversations: true,
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				"POST /graphql": reviewThreadsJSON(reviewThreadJSON(false, "pure-bot[bot]"), reviewThreadJSON(true, "rhuss")),
+			}),
+			expectedCalls: []string{"POST /graphql", mergePullRequest},
+		},
+		{
+			name:      "native auto-merge ignores reviews",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:        autoMergeConfig.Labels,
+				MergeStrategy: "native",
+			},
+		},
+		{
+			name:          "successful status",
+			eventType:     "status",
+			fixture:       "status.json",
+			handler:       &autoMerger{},
+			config:        autoMergeConfig,
+			responses:     mergeableResponses(nil),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "successful status for outdated commit",
+			eventType: "status",
+			fixture:   "status.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getPullRequest: pullRequestJSON(fixturePR, "9e6f9b6d5a1c2a3b4c5d6e7f8a9b0c1d2e3f4a5b", "clean"),
+			}),
+		},
+		{
+			name:          "successful check suite",
+			eventType:     "check_suite",
+			fixture:       "check_suite_completed.json",
+			handler:       &autoMerger{},
+			config:        autoMergeConfig,
+			responses:     mergeableResponses(nil),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "completed workflow run",
+			eventType: "workflow_run",
+			fixture:   "workflow_run_completed.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				listCommitPulls: []interface{}{pullRequestJSON(fixturePR, fixtureSHA, "clean")},
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:      "completed workflow run mirrored as status",
+			eventType: "workflow_run",
+			fixture:   "workflow_run_completed.json",
+			handler:   &autoMerger{},
+			config: config.RepoConfig{
+				Labels:               autoMergeConfig.Labels,
+				DebounceWindow:       autoMergeConfig.DebounceWindow,
+				MirrorWorkflowStatus: true,
+			},
+			responses: mergeableResponses(map[string]interface{}{
+				listCommitPulls: []interface{}{pullRequestJSON(fixturePR, fixtureSHA, "clean")},
+			}),
+			expectedCalls: []string{createStatus, mergePullRequest},
+		},
+		{
+			name:      "failed check run",
+			eventType: "check_run",
+			fixture:   "check_run_completed.json",
+			handler:   &autoMerger{},
+			config:    autoMergeConfig,
+		},
+	})
+}
+
+func TestAutoMergeBaseChanged(t *testing.T) {
+	defer func(a *mergeActivity) { activity = a }(activity)
+	activity = newMergeActivity()
+	// A Wednesday
+	defer func(clock func() time.Time) { timeNow = clock }(timeNow)
+	timeNow = func() time.Time { return time.Date(2018, 3, 7, 10, 0, 0, 0, time.UTC) }
+
+	releaseContexts := "GET " + fixtureRepo + "/branches/release-1.0/protection/required_status_checks/contexts"
+	releaseReviews := "GET " + fixtureRepo + "/branches/release-1.0/protection/required_pull_request_reviews"
+	edited := func(changes *github.EditChange, base string) *github.PullRequestEvent {
+		event := pullRequestEvent("edited")
+		event.Changes = changes
+		event.Repo.FullName = github.String("syndesisio/syndesis-rest")
+		event.PullRequest.Base = &github.PullRequestBranch{Ref: github.String(base)}
+		return event
+	}
+	retargeted := &github.EditChange{}
+	missingE2E := "statuses and checks: ci/e2e (missing)"
+
+	// Runs in sequence, sharing the merge activity
+	tests := []struct {
+		name          string
+		event         *github.PullRequestEvent
+		responses     map[string]interface{}
+		expectFailure bool
+		expectedCalls []string
+		waitingOn     string
+	}{
+		{
+			name:  "retargeted to a protected base",
+			event: edited(retargeted, "master"),
+			responses: mergeableResponses(map[string]interface{}{
+				getRequiredContexts: []string{"ci/e2e"},
+			}),
+			waitingOn: missingE2E,
+		},
+		{
+			name: "title edited",
+			event: edited(&github.EditChange{Title: &struct {
+				From *string `json:"from,omitempty"`
+			}{From: github.String("WIP")}}, "master"),
+			waitingOn: missingE2E,
+		},
+		{
+			name:  "retargeted, evaluation failed",
+			event: edited(retargeted, "release-1.0"),
+			responses: mergeableResponses(map[string]interface{}{
+				getCombinedStatus: fakeResponse{status: http.StatusInternalServerError, body: map[string]string{"message": "Server Error"}},
+			}),
+			expectFailure: true,
+		},
+		{
+			name:  "retargeted to an unprotected base",
+			event: edited(retargeted, "release-1.0"),
+			responses: mergeableResponses(map[string]interface{}{
+				releaseContexts: notFound,
+				releaseReviews:  notFound,
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			err := (&autoMerger{}).HandleEvent(context.Background(), test.event, fake.client(), fake.graphQL(), autoMergeConfig, zap.NewNop())
+			if failed := err != nil; failed != test.expectFailure {
+				t.Fatalf("expected failure %v, got %+v", test.expectFailure, err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if waitingOn := activity.waitingOn("syndesisio/syndesis-rest", fixturePR); waitingOn != test.waitingOn {
+				t.Errorf("expected to wait on %q, got %q", test.waitingOn, waitingOn)
+			}
+		})
+	}
+}
+
+// reviewThreadsJSON is the GraphQL response listing the review threads of a
+// pull request, as seen by the bot
+func reviewThreadsJSON(threads ...map[string]interface{}) map[string]interface{} {
+	return graphQLData(map[string]interface{}{
+		"viewer": map[string]interface{}{"login": "pure-bot[bot]"},
+		"repository": map[string]interface{}{"pullRequest": map[string]interface{}{"reviewThreads": map[string]interface{}{
+			"pageInfo": map[string]interface{}{"hasNextPage": false, "endCursor": nil},
+			"nodes":    threads,
+		}}},
+	})
+}
+
+func reviewThreadJSON(resolved bool, starter string) map[string]interface{} {
+	return map[string]interface{}{
+		"isResolved": resolved,
+		"path":       "pkg/webhook/auto_merge.go",
+		"line":       nil,
+		"comments":   map[string]interface{}{"nodes": []interface{}{map[string]interface{}{"author": map[string]interface{}{"login": starter}}}},
+	}
+}
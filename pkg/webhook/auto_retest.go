@@ -0,0 +1,192 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	// How long retry counters are remembered
+	retestCounterExpiry = 24 * time.Hour
+
+	// Preview media type of the checks API
+	antiopePreviewAcceptHeader = "application/vnd.github.antiope-preview+json"
+)
+
+type autoRetest struct{}
+
+type retestCounter struct {
+	count   int
+	updated time.Time
+}
+
+var (
+	retestCountersMu sync.Mutex
+	// retries per repository and head SHA
+	retestCounters = make(map[string]*retestCounter)
+)
+
+func (h *autoRetest) EventTypesHandled() []string {
+	return []string{"check_run"}
+}
+
+func (h *autoRetest) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	event, ok := eventObject.(*github.CheckRunEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+
+	retestConfig := config.AutoRetest
+	if len(retestConfig.Checks) == 0 || config.Labels.Approved == "" {
+		return nil
+	}
+
+	checkRun := event.CheckRun
+	conclusion := strings.ToLower(checkRun.GetConclusion())
+	if strings.ToLower(event.GetAction()) != "completed" || (conclusion != "failure" && conclusion != "timed_out") {
+		return nil
+	}
+
+	if !checkNameMatches(retestConfig.Checks, checkRun.GetName()) {
+		logger.Debug("Check not configured for automatic retest", zap.String("check", checkRun.GetName()))
+		return nil
+	}
+
+	prs, err := searchPullRequestsBySHA(ctx, gh, event.Repo, checkRun.GetHeadSHA())
+	if err != nil {
+		return err
+	}
+
+	var approved []github.Issue
+	for _, issue := range prs {
+		if containsLabel(issue.Labels, config.Labels.Approved) {
+			approved = append(approved, issue)
+		}
+	}
+	if len(approved) == 0 {
+		return nil
+	}
+	return retest(ctx, event, approved, gh, retestConfig, logger)
+}
+
+// retest re-runs the check suite of a failed check run once for all pull
+// requests of its commit, or labels them as flaky when the retries of the
+// commit are used up
+func retest(ctx context.Context, event *github.CheckRunEvent, prs []github.Issue, gh *github.Client, cfg config.AutoRetestConfig, logger *zap.Logger) error {
+	owner, repo, checkRun := event.Repo.Owner.GetLogin(), event.Repo.GetName(), event.CheckRun
+	key := event.Repo.GetFullName() + "@" + checkRun.GetHeadSHA()
+
+	var multiErr error
+	if retestCount(key) >= cfg.MaxRetries {
+		logger.Debug("Maximum number of retests reached", zap.String("sha", checkRun.GetHeadSHA()), zap.String("check", checkRun.GetName()))
+		if cfg.FlakeLabel == "" {
+			return nil
+		}
+		for _, issue := range prs {
+			if containsLabel(issue.Labels, cfg.FlakeLabel) {
+				continue
+			}
+			if _, _, err := gh.Issues.AddLabelsToIssue(ctx, owner, repo, issue.GetNumber(), []string{cfg.FlakeLabel}); err != nil {
+				multiErr = multierr.Combine(multiErr, errors.Wrapf(err, "failed to add label '%s' to PR %s", cfg.FlakeLabel, issue.GetHTMLURL()))
+			}
+		}
+		return multiErr
+	}
+
+	if err := rerequestCheckSuite(ctx, gh, owner, repo, checkRun.CheckSuite.GetID()); err != nil {
+		return errors.Wrapf(err, "failed to re-run check %s of commit %s", checkRun.GetName(), checkRun.GetHeadSHA())
+	}
+	retries := incrementRetestCounter(key)
+
+	message := fmt.Sprintf("Check _%s_ returned **%s**, re-running it (retry %d of %d).", checkRun.GetName(), checkRun.GetConclusion(), retries, cfg.MaxRetries)
+	for _, issue := range prs {
+		_, _, err := gh.Issues.CreateComment(ctx, owner, repo, issue.GetNumber(), &github.IssueComment{
+			Body: &message,
+		})
+		if err != nil {
+			multiErr = multierr.Combine(multiErr, errors.Wrapf(err, "failed to create comment on PR %s", issue.GetHTMLURL()))
+		}
+	}
+	return multiErr
+}
+
+// rerequestCheckSuite triggers a new run of all checks of a check suite
+func rerequestCheckSuite(ctx context.Context, gh *github.Client, owner, repository string, id int64) error {
+	req, err := gh.NewRequest("POST", fmt.Sprintf("repos/%s/%s/check-suites/%d/rerequest", owner, repository, id), nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Accept", antiopePreviewAcceptHeader)
+
+	_, err = gh.Do(ctx, req, nil)
+	return err
+}
+
+func checkNameMatches(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if regexp.MustCompile(`(?i)^(?:` + pattern + `)$`).MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// retestCount returns the number of retries counted for the given key
+func retestCount(key string) int {
+	retestCountersMu.Lock()
+	defer retestCountersMu.Unlock()
+
+	counter, found := retestCounters[key]
+	if !found || time.Since(counter.updated) > retestCounterExpiry {
+		return 0
+	}
+	return counter.count
+}
+
+// incrementRetestCounter counts a retry for the given key and returns the
+// new count
+func incrementRetestCounter(key string) int {
+	retestCountersMu.Lock()
+	defer retestCountersMu.Unlock()
+
+	now := time.Now()
+	for k, c := range retestCounters {
+		if now.Sub(c.updated) > retestCounterExpiry {
+			delete(retestCounters, k)
+		}
+	}
+
+	counter, found := retestCounters[key]
+	if !found {
+		counter = &retestCounter{}
+		retestCounters[key] = counter
+	}
+	counter.count++
+	counter.updated = now
+	return counter.count
+}
@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+var (
+	rerequestCheckSuiteCall = "POST " + fixtureRepo + "/check-suites/5/rerequest"
+	retestKey               = "syndesisio/syndesis-rest@" + fixtureSHA
+)
+
+var retestConfig = config.RepoConfig{
+	Labels: config.LabelConfig{
+		Approved: "approved",
+	},
+	AutoRetest: config.AutoRetestConfig{
+		Checks:     []string{"integration-.*"},
+		MaxRetries: 2,
+		FlakeLabel: "ci-flake",
+	},
+}
+
+func TestAutoRetest(t *testing.T) {
+	defer func(counters map[string]*retestCounter) { retestCounters = counters }(retestCounters)
+	retestCounters = make(map[string]*retestCounter)
+
+	createComment := func(number int) string {
+		return "POST " + fixtureRepo + "/issues/" + strconv.Itoa(number) + "/comments"
+	}
+	withoutFlakeLabel := retestConfig
+	withoutFlakeLabel.AutoRetest.FlakeLabel = ""
+
+	// Runs in sequence, sharing the retry counter of the commit
+	runScenarios(t, []scenario{
+		{
+			name:      "failed check of approved PRs",
+			eventType: "check_run",
+			fixture:   "check_run_completed.json",
+			handler:   &autoRetest{},
+			config:    retestConfig,
+			responses: map[string]interface{}{
+				searchIssues: searchJSON(issueJSON(fixturePR, "approved"), issueJSON(277, "approved"), issueJSON(278)),
+			},
+			expectedCalls: []string{rerequestCheckSuiteCall, createComment(fixturePR), createComment(277)},
+		},
+		{
+			name:      "failed check of unapproved PR",
+			eventType: "check_run",
+			fixture:   "check_run_completed.json",
+			handler:   &autoRetest{},
+			config:    retestConfig,
+			responses: map[string]interface{}{
+				searchIssues: searchJSON(issueJSON(fixturePR)),
+			},
+		},
+		{
+			name:      "last retry",
+			eventType: "check_run",
+			fixture:   "check_run_completed.json",
+			handler:   &autoRetest{},
+			config:    retestConfig,
+			responses: map[string]interface{}{
+				searchIssues: searchJSON(issueJSON(fixturePR, "approved")),
+			},
+			expectedCalls: []string{rerequestCheckSuiteCall, createComment(fixturePR)},
+		},
+		{
+			name:      "retries used up",
+			eventType: "check_run",
+			fixture:   "check_run_completed.json",
+			handler:   &autoRetest{},
+			config:    retestConfig,
+			responses: map[string]interface{}{
+				searchIssues: searchJSON(issueJSON(fixturePR, "approved"), issueJSON(277, "approved", "ci-flake")),
+			},
+			expectedCalls: []string{"POST " + fixtureRepo + "/issues/" + strconv.Itoa(fixturePR) + "/labels"},
+		},
+		{
+			name:      "retries used up without flake label",
+			eventType: "check_run",
+			fixture:   "check_run_completed.json",
+			handler:   &autoRetest{},
+			config:    withoutFlakeLabel,
+			responses: map[string]interface{}{
+				searchIssues: searchJSON(issueJSON(fixturePR, "approved")),
+			},
+		},
+	})
+
+	if count := retestCount(retestKey); count != 2 {
+		t.Errorf("expected 2 retries, got %d", count)
+	}
+}
+
+func TestAutoRetestFailedRerequest(t *testing.T) {
+	defer func(counters map[string]*retestCounter) { retestCounters = counters }(retestCounters)
+	retestCounters = make(map[string]*retestCounter)
+
+	fake := newFakeGitHub(t, map[string]interface{}{
+		searchIssues:            searchJSON(issueJSON(fixturePR, "approved")),
+		rerequestCheckSuiteCall: fakeResponse{status: http.StatusInternalServerError, body: map[string]string{"message": "Server Error"}},
+	})
+	defer fake.close()
+
+	payload, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "check_run_completed.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := parseWebHook("check_run", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&autoRetest{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), retestConfig, zap.NewNop()); err == nil {
+		t.Error("expected the failed re-run to be reported")
+	}
+	if count := retestCount(retestKey); count != 0 {
+		t.Errorf("expected no retry to be counted, got %d", count)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const defaultBackportLabelPrefix = "backport/"
+
+var backportCommand = Command{
+	Help:            "Backports the pull request to the given branches, once merged",
+	Usage:           "<branch> ...",
+	PullRequestOnly: true,
+	Run:             runBackport,
+}
+
+// backportLabels backports merged pull requests to the branches named by
+// their backport labels
+type backportLabels struct{}
+
+func (h *backportLabels) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *backportLabels) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	pr := event.PullRequest
+	if !pr.GetMerged() {
+		return nil
+	}
+	prefix := backportLabelPrefix(config)
+
+	var targets []string
+	switch strings.ToLower(event.GetAction()) {
+	case "closed":
+		for _, label := range pr.Labels {
+			if target := strings.TrimPrefix(label.GetName(), prefix); target != label.GetName() {
+				targets = append(targets, target)
+			}
+		}
+	case "labeled":
+		if target := strings.TrimPrefix(event.Label.GetName(), prefix); target != event.Label.GetName() {
+			targets = append(targets, target)
+		}
+	}
+
+	var multiErr error
+	for _, target := range targets {
+		multiErr = multierr.Combine(multiErr, backport(ctx, gh, event.Repo.Owner.GetLogin(), event.Repo.GetName(), pr, target, config, logger))
+	}
+	return multiErr
+}
+
+// runBackport backports merged pull requests right away and labels open ones
+// for being backported once merged
+func runBackport(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if len(cmd.Args) == 0 {
+		return cmd.Reply(ctx, gh, "please name the branches to backport to, like `/backport release-1.4`.")
+	}
+	owner, repository := cmd.Owner(), cmd.Repo()
+
+	var rejected []string
+	for _, target := range cmd.Args {
+		if !backportAllowed(config, target) {
+			rejected = append(rejected, target)
+		}
+	}
+	if len(rejected) > 0 {
+		return cmd.Reply(ctx, gh, fmt.Sprintf("backporting to %s is not allowed in this repository.", strings.Join(rejected, ", ")))
+	}
+
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repository, cmd.Number())
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pull request %s", cmd.Event.Issue.GetHTMLURL())
+	}
+	if pr.GetMerged() {
+		var multiErr error
+		for _, target := range cmd.Args {
+			multiErr = multierr.Combine(multiErr, backport(ctx, gh, owner, repository, pr, target, config, logger))
+		}
+		return multiErr
+	}
+	if pr.GetState() != "open" {
+		return cmd.Reply(ctx, gh, "only merged pull requests can be backported.")
+	}
+
+	var labels []string
+	for _, target := range cmd.Args {
+		if label := backportLabelPrefix(config) + target; !containsLabel(cmd.Event.Issue.Labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	_, _, err = gh.Issues.AddLabelsToIssue(ctx, owner, repository, cmd.Number(), labels)
+	return errors.Wrapf(err, "failed to add labels %v to %s", labels, cmd.Event.Issue.GetHTMLURL())
+}
+
+func backportLabelPrefix(config config.RepoConfig) string {
+	if config.Backport.LabelPrefix != "" {
+		return config.Backport.LabelPrefix
+	}
+	return defaultBackportLabelPrefix
+}
+
+func backportAllowed(config config.RepoConfig, target string) bool {
+	return len(config.Backport.Branches) == 0 || checkNameMatches(config.Backport.Branches, target)
+}
+
+// backport cherry-picks the commits of a merged pull request onto a new
+// branch based on the target branch and opens a pull request for it.
+// Conflicts and unknown branches are reported on the merged pull request.
+func backport(ctx context.Context, gh *github.Client, owner, repository string, pr *github.PullRequest, target string, config config.RepoConfig, logger *zap.Logger) error {
+	number := pr.GetNumber()
+	report := func(message string) error {
+		_, _, err := gh.Issues.CreateComment(ctx, owner, repository, number, &github.IssueComment{Body: github.String(message)})
+		return errors.Wrapf(err, "failed to comment on PR %s", pr.GetHTMLURL())
+	}
+	if !backportAllowed(config, target) {
+		return report(fmt.Sprintf("Backporting to `%s` is not allowed in this repository.", target))
+	}
+
+	targetRef, _, err := gh.Git.GetRef(ctx, owner, repository, "heads/"+target)
+	if err != nil {
+		if errResp, ok := err.(*github.ErrorResponse); ok && errResp.Response.StatusCode == http.StatusNotFound {
+			return report(fmt.Sprintf("Can't backport to `%s`, there is no such branch.", target))
+		}
+		return errors.Wrapf(err, "failed to get branch %s of %s/%s", target, owner, repository)
+	}
+	head, _, err := gh.Git.GetCommit(ctx, owner, repository, targetRef.Object.GetSHA())
+	if err != nil {
+		return errors.Wrapf(err, "failed to get head commit of %s in %s/%s", target, owner, repository)
+	}
+	picks, err := backportCommits(ctx, gh, owner, repository, pr, config)
+	if err != nil {
+		return err
+	}
+
+	branch := fmt.Sprintf("backport/%d-to-%s", number, target)
+	_, _, err = gh.Git.CreateRef(ctx, owner, repository, &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: head.SHA},
+	})
+	if err != nil {
+		if errResp, ok := err.(*github.ErrorResponse); ok && errResp.Response.StatusCode == http.StatusUnprocessableEntity {
+			logger.Debug("Backport branch exists already", zap.String("branch", branch), zap.Int("pr", number))
+			return nil
+		}
+		return errors.Wrapf(err, "failed to create branch %s in %s/%s", branch, owner, repository)
+	}
+
+	for _, pick := range picks {
+		head, err = cherryPick(ctx, gh, owner, repository, branch, head, pick)
+		if err == nil {
+			continue
+		}
+		// The branch would block a later attempt
+		if _, deleteErr := gh.Git.DeleteRef(ctx, owner, repository, "heads/"+branch); deleteErr != nil {
+			logger.Warn("Failed to delete backport branch", zap.String("branch", branch), zap.Error(deleteErr))
+		}
+		if err == errCherryPickConflict {
+			command := "git cherry-pick -x "
+			if len(pick.Parents) > 1 {
+				command += "-m 1 "
+			}
+			return report(fmt.Sprintf("Backporting to `%s` failed, commit %s doesn't apply cleanly. "+
+				"Please backport it by hand, e.g. with `%s%s` on a branch of `%s`.",
+				target, shortSHA(pick.GetSHA()), command, pick.GetSHA(), target))
+		}
+		return err
+	}
+
+	backportPR, _, err := gh.PullRequests.Create(ctx, owner, repository, &github.NewPullRequest{
+		Title: github.String(fmt.Sprintf("[%s] %s", target, pr.GetTitle())),
+		Head:  github.String(branch),
+		Base:  github.String(target),
+		Body:  github.String(fmt.Sprintf("Backport of #%d to `%s`.", number, target)),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to open backport of PR %s to %s", pr.GetHTMLURL(), target)
+	}
+	logger.Info("Backported pull request", zap.Int("pr", number), zap.String("target", target), zap.Int("backport", backportPR.GetNumber()))
+	return report(fmt.Sprintf("Backported to `%s` with #%d.", target, backportPR.GetNumber()))
+}
+
+// backportCommits returns the commits a merged pull request added to its
+// base branch: the merge or squashed commit, or the rebased commits when
+// the repository merges by rebasing
+func backportCommits(ctx context.Context, gh *github.Client, owner, repository string, pr *github.PullRequest, config config.RepoConfig) ([]*github.Commit, error) {
+	count := 1
+	if config.MergeMethod == "rebase" && pr.GetCommits() > 1 {
+		count = pr.GetCommits()
+	}
+	picks := make([]*github.Commit, count)
+	sha := pr.GetMergeCommitSHA()
+	for i := count - 1; i >= 0; i-- {
+		commit, _, err := gh.Git.GetCommit(ctx, owner, repository, sha)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get commit %s of %s/%s", sha, owner, repository)
+		}
+		if len(commit.Parents) == 0 {
+			return nil, errors.Errorf("commit %s of %s/%s has no parent", sha, owner, repository)
+		}
+		picks[i] = commit
+		sha = commit.Parents[0].GetSHA()
+	}
+	return picks, nil
+}
+
+var errCherryPickConflict = errors.New("cherry-pick conflicts")
+
+// cherryPick applies the changes of a commit relative to its first parent on
+// top of the branch head and returns the new head. As the API has no
+// cherry-pick, the branch is moved to a temporary commit with the branch
+// head's tree and the commit's parent, into which the commit gets merged.
+func cherryPick(ctx context.Context, gh *github.Client, owner, repository, branch string, head *github.Commit, pick *github.Commit) (*github.Commit, error) {
+	ref := &github.Reference{Ref: github.String("heads/" + branch)}
+	temporary, _, err := gh.Git.CreateCommit(ctx, owner, repository, &github.Commit{
+		Message: github.String("Temporary commit for cherry-picking " + pick.GetSHA()),
+		Tree:    head.Tree,
+		Parents: []github.Commit{pick.Parents[0]},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create temporary commit in %s/%s", owner, repository)
+	}
+	ref.Object = &github.GitObject{SHA: temporary.SHA}
+	if _, _, err := gh.Git.UpdateRef(ctx, owner, repository, ref, true); err != nil {
+		return nil, errors.Wrapf(err, "failed to update branch %s of %s/%s", branch, owner, repository)
+	}
+
+	merge, _, err := gh.Repositories.Merge(ctx, owner, repository, &github.RepositoryMergeRequest{
+		Base: github.String(branch),
+		Head: github.String(pick.GetSHA()),
+	})
+	if err != nil {
+		if errResp, ok := err.(*github.ErrorResponse); ok && errResp.Response.StatusCode == http.StatusConflict {
+			return nil, errCherryPickConflict
+		}
+		return nil, errors.Wrapf(err, "failed to merge %s into %s of %s/%s", pick.GetSHA(), branch, owner, repository)
+	}
+	if merge.Commit == nil || merge.Commit.Tree == nil {
+		return nil, errors.Errorf("merging %s into %s of %s/%s created no commit", pick.GetSHA(), branch, owner, repository)
+	}
+
+	picked, _, err := gh.Git.CreateCommit(ctx, owner, repository, &github.Commit{
+		Message: github.String(fmt.Sprintf("%s\n\n(cherry picked from commit %s)", strings.TrimRight(pick.GetMessage(), "\n"), pick.GetSHA())),
+		Author:  pick.Author,
+		Tree:    merge.Commit.Tree,
+		Parents: []github.Commit{{SHA: head.SHA}},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create commit in %s/%s", owner, repository)
+	}
+	ref.Object = &github.GitObject{SHA: picked.SHA}
+	if _, _, err := gh.Git.UpdateRef(ctx, owner, repository, ref, true); err != nil {
+		return nil, errors.Wrapf(err, "failed to update branch %s of %s/%s", branch, owner, repository)
+	}
+	return picked, nil
+}
@@ -0,0 +1,248 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const (
+	mergeSHA      = "9d4c1a0b6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b"
+	releaseSHA    = "1e2d3c4b5a69788796a5b4c3d2e1f0a9b8c7d6e5"
+	backportRef   = "/git/refs/heads/backport/276-to-release-1.4"
+	createRef     = "POST " + fixtureRepo + "/git/refs"
+	createCommit  = "POST " + fixtureRepo + "/git/commits"
+	updateRef     = "PATCH " + fixtureRepo + backportRef
+	mergeBranches = "POST " + fixtureRepo + "/merges"
+	createPull    = "POST " + fixtureRepo + "/pulls"
+)
+
+func gitCommitJSON(sha string, tree string, parents ...string) map[string]interface{} {
+	parentObjects := make([]map[string]interface{}, 0, len(parents))
+	for _, parent := range parents {
+		parentObjects = append(parentObjects, map[string]interface{}{"sha": parent})
+	}
+	return map[string]interface{}{
+		"sha":     sha,
+		"tree":    map[string]interface{}{"sha": tree},
+		"parents": parentObjects,
+		"message": "Fix label sync\n",
+		"author":  map[string]interface{}{"name": "Roland Huß", "email": "roland@jolokia.org"},
+	}
+}
+
+// backportResponses describes the release-1.4 branch and the squashed commit
+// of the fixture PR on master
+func backportResponses(changes map[string]interface{}) map[string]interface{} {
+	responses := map[string]interface{}{
+		"GET " + fixtureRepo + "/git/refs/heads/release-1.4": map[string]interface{}{"object": map[string]interface{}{"sha": releaseSHA}},
+		"GET " + fixtureRepo + "/git/commits/" + releaseSHA:  gitCommitJSON(releaseSHA, "release-tree"),
+		"GET " + fixtureRepo + "/git/commits/" + mergeSHA:    gitCommitJSON(mergeSHA, "merge-tree", "master-parent"),
+		createCommit:  fakeSequence{gitCommitJSON("temporary", "release-tree", "master-parent"), gitCommitJSON("picked", "picked-tree", releaseSHA)},
+		mergeBranches: map[string]interface{}{"sha": "merged", "commit": map[string]interface{}{"tree": map[string]interface{}{"sha": "picked-tree"}}},
+		createPull:    map[string]interface{}{"number": 301},
+	}
+	for call, response := range changes {
+		responses[call] = response
+	}
+	return responses
+}
+
+func mergedPullRequestEvent(action string, labels ...string) *github.PullRequestEvent {
+	event := pullRequestEvent(action)
+	event.PullRequest.Merged = github.Bool(true)
+	event.PullRequest.MergeCommitSHA = github.String(mergeSHA)
+	event.PullRequest.Title = github.String("Fix label sync")
+	for _, label := range labels {
+		event.PullRequest.Labels = append(event.PullRequest.Labels, &github.Label{Name: github.String(label)})
+	}
+	return event
+}
+
+func TestBackportLabels(t *testing.T) {
+	labeled := mergedPullRequestEvent("labeled", "backport/release-1.4")
+	labeled.Label = &github.Label{Name: github.String("backport/release-1.4")}
+	notMerged := pullRequestEvent("closed")
+	notMerged.PullRequest.Labels = []*github.Label{{Name: github.String("backport/release-1.4")}}
+
+	tests := []struct {
+		name          string
+		event         *github.PullRequestEvent
+		config        config.RepoConfig
+		responses     map[string]interface{}
+		expectedCalls []string
+		comment       string
+	}{
+		{
+			name:          "merged with label",
+			event:         mergedPullRequestEvent("closed", "kind/bug", "backport/release-1.4"),
+			responses:     backportResponses(nil),
+			expectedCalls: []string{createRef, createCommit, updateRef, mergeBranches, createCommit, updateRef, createPull, createComment},
+			comment:       "Backported to `release-1.4` with #301.",
+		},
+		{
+			name:  "labeled after merge with conflict",
+			event: labeled,
+			responses: backportResponses(map[string]interface{}{
+				mergeBranches: fakeResponse{status: http.StatusConflict, body: map[string]string{"message": "Merge conflict"}},
+			}),
+			expectedCalls: []string{createRef, createCommit, updateRef, mergeBranches, "DELETE " + fixtureRepo + backportRef, createComment},
+			comment:       "commit 9d4c1a0 doesn't apply cleanly. Please backport it by hand, e.g. with `git cherry-pick -x " + mergeSHA + "`",
+		},
+		{
+			name:  "backport branch exists",
+			event: mergedPullRequestEvent("closed", "backport/release-1.4"),
+			responses: backportResponses(map[string]interface{}{
+				createRef: fakeResponse{status: http.StatusUnprocessableEntity, body: map[string]string{"message": "Reference already exists"}},
+			}),
+			expectedCalls: []string{createRef},
+		},
+		{
+			name:  "unknown branch",
+			event: mergedPullRequestEvent("closed", "backport/release-1.4"),
+			responses: map[string]interface{}{
+				"GET " + fixtureRepo + "/git/refs/heads/release-1.4": notFound,
+			},
+			expectedCalls: []string{createComment},
+			comment:       "Can't backport to `release-1.4`, there is no such branch.",
+		},
+		{
+			name:          "branch not allowed",
+			event:         mergedPullRequestEvent("closed", "backport/release-1.4"),
+			config:        config.RepoConfig{Backport: config.BackportConfig{Branches: []string{"release-2\\..*"}}},
+			expectedCalls: []string{createComment},
+			comment:       "Backporting to `release-1.4` is not allowed in this repository.",
+		},
+		{
+			name:  "not merged",
+			event: notMerged,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			if err := (&backportLabels{}).HandleEvent(context.Background(), test.event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("backport failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if test.comment == "" {
+				return
+			}
+			var comment github.IssueComment
+			if err := fake.requestBody(createComment, &comment); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(comment.GetBody(), test.comment) {
+				t.Errorf("expected comment containing %q, got %q", test.comment, comment.GetBody())
+			}
+		})
+	}
+}
+
+func TestBackportCherryPick(t *testing.T) {
+	fake := newFakeGitHub(t, backportResponses(nil))
+	defer fake.close()
+
+	event := mergedPullRequestEvent("closed", "backport/release-1.4")
+	if err := (&backportLabels{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), config.RepoConfig{}, zap.NewNop()); err != nil {
+		t.Fatalf("backport failed: %+v", err)
+	}
+
+	var picked struct {
+		Message string   `json:"message"`
+		Tree    string   `json:"tree"`
+		Parents []string `json:"parents"`
+	}
+	if err := fake.requestBody(createCommit, &picked); err != nil {
+		t.Fatal(err)
+	}
+	expectedMessage := "Fix label sync\n\n(cherry picked from commit " + mergeSHA + ")"
+	if picked.Message != expectedMessage || picked.Tree != "picked-tree" || !reflect.DeepEqual(picked.Parents, []string{releaseSHA}) {
+		t.Errorf("expected commit %q with merged tree on top of release-1.4, got %+v", expectedMessage, picked)
+	}
+
+	var pull struct {
+		Title string `json:"title"`
+		Head  string `json:"head"`
+		Base  string `json:"base"`
+	}
+	if err := fake.requestBody(createPull, &pull); err != nil {
+		t.Fatal(err)
+	}
+	expectedPull := "[release-1.4] Fix label sync from backport/276-to-release-1.4 to release-1.4"
+	if actual := pull.Title + " from " + pull.Head + " to " + pull.Base; actual != expectedPull {
+		t.Errorf("expected PR %q, got %q", expectedPull, actual)
+	}
+}
+
+func TestBackportCommand(t *testing.T) {
+	addLabels := "POST " + fixtureRepo + "/issues/276/labels"
+
+	tests := []struct {
+		name          string
+		body          string
+		config        config.RepoConfig
+		pullRequest   map[string]interface{}
+		expectedCalls []string
+		expected      string
+	}{
+		{
+			name:          "open PR labeled",
+			body:          "/backport release-1.4 release-1.3",
+			pullRequest:   pullRequestJSON(fixturePR, fixtureSHA, "clean"),
+			expectedCalls: []string{addLabels},
+			expected:      `["backport/release-1.4","backport/release-1.3"]`,
+		},
+		{
+			name:          "no branch",
+			body:          "/backport",
+			expectedCalls: []string{createComment},
+			expected:      "please name the branches to backport to",
+		},
+		{
+			name:          "branch not allowed",
+			body:          "/backport master",
+			config:        config.RepoConfig{Backport: config.BackportConfig{Branches: []string{"release-.*"}}},
+			expectedCalls: []string{createComment},
+			expected:      "backporting to master is not allowed in this repository.",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			responses := map[string]interface{}{
+				"GET " + fixtureRepo + "/collaborators/roland/permission": permissionJSON("write"),
+			}
+			if test.pullRequest != nil {
+				responses[getPullRequest] = test.pullRequest
+			}
+			fake := newFakeGitHub(t, responses)
+			defer fake.close()
+
+			event := commentEvent(test.body, "roland", "User", true)
+			if err := (&slashCommands{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("handler failed: %+v", err)
+			}
+			calls := fake.mutatingCalls()
+			if !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Fatalf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if actual := string(fake.bodies[calls[0]]); !strings.Contains(actual, test.expected) {
+				t.Errorf("expected request containing %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
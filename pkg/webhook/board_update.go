@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"github.com/go-resty/resty"
 	"github.com/google/go-github/github"
+	"github.com/shurcooL/githubv4"
 	"github.com/syndesisio/pure-bot/pkg/config"
 	"go.uber.org/zap"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -26,6 +28,9 @@ type column struct {
 	isInbox             bool
 }
 
+// Guards the mappings below, events are handled concurrently
+var boardMu sync.Mutex
+
 var stateMapping = map[string]column{}
 
 var postProcessing = make(map[string]column)
@@ -38,7 +43,9 @@ var doneColumn = &column{}
 
 var inboxColumn = &column{}
 
-func (h *boardUpdate) HandleEvent(eventObject interface{}, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *boardUpdate) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	boardMu.Lock()
+	defer boardMu.Unlock()
 
 	if "<repo>" == config.Board.GithubRepo {
 		logger.Warn("Repo not configured, ignore event")
@@ -78,15 +85,15 @@ func (h *boardUpdate) HandleEvent(eventObject interface{}, gh *github.Client, co
 
 	switch event := eventObject.(type) {
 	case *github.IssuesEvent:
-		return h.handleIssuesEvent(event, gh, config, logger)
+		return h.handleIssuesEvent(ctx, event, gh, config, logger)
 	case *github.PullRequestEvent:
-		return h.handlePullRequestEvent(event, gh, config, logger)
+		return h.handlePullRequestEvent(ctx, event, gh, config, logger)
 	default:
 		return nil
 	}
 }
 
-func (h *boardUpdate) handleIssuesEvent(event *github.IssuesEvent, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *boardUpdate) handleIssuesEvent(ctx context.Context, event *github.IssuesEvent, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
 
 	var messageType = "issues"
 
@@ -102,10 +109,14 @@ func (h *boardUpdate) handleIssuesEvent(event *github.IssuesEvent, gh *github.Cl
 		if _, ok := postProcessing[number]; ok {
 			logger.Debug("Post process issue: " + number)
 			//delete(postProcessing, number)
-			go postProcess(event, gh, config, logger)
+			if !goAsync(func(ctx context.Context) {
+				postProcess(ctx, event, gh, config, logger)
+			}) {
+				logger.Warn("Shutting down, not post processing issue: " + number)
+			}
 			return nil
 		} else {
-			clearProgressLabel(*event.GetIssue(), gh, event.Repo)
+			clearProgressLabel(ctx, *event.GetIssue(), gh, event.Repo)
 		}
 
 	} else if "issues_reopened" == eventKey && event.GetIssue().GetLocked() {
@@ -122,9 +133,9 @@ func (h *boardUpdate) handleIssuesEvent(event *github.IssuesEvent, gh *github.Cl
 			}
 
 			// update progress/* label
-			changeProgressLabel(gh, event.Repo, *event.Issue, doneColumn.name)
+			changeProgressLabel(ctx, gh, event.Repo, *event.Issue, doneColumn.name)
 
-			response, err := gh.Issues.Unlock(context.Background(), event.Repo.Owner.GetLogin(), event.Repo.GetName(), *event.Issue.Number)
+			response, err := gh.Issues.Unlock(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), *event.Issue.Number)
 
 			if err != nil {
 				logger.Warn("Error unlocking issue: " + response.Status)
@@ -169,7 +180,7 @@ func (h *boardUpdate) handleIssuesEvent(event *github.IssuesEvent, gh *github.Cl
 
 		if nil == err {
 			// update progress/* label
-			changeProgressLabel(gh, event.Repo, *event.Issue, col.name)
+			changeProgressLabel(ctx, gh, event.Repo, *event.Issue, col.name)
 		}
 
 		return err
@@ -180,49 +191,54 @@ func (h *boardUpdate) handleIssuesEvent(event *github.IssuesEvent, gh *github.Cl
 	return nil
 }
 
-func changeProgressLabel(gh *github.Client, repo *github.Repository, issue github.Issue, newLabel string) {
+func changeProgressLabel(ctx context.Context, gh *github.Client, repo *github.Repository, issue github.Issue, newLabel string) {
 
-	/*clearProgressLabel(issue, gh, repo)
+	/*clearProgressLabel(ctx, issue, gh, repo)
 
 	labels := []string{"progress/" + newLabel}
 
-	gh.Issues.AddLabelsToIssue(context.Background(), repo.Owner.GetLogin(), repo.GetName(),
+	gh.Issues.AddLabelsToIssue(ctx, repo.Owner.GetLogin(), repo.GetName(),
 		issue.GetNumber(), labels)*/
 
 }
 
-func clearProgressLabel(issue github.Issue, gh *github.Client, repo *github.Repository) {
+func clearProgressLabel(ctx context.Context, issue github.Issue, gh *github.Client, repo *github.Repository) {
 	for _, label := range issue.Labels {
 		if strings.HasPrefix(*label.Name, "progress/") {
-			gh.Issues.RemoveLabelForIssue(context.Background(), repo.Owner.GetLogin(), repo.GetName(),
+			gh.Issues.RemoveLabelForIssue(ctx, repo.Owner.GetLogin(), repo.GetName(),
 				issue.GetNumber(), *label.Name)
 
 		}
 	}
 }
 
-func postProcess(event *github.IssuesEvent, gh *github.Client, config config.RepoConfig, logger *zap.Logger) {
+func postProcess(ctx context.Context, event *github.IssuesEvent, gh *github.Client, config config.RepoConfig, logger *zap.Logger) {
 
 	number := strconv.Itoa(*event.Issue.Number)
 
 	logger.Debug("Enter grace time before prost processing ... ")
-	time.Sleep(10 * time.Second)
+	select {
+	case <-time.After(10 * time.Second):
+	case <-ctx.Done():
+		logger.Warn("Post processing cancelled by shutdown: " + number)
+		return
+	}
 
-	_, e := gh.Issues.Lock(context.Background(), event.Repo.Owner.GetLogin(), event.Repo.GetName(), *event.Issue.Number, &github.LockIssueOptions{LockReason: "resolved"})
+	_, e := gh.Issues.Lock(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), *event.Issue.Number, &github.LockIssueOptions{LockReason: "resolved"})
 	if e != nil {
 		logger.Error("Locking issue failed: " + number)
 	}
 
 	// re-open
 	state := "open"
-	_, _, err := gh.Issues.Edit(context.Background(), event.Repo.Owner.GetLogin(), event.Repo.GetName(), *event.Issue.Number, &github.IssueRequest{State: &state})
+	_, _, err := gh.Issues.Edit(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), *event.Issue.Number, &github.IssueRequest{State: &state})
 	if err != nil {
 		logger.Error("Post processing failed ")
 	}
 
 }
 
-func (h *boardUpdate) handlePullRequestEvent(event *github.PullRequestEvent, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *boardUpdate) handlePullRequestEvent(ctx context.Context, event *github.PullRequestEvent, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
 
 	var messageType = "pull_request"
 	eventKey := messageType + "_" + *event.Action
@@ -230,7 +246,7 @@ func (h *boardUpdate) handlePullRequestEvent(event *github.PullRequestEvent, gh
 	prNumber := strconv.Itoa(*event.PullRequest.Number)
 	logger.Info("<< Event " + eventKey + " on PR " + prNumber + " >>")
 
-	commits, _, err := gh.PullRequests.ListCommits(context.Background(), event.Repo.Owner.GetLogin(), event.Repo.GetName(),
+	commits, _, err := gh.PullRequests.ListCommits(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(),
 		*event.PullRequest.Number, nil)
 
 	if err != nil {
@@ -282,10 +298,10 @@ func (h *boardUpdate) handlePullRequestEvent(event *github.PullRequestEvent, gh
 			err := moveIssueOnBoard(config, number, col, logger)
 
 			i, _ := strconv.Atoi(number)
-			item, _, _ := gh.Issues.Get(context.Background(), event.Repo.Owner.GetLogin(), event.Repo.GetName(), i)
+			item, _, _ := gh.Issues.Get(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), i)
 
 			if nil == err {
-				changeProgressLabel(gh, event.Repo, *item, col.name)
+				changeProgressLabel(ctx, gh, event.Repo, *item, col.name)
 			}
 			return err
 		} else {
@@ -313,6 +329,10 @@ func extractIssueNumbers(issues *[]string, commitMessage string) {
 func moveIssueOnBoard(config config.RepoConfig, issue string, col column, logger *zap.Logger) error {
 
 	logger.Info("Moving #" + issue + " to `" + col.name + "`")
+	if currentConfig().DryRun {
+		logger.Info("Dry run, skipping ZenHub move")
+		return nil
+	}
 
 	url := zenHubApi + "/p1/repositories/" + config.Board.GithubRepo + "/issues/" + issue + "/moves"
 	response, err := resty.R().
@@ -0,0 +1,99 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+// branchCleanup deletes the head branch of merged pull requests, no matter
+// whether the bot or a human merged them
+type branchCleanup struct{}
+
+func (h *branchCleanup) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *branchCleanup) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if !config.BranchCleanup.Enabled {
+		return nil
+	}
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	pr := event.PullRequest
+	if strings.ToLower(event.GetAction()) != "closed" || !pr.GetMerged() {
+		return nil
+	}
+
+	// Branches of forks can't be deleted by the bot
+	if pr.Head.Repo == nil || pr.Head.Repo.GetFullName() != event.Repo.GetFullName() {
+		logger.Debug("Not deleting branch of fork", zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+	owner, repository := event.Repo.Owner.GetLogin(), event.Repo.GetName()
+	branchName := pr.Head.GetRef()
+	if branchName == event.Repo.GetDefaultBranch() || checkNameMatches(config.BranchCleanup.Exclude, branchName) {
+		logger.Debug("Not deleting excluded branch", zap.String("branch", branchName), zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+
+	branch, resp, err := gh.Repositories.GetBranch(ctx, owner, repository, branchName)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		// Already deleted, e.g. by GitHub's "automatically delete head branches"
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get branch %s of %s/%s", branchName, owner, repository)
+	}
+	if branch.GetProtected() {
+		logger.Debug("Not deleting protected branch", zap.String("branch", branchName), zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+	// Commits pushed after the merge would be lost
+	if branch.Commit.GetSHA() != pr.Head.GetSHA() {
+		logger.Debug("Not deleting branch with further commits", zap.String("branch", branchName), zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+	// GitHub would close the pull requests based on the branch
+	dependents, _, err := gh.PullRequests.List(ctx, owner, repository, &github.PullRequestListOptions{State: "open", Base: branchName})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list pull requests based on %s of %s/%s", branchName, owner, repository)
+	}
+	if len(dependents) > 0 {
+		logger.Debug("Not deleting branch other pull requests are based on", zap.String("branch", branchName), zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+
+	resp, err = gh.Git.DeleteRef(ctx, owner, repository, "heads/"+branchName)
+	if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity) {
+		// Deleted in the meantime
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete branch %s of merged PR %s", branchName, pr.GetHTMLURL())
+	}
+	logger.Info("Deleted branch of merged pull request", zap.String("branch", branchName), zap.Int("pr", pr.GetNumber()))
+	return nil
+}
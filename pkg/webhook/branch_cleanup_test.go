@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func branchJSON(sha string, protected bool) map[string]interface{} {
+	return map[string]interface{}{"name": "fix-labels", "commit": map[string]interface{}{"sha": sha}, "protected": protected}
+}
+
+func TestBranchCleanup(t *testing.T) {
+	getBranch := "GET " + fixtureRepo + "/branches/fix-labels"
+	listPulls := "GET " + fixtureRepo + "/pulls"
+	deleteBranch := "DELETE " + fixtureRepo + "/git/refs/heads/fix-labels"
+	cleanupConfig := config.RepoConfig{BranchCleanup: config.BranchCleanupConfig{Enabled: true, Exclude: []string{"release-.*"}}}
+
+	tests := []struct {
+		name          string
+		action        string
+		merged        bool
+		fork          bool
+		branch        string
+		config        config.RepoConfig
+		responses     map[string]interface{}
+		expectedCalls []string
+	}{
+		{
+			name:   "merged",
+			action: "closed",
+			merged: true,
+			config: cleanupConfig,
+			responses: map[string]interface{}{
+				getBranch: branchJSON(fixtureSHA, false),
+				listPulls: []interface{}{},
+			},
+			expectedCalls: []string{deleteBranch},
+		},
+		{
+			name:   "closed without merge",
+			action: "closed",
+			config: cleanupConfig,
+		},
+		{
+			name:   "fork",
+			action: "closed",
+			merged: true,
+			fork:   true,
+			config: cleanupConfig,
+		},
+		{
+			name:   "excluded",
+			action: "closed",
+			merged: true,
+			branch: "release-1.3",
+			config: cleanupConfig,
+		},
+		{
+			name:   "default branch",
+			action: "closed",
+			merged: true,
+			branch: "master",
+			config: cleanupConfig,
+		},
+		{
+			name:      "protected",
+			action:    "closed",
+			merged:    true,
+			config:    cleanupConfig,
+			responses: map[string]interface{}{getBranch: branchJSON(fixtureSHA, true)},
+		},
+		{
+			name:      "further commits",
+			action:    "closed",
+			merged:    true,
+			config:    cleanupConfig,
+			responses: map[string]interface{}{getBranch: branchJSON("0c3f1a2", false)},
+		},
+		{
+			name:   "base of other PRs",
+			action: "closed",
+			merged: true,
+			config: cleanupConfig,
+			responses: map[string]interface{}{
+				getBranch: branchJSON(fixtureSHA, false),
+				listPulls: []interface{}{pullRequestJSON(277, "0c3f1a2", "clean")},
+			},
+		},
+		{
+			name:      "already deleted",
+			action:    "closed",
+			merged:    true,
+			config:    cleanupConfig,
+			responses: map[string]interface{}{getBranch: notFound},
+		},
+		{
+			name:   "not enabled",
+			action: "closed",
+			merged: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			event := pullRequestEvent(test.action)
+			event.Repo.FullName = github.String("syndesisio/syndesis-rest")
+			event.Repo.DefaultBranch = github.String("master")
+			event.PullRequest.Merged = github.Bool(test.merged)
+			event.PullRequest.Head.Ref = github.String("fix-labels")
+			if test.branch != "" {
+				event.PullRequest.Head.Ref = github.String(test.branch)
+			}
+			event.PullRequest.Head.Repo = &github.Repository{FullName: github.String("syndesisio/syndesis-rest")}
+			if test.fork {
+				event.PullRequest.Head.Repo.FullName = github.String("rhuss/syndesis-rest")
+			}
+
+			if err := (&branchCleanup{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("branch cleanup failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+// branchMilestone assigns the milestone of their base branch to pull
+// requests opened or merged without milestone
+type branchMilestone struct{}
+
+func (h *branchMilestone) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *branchMilestone) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if len(config.BranchMilestones) == 0 {
+		return nil
+	}
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	pr := event.PullRequest
+	switch strings.ToLower(event.GetAction()) {
+	case "opened", "reopened":
+	case "closed":
+		if !pr.GetMerged() {
+			return nil
+		}
+	default:
+		return nil
+	}
+	if pr.Milestone != nil {
+		return nil
+	}
+
+	title := milestoneForBranch(config.BranchMilestones, pr.Base.GetRef())
+	if title == "" {
+		return nil
+	}
+	owner, repository := event.Repo.Owner.GetLogin(), event.Repo.GetName()
+	milestones, err := listMilestones(ctx, gh, owner, repository)
+	if err != nil {
+		return err
+	}
+	for _, milestone := range milestones {
+		if strings.EqualFold(milestone.GetTitle(), title) {
+			_, _, err = gh.Issues.Edit(ctx, owner, repository, pr.GetNumber(), &github.IssueRequest{Milestone: milestone.Number})
+			return errors.Wrapf(err, "failed to set milestone %s on PR %s", milestone.GetTitle(), pr.GetHTMLURL())
+		}
+	}
+	logger.Warn("No open milestone for base branch", zap.String("milestone", title), zap.String("branch", pr.Base.GetRef()), zap.Int("pr", pr.GetNumber()))
+	return nil
+}
+
+// milestoneForBranch returns the milestone title of the first mapping
+// matching the branch, with references to groups of the pattern expanded
+func milestoneForBranch(mappings []config.BranchMilestone, branch string) string {
+	for _, mapping := range mappings {
+		re, err := regexp.Compile(`^(?:` + mapping.Branch + `)$`)
+		if err != nil {
+			continue
+		}
+		if match := re.FindStringSubmatchIndex(branch); match != nil {
+			return string(re.ExpandString(nil, mapping.Milestone, branch, match))
+		}
+	}
+	return ""
+}
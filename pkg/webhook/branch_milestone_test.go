@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestMilestoneForBranch(t *testing.T) {
+	mappings := []config.BranchMilestone{
+		{Branch: "master|main", Milestone: "next"},
+		{Branch: `release-(\d+)\.x`, Milestone: "$1.x.z"},
+	}
+	tests := map[string]string{
+		"main":          "next",
+		"release-1.x":   "1.x.z",
+		"release-1.x-2": "",
+		"feature":       "",
+	}
+	for branch, expected := range tests {
+		if milestone := milestoneForBranch(mappings, branch); milestone != expected {
+			t.Errorf("expected milestone %q for %s, got %q", expected, branch, milestone)
+		}
+	}
+}
+
+func TestBranchMilestone(t *testing.T) {
+	listMilestones := "GET " + fixtureRepo + "/milestones"
+	editIssue := "PATCH " + fixtureRepo + "/issues/276"
+	milestones := []interface{}{
+		map[string]interface{}{"number": 3, "title": "next"},
+		map[string]interface{}{"number": 4, "title": "1.x.z"},
+	}
+	milestoneConfig := config.RepoConfig{BranchMilestones: []config.BranchMilestone{
+		{Branch: "master", Milestone: "next"},
+		{Branch: `release-(\d+)\.x`, Milestone: "$1.x.z"},
+		{Branch: "release-2.x", Milestone: "2.x.z"},
+	}}
+
+	tests := []struct {
+		name          string
+		action        string
+		merged        bool
+		base          string
+		milestone     *github.Milestone
+		responses     map[string]interface{}
+		expectedCalls []string
+		expected      int
+	}{
+		{
+			name:          "opened",
+			action:        "opened",
+			base:          "master",
+			responses:     map[string]interface{}{listMilestones: milestones},
+			expectedCalls: []string{editIssue},
+			expected:      3,
+		},
+		{
+			name:          "merged into release branch",
+			action:        "closed",
+			merged:        true,
+			base:          "release-1.x",
+			responses:     map[string]interface{}{listMilestones: milestones},
+			expectedCalls: []string{editIssue},
+			expected:      4,
+		},
+		{
+			name:      "milestone set already",
+			action:    "opened",
+			base:      "master",
+			milestone: &github.Milestone{Number: github.Int(2)},
+		},
+		{
+			name:   "closed without merge",
+			action: "closed",
+			base:   "master",
+		},
+		{
+			name:   "no mapping",
+			action: "opened",
+			base:   "feature",
+		},
+		{
+			name:      "no open milestone",
+			action:    "opened",
+			base:      "release-2.x",
+			responses: map[string]interface{}{listMilestones: milestones},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			event := pullRequestEvent(test.action)
+			event.PullRequest.Merged = github.Bool(test.merged)
+			event.PullRequest.Milestone = test.milestone
+			event.PullRequest.Base = &github.PullRequestBranch{Ref: github.String(test.base)}
+			if err := (&branchMilestone{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), milestoneConfig, zap.NewNop()); err != nil {
+				t.Fatalf("assigning milestone failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Fatalf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if test.expected == 0 {
+				return
+			}
+			var request struct {
+				Milestone int `json:"milestone"`
+			}
+			if err := fake.requestBody(editIssue, &request); err != nil {
+				t.Fatal(err)
+			}
+			if request.Milestone != test.expected {
+				t.Errorf("expected milestone %d, got %d", test.expected, request.Milestone)
+			}
+		})
+	}
+}
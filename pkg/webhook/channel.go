@@ -0,0 +1,148 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+// Wait before reconnecting to a channel unless it asks for another delay
+const defaultChannelRetry = 5 * time.Second
+
+// channelMessage is a delivery forwarded by a webhook proxy like smee.io:
+// the lowercased headers of the delivery and its body as JSON
+type channelMessage struct {
+	Event      string          `json:"x-github-event"`
+	DeliveryID string          `json:"x-github-delivery"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// ListenChannel subscribes to a webhook proxy channel like
+// https://smee.io/<channel> and feeds the forwarded deliveries through the
+// handlers until the context is cancelled. This allows developing handlers
+// without exposing a public endpoint. As the proxy forwards the payload
+// re-encoded, signatures can't be verified, so this is for development only.
+func ListenChannel(ctx context.Context, channelURL string, token string, cfg config.Config, logger *zap.Logger) error {
+	if err := initLocally(cfg, logger); err != nil {
+		return err
+	}
+	localToken = token
+	defer func() { localToken = "" }()
+
+	for {
+		logger.Info("Connecting to channel", zap.String("url", channelURL))
+		retry, err := readChannel(ctx, channelURL, logger)
+		if ctx.Err() != nil {
+			return nil
+		}
+		logger.Warn("channel disconnected, reconnecting", zap.Duration("after", retry), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retry):
+		}
+	}
+}
+
+// readChannel handles the server-sent events of a channel until the stream
+// ends. It returns the delay before reconnecting.
+func readChannel(ctx context.Context, channelURL string, logger *zap.Logger) (time.Duration, error) {
+	retry := defaultChannelRetry
+	req, err := http.NewRequest(http.MethodGet, channelURL, nil)
+	if err != nil {
+		return retry, errors.Wrap(err, "invalid channel URL")
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return retry, errors.Wrap(err, "failed to connect to channel")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return retry, errors.Errorf("channel responded with %s", resp.Status)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var eventName, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("channel closed the stream")
+			}
+			return retry, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "":
+			// A blank line dispatches the event, a line starting with a
+			// colon is a comment
+			if line == "" {
+				if eventName == "" || eventName == "message" {
+					forwardChannelMessage(data, logger)
+				}
+				eventName, data = "", ""
+			}
+		case "event":
+			eventName = value
+		case "data":
+			if data != "" {
+				data += "\n"
+			}
+			data += value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+func forwardChannelMessage(data string, logger *zap.Logger) {
+	if data == "" {
+		return
+	}
+	var message channelMessage
+	if err := json.Unmarshal([]byte(data), &message); err != nil {
+		logger.Warn("ignoring invalid channel message", zap.Error(err))
+		return
+	}
+	if message.Event == "" || len(message.Body) == 0 {
+		logger.Debug("ignoring channel message without event")
+		return
+	}
+
+	logger.Info("Received delivery from channel", zap.String("delivery", message.DeliveryID), zap.String("event", message.Event))
+	if err := handleLocalDelivery(message.Event, message.DeliveryID, message.Body, logger); err != nil {
+		logger.Error("failed to handle delivery", zap.String("delivery", message.DeliveryID), zap.Error(err))
+	}
+}
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestListenChannel(t *testing.T) {
+	defer func(history *deliveryHistory) { recentDeliveries = history }(recentDeliveries)
+	recentDeliveries = newDeliveryHistory(10)
+
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer gh.Close()
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: ready\ndata: {}\n\n")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, `data: {"x-github-event":"repository","x-github-delivery":"72d3162e","body":{"action":"created",`+"\n")
+		fmt.Fprint(w, `data: "repository":{"name":"channel-test","full_name":"syndesisio/channel-test","owner":{"login":"syndesisio"}}}}`+"\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer channel.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	cfg := config.Config{GitHubApp: config.GitHubAppConfig{BaseURL: gh.URL}}
+	go func() { done <- ListenChannel(ctx, channel.URL, "abc", cfg, zap.NewNop()) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if delivery, found := recentDeliveries.get("72d3162e"); found {
+			if delivery.Type != "repository" || delivery.Repo != "syndesisio/channel-test" || delivery.Error != "" {
+				t.Errorf("unexpected delivery %+v", delivery)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected delivery of the channel to be handled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	if len(recentDeliveries.recent(10)) != 1 {
+		t.Error("expected only the forwarded delivery to be handled")
+	}
+}
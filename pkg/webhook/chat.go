@@ -0,0 +1,106 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/notify"
+	"go.uber.org/zap"
+)
+
+const defaultFailureThreshold = 3
+
+var (
+	// Chats posting to the rooms of repositories, nil if not configured
+	slack  *notify.Slack
+	matrix *notify.Matrix
+
+	// Consecutive failures per handler and repository
+	handlerFailuresMu sync.Mutex
+	handlerFailures   = make(map[string]int)
+)
+
+// chatEnabled tells whether a repository has a chat room to post to
+func chatEnabled(config config.RepoConfig) bool {
+	return (slack != nil && config.SlackChannel != "") || (matrix != nil && config.MatrixRoom != "")
+}
+
+// postToChats posts a message to the chat rooms of a repository
+func postToChats(config config.RepoConfig, message notify.Message) {
+	slack.Post(config.SlackChannel, message)
+	matrix.Post(config.MatrixRoom, message)
+}
+
+func pullRequestMessage(kind notify.Kind, text string, repo string, issue *github.Issue, detail string) notify.Message {
+	return notify.Message{
+		Kind:     kind,
+		Text:     text,
+		LinkText: repo + "#" + strconv.Itoa(issue.GetNumber()),
+		LinkURL:  issue.GetHTMLURL(),
+		Detail:   detail,
+	}
+}
+
+func notifyMerged(config config.RepoConfig, repo string, issue *github.Issue) {
+	postToChats(config, pullRequestMessage(notify.Success, "Merged", repo, issue, issue.GetTitle()))
+}
+
+func notifyMergeFailed(config config.RepoConfig, repo string, issue *github.Issue, err error) {
+	postToChats(config, pullRequestMessage(notify.Failure, "Failed to merge", repo, issue, err.Error()))
+}
+
+// alertFailures reports a handler failing repeatedly for a repository to the
+// repository's chat rooms, once per series of failures
+func alertFailures(name string, next HandleFunc) HandleFunc {
+	return func(ctx context.Context, eventObject interface{}, client *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+		err := next(ctx, eventObject, client, gql, config, logger)
+		if !chatEnabled(config) || eventObject == nil {
+			return err
+		}
+		repo, _ := extractRepository(eventObject)
+		if repo == nil {
+			return err
+		}
+		key := name + " " + repo.GetFullName()
+
+		handlerFailuresMu.Lock()
+		defer handlerFailuresMu.Unlock()
+		if err == nil {
+			delete(handlerFailures, key)
+			return nil
+		}
+		handlerFailures[key]++
+		threshold := currentConfig().FailureThreshold
+		if threshold <= 0 {
+			threshold = defaultFailureThreshold
+		}
+		if handlerFailures[key] == threshold {
+			postToChats(config, notify.Message{
+				Kind:     notify.Warning,
+				Text:     fmt.Sprintf("Handler %s failed %d times in a row for", name, threshold),
+				LinkText: repo.GetFullName(),
+				Detail:   err.Error(),
+			})
+		}
+		return err
+	}
+}
@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/notify"
+	"go.uber.org/zap"
+)
+
+func TestAlertFailures(t *testing.T) {
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message struct{ Channel, Text string }
+		json.NewDecoder(r.Body).Decode(&message)
+		texts = append(texts, message.Channel+" "+message.Text)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+	defer func(s *notify.Slack) { slack = s }(slack)
+	slack = notify.NewSlack(config.SlackConfig{Token: "xoxb-token", APIURL: server.URL}, zap.NewNop())
+	defer func() { handlerFailures = make(map[string]int) }()
+
+	fail := true
+	handle := alertFailures("labelSync", func(ctx context.Context, eventObject interface{}, client *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	event := &github.RepositoryEvent{Repo: &github.Repository{FullName: github.String("syndesisio/syndesis")}}
+	cfg := config.RepoConfig{SlackChannel: "#syndesis"}
+	call := func() { handle(context.Background(), event, nil, nil, cfg, zap.NewNop()) }
+
+	// Reported once on the third failure in a row, a success starts over
+	call()
+	call()
+	fail = false
+	call()
+	fail = true
+	for i := 0; i < 4; i++ {
+		call()
+	}
+	slack.Close()
+
+	if len(texts) != 1 || !strings.HasPrefix(texts[0], "#syndesis :warning: Handler labelSync failed 3 times in a row for syndesisio/syndesis") {
+		t.Errorf("unexpected messages %q", texts)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const checkCompletedAction = "completed"
+
+func (h *autoMerger) handleCheckRunEvent(ctx context.Context, event *github.CheckRunEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	run := event.GetCheckRun()
+	if strings.ToLower(event.GetAction()) != checkCompletedAction || !isPassingConclusion(run.GetConclusion(), config) {
+		logger.Debug("skipping check_run event as it doesn't pass", zap.String("action", event.GetAction()), zap.String("conclusion", run.GetConclusion()))
+		return nil
+	}
+
+	return h.scheduleCheckMerge(ctx, event.Repo, run.GetHeadSHA(), run.PullRequests, gh, gql, config, logger)
+}
+
+func (h *autoMerger) handleCheckSuiteEvent(ctx context.Context, event *github.CheckSuiteEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	suite := event.GetCheckSuite()
+	if strings.ToLower(event.GetAction()) != checkCompletedAction || !isPassingConclusion(suite.GetConclusion(), config) {
+		logger.Debug("skipping check_suite event as it doesn't pass", zap.String("action", event.GetAction()), zap.String("conclusion", suite.GetConclusion()))
+		return nil
+	}
+
+	return h.scheduleCheckMerge(ctx, event.Repo, suite.GetHeadSHA(), suite.PullRequests, gh, gql, config, logger)
+}
+
+// scheduleCheckMerge evaluates the pull requests of a commit whose checks
+// completed. A check suite completes together with its last check run, so
+// both share the debounce key with status and workflow_run events.
+func (h *autoMerger) scheduleCheckMerge(ctx context.Context, repo *github.Repository, commitSHA string, prs []*github.PullRequest, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	metrics.Add(mergeEventsReceived, 1)
+	owner, repository := repo.Owner.GetLogin(), repo.GetName()
+	return debounce(ctx, repo.GetFullName()+"@"+commitSHA, debounceWindow(config), logger, func(ctx context.Context) error {
+		return mergeCommitPRs(ctx, owner, repository, commitSHA, prs, gh, gql, config, logger)
+	})
+}
@@ -0,0 +1,270 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const (
+	claCheckName      = "pure-bot/cla"
+	defaultCLATimeout = 10 * time.Second
+	// Marks the comment asking to sign the CLA, so that it gets updated
+	// instead of adding new comments
+	claMarker = "<!-- pure-bot:cla -->"
+)
+
+// claAuthor is the author of a commit, identified by GitHub login if the
+// commit is linked to a GitHub user and by email otherwise
+type claAuthor struct {
+	Repository string `json:"repository"`
+	Login      string `json:"login,omitempty"`
+	Email      string `json:"email"`
+}
+
+func (a claAuthor) String() string {
+	if a.Login != "" {
+		return "@" + a.Login
+	}
+	return a.Email
+}
+
+// claBackend tells whether an author signed the CLA
+type claBackend interface {
+	signed(ctx context.Context, author claAuthor) (bool, error)
+}
+
+// claVerification reports a check run telling whether all commit authors of
+// a pull request signed the CLA and asks the others to sign it
+type claVerification struct{}
+
+func (h *claVerification) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *claVerification) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if config.CLA.URL == "" && config.CLA.AllowlistFile == "" {
+		return nil
+	}
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	action := strings.ToLower(event.GetAction())
+	if action != "opened" && action != "reopened" && action != "synchronize" {
+		return nil
+	}
+
+	pr := event.PullRequest
+	owner, repository := event.Repo.Owner.GetLogin(), event.Repo.GetName()
+	backend, err := newCLABackend(ctx, gh, owner, repository, config.CLA)
+	if err != nil {
+		return err
+	}
+	commits, err := listPullRequestCommits(ctx, gh, owner, repository, pr.GetNumber())
+	if err != nil {
+		return err
+	}
+
+	var unsigned []claAuthor
+	for _, author := range commitAuthors(owner+"/"+repository, commits, config.CLA.ExemptAuthors) {
+		signed, err := backend.signed(ctx, author)
+		if err != nil {
+			return err
+		}
+		if !signed {
+			unsigned = append(unsigned, author)
+		}
+	}
+
+	if err := reportCLA(ctx, gh, owner, repository, pr, unsigned); err != nil {
+		return err
+	}
+	if len(unsigned) > 0 {
+		logger.Info("Authors without CLA", zap.Int("pr", pr.GetNumber()), zap.Int("unsigned", len(unsigned)))
+	}
+	return updateCLAComment(ctx, gh, owner, repository, pr, unsigned, config.CLA.SignURL)
+}
+
+// commitAuthors returns the distinct authors of the commits, leaving out bots
+// and exempt authors
+func commitAuthors(repository string, commits []*github.RepositoryCommit, exempt []string) []claAuthor {
+	var authors []claAuthor
+	seen := make(map[string]bool)
+	for _, commit := range commits {
+		author := claAuthor{Repository: repository, Login: commit.Author.GetLogin(), Email: commit.Commit.Author.GetEmail()}
+		if commit.Author.GetType() == "Bot" || containsIgnoreCase(exempt, author.Login) {
+			continue
+		}
+		key := strings.ToLower(author.String())
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		authors = append(authors, author)
+	}
+	return authors
+}
+
+func reportCLA(ctx context.Context, gh *github.Client, owner, repository string, pr *github.PullRequest, unsigned []claAuthor) error {
+	opts := github.CreateCheckRunOptions{
+		Name:    claCheckName,
+		HeadSHA: pr.Head.GetSHA(),
+		Status:  github.String("completed"),
+		Output:  &github.CheckRunOutput{},
+	}
+	if len(unsigned) == 0 {
+		opts.Conclusion = github.String("success")
+		opts.Output.Title = github.String("All authors signed the CLA")
+		opts.Output.Summary = github.String("All commit authors signed the Contributor License Agreement.")
+	} else {
+		names := make([]string, 0, len(unsigned))
+		for _, author := range unsigned {
+			names = append(names, "- "+author.String())
+		}
+		opts.Conclusion = github.String("failure")
+		opts.Output.Title = github.String(fmt.Sprintf("%d authors need to sign the CLA", len(unsigned)))
+		opts.Output.Summary = github.String("These commit authors haven't signed the Contributor License Agreement:\n\n" + strings.Join(names, "\n"))
+	}
+
+	_, _, err := gh.Checks.CreateCheckRun(ctx, owner, repository, opts)
+	return errors.Wrapf(err, "failed to create check run %s for PR %s", claCheckName, pr.GetHTMLURL())
+}
+
+// updateCLAComment asks the authors to sign the CLA in a single comment,
+// which is updated once they did
+func updateCLAComment(ctx context.Context, gh *github.Client, owner, repository string, pr *github.PullRequest, unsigned []claAuthor, signURL string) error {
+	comment, err := findComment(ctx, gh, owner, repository, pr.GetNumber(), claMarker)
+	if err != nil {
+		return err
+	}
+	if comment == nil && len(unsigned) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	body.WriteString(claMarker + "\n")
+	if len(unsigned) == 0 {
+		body.WriteString("Thanks, all commit authors signed the Contributor License Agreement.\n")
+	} else {
+		agreement := "Contributor License Agreement"
+		if signURL != "" {
+			agreement = "[" + agreement + "](" + signURL + ")"
+		}
+		fmt.Fprintf(&body, "Thanks for your pull request! Before it can be merged, these commit authors need to sign the %s:\n\n", agreement)
+		for _, author := range unsigned {
+			fmt.Fprintf(&body, "- %s\n", author)
+		}
+		body.WriteString("\nThe CLA is checked again with the next push. Commits without a linked GitHub account are identified by the email address of their author.\n")
+	}
+
+	if comment == nil {
+		_, _, err = gh.Issues.CreateComment(ctx, owner, repository, pr.GetNumber(), &github.IssueComment{Body: github.String(body.String())})
+		return errors.Wrapf(err, "failed to ask for signing the CLA on PR %s", pr.GetHTMLURL())
+	}
+	if comment.GetBody() == body.String() {
+		return nil
+	}
+	_, _, err = gh.Issues.EditComment(ctx, owner, repository, comment.GetID(), &github.IssueComment{Body: github.String(body.String())})
+	return errors.Wrapf(err, "failed to update CLA comment on PR %s", pr.GetHTMLURL())
+}
+
+func newCLABackend(ctx context.Context, gh *github.Client, owner, repository string, cfg config.CLAConfig) (claBackend, error) {
+	if cfg.URL != "" {
+		return &claEndpoint{cfg}, nil
+	}
+	content, err := getFileContent(ctx, gh, owner, repository, "", cfg.AllowlistFile)
+	if err != nil {
+		return nil, err
+	}
+	return parseCLAAllowlist(content), nil
+}
+
+// claEndpoint asks an HTTP endpoint for each author
+type claEndpoint struct {
+	cfg config.CLAConfig
+}
+
+func (e *claEndpoint) signed(ctx context.Context, author claAuthor) (bool, error) {
+	body, err := json.Marshal(author)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to encode CLA request")
+	}
+	timeout := e.cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultCLATimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, e.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create CLA request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check CLA of %s", author)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return false, errors.Errorf("checking CLA of %s failed with status %d: %s", author, resp.StatusCode, msg)
+	}
+
+	var result struct {
+		Signed bool `json:"signed"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, errors.Wrapf(err, "invalid CLA response for %s", author)
+	}
+	return result.Signed, nil
+}
+
+// claAllowlist holds the lower-cased logins and emails of signers
+type claAllowlist map[string]bool
+
+// parseCLAAllowlist reads one login or email per line, ignoring empty lines
+// and comments starting with "#"
+func parseCLAAllowlist(content string) claAllowlist {
+	allowlist := make(claAllowlist)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		allowlist[strings.ToLower(strings.TrimPrefix(line, "@"))] = true
+	}
+	return allowlist
+}
+
+func (a claAllowlist) signed(ctx context.Context, author claAuthor) (bool, error) {
+	return (author.Login != "" && a[strings.ToLower(author.Login)]) || a[strings.ToLower(author.Email)], nil
+}
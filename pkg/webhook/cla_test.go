@@ -0,0 +1,176 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func authoredCommitJSON(sha, login, email string) map[string]interface{} {
+	commit := commitJSON(sha, email, "Add size labels", 1)
+	if login != "" {
+		commit["author"] = map[string]interface{}{"login": login, "type": "User"}
+	}
+	return commit
+}
+
+func TestCLAVerification(t *testing.T) {
+	listCommits := "GET " + fixtureRepo + "/pulls/276/commits"
+	listComments := "GET " + fixtureRepo + "/issues/276/comments"
+	allowlistFile := "GET " + fixtureRepo + "/contents/CLA_SIGNERS"
+	createCheckRun := "POST " + fixtureRepo + "/check-runs"
+	editComment := "PATCH " + fixtureRepo + "/issues/comments/7"
+	allowlistConfig := config.RepoConfig{CLA: config.CLAConfig{AllowlistFile: "CLA_SIGNERS", SignURL: "https://cla.example.com"}}
+	allowlist := fileContentJSON("# Signers\nroland\nTom@example.com\n")
+	commits := []interface{}{
+		authoredCommitJSON("1111111111111111111111111111111111111111", "roland", "roland@example.com"),
+		authoredCommitJSON("2222222222222222222222222222222222222222", "", "tom@example.com"),
+		authoredCommitJSON("3333333333333333333333333333333333333333", "roland", "roland@example.com"),
+	}
+	newAuthor := append(append([]interface{}{}, commits...), authoredCommitJSON("4444444444444444444444444444444444444444", "jimmi", "jimmi@example.com"))
+	claComment := func(body string) []interface{} {
+		return []interface{}{map[string]interface{}{"id": 7, "body": claMarker + "\n" + body}}
+	}
+
+	tests := []struct {
+		name               string
+		config             config.RepoConfig
+		responses          map[string]interface{}
+		expectedConclusion string
+		expectedCalls      []string
+		expectedComment    string
+	}{
+		{
+			name:   "all signed",
+			config: allowlistConfig,
+			responses: map[string]interface{}{
+				allowlistFile: allowlist,
+				listCommits:   commits,
+				listComments:  []interface{}{},
+			},
+			expectedConclusion: "success",
+			expectedCalls:      []string{createCheckRun},
+		},
+		{
+			name:   "author without CLA",
+			config: allowlistConfig,
+			responses: map[string]interface{}{
+				allowlistFile: allowlist,
+				listCommits:   newAuthor,
+				listComments:  []interface{}{},
+			},
+			expectedConclusion: "failure",
+			expectedCalls:      []string{createCheckRun, createComment},
+			expectedComment:    "these commit authors need to sign the [Contributor License Agreement](https://cla.example.com):\n\n- @jimmi\n",
+		},
+		{
+			name:   "signed after asking",
+			config: allowlistConfig,
+			responses: map[string]interface{}{
+				allowlistFile: allowlist,
+				listCommits:   commits,
+				listComments:  claComment("Thanks for your pull request!"),
+			},
+			expectedConclusion: "success",
+			expectedCalls:      []string{createCheckRun, editComment},
+			expectedComment:    "Thanks, all commit authors signed the Contributor License Agreement.",
+		},
+		{
+			name:   "exempt author",
+			config: config.RepoConfig{CLA: config.CLAConfig{AllowlistFile: "CLA_SIGNERS", ExemptAuthors: []string{"jimmi"}}},
+			responses: map[string]interface{}{
+				allowlistFile: allowlist,
+				listCommits:   newAuthor,
+				listComments:  []interface{}{},
+			},
+			expectedConclusion: "success",
+			expectedCalls:      []string{createCheckRun},
+		},
+		{
+			name: "not configured",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			if err := (&claVerification{}).HandleEvent(context.Background(), pullRequestEvent("synchronize"), fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("CLA verification failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if test.expectedConclusion != "" {
+				var checkRun github.CreateCheckRunOptions
+				if err := fake.requestBody(createCheckRun, &checkRun); err != nil {
+					t.Fatal(err)
+				}
+				if checkRun.Name != claCheckName || checkRun.GetConclusion() != test.expectedConclusion {
+					t.Errorf("expected %s check run concluding %s, got %+v", claCheckName, test.expectedConclusion, checkRun)
+				}
+			}
+			if test.expectedComment != "" {
+				var comment struct{ Body string }
+				call := test.expectedCalls[len(test.expectedCalls)-1]
+				if err := fake.requestBody(call, &comment); err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(comment.Body, test.expectedComment) {
+					t.Errorf("expected comment containing %q, got %q", test.expectedComment, comment.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestCLAEndpoint(t *testing.T) {
+	var requests []claAuthor
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var author claAuthor
+		if err := json.NewDecoder(r.Body).Decode(&author); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		requests = append(requests, author)
+		json.NewEncoder(w).Encode(map[string]bool{"signed": author.Login == "roland"})
+	}))
+	defer server.Close()
+
+	fake := newFakeGitHub(t, map[string]interface{}{
+		"GET " + fixtureRepo + "/pulls/276/commits": []interface{}{
+			authoredCommitJSON("1111111111111111111111111111111111111111", "roland", "roland@example.com"),
+			authoredCommitJSON("2222222222222222222222222222222222222222", "", "tom@example.com"),
+		},
+		"GET " + fixtureRepo + "/issues/276/comments": []interface{}{},
+	})
+	defer fake.close()
+
+	claConfig := config.RepoConfig{CLA: config.CLAConfig{URL: server.URL}}
+	if err := (&claVerification{}).HandleEvent(context.Background(), pullRequestEvent("synchronize"), fake.client(), fake.graphQL(), claConfig, zap.NewNop()); err != nil {
+		t.Fatalf("CLA verification failed: %+v", err)
+	}
+	expected := []claAuthor{
+		{Repository: "syndesisio/syndesis-rest", Login: "roland", Email: "roland@example.com"},
+		{Repository: "syndesisio/syndesis-rest", Email: "tom@example.com"},
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("expected requests %+v, got %+v", expected, requests)
+	}
+	var comment struct{ Body string }
+	if err := fake.requestBody(createComment, &comment); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(comment.Body, "- tom@example.com\n") {
+		t.Errorf("expected tom@example.com asked to sign, got %q", comment.Body)
+	}
+}
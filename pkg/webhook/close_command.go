@@ -0,0 +1,69 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+var (
+	closeCommand = Command{
+		Help:          "Closes the issue or pull request, a given reason is recorded in a reply",
+		Usage:         "[reason]",
+		AuthorAllowed: true,
+		Run:           runClose,
+	}
+	reopenCommand = Command{
+		Help:          "Reopens the issue or pull request, a given reason is recorded in a reply",
+		Usage:         "[reason]",
+		AuthorAllowed: true,
+		Run:           runReopen,
+	}
+)
+
+func runClose(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	return setIssueState(ctx, cmd, gh, "closed")
+}
+
+func runReopen(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	return setIssueState(ctx, cmd, gh, "open")
+}
+
+// setIssueState closes or reopens the commented issue or pull request and
+// replies with the reason, if one is given
+func setIssueState(ctx context.Context, cmd CommandEvent, gh *github.Client, state string) error {
+	if strings.EqualFold(cmd.Event.Issue.GetState(), state) {
+		return nil
+	}
+	_, _, err := gh.Issues.Edit(ctx, cmd.Owner(), cmd.Repo(), cmd.Number(), &github.IssueRequest{State: &state})
+	if err != nil {
+		return errors.Wrapf(err, "failed to set state of %s to %s", cmd.Event.Issue.GetHTMLURL(), state)
+	}
+	if len(cmd.Args) == 0 {
+		return nil
+	}
+	verb := "closed"
+	if state == "open" {
+		verb = "reopened"
+	}
+	return cmd.Reply(ctx, gh, verb+" this because: "+strings.Join(cmd.Args, " "))
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestCloseCommands(t *testing.T) {
+	editIssue := "PATCH " + fixtureRepo + "/issues/276"
+
+	tests := []struct {
+		name          string
+		comment       string
+		author        string
+		state         string
+		permission    interface{}
+		expectedCalls []string
+		expectedState string
+		reply         string
+	}{
+		{
+			name:          "close with reason",
+			comment:       "/close duplicate of #12",
+			state:         "open",
+			permission:    permissionJSON("write"),
+			expectedCalls: []string{editIssue, createComment},
+			expectedState: "closed",
+			reply:         "@roland closed this because: duplicate of #12",
+		},
+		{
+			name:          "reopen by author",
+			comment:       "/reopen",
+			author:        "roland",
+			state:         "closed",
+			expectedCalls: []string{editIssue},
+			expectedState: "open",
+		},
+		{
+			name:          "close without permission",
+			comment:       "/close",
+			state:         "open",
+			permission:    permissionJSON("read"),
+			expectedCalls: []string{createComment},
+			reply:         "@roland you need write permission on this repository to use `/close`.",
+		},
+		{
+			name:       "already closed",
+			comment:    "/close",
+			state:      "closed",
+			permission: permissionJSON("admin"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			responses := map[string]interface{}{}
+			if test.permission != nil {
+				responses["GET "+fixtureRepo+"/collaborators/roland/permission"] = test.permission
+			}
+			fake := newFakeGitHub(t, responses)
+			defer fake.close()
+
+			event := commentEvent(test.comment, "roland", "User", false)
+			event.Issue.State = github.String(test.state)
+			event.Issue.User = &github.User{Login: github.String("kurt")}
+			if test.author != "" {
+				event.Issue.User.Login = github.String(test.author)
+			}
+			if err := (&slashCommands{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), config.RepoConfig{}, zap.NewNop()); err != nil {
+				t.Fatalf("handler failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Fatalf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if test.expectedState != "" {
+				var request map[string]interface{}
+				if err := fake.requestBody(editIssue, &request); err != nil {
+					t.Fatal(err)
+				}
+				if request["state"] != test.expectedState {
+					t.Errorf("expected state %s, got %v", test.expectedState, request["state"])
+				}
+			}
+			if test.reply != "" {
+				var comment github.IssueComment
+				if err := fake.requestBody(createComment, &comment); err != nil {
+					t.Fatal(err)
+				}
+				if comment.GetBody() != test.reply {
+					t.Errorf("expected reply %q, got %q", test.reply, comment.GetBody())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+// Locations of the CODEOWNERS file, in the order GitHub looks for it
+var codeOwnersFiles = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// codeOwnersRule is a line of a CODEOWNERS file
+type codeOwnersRule struct {
+	pattern *regexp.Regexp
+	// "@login", "@org/team" or an email
+	owners []string
+}
+
+// codeOwnersReviewers requests reviews from the code owners of the files
+// changed by a new pull request
+type codeOwnersReviewers struct{}
+
+func (h *codeOwnersReviewers) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *codeOwnersReviewers) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if !config.ReviewerAssignment.CodeOwners {
+		return nil
+	}
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	action := strings.ToLower(event.GetAction())
+	if action != "opened" && action != "reopened" {
+		return nil
+	}
+
+	pr := event.PullRequest
+	owner, repository := event.Repo.Owner.GetLogin(), event.Repo.GetName()
+	rules, err := loadCodeOwners(ctx, gh, owner, repository)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	files, err := listPullRequestFiles(ctx, gh, owner, repository, pr.GetNumber())
+	if err != nil {
+		return err
+	}
+
+	var request github.ReviewersRequest
+	var teams []string
+	for _, codeOwner := range codeOwnersOf(rules, files) {
+		switch {
+		case !strings.HasPrefix(codeOwner, "@"):
+			// Users given by email can't be requested
+		case strings.Contains(codeOwner, "/"):
+			parts := strings.SplitN(codeOwner[1:], "/", 2)
+			// Only teams of the repository's organisation can review
+			if strings.EqualFold(parts[0], owner) {
+				teams = append(teams, parts[1])
+			}
+		default:
+			login := codeOwner[1:]
+			if strings.EqualFold(login, pr.User.GetLogin()) || containsIgnoreCase(config.ReviewerAssignment.Unavailable, login) {
+				continue
+			}
+			request.Reviewers = append(request.Reviewers, login)
+		}
+	}
+	if len(teams) > 0 {
+		if request.TeamReviewers, err = existingTeams(ctx, gh, owner, teams); err != nil {
+			return err
+		}
+	}
+	if len(request.Reviewers) == 0 && len(request.TeamReviewers) == 0 {
+		logger.Debug("No code owners to request reviews from", zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+
+	if _, _, err := gh.PullRequests.RequestReviewers(ctx, owner, repository, pr.GetNumber(), request); err != nil {
+		return errors.Wrapf(err, "failed to request reviews of code owners for PR %s", pr.GetHTMLURL())
+	}
+	logger.Debug("Requested reviews of code owners", zap.Int("pr", pr.GetNumber()), zap.Strings("reviewers", request.Reviewers), zap.Strings("teams", request.TeamReviewers))
+	return nil
+}
+
+// loadCodeOwners parses the CODEOWNERS file of the default branch, nil if
+// there is none
+func loadCodeOwners(ctx context.Context, gh *github.Client, owner, repository string) ([]codeOwnersRule, error) {
+	for _, file := range codeOwnersFiles {
+		content, err := getFileContent(ctx, gh, owner, repository, "", file)
+		if err != nil {
+			return nil, err
+		}
+		if content != "" {
+			return parseCodeOwners(content)
+		}
+	}
+	return nil, nil
+}
+
+func parseCodeOwners(content string) ([]codeOwnersRule, error) {
+	var rules []codeOwnersRule
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		pattern, err := regexp.Compile(codeOwnersPattern(fields[0]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid CODEOWNERS pattern %q", fields[0])
+		}
+		rules = append(rules, codeOwnersRule{pattern, fields[1:]})
+	}
+	return rules, scanner.Err()
+}
+
+// codeOwnersPattern translates a pattern of a CODEOWNERS file, which follows
+// the rules of .gitignore files: patterns without a slash but a trailing one
+// match at any depth, patterns naming a directory match all files in it,
+// but "dir/*" only matches the files directly in dir.
+func codeOwnersPattern(pattern string) string {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	directory := strings.HasSuffix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+
+	prefix := "^(.*/)?"
+	if anchored {
+		prefix = "^"
+	}
+	suffix := "(/.*)?$"
+	switch {
+	case directory:
+		suffix = "/.*$"
+	case pattern == "*" || strings.HasSuffix(pattern, "/*"):
+		suffix = "$"
+	}
+	return prefix + globPattern(pattern) + suffix
+}
+
+// codeOwnersOf returns the distinct owners of the files, which are those of
+// the last rule matching each file
+func codeOwnersOf(rules []codeOwnersRule, files []string) []string {
+	var owners []string
+	for _, file := range files {
+		for i := len(rules) - 1; i >= 0; i-- {
+			if !rules[i].pattern.MatchString(file) {
+				continue
+			}
+			for _, owner := range rules[i].owners {
+				if !containsIgnoreCase(owners, owner) {
+					owners = append(owners, owner)
+				}
+			}
+			break
+		}
+	}
+	return owners
+}
+
+// existingTeams returns the slugs of those of the teams which exist in the
+// organisation
+func existingTeams(ctx context.Context, gh *github.Client, org string, slugs []string) ([]string, error) {
+	var existing []string
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := gh.Teams.ListTeams(ctx, org, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list teams of %s", org)
+		}
+		for _, team := range page {
+			if containsIgnoreCase(slugs, team.GetSlug()) {
+				existing = append(existing, team.GetSlug())
+			}
+		}
+		if resp.NextPage == 0 {
+			return existing, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const testCodeOwners = `# Default owners
+*       @rhuss
+
+*.md    @syndesisio/docs docs@example.com
+/pkg/   @roland @syndesisio/core   # webhook and config
+docs/*  @other-org/writers
+apps/   @jimmi
+/vendor/
+`
+
+func TestCodeOwnersOf(t *testing.T) {
+	rules, err := parseCodeOwners(testCodeOwners)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file     string
+		expected []string
+	}{
+		{"Makefile", []string{"@rhuss"}},
+		{"README.md", []string{"@syndesisio/docs", "docs@example.com"}},
+		{"pkg/webhook/wip_check.go", []string{"@roland", "@syndesisio/core"}},
+		{"docs/index.md", []string{"@other-org/writers"}},
+		{"docs/api/index.md", []string{"@syndesisio/docs", "docs@example.com"}},
+		{"web/apps/main.js", []string{"@jimmi"}},
+		{"vendor/github.com/pkg/errors/errors.go", nil},
+	}
+	for _, test := range tests {
+		if owners := codeOwnersOf(rules, []string{test.file}); !reflect.DeepEqual(owners, test.expected) {
+			t.Errorf("expected owners %v of %s, got %v", test.expected, test.file, owners)
+		}
+	}
+}
+
+func TestCodeOwnersReviewers(t *testing.T) {
+	listFiles := "GET " + fixtureRepo + "/pulls/276/files"
+	requestReviewers := "POST " + fixtureRepo + "/pulls/276/requested_reviewers"
+	codeOwners := fileContentJSON(testCodeOwners)
+	teams := []interface{}{map[string]interface{}{"id": 1, "slug": "core"}}
+	ownersConfig := config.RepoConfig{ReviewerAssignment: config.ReviewerAssignmentConfig{CodeOwners: true, Unavailable: []string{"rhuss"}}}
+	files := func(names ...string) []map[string]interface{} {
+		page := make([]map[string]interface{}, 0, len(names))
+		for _, name := range names {
+			page = append(page, map[string]interface{}{"filename": name})
+		}
+		return page
+	}
+
+	tests := []struct {
+		name            string
+		author          string
+		config          config.RepoConfig
+		responses       map[string]interface{}
+		expectedRequest *github.ReviewersRequest
+	}{
+		{
+			name:   "users and teams requested",
+			author: "jimmi",
+			config: ownersConfig,
+			responses: map[string]interface{}{
+				"GET " + fixtureRepo + "/contents/.github/CODEOWNERS": codeOwners,
+				listFiles:                    files("pkg/webhook/wip_check.go", "README.md", "docs/index.md", "Makefile"),
+				"GET /orgs/syndesisio/teams": teams,
+			},
+			expectedRequest: &github.ReviewersRequest{Reviewers: []string{"roland"}, TeamReviewers: []string{"core"}},
+		},
+		{
+			name:   "author skipped",
+			author: "roland",
+			config: ownersConfig,
+			responses: map[string]interface{}{
+				"GET " + fixtureRepo + "/contents/.github/CODEOWNERS": notFound,
+				"GET " + fixtureRepo + "/contents/CODEOWNERS":         fileContentJSON("/pkg/ @roland\n"),
+				listFiles: files("pkg/webhook/wip_check.go"),
+			},
+		},
+		{
+			name:   "no CODEOWNERS",
+			author: "jimmi",
+			config: ownersConfig,
+			responses: map[string]interface{}{
+				"GET " + fixtureRepo + "/contents/.github/CODEOWNERS": notFound,
+				"GET " + fixtureRepo + "/contents/CODEOWNERS":         notFound,
+				"GET " + fixtureRepo + "/contents/docs/CODEOWNERS":    notFound,
+			},
+		},
+		{
+			name:   "not enabled",
+			author: "jimmi",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			event := pullRequestEvent("opened")
+			event.PullRequest.User = &github.User{Login: github.String(test.author)}
+			if err := (&codeOwnersReviewers{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("requesting code owners failed: %+v", err)
+			}
+			if test.expectedRequest == nil {
+				if calls := fake.mutatingCalls(); len(calls) > 0 {
+					t.Errorf("expected no calls, got %v", calls)
+				}
+				return
+			}
+			var request struct {
+				Reviewers     []string `json:"reviewers"`
+				TeamReviewers []string `json:"team_reviewers"`
+			}
+			if err := fake.requestBody(requestReviewers, &request); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(request.Reviewers, test.expectedRequest.Reviewers) || !reflect.DeepEqual(request.TeamReviewers, test.expectedRequest.TeamReviewers) {
+				t.Errorf("expected request %+v, got %+v", *test.expectedRequest, request)
+			}
+		})
+	}
+}
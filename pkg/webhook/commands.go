@@ -0,0 +1,312 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	// Single commands are switched off in the "handlers" config with
+	// "command:<name>", all of them with "commands"
+	commandsHandlerName  = "commands"
+	commandHandlerPrefix = "command:"
+
+	// Permission needed for commands not declaring one
+	defaultCommandPermission = "write"
+)
+
+// A command is a line starting with a slash, e.g. "/hold cancel"
+var commandLineRE = regexp.MustCompile(`(?m)^/([a-z][a-z0-9-]*)(?:[ \t]+(.*?))?\s*$`)
+
+// permissionRanks orders the repository roles of GitHub
+var permissionRanks = map[string]int{"none": 0, "read": 1, "triage": 2, "write": 3, "maintain": 4, "admin": 5}
+
+// repositoryPermission is the permission of a user on a repository. The
+// permission maps the triage and maintain roles to read and write, the role
+// name keeps them.
+type repositoryPermission struct {
+	Permission string `json:"permission"`
+	RoleName   string `json:"role_name"`
+}
+
+// Command is a slash command given in a comment of an issue or pull request,
+// like "/merge". Additional ones can be compiled in with RegisterCommand.
+type Command struct {
+	// Description listed by "/help"
+	Help string
+	// Arguments listed by "/help", e.g. "[cancel]"
+	Usage string
+	// Permission the commenter needs on the repository, "none", "read",
+	// "triage", "write", "maintain" or "admin". Defaults to "write".
+	Permission string
+	// Rejects the command on issues
+	PullRequestOnly bool
+	// Lets the author of the issue or pull request use the command without
+	// the permission
+	AuthorAllowed bool
+	// Run executes the command, after the permission has been checked
+	Run func(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error
+}
+
+// CommandEvent is a command found in a comment
+type CommandEvent struct {
+	Name  string
+	Args  []string
+	Event *github.IssueCommentEvent
+}
+
+// Owner returns the owner of the repository the comment was made in
+func (c CommandEvent) Owner() string {
+	return c.Event.Repo.Owner.GetLogin()
+}
+
+// Repo returns the name of the repository the comment was made in
+func (c CommandEvent) Repo() string {
+	return c.Event.Repo.GetName()
+}
+
+// Number returns the number of the commented issue or pull request
+func (c CommandEvent) Number() int {
+	return c.Event.Issue.GetNumber()
+}
+
+// Commenter returns the login of the user who gave the command
+func (c CommandEvent) Commenter() string {
+	return c.Event.Comment.User.GetLogin()
+}
+
+// IsAuthor tells whether the commenter opened the issue or pull request
+func (c CommandEvent) IsAuthor() bool {
+	return strings.EqualFold(c.Commenter(), c.Event.Issue.User.GetLogin())
+}
+
+// IsPullRequest tells whether the command was given on a pull request
+func (c CommandEvent) IsPullRequest() bool {
+	return c.Event.Issue.PullRequestLinks != nil
+}
+
+// Reply comments on the issue or pull request, mentioning the commenter
+func (c CommandEvent) Reply(ctx context.Context, gh *github.Client, text string) error {
+	body := fmt.Sprintf("@%s %s", c.Commenter(), text)
+	_, _, err := gh.Issues.CreateComment(ctx, c.Owner(), c.Repo(), c.Number(), &github.IssueComment{
+		Body: &body,
+	})
+	return errors.Wrapf(err, "failed to comment on %s", c.Event.Issue.GetHTMLURL())
+}
+
+var (
+	commandsMu sync.RWMutex
+	commands   = make(map[string]Command)
+)
+
+func init() {
+	RegisterCommand("help", helpCommand)
+	RegisterCommand("retest", retestCommand)
+	RegisterCommand("merge", mergeCommand)
+	RegisterCommand("hold", holdCommand)
+	RegisterCommand("unhold", unholdCommand)
+	RegisterCommand("lgtm", lgtmCommand)
+	RegisterCommand("assign", assignCommand)
+	RegisterCommand("unassign", unassignCommand)
+	RegisterCommand("cc", ccCommand)
+	RegisterCommand("uncc", unccCommand)
+	RegisterCommand("label", labelCommand)
+	RegisterCommand("remove-label", removeLabelCommand)
+	RegisterCommand("close", closeCommand)
+	RegisterCommand("reopen", reopenCommand)
+	RegisterCommand("milestone", milestoneCommand)
+	RegisterCommand("backport", backportCommand)
+}
+
+// RegisterCommand adds a slash command. It panics if the name is taken
+// already or the command has no Run function.
+func RegisterCommand(name string, command Command) {
+	if command.Run == nil {
+		panic("webhook: RegisterCommand called without Run function for command " + name)
+	}
+	if command.Permission == "" {
+		command.Permission = defaultCommandPermission
+	}
+	if _, found := permissionRanks[command.Permission]; !found {
+		panic("webhook: RegisterCommand called with unknown permission " + command.Permission + " for command " + name)
+	}
+
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	if _, found := commands[name]; found {
+		panic("webhook: RegisterCommand called twice for command " + name)
+	}
+	commands[name] = command
+}
+
+func lookupCommand(name string) (Command, bool) {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+	command, found := commands[name]
+	return command, found
+}
+
+// commandNames returns the names of all registered commands, sorted
+func commandNames() []string {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// commandEnabled tells whether a repository switched a command on
+func commandEnabled(name string, repoConfig config.RepoConfig) bool {
+	return repoConfig.HandlerEnabled(commandHandlerPrefix + name)
+}
+
+// parseCommands returns the commands of a comment, each only once. Unknown
+// commands are returned as well, they may be handled elsewhere, like
+// "/approve".
+func parseCommands(body string) [][]string {
+	var parsed [][]string
+	seen := make(map[string]bool)
+	for _, match := range commandLineRE.FindAllStringSubmatch(strings.Replace(body, "\r\n", "\n", -1), -1) {
+		if seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		parsed = append(parsed, append([]string{match[1]}, strings.Fields(match[2])...))
+	}
+	return parsed
+}
+
+// slashCommands runs the registered commands given in new comments
+type slashCommands struct{}
+
+func (h *slashCommands) EventTypesHandled() []string {
+	return []string{"issue_comment"}
+}
+
+func (h *slashCommands) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	event, ok := eventObject.(*github.IssueCommentEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	if strings.ToLower(event.GetAction()) != "created" || strings.EqualFold(event.Comment.User.GetType(), "Bot") {
+		return nil
+	}
+
+	var err error
+	for _, parsed := range parseCommands(event.Comment.GetBody()) {
+		command, found := lookupCommand(parsed[0])
+		if !found || !commandEnabled(parsed[0], config) {
+			continue
+		}
+		cmd := CommandEvent{Name: parsed[0], Args: parsed[1:], Event: event}
+		cmdLogger := logger.With(zap.String("command", cmd.Name), zap.String("user", cmd.Commenter()), zap.Int("number", cmd.Number()))
+		err = multierr.Combine(err, runCommand(ctx, command, cmd, gh, gql, config, cmdLogger))
+	}
+	return err
+}
+
+// runCommand checks whether a command may be run by the commenter and runs
+// it. Rejected commands are answered with a comment.
+func runCommand(ctx context.Context, command Command, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if command.PullRequestOnly && !cmd.IsPullRequest() {
+		logger.Debug("command rejected on issue")
+		return cmd.Reply(ctx, gh, fmt.Sprintf("`/%s` can only be used on pull requests.", cmd.Name))
+	}
+
+	if command.Permission != "none" && !(command.AuthorAllowed && cmd.IsAuthor()) {
+		permission, err := commenterPermission(ctx, gh, cmd)
+		if err != nil {
+			return err
+		}
+		if permissionRanks[permission] < permissionRanks[command.Permission] {
+			logger.Info("command rejected, missing permission", zap.String("permission", permission))
+			return cmd.Reply(ctx, gh, fmt.Sprintf("you need %s permission on this repository to use `/%s`.", command.Permission, cmd.Name))
+		}
+	}
+
+	logger.Info("running command", zap.Strings("args", cmd.Args))
+	return errors.Wrapf(command.Run(ctx, cmd, gh, gql, config, logger), "command /%s", cmd.Name)
+}
+
+// commenterPermission returns the role of the commenter, "none" for users who
+// are not collaborators of a private repository. Custom roles are reduced to
+// the permission they are based on.
+func commenterPermission(ctx context.Context, gh *github.Client, cmd CommandEvent) (string, error) {
+	req, err := gh.NewRequest("GET", fmt.Sprintf("repos/%v/%v/collaborators/%v/permission", cmd.Owner(), cmd.Repo(), cmd.Commenter()), nil)
+	if err != nil {
+		return "", err
+	}
+	var permission repositoryPermission
+	if _, err := gh.Do(ctx, req, &permission); err != nil {
+		if errResp, ok := err.(*github.ErrorResponse); ok && errResp.Response.StatusCode == http.StatusNotFound {
+			return "none", nil
+		}
+		return "", errors.Wrapf(err, "failed to get permission of %s on %s/%s", cmd.Commenter(), cmd.Owner(), cmd.Repo())
+	}
+	if role := strings.ToLower(permission.RoleName); permissionRanks[role] > 0 {
+		return role, nil
+	}
+	return strings.ToLower(permission.Permission), nil
+}
+
+var helpCommand = Command{
+	Help:       "Lists the commands available in this repository",
+	Permission: "read",
+	Run:        runHelp,
+}
+
+// runHelp lists the commands enabled in the repository
+func runHelp(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	var buf bytes.Buffer
+	buf.WriteString("these commands are available here:\n\n| Command | Description | Permission |\n| --- | --- | --- |\n")
+	for _, name := range commandNames() {
+		command, _ := lookupCommand(name)
+		if !commandEnabled(name, config) {
+			continue
+		}
+		usage := "/" + name
+		if command.Usage != "" {
+			usage += " " + command.Usage
+		}
+		help := command.Help
+		if command.PullRequestOnly {
+			help += " (pull requests only)"
+		}
+		permission := command.Permission
+		if command.AuthorAllowed {
+			permission += " or author"
+		}
+		fmt.Fprintf(&buf, "| `%s` | %s | %s |\n", usage, help, permission)
+	}
+	return cmd.Reply(ctx, gh, buf.String())
+}
@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+// Runs of the "ping" command registered by the test
+var pings []CommandEvent
+
+func init() {
+	RegisterCommand("ping", Command{
+		Help:            "Answers with pong",
+		Usage:           "[text]",
+		PullRequestOnly: true,
+		Run: func(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+			pings = append(pings, cmd)
+			return nil
+		},
+	})
+}
+
+func TestParseCommands(t *testing.T) {
+	body := "Looks good.\r\n/ping  a b \r\n/approve\n /help\n/ping c\n/Help\n"
+	expected := [][]string{{"ping", "a", "b"}, {"approve"}}
+	if parsed := parseCommands(body); !reflect.DeepEqual(parsed, expected) {
+		t.Errorf("expected %q, got %q", expected, parsed)
+	}
+}
+
+func commentEvent(body string, login string, userType string, pullRequest bool) *github.IssueCommentEvent {
+	issue := &github.Issue{Number: github.Int(fixturePR)}
+	if pullRequest {
+		issue.PullRequestLinks = &github.PullRequestLinks{}
+	}
+	return &github.IssueCommentEvent{
+		Action: github.String("created"),
+		Issue:  issue,
+		Comment: &github.IssueComment{
+			Body: github.String(body),
+			User: &github.User{Login: github.String(login), Type: github.String(userType)},
+		},
+		Repo: &github.Repository{Name: github.String("syndesis-rest"), Owner: &github.User{Login: github.String("syndesisio")}},
+	}
+}
+
+func permissionJSON(permission string) map[string]interface{} {
+	return map[string]interface{}{"permission": permission}
+}
+
+func TestSlashCommands(t *testing.T) {
+	comments := "POST " + fixtureRepo + "/issues/276/comments"
+	tests := []struct {
+		name        string
+		event       *github.IssueCommentEvent
+		config      config.RepoConfig
+		permission  interface{}
+		pings       int
+		reply       string
+		expectCalls []string
+	}{
+		{
+			name:       "allowed",
+			event:      commentEvent("/ping me", "roland", "User", true),
+			permission: permissionJSON("write"),
+			pings:      1,
+		},
+		{
+			name:        "missing permission",
+			event:       commentEvent("/ping", "roland", "User", true),
+			permission:  permissionJSON("read"),
+			reply:       "@roland you need write permission on this repository to use `/ping`.",
+			expectCalls: []string{comments},
+		},
+		{
+			name:        "no collaborator",
+			event:       commentEvent("/ping", "roland", "User", true),
+			permission:  notFound,
+			reply:       "@roland you need write permission on this repository to use `/ping`.",
+			expectCalls: []string{comments},
+		},
+		{
+			name:        "issue",
+			event:       commentEvent("/ping", "roland", "User", false),
+			reply:       "@roland `/ping` can only be used on pull requests.",
+			expectCalls: []string{comments},
+		},
+		{
+			name:  "unknown command",
+			event: commentEvent("/approve", "roland", "User", true),
+		},
+		{
+			name:  "bot",
+			event: commentEvent("/ping", "pure-bot[bot]", "Bot", true),
+		},
+		{
+			name:   "disabled",
+			event:  commentEvent("/ping", "roland", "User", true),
+			config: config.RepoConfig{Handlers: map[string]bool{"command:ping": false}},
+		},
+		{
+			name:        "help",
+			event:       commentEvent("/help", "roland", "User", false),
+			permission:  permissionJSON("read"),
+			reply:       "| `/ping [text]` | Answers with pong (pull requests only) | write |",
+			expectCalls: []string{comments},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pings = nil
+			responses := make(map[string]interface{})
+			if test.permission != nil {
+				responses["GET "+fixtureRepo+"/collaborators/roland/permission"] = test.permission
+			}
+			fake := newFakeGitHub(t, responses)
+			defer fake.close()
+
+			if err := (&slashCommands{}).HandleEvent(context.Background(), test.event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("handler failed: %+v", err)
+			}
+			if len(pings) != test.pings {
+				t.Errorf("expected %d runs, got %d", test.pings, len(pings))
+			} else if test.pings > 0 && !reflect.DeepEqual(pings[0].Args, []string{"me"}) {
+				t.Errorf("expected arguments [me], got %q", pings[0].Args)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectCalls, calls)
+			}
+			if test.reply == "" {
+				return
+			}
+			var comment github.IssueComment
+			if err := fake.requestBody(comments, &comment); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(comment.GetBody(), test.reply) {
+				t.Errorf("expected reply containing %q, got %q", test.reply, comment.GetBody())
+			}
+		})
+	}
+}
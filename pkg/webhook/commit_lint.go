@@ -0,0 +1,178 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const (
+	commitLintCheckName = "pure-bot/commit-lint"
+	commitLintCommits   = "commits"
+	commitLintTitle     = "title"
+)
+
+var (
+	defaultCommitTypes = []string{"build", "chore", "ci", "docs", "feat", "fix", "perf", "refactor", "revert", "style", "test"}
+	// type(scope)!: description
+	conventionalCommitRE = regexp.MustCompile(`^([A-Za-z]+)(?:\(([^()]*)\))?(!)?: (\S.*)$`)
+)
+
+// commitLint publishes a check run telling whether the commit messages of a
+// pull request, or its title when squash merging, follow the Conventional
+// Commits specification
+type commitLint struct{}
+
+func (h *commitLint) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *commitLint) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if !config.CommitLint.Enabled {
+		return nil
+	}
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	target := commitLintTarget(config)
+	switch strings.ToLower(event.GetAction()) {
+	case "opened", "reopened", "synchronize":
+	case "edited":
+		if target != commitLintTitle {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	pr := event.PullRequest
+	owner, repository := event.Repo.Owner.GetLogin(), event.Repo.GetName()
+	opts := github.CreateCheckRunOptions{
+		Name:    commitLintCheckName,
+		HeadSHA: pr.Head.GetSHA(),
+		Status:  github.String("completed"),
+		Output:  &github.CheckRunOutput{},
+	}
+
+	if target == commitLintTitle {
+		if problem := lintCommitHeader(pr.GetTitle(), config.CommitLint); problem != "" {
+			opts.Conclusion = github.String("failure")
+			opts.Output.Title = github.String("The pull request title is not a conventional commit message")
+			opts.Output.Summary = github.String(fmt.Sprintf("`%s`: %s\n\n%s", pr.GetTitle(), problem, commitLintHelp(config.CommitLint)))
+		} else {
+			opts.Conclusion = github.String("success")
+			opts.Output.Title = github.String("The pull request title is a conventional commit message")
+			opts.Output.Summary = github.String("The title becomes the message of the squashed commit.")
+		}
+	} else {
+		commits, err := listPullRequestCommits(ctx, gh, owner, repository, pr.GetNumber())
+		if err != nil {
+			return err
+		}
+		for _, commit := range commits {
+			// Merge commits are created by git with a fixed message
+			if len(commit.Parents) > 1 {
+				continue
+			}
+			subject := commitSubject(commit.Commit.GetMessage())
+			if problem := lintCommitHeader(subject, config.CommitLint); problem != "" {
+				// Annotations need a path, commits are named by their SHA
+				// instead
+				opts.Output.Annotations = append(opts.Output.Annotations, &github.CheckRunAnnotation{
+					Path:            github.String(shortSHA(commit.GetSHA())),
+					BlobHRef:        github.String(commit.GetHTMLURL()),
+					StartLine:       github.Int(1),
+					EndLine:         github.Int(1),
+					AnnotationLevel: github.String("failure"),
+					Title:           github.String(subject),
+					Message:         github.String(problem),
+				})
+			}
+		}
+		if failed := len(opts.Output.Annotations); failed > 0 {
+			logger.Info("Commit messages not following the Conventional Commits", zap.Int("pr", pr.GetNumber()), zap.Int("commits", failed))
+			opts.Conclusion = github.String("failure")
+			opts.Output.Title = github.String(fmt.Sprintf("%d of %d commit messages are not conventional", failed, len(commits)))
+			opts.Output.Summary = github.String(commitLintHelp(config.CommitLint))
+		} else {
+			opts.Conclusion = github.String("success")
+			opts.Output.Title = github.String("All commit messages are conventional")
+			opts.Output.Summary = github.String(fmt.Sprintf("Checked %d commits.", len(commits)))
+		}
+	}
+
+	_, _, err := gh.Checks.CreateCheckRun(ctx, owner, repository, opts)
+	return errors.Wrapf(err, "failed to create check run %s for PR %s", commitLintCheckName, pr.GetHTMLURL())
+}
+
+func commitLintTarget(config config.RepoConfig) string {
+	if config.CommitLint.Target != "" {
+		return config.CommitLint.Target
+	}
+	if config.MergeMethod == "squash" {
+		return commitLintTitle
+	}
+	return commitLintCommits
+}
+
+// lintCommitHeader tells why the first line of a commit message doesn't
+// follow the Conventional Commits, an empty string if it does
+func lintCommitHeader(header string, cfg config.CommitLintConfig) string {
+	match := conventionalCommitRE.FindStringSubmatch(header)
+	if match == nil {
+		return `expected "type(scope): description"`
+	}
+	commitType, scope := match[1], match[2]
+
+	types := cfg.Types
+	if len(types) == 0 {
+		types = defaultCommitTypes
+	}
+	if !containsIgnoreCase(types, commitType) {
+		return fmt.Sprintf("unknown type %q", commitType)
+	}
+	if scope == "" {
+		if cfg.RequireScope {
+			return "missing scope"
+		}
+		return ""
+	}
+	if len(cfg.Scopes) > 0 && !containsIgnoreCase(cfg.Scopes, scope) {
+		return fmt.Sprintf("unknown scope %q", scope)
+	}
+	return ""
+}
+
+func commitLintHelp(cfg config.CommitLintConfig) string {
+	types := cfg.Types
+	if len(types) == 0 {
+		types = defaultCommitTypes
+	}
+	help := "Commit messages must follow the [Conventional Commits](https://www.conventionalcommits.org/) like `feat(webhook): add size labels`. Allowed types are " + strings.Join(types, ", ") + "."
+	if len(cfg.Scopes) > 0 {
+		help += " Allowed scopes are " + strings.Join(cfg.Scopes, ", ") + "."
+	}
+	return help
+}
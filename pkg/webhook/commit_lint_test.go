@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestLintCommitHeader(t *testing.T) {
+	scoped := config.CommitLintConfig{Scopes: []string{"webhook", "config"}, RequireScope: true}
+	tests := []struct {
+		header   string
+		cfg      config.CommitLintConfig
+		expected string
+	}{
+		{"feat: add size labels", config.CommitLintConfig{}, ""},
+		{"fix(webhook)!: drop the wip label", config.CommitLintConfig{}, ""},
+		{"Add size labels", config.CommitLintConfig{}, `expected "type(scope): description"`},
+		{"feat:add size labels", config.CommitLintConfig{}, `expected "type(scope): description"`},
+		{"feature: add size labels", config.CommitLintConfig{}, `unknown type "feature"`},
+		{"feature: add size labels", config.CommitLintConfig{Types: []string{"feature"}}, ""},
+		{"feat(webhook): add size labels", scoped, ""},
+		{"feat(cmd): add a flag", scoped, `unknown scope "cmd"`},
+		{"feat: add size labels", scoped, "missing scope"},
+	}
+	for _, test := range tests {
+		if problem := lintCommitHeader(test.header, test.cfg); problem != test.expected {
+			t.Errorf("expected %q for %q, got %q", test.expected, test.header, problem)
+		}
+	}
+}
+
+func TestCommitLint(t *testing.T) {
+	listCommits := "GET " + fixtureRepo + "/pulls/276/commits"
+	createCheckRun := "POST " + fixtureRepo + "/check-runs"
+	lintConfig := config.RepoConfig{CommitLint: config.CommitLintConfig{Enabled: true}}
+
+	tests := []struct {
+		name                string
+		action              string
+		config              config.RepoConfig
+		commits             []interface{}
+		expectedConclusion  string
+		expectedAnnotations []string
+	}{
+		{
+			name:   "conventional commits",
+			action: "synchronize",
+			config: lintConfig,
+			commits: []interface{}{
+				commitJSON("1111111111111111111111111111111111111111", "roland@example.com", "feat: add size labels\n\nLabels PRs by diff size.", 1),
+				commitJSON("2222222222222222222222222222222222222222", "roland@example.com", "Merge branch 'master'", 2),
+			},
+			expectedConclusion: "success",
+		},
+		{
+			name:   "unconventional commit annotated",
+			action: "synchronize",
+			config: lintConfig,
+			commits: []interface{}{
+				commitJSON("1111111111111111111111111111111111111111", "roland@example.com", "feat: add size labels", 1),
+				commitJSON("3333333333333333333333333333333333333333", "roland@example.com", "Fix typo", 1),
+			},
+			expectedConclusion:  "failure",
+			expectedAnnotations: []string{"3333333"},
+		},
+		{
+			name:               "squash title",
+			action:             "edited",
+			config:             config.RepoConfig{MergeMethod: "squash", CommitLint: lintConfig.CommitLint},
+			expectedConclusion: "failure",
+		},
+		{
+			name:   "title edited without squashing",
+			action: "edited",
+			config: lintConfig,
+		},
+		{
+			name:   "not enabled",
+			action: "synchronize",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, map[string]interface{}{listCommits: test.commits})
+			defer fake.close()
+
+			event := pullRequestEvent(test.action)
+			event.PullRequest.Title = github.String("Add size labels")
+			if err := (&commitLint{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("commit lint failed: %+v", err)
+			}
+			if test.expectedConclusion == "" {
+				if calls := fake.mutatingCalls(); len(calls) > 0 {
+					t.Errorf("expected no calls, got %v", calls)
+				}
+				return
+			}
+
+			// Decoded by the names of the checks API rather than by
+			// go-github's types
+			var checkRun struct {
+				Name       string `json:"name"`
+				Conclusion string `json:"conclusion"`
+				Output     struct {
+					Annotations []struct {
+						Path            string `json:"path"`
+						AnnotationLevel string `json:"annotation_level"`
+					} `json:"annotations"`
+				} `json:"output"`
+			}
+			if err := fake.requestBody(createCheckRun, &checkRun); err != nil {
+				t.Fatal(err)
+			}
+			if checkRun.Name != commitLintCheckName || checkRun.Conclusion != test.expectedConclusion {
+				t.Errorf("expected %s check run concluding %s, got %+v", commitLintCheckName, test.expectedConclusion, checkRun)
+			}
+			var annotated []string
+			for _, annotation := range checkRun.Output.Annotations {
+				if annotation.AnnotationLevel != "failure" {
+					t.Errorf("expected failure annotation, got %s", annotation.AnnotationLevel)
+				}
+				annotated = append(annotated, annotation.Path)
+			}
+			if !reflect.DeepEqual(annotated, test.expectedAnnotations) {
+				t.Errorf("expected annotations of %v, got %v", test.expectedAnnotations, annotated)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const dashboardPath = "/admin/dashboard"
+
+// Number of errors shown on the dashboard
+const recentErrorsSize = 20
+
+// dashboard is the read-only overview of the bot's activity
+type dashboard struct {
+	Generated  time.Time
+	RateLimits []rateLimitStatus
+	Repos      []repoActivity
+	Errors     []deliveryError
+}
+
+type rateLimitStatus struct {
+	Installation string
+	// -1 if no request has been made yet
+	Remaining    int
+	Reset        time.Time
+	BlockedUntil time.Time
+}
+
+// repoActivity collects the pending and merged pull requests of a repository
+type repoActivity struct {
+	Repo    string
+	Pending []pendingMerge
+	Merged  []recentMerge
+}
+
+// deliveryError is a failed delivery or handler
+type deliveryError struct {
+	DeliveryID string
+	Type       string
+	Repo       string
+	Processed  time.Time
+	Handler    string
+	Error      string
+}
+
+func collectDashboard() dashboard {
+	d := dashboard{Generated: timeNow()}
+
+	rateLimitsMu.Lock()
+	for _, limit := range rateLimits {
+		limit.mu.Lock()
+		d.RateLimits = append(d.RateLimits, rateLimitStatus{limit.installation, limit.remaining, limit.reset, limit.blockedUntil})
+		limit.mu.Unlock()
+	}
+	rateLimitsMu.Unlock()
+	sort.Slice(d.RateLimits, func(i, j int) bool { return d.RateLimits[i].Installation < d.RateLimits[j].Installation })
+
+	pending, merged := activity.snapshot()
+	repos := make(map[string]*repoActivity)
+	repo := func(name string) *repoActivity {
+		r, found := repos[name]
+		if !found {
+			r = &repoActivity{Repo: name}
+			repos[name] = r
+		}
+		return r
+	}
+	for _, p := range pending {
+		r := repo(p.Repo)
+		r.Pending = append(r.Pending, p)
+	}
+	for _, m := range merged {
+		r := repo(m.Repo)
+		r.Merged = append(r.Merged, m)
+	}
+	for _, r := range repos {
+		d.Repos = append(d.Repos, *r)
+	}
+	sort.Slice(d.Repos, func(i, j int) bool { return d.Repos[i].Repo < d.Repos[j].Repo })
+
+	for _, delivery := range recentDeliveries.recent(cap(recentDeliveries.deliveries)) {
+		if delivery.Error != "" {
+			d.Errors = append(d.Errors, deliveryError{delivery.DeliveryID, delivery.Type, delivery.Repo, delivery.Processed, "", delivery.Error})
+		}
+		for _, outcome := range delivery.Handlers {
+			if outcome.Error != "" {
+				d.Errors = append(d.Errors, deliveryError{delivery.DeliveryID, delivery.Type, delivery.Repo, delivery.Processed, outcome.Name, outcome.Error})
+			}
+		}
+		if len(d.Errors) >= recentErrorsSize {
+			d.Errors = d.Errors[:recentErrorsSize]
+			break
+		}
+	}
+	return d
+}
+
+func showDashboard(w http.ResponseWriter, logger *zap.Logger) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, collectDashboard()); err != nil {
+		logger.Error("failed to render dashboard", zap.Error(err))
+	}
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"since": func(t time.Time) string {
+		return timeNow().Sub(t).Round(time.Second).String() + " ago"
+	},
+	"timestamp": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.UTC().Format("2006-01-02 15:04:05 MST")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>PuRe Bot</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #24292e; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 0.3em 0.8em; border-bottom: 1px solid #e1e4e8; }
+.muted { color: #6a737d; }
+.error { color: #cb2431; }
+</style>
+</head>
+<body>
+<h1>PuRe Bot</h1>
+<p class="muted">Generated {{timestamp .Generated}}</p>
+
+<h2>Rate limits</h2>
+{{if .RateLimits}}
+<table>
+<tr><th>Installation</th><th>Remaining</th><th>Reset</th><th>Blocked until</th></tr>
+{{range .RateLimits}}
+<tr><td>{{.Installation}}</td><td>{{if lt .Remaining 0}}unknown{{else}}{{.Remaining}}{{end}}</td><td>{{timestamp .Reset}}</td><td>{{timestamp .BlockedUntil}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<p class="muted">No requests made yet.</p>
+{{end}}
+
+<h2>Repositories</h2>
+{{range .Repos}}
+<h3>{{.Repo}}</h3>
+{{if .Pending}}
+<table>
+<tr><th>Pending merge</th><th>Waiting on</th><th>Since</th></tr>
+{{range .Pending}}
+<tr><td><a href="{{.URL}}">#{{.Number}}</a> {{.Title}}</td><td>{{.WaitingOn}}</td><td title="{{timestamp .Since}}">{{since .Since}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{if .Merged}}
+<table>
+<tr><th>Merged</th><th>When</th></tr>
+{{range .Merged}}
+<tr><td><a href="{{.URL}}">#{{.Number}}</a> {{.Title}}</td><td title="{{timestamp .Merged}}">{{since .Merged}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{else}}
+<p class="muted">No merge activity yet.</p>
+{{end}}
+
+<h2>Recent errors</h2>
+{{if .Errors}}
+<table>
+<tr><th>When</th><th>Delivery</th><th>Repository</th><th>Handler</th><th>Error</th></tr>
+{{range .Errors}}
+<tr><td>{{timestamp .Processed}}</td><td>{{.DeliveryID}} ({{.Type}})</td><td>{{.Repo}}</td><td>{{.Handler}}</td><td class="error">{{.Error}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<p class="muted">No errors.</p>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"go.uber.org/zap"
+)
+
+func TestDashboard(t *testing.T) {
+	defer func(a *mergeActivity) { activity = a }(activity)
+	activity = newMergeActivity()
+	defer func(history *deliveryHistory) { recentDeliveries = history }(recentDeliveries)
+	recentDeliveries = newDeliveryHistory(10)
+
+	pr := func(number int, title string) *github.Issue {
+		return &github.Issue{Number: github.Int(number), Title: github.String(title)}
+	}
+	activity.waiting("syndesisio/syndesis", pr(1, "Add <script>"), "on hold")
+	activity.waiting("syndesisio/syndesis", pr(2, "Fix build"), "draft")
+	activity.waiting("syndesisio/syndesis", pr(2, "Fix build"), "statuses and checks: ci (failure)")
+	activity.waiting("syndesisio/pure-bot", pr(3, "Closed"), "draft")
+	activity.forget("syndesisio/pure-bot", 3)
+	activity.waiting("syndesisio/pure-bot", pr(4, "Merged"), "draft")
+	activity.mergedPR("syndesisio/pure-bot", pr(4, "Merged"))
+
+	pending, merged := activity.snapshot()
+	if len(pending) != 2 || pending[0].Number != 1 || pending[1].WaitingOn != "statuses and checks: ci (failure)" {
+		t.Errorf("unexpected pending merges %+v", pending)
+	}
+	if len(merged) != 1 || merged[0].Number != 4 {
+		t.Errorf("unexpected merges %+v", merged)
+	}
+
+	recentDeliveries.add(processedDelivery{DeliveryID: "72d3162e", Type: "status", Handlers: []handlerOutcome{{Name: "autoMerge", Error: "boom"}}})
+
+	handler := NewAdminHandler("secret", zap.NewNop())
+	r := httptest.NewRequest(http.MethodGet, "/admin/dashboard", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected browsers to be asked for credentials, got %d", w.Code)
+	}
+
+	r.SetBasicAuth("admin", "secret")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected dashboard, got %d", w.Code)
+	}
+	page := w.Body.String()
+	for _, expected := range []string{"syndesisio/syndesis", "ci (failure)", "Merged", "autoMerge", "boom", "Add &lt;script&gt;"} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("expected dashboard to contain %q", expected)
+		}
+	}
+}
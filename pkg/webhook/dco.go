@@ -0,0 +1,144 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const dcoCheckName = "pure-bot/dco"
+
+var signedOffByRE = regexp.MustCompile(`(?m)^Signed-off-by: .*<([^>]+)>\s*$`)
+
+// dcoSignOff checks that every commit of a pull request carries a
+// Developer Certificate of Origin sign-off by its author
+type dcoSignOff struct{}
+
+func (h *dcoSignOff) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *dcoSignOff) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if !config.DCO.Enabled {
+		return nil
+	}
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	action := strings.ToLower(event.GetAction())
+	if action != "opened" && action != "reopened" && action != "synchronize" {
+		return nil
+	}
+	pr := event.PullRequest
+	if containsIgnoreCase(config.DCO.ExemptAuthors, pr.User.GetLogin()) {
+		return nil
+	}
+
+	owner, repository := event.Repo.Owner.GetLogin(), event.Repo.GetName()
+	commits, err := listPullRequestCommits(ctx, gh, owner, repository, pr.GetNumber())
+	if err != nil {
+		return err
+	}
+
+	var summary []string
+	missing := 0
+	for _, commit := range commits {
+		line := fmt.Sprintf("`%s` %s", shortSHA(commit.GetSHA()), commitSubject(commit.Commit.GetMessage()))
+		if problem := signOffProblem(commit); problem != "" {
+			missing++
+			summary = append(summary, "- [ ] "+line+": "+problem)
+		} else {
+			summary = append(summary, "- [x] "+line)
+		}
+	}
+
+	opts := github.CreateCheckRunOptions{
+		Name:    dcoCheckName,
+		HeadSHA: pr.Head.GetSHA(),
+		Status:  github.String("completed"),
+		Output:  &github.CheckRunOutput{},
+	}
+	if missing == 0 {
+		opts.Conclusion = github.String("success")
+		opts.Output.Title = github.String("All commits are signed off")
+	} else {
+		logger.Info("Commits without sign-off", zap.Int("pr", pr.GetNumber()), zap.Int("missing", missing))
+		opts.Conclusion = github.String("failure")
+		opts.Output.Title = github.String(fmt.Sprintf("%d of %d commits are not signed off", missing, len(commits)))
+		summary = append(summary, "",
+			"Sign off your commits to certify the [Developer Certificate of Origin](https://developercertificate.org/), "+
+				"e.g. with `git rebase --signoff "+pr.Base.GetRef()+"` and a force push.")
+	}
+	opts.Output.Summary = github.String(strings.Join(summary, "\n"))
+
+	_, _, err = gh.Checks.CreateCheckRun(ctx, owner, repository, opts)
+	return errors.Wrapf(err, "failed to create check run %s for PR %s", dcoCheckName, pr.GetHTMLURL())
+}
+
+// signOffProblem tells why a commit lacks a valid sign-off, an empty string
+// if it is signed off by its author. Merge commits need no sign-off.
+func signOffProblem(commit *github.RepositoryCommit) string {
+	if len(commit.Parents) > 1 {
+		return ""
+	}
+	matches := signedOffByRE.FindAllStringSubmatch(commit.Commit.GetMessage(), -1)
+	if len(matches) == 0 {
+		return "no Signed-off-by line"
+	}
+	email := commit.Commit.Author.GetEmail()
+	for _, match := range matches {
+		if strings.EqualFold(match[1], email) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("not signed off by its author %s", email)
+}
+
+func listPullRequestCommits(ctx context.Context, gh *github.Client, owner, repository string, number int) ([]*github.RepositoryCommit, error) {
+	var commits []*github.RepositoryCommit
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := gh.PullRequests.ListCommits(ctx, owner, repository, number, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list commits of pull request %s/%s#%d", owner, repository, number)
+		}
+		commits = append(commits, page...)
+		if resp.NextPage == 0 {
+			return commits, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func commitSubject(message string) string {
+	return strings.SplitN(message, "\n", 2)[0]
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
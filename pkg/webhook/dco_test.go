@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func commitJSON(sha, email, message string, parents int) map[string]interface{} {
+	parentObjects := make([]map[string]interface{}, parents)
+	for i := range parentObjects {
+		parentObjects[i] = map[string]interface{}{"sha": "0000000000000000000000000000000000000000"}
+	}
+	return map[string]interface{}{
+		"sha":     sha,
+		"commit":  map[string]interface{}{"message": message, "author": map[string]interface{}{"name": "Roland", "email": email}},
+		"parents": parentObjects,
+	}
+}
+
+func TestDCOSignOff(t *testing.T) {
+	listCommits := "GET " + fixtureRepo + "/pulls/276/commits"
+	createCheckRun := "POST " + fixtureRepo + "/check-runs"
+	dcoConfig := config.RepoConfig{DCO: config.DCOConfig{Enabled: true, ExemptAuthors: []string{"dependabot[bot]"}}}
+	signedOff := commitJSON("1111111111111111111111111111111111111111", "roland@example.com", "Add size labels\n\nSigned-off-by: Roland <roland@example.com>\n", 1)
+
+	tests := []struct {
+		name               string
+		author             string
+		config             config.RepoConfig
+		commits            []interface{}
+		expectedConclusion string
+		expectedSummary    []string
+	}{
+		{
+			name:               "all signed off",
+			config:             dcoConfig,
+			commits:            []interface{}{signedOff, commitJSON("2222222222222222222222222222222222222222", "roland@example.com", "Merge branch 'master'", 2)},
+			expectedConclusion: "success",
+			expectedSummary:    []string{"- [x] `1111111` Add size labels", "- [x] `2222222` Merge branch 'master'"},
+		},
+		{
+			name:   "sign-off missing",
+			config: dcoConfig,
+			commits: []interface{}{
+				signedOff,
+				commitJSON("3333333333333333333333333333333333333333", "roland@example.com", "Fix typo", 1),
+				commitJSON("4444444444444444444444444444444444444444", "roland@example.com", "Fix tests\n\nSigned-off-by: Tom <tom@example.com>", 1),
+			},
+			expectedConclusion: "failure",
+			expectedSummary: []string{
+				"- [x] `1111111` Add size labels",
+				"- [ ] `3333333` Fix typo: no Signed-off-by line",
+				"- [ ] `4444444` Fix tests: not signed off by its author roland@example.com",
+			},
+		},
+		{
+			name:   "exempt author",
+			author: "dependabot[bot]",
+			config: dcoConfig,
+		},
+		{
+			name: "not enabled",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, map[string]interface{}{listCommits: test.commits})
+			defer fake.close()
+
+			author := test.author
+			if author == "" {
+				author = "roland"
+			}
+			event := &github.PullRequestEvent{
+				Action: github.String("synchronize"),
+				PullRequest: &github.PullRequest{
+					Number: github.Int(fixturePR),
+					User:   &github.User{Login: github.String(author)},
+					Head:   &github.PullRequestBranch{SHA: github.String(fixtureSHA)},
+					Base:   &github.PullRequestBranch{Ref: github.String("master")},
+				},
+				Repo: &github.Repository{Name: github.String("syndesis-rest"), Owner: &github.User{Login: github.String("syndesisio")}},
+			}
+			if err := (&dcoSignOff{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("DCO check failed: %+v", err)
+			}
+			if test.expectedConclusion == "" {
+				if calls := fake.mutatingCalls(); len(calls) > 0 {
+					t.Errorf("expected no calls, got %v", calls)
+				}
+				return
+			}
+
+			var checkRun github.CreateCheckRunOptions
+			if err := fake.requestBody(createCheckRun, &checkRun); err != nil {
+				t.Fatal(err)
+			}
+			if checkRun.Name != dcoCheckName || checkRun.HeadSHA != fixtureSHA || checkRun.GetConclusion() != test.expectedConclusion {
+				t.Errorf("expected %s check run concluding %s for %s, got %+v", dcoCheckName, test.expectedConclusion, fixtureSHA, checkRun)
+			}
+			lines := strings.Split(checkRun.GetOutput().GetSummary(), "\n")
+			if len(lines) > len(test.expectedSummary) {
+				lines = lines[:len(test.expectedSummary)]
+			}
+			if !reflect.DeepEqual(lines, test.expectedSummary) {
+				t.Errorf("expected summary\n%s\ngot\n%s", strings.Join(test.expectedSummary, "\n"), checkRun.GetOutput().GetSummary())
+			}
+		})
+	}
+}
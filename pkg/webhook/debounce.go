@@ -0,0 +1,131 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syndesisio/pure-bot/pkg/audit"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Status and workflow run events arriving within this window for the same
+// commit are evaluated together, unless configured otherwise
+const defaultDebounceWindow = 10 * time.Second
+
+var (
+	pendingEvaluationsMu sync.Mutex
+	// closing the channel cancels the scheduled evaluation
+	pendingEvaluations = make(map[string]chan struct{})
+)
+
+// debounceWindow returns the configured window, a negative window disables
+// debouncing
+func debounceWindow(config config.RepoConfig) time.Duration {
+	if config.DebounceWindow == 0 {
+		return defaultDebounceWindow
+	}
+	return config.DebounceWindow
+}
+
+// debounce runs evaluate once the window has passed. Further calls for the
+// same key within the window are coalesced into this single evaluation.
+// Without a window, or when the bot is shutting down, evaluate is called
+// right away with ctx and its error is returned. Otherwise it is called with a context which is only done on
+// shutdown, and a failure queues the delivery of ctx again.
+func debounce(ctx context.Context, key string, window time.Duration, logger *zap.Logger, evaluate func(ctx context.Context) error) error {
+	if window < 0 {
+		metrics.Add(mergeEvaluationsPerformed, 1)
+		return evaluate(ctx)
+	}
+
+	pendingEvaluationsMu.Lock()
+	if _, pending := pendingEvaluations[key]; pending {
+		pendingEvaluationsMu.Unlock()
+		logger.Debug("Evaluation already scheduled", zap.String("key", key))
+		return nil
+	}
+	cancel := make(chan struct{})
+	pendingEvaluations[key] = cancel
+	pendingEvaluationsMu.Unlock()
+
+	// The span covers the wait, so that it shows up in the event's trace
+	_, span := tracer.Start(ctx, "scheduled evaluation")
+	span.SetAttributes(attribute.String("key", key), attribute.String("window", window.String()))
+	trigger := audit.TriggerFromContext(ctx)
+	delivery := queuedDeliveryFrom(ctx)
+	scheduled := goAsync(func(ctx context.Context) {
+		defer span.End()
+		ctx = audit.WithTrigger(trace.ContextWithSpan(ctx, span), trigger)
+		select {
+		case <-time.After(window):
+		case <-cancel:
+			logger.Info("Scheduled evaluation cancelled", zap.String("key", key))
+			span.SetAttributes(attribute.Bool("cancelled", true))
+			return
+		case <-ctx.Done():
+			logger.Warn("Scheduled evaluation cancelled by shutdown", zap.String("key", key))
+			span.SetAttributes(attribute.Bool("cancelled", true))
+			return
+		}
+
+		// Events arriving from now on need a new evaluation
+		pendingEvaluationsMu.Lock()
+		if pendingEvaluations[key] == cancel {
+			delete(pendingEvaluations, key)
+		}
+		pendingEvaluationsMu.Unlock()
+
+		metrics.Add(mergeEvaluationsPerformed, 1)
+		if err := evaluate(ctx); err != nil {
+			logger.Error("Scheduled evaluation failed", zap.String("key", key), zap.Error(err))
+			tracing.SetError(span, err)
+			delivery.requeue(err, logger)
+		}
+	})
+	if !scheduled {
+		// The delivery is drained on shutdown, evaluate it right away
+		span.End()
+		pendingEvaluationsMu.Lock()
+		if pendingEvaluations[key] == cancel {
+			delete(pendingEvaluations, key)
+		}
+		pendingEvaluationsMu.Unlock()
+		metrics.Add(mergeEvaluationsPerformed, 1)
+		return evaluate(ctx)
+	}
+	return nil
+}
+
+// cancelScheduled cancels all scheduled evaluations whose key starts with the
+// given prefix
+func cancelScheduled(prefix string) {
+	pendingEvaluationsMu.Lock()
+	defer pendingEvaluationsMu.Unlock()
+
+	for key, cancel := range pendingEvaluations {
+		if strings.HasPrefix(key, prefix) {
+			close(cancel)
+			delete(pendingEvaluations, key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"container/list"
+	"sync"
+)
+
+const defaultDedupCacheSize = 10000
+
+// deliveryDeduplicator detects redeliveries of webhook events by their
+// X-GitHub-Delivery ID
+type deliveryDeduplicator interface {
+	// firstDelivery records a delivery ID and returns false if it has been
+	// recorded before
+	firstDelivery(id string) bool
+	// forget removes a delivery ID, so that a failed delivery can be
+	// redelivered
+	forget(id string)
+}
+
+// lruDeduplicator remembers the most recent delivery IDs of this process
+type lruDeduplicator struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	ids   map[string]*list.Element
+}
+
+func newLRUDeduplicator(size int) *lruDeduplicator {
+	if size <= 0 {
+		size = defaultDedupCacheSize
+	}
+	return &lruDeduplicator{size: size, order: list.New(), ids: make(map[string]*list.Element)}
+}
+
+func (d *lruDeduplicator) firstDelivery(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, found := d.ids[id]; found {
+		d.order.MoveToFront(e)
+		return false
+	}
+	d.ids[id] = d.order.PushFront(id)
+	if d.order.Len() > d.size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.ids, oldest.Value.(string))
+	}
+	return true
+}
+
+func (d *lruDeduplicator) forget(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, found := d.ids[id]; found {
+		d.order.Remove(e)
+		delete(d.ids, id)
+	}
+}
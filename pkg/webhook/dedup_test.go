@@ -0,0 +1,25 @@
+package webhook
+
+import "testing"
+
+func TestLRUDeduplicator(t *testing.T) {
+	d := newLRUDeduplicator(2)
+
+	if !d.firstDelivery("a") || !d.firstDelivery("b") {
+		t.Fatal("expected new deliveries to be first ones")
+	}
+	if d.firstDelivery("a") {
+		t.Error("expected redelivery of a to be detected")
+	}
+
+	// b is the least recently seen one now
+	d.firstDelivery("c")
+	if !d.firstDelivery("b") {
+		t.Error("expected b to be evicted")
+	}
+
+	d.forget("c")
+	if !d.firstDelivery("c") {
+		t.Error("expected forgotten delivery to be accepted again")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const defaultHistorySize = 100
+
+// processedDelivery describes how the handlers dealt with a delivery
+type processedDelivery struct {
+	DeliveryID string    `json:"deliveryId"`
+	Type       string    `json:"type"`
+	Repo       string    `json:"repo,omitempty"`
+	Processed  time.Time `json:"processed"`
+	// Why no handler ran, e.g. because the repository is disabled
+	Skipped  string           `json:"skipped,omitempty"`
+	Handlers []handlerOutcome `json:"handlers"`
+	Error    string           `json:"error,omitempty"`
+	// Only included when a single delivery is requested
+	Payload json.RawMessage `json:"payload,omitempty"`
+
+	payload []byte
+}
+
+// handlerOutcome is the result of a single handler
+type handlerOutcome struct {
+	Name string `json:"name"`
+	// Handlers switched off for the repository don't run
+	Disabled bool `json:"disabled,omitempty"`
+	// Handlers which succeeded on an earlier attempt of a queued delivery
+	// don't run again
+	Skipped      bool    `json:"skipped,omitempty"`
+	Milliseconds float64 `json:"milliseconds,omitempty"`
+	Error        string  `json:"error,omitempty"`
+}
+
+func newHandlerOutcome(name string, start time.Time, err error) handlerOutcome {
+	outcome := handlerOutcome{Name: name, Milliseconds: time.Since(start).Seconds() * 1000}
+	if err != nil {
+		outcome.Error = err.Error()
+	}
+	return outcome
+}
+
+// deliveryHistory keeps the most recently processed deliveries, including
+// their payload so that they can be processed again
+type deliveryHistory struct {
+	mu         sync.Mutex
+	deliveries []processedDelivery
+	// Index of the oldest delivery once the history is full
+	next int
+}
+
+var recentDeliveries = newDeliveryHistory(defaultHistorySize)
+
+func newDeliveryHistory(size int) *deliveryHistory {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &deliveryHistory{deliveries: make([]processedDelivery, 0, size)}
+}
+
+// add remembers a processed delivery, replacing the oldest one when full
+func (h *deliveryHistory) add(delivery processedDelivery) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.deliveries) < cap(h.deliveries) {
+		h.deliveries = append(h.deliveries, delivery)
+		return
+	}
+	h.deliveries[h.next] = delivery
+	h.next = (h.next + 1) % len(h.deliveries)
+}
+
+// recent returns up to limit deliveries, the most recent first
+func (h *deliveryHistory) recent(limit int) []processedDelivery {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	recent := []processedDelivery{}
+	for i := 1; i <= len(h.deliveries) && len(recent) < limit; i++ {
+		recent = append(recent, h.deliveries[(h.next-i+len(h.deliveries))%len(h.deliveries)])
+	}
+	return recent
+}
+
+// get returns the most recent processing of a delivery
+func (h *deliveryHistory) get(deliveryID string) (processedDelivery, bool) {
+	h.mu.Lock()
+	size := cap(h.deliveries)
+	h.mu.Unlock()
+	for _, delivery := range h.recent(size) {
+		if delivery.DeliveryID == deliveryID {
+			return delivery, true
+		}
+	}
+	return processedDelivery{}, false
+}
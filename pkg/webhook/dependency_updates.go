@@ -0,0 +1,200 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const (
+	majorUpdate   = "major"
+	minorUpdate   = "minor"
+	patchUpdate   = "patch"
+	unknownUpdate = ""
+)
+
+var (
+	// Dependabot: "Bump lodash from 4.17.15 to 4.17.21 in /web"
+	// Renovate (custom templates): "Update lodash from 4.17.15 to 4.17.21"
+	fromToVersionRE = regexp.MustCompile("(?i)\\bfrom\\s+`?v?([^\\s`]+)`?\\s+to\\s+`?v?([^\\s`]+)`?")
+	// Renovate: "Update dependency lodash to v5" for major updates
+	renovateMajorRE = regexp.MustCompile(`(?i)\bupdate\b.*\bto\s+v(\d+)(?:\s|\)|$)`)
+	// Renovate PR table: "`4.17.15` -> `4.17.21`"
+	renovateTableRE = regexp.MustCompile("`v?([^`\\s]+)`\\s*->\\s*`v?([^`\\s]+)`")
+	// Dependabot metadata: "update-type: version-update:semver-minor"
+	dependabotMetadataRE = regexp.MustCompile(`update-type:\s*version-update:semver-(major|minor|patch)`)
+)
+
+type dependencyUpdates struct{}
+
+func (h *dependencyUpdates) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *dependencyUpdates) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+
+	updateConfig := config.DependencyUpdates
+	approvedLabel := config.Labels.Approved
+	// Disabled because not configured
+	if len(updateConfig.Authors) == 0 || approvedLabel == "" {
+		return nil
+	}
+
+	action := strings.ToLower(event.GetAction())
+	if action != "opened" && action != "reopened" {
+		return nil
+	}
+
+	pr := event.PullRequest
+	if !containsIgnoreCase(updateConfig.Authors, pr.User.GetLogin()) {
+		return nil
+	}
+
+	owner, repo, prNumber, prURL := event.Repo.Owner.GetLogin(), event.Repo.GetName(), pr.GetNumber(), pr.GetHTMLURL()
+
+	updateType := parseUpdateType(pr.GetTitle(), pr.GetBody())
+	if !containsIgnoreCase(updateConfig.AllowedUpdateTypes, updateType) {
+		logger.Info("Dependency update needs a human review", zap.String("pr", prURL), zap.String("updateType", updateType))
+		if updateConfig.NeedsReviewLabel == "" || labelsContainsLabel(pr.Labels, updateConfig.NeedsReviewLabel) {
+			return nil
+		}
+		_, _, err := gh.Issues.AddLabelsToIssue(ctx, owner, repo, prNumber, []string{updateConfig.NeedsReviewLabel})
+		if err != nil {
+			return errors.Wrapf(err, "failed to add label '%s' to PR %s", updateConfig.NeedsReviewLabel, prURL)
+		}
+		return nil
+	}
+
+	logger.Info("Approving dependency update", zap.String("pr", prURL), zap.String("updateType", updateType))
+
+	if updateConfig.SubmitReview {
+		message := fmt.Sprintf("Automatically approving %s dependency update", updateType)
+		_, _, err := gh.PullRequests.CreateReview(ctx, owner, repo, prNumber, &github.PullRequestReviewRequest{
+			Body:  &message,
+			Event: github.String("APPROVE"),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to approve PR %s", prURL)
+		}
+	}
+
+	if labelsContainsLabel(pr.Labels, approvedLabel) {
+		return nil
+	}
+	_, _, err := gh.Issues.AddLabelsToIssue(ctx, owner, repo, prNumber, []string{approvedLabel})
+	if err != nil {
+		return errors.Wrapf(err, "failed to add label '%s' to PR %s", approvedLabel, prURL)
+	}
+
+	return nil
+}
+
+// parseUpdateType determines whether a dependency update PR is a major, minor
+// or patch update. Dependabot metadata in the body takes precedence over the
+// versions mentioned in the title. An empty string is returned if the update
+// type can't be determined.
+func parseUpdateType(title string, body string) string {
+	if matches := dependabotMetadataRE.FindAllStringSubmatch(body, -1); matches != nil {
+		updateType := patchUpdate
+		for _, match := range matches {
+			updateType = maxUpdateType(updateType, match[1])
+		}
+		return updateType
+	}
+
+	if match := fromToVersionRE.FindStringSubmatch(title); match != nil {
+		return compareVersions(match[1], match[2])
+	}
+
+	if match := renovateMajorRE.FindStringSubmatch(title); match != nil {
+		return majorUpdate
+	}
+
+	// Renovate lists all updated packages in a table
+	if matches := renovateTableRE.FindAllStringSubmatch(body, -1); matches != nil {
+		updateType := patchUpdate
+		for _, match := range matches {
+			updateType = maxUpdateType(updateType, compareVersions(match[1], match[2]))
+		}
+		return updateType
+	}
+
+	return unknownUpdate
+}
+
+// compareVersions returns the update type between two semantic versions
+func compareVersions(from string, to string) string {
+	fromParts, fromOK := versionParts(from)
+	toParts, toOK := versionParts(to)
+	if !fromOK || !toOK {
+		return unknownUpdate
+	}
+
+	switch {
+	case fromParts[0] != toParts[0]:
+		return majorUpdate
+	case fromParts[1] != toParts[1]:
+		return minorUpdate
+	default:
+		return patchUpdate
+	}
+}
+
+func versionParts(version string) ([3]int, bool) {
+	var parts [3]int
+
+	// Ignore pre-release and build metadata
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	version = strings.TrimSuffix(version, ".")
+
+	fields := strings.Split(version, ".")
+	if len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// maxUpdateType returns the more significant update type, treating an
+// unknown update type as the most significant one
+func maxUpdateType(a string, b string) string {
+	rank := map[string]int{patchUpdate: 0, minorUpdate: 1, majorUpdate: 2, unknownUpdate: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
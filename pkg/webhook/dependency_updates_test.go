@@ -0,0 +1,34 @@
+package webhook
+
+import "testing"
+
+func TestParseUpdateType(t *testing.T) {
+	testCases := []struct {
+		title    string
+		body     string
+		expected string
+	}{
+		// Dependabot
+		{"Bump lodash from 4.17.15 to 4.17.21", "", patchUpdate},
+		{"Bump lodash from 4.17.15 to 4.18.0 in /web", "", minorUpdate},
+		{"build(deps): bump github.com/pkg/errors from v0.8.0 to v1.0.0", "", majorUpdate},
+		{"Bump actions/checkout from 2 to 3", "", majorUpdate},
+		{"Bump rails from `a1b2c3d` to `e4f5a6b`", "", unknownUpdate},
+		{"Bump the npm group with 3 updates", "", unknownUpdate},
+		{"Bump the npm group with 3 updates", "update-type: version-update:semver-patch\nupdate-type: version-update:semver-minor", minorUpdate},
+		{"Bump lodash from 4.17.15 to 5.0.0", "update-type: version-update:semver-patch", patchUpdate},
+		// Renovate
+		{"Update dependency lodash to v5", "", majorUpdate},
+		{"chore(deps): update module github.com/spf13/cobra to v2 (major)", "", majorUpdate},
+		{"Update dependency lodash to v4.17.21", "| lodash | [`4.17.15` -> `4.17.21`](https://renovatebot.com/diffs/npm/lodash/4.17.15/4.17.21) |", patchUpdate},
+		{"fix(deps): update dependency lodash to v4.18.0", "| lodash | `4.17.15` -> `4.18.0` |", minorUpdate},
+		{"Update all non-major dependencies", "| a | `1.0.0` -> `1.0.1` |\n| b | `v2.1.0` -> `v2.2.0` |", minorUpdate},
+		{"Update dependency lodash to v4.17.21", "", unknownUpdate},
+	}
+
+	for _, tc := range testCases {
+		if updateType := parseUpdateType(tc.title, tc.body); updateType != tc.expected {
+			t.Errorf("%q: expected update type %q, got %q", tc.title, tc.expected, updateType)
+		}
+	}
+}
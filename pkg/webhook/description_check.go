@@ -0,0 +1,187 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const (
+	descriptionContext = "pure-bot/pr-description"
+
+	// GitHub rejects longer status descriptions
+	maxStatusDescriptionLength = 140
+)
+
+var (
+	headingRE  = regexp.MustCompile(`^\s{0,3}(#{1,6})\s+(.*?)[\s#]*$`)
+	checkboxRE = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.*)$`)
+	// Placeholders of PR templates are usually HTML comments
+	htmlCommentRE = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+type descriptionCheck struct{}
+
+func (h *descriptionCheck) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *descriptionCheck) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+
+	descriptionConfig := config.Description
+	if !descriptionConfig.Enabled() {
+		return nil
+	}
+
+	action := strings.ToLower(event.GetAction())
+	if action != "opened" && action != "reopened" && action != "edited" && action != "synchronize" {
+		return nil
+	}
+
+	pr := event.PullRequest
+	if containsIgnoreCase(descriptionConfig.ExemptAuthors, pr.User.GetLogin()) {
+		return reportDescription(ctx, gh, event.Repo, pr, "The author is exempt", nil)
+	}
+
+	problems := validateDescription(pr.GetBody(), descriptionConfig)
+	if len(problems) == 0 {
+		return reportDescription(ctx, gh, event.Repo, pr, "The description is complete", nil)
+	}
+
+	logger.Debug("PR description incomplete", zap.Int("pr", pr.GetNumber()), zap.Strings("problems", problems))
+	return reportDescription(ctx, gh, event.Repo, pr, fmt.Sprintf("The description is incomplete (%d problems)", len(problems)), problems)
+}
+
+// reportDescription publishes a check run, failing if there are problems
+func reportDescription(ctx context.Context, gh *github.Client, repo *github.Repository, pr *github.PullRequest, title string, problems []string) error {
+	opts := github.CreateCheckRunOptions{
+		Name:    descriptionContext,
+		HeadSHA: pr.Head.GetSHA(),
+		Status:  github.String("completed"),
+		Output:  &github.CheckRunOutput{Title: github.String(title)},
+	}
+	if len(problems) == 0 {
+		opts.Conclusion = github.String("success")
+		opts.Output.Summary = github.String("The description of the pull request complies with the rules of the repository.")
+	} else {
+		opts.Conclusion = github.String("failure")
+		opts.Output.Summary = github.String("Please edit the description of the pull request. Missing:\n\n- " + strings.Join(problems, "\n- "))
+	}
+	_, _, err := gh.Checks.CreateCheckRun(ctx, repo.Owner.GetLogin(), repo.GetName(), opts)
+	return errors.Wrapf(err, "failed to create check run %s for PR %s", descriptionContext, pr.GetHTMLURL())
+}
+
+// descriptionIncomplete tells whether the description check fails for a PR
+func descriptionIncomplete(pr *github.PullRequest, config config.RepoConfig) bool {
+	cfg := config.Description
+	if !cfg.Enabled() || !config.HandlerEnabled("descriptionCheck") || containsIgnoreCase(cfg.ExemptAuthors, pr.User.GetLogin()) {
+		return false
+	}
+	return len(validateDescription(pr.GetBody(), cfg)) > 0
+}
+
+// validateDescription returns everything which is missing in or forbidden
+// for the description of a pull request
+func validateDescription(body string, cfg config.DescriptionConfig) []string {
+	var problems []string
+
+	if cfg.MinLength > 0 && len(strings.TrimSpace(body)) < cfg.MinLength {
+		problems = append(problems, fmt.Sprintf("at least %d characters", cfg.MinLength))
+	}
+
+	// Whether each heading has content, including that of its subsections
+	headings := make(map[string]bool)
+	type section struct {
+		name  string
+		level int
+	}
+	var open []section
+	var checked []string
+	for _, line := range strings.Split(htmlCommentRE.ReplaceAllString(body, ""), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if match := headingRE.FindStringSubmatch(line); match != nil {
+			name, level := strings.ToLower(match[2]), len(match[1])
+			for len(open) > 0 && open[len(open)-1].level >= level {
+				open = open[:len(open)-1]
+			}
+			open = append(open, section{name, level})
+			if _, found := headings[name]; !found {
+				headings[name] = false
+			}
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			for _, parent := range open {
+				headings[parent.name] = true
+			}
+		}
+		if match := checkboxRE.FindStringSubmatch(line); match != nil && strings.ToLower(match[1]) == "x" {
+			checked = append(checked, strings.ToLower(match[2]))
+		}
+	}
+
+	for _, heading := range cfg.RequiredHeadings {
+		name := strings.TrimSpace(strings.TrimLeft(heading, "#"))
+		filled, found := headings[strings.ToLower(name)]
+		if !found {
+			problems = append(problems, "section '"+name+"'")
+		} else if !filled {
+			problems = append(problems, "content in section '"+name+"'")
+		}
+	}
+
+	for _, checkbox := range cfg.RequiredCheckboxes {
+		if !containsSubstring(checked, strings.ToLower(checkbox)) {
+			problems = append(problems, "checked '"+checkbox+"'")
+		}
+	}
+
+	for _, forbidden := range cfg.ForbiddenStrings {
+		if strings.Contains(body, forbidden) {
+			problems = append(problems, "replaced '"+forbidden+"'")
+		}
+	}
+
+	return problems
+}
+
+func containsSubstring(list []string, s string) bool {
+	for _, e := range list {
+		if strings.Contains(e, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-3] + "..."
+}
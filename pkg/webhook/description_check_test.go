@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestValidateDescription(t *testing.T) {
+	cfg := config.DescriptionConfig{
+		RequiredHeadings:   []string{"## Motivation", "Testing done"},
+		MinLength:          20,
+		RequiredCheckboxes: []string{"Tests added", "Docs updated"},
+		ForbiddenStrings:   []string{"TODO"},
+	}
+
+	body := "## Motivation\r\nFixes the flux capacitor\r\n\r\n### Testing Done ###\r\nManually\r\n\r\n- [x] Tests added\r\n- [ ] Docs updated\r\n"
+	expected := []string{"checked 'Docs updated'"}
+	if problems := validateDescription(body, cfg); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("unexpected problems %v", problems)
+	}
+
+	expected = []string{"at least 20 characters", "section 'Motivation'", "section 'Testing done'", "checked 'Tests added'", "checked 'Docs updated'", "replaced 'TODO'"}
+	if problems := validateDescription("TODO", cfg); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("unexpected problems %v", problems)
+	}
+}
+
+func TestValidateDescriptionSections(t *testing.T) {
+	cfg := config.DescriptionConfig{RequiredHeadings: []string{"## Motivation", "## Testing"}}
+
+	body := "## Motivation\n<!-- Why is this change needed? -->\n\n## Testing\n### Unit tests\nAdded\n## Notes\n"
+	expected := []string{"content in section 'Motivation'"}
+	if problems := validateDescription(body, cfg); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected problems %v, got %v", expected, problems)
+	}
+}
+
+func TestDescriptionCheck(t *testing.T) {
+	createCheckRun := "POST " + fixtureRepo + "/check-runs"
+	descriptionConfig := config.RepoConfig{Description: config.DescriptionConfig{
+		RequiredHeadings:   []string{"## Motivation"},
+		RequiredCheckboxes: []string{"Tests added"},
+		ExemptAuthors:      []string{"dependabot[bot]"},
+	}}
+
+	tests := []struct {
+		name               string
+		author             string
+		body               string
+		expectedConclusion string
+		expectedSummary    string
+	}{
+		{
+			name:               "complete",
+			author:             "roland",
+			body:               "## Motivation\nFaster reviews\n\n- [x] Tests added\n",
+			expectedConclusion: "success",
+		},
+		{
+			name:               "unchecked and empty",
+			author:             "roland",
+			body:               "## Motivation\n\n## Checklist\n- [ ] Tests added\n",
+			expectedConclusion: "failure",
+			expectedSummary:    "Please edit the description of the pull request. Missing:\n\n- content in section 'Motivation'\n- checked 'Tests added'",
+		},
+		{
+			name:               "exempt author",
+			author:             "dependabot[bot]",
+			expectedConclusion: "success",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, nil)
+			defer fake.close()
+
+			event := pullRequestEvent("edited")
+			event.PullRequest.User = &github.User{Login: github.String(test.author)}
+			event.PullRequest.Body = github.String(test.body)
+			if err := (&descriptionCheck{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), descriptionConfig, zap.NewNop()); err != nil {
+				t.Fatalf("description check failed: %+v", err)
+			}
+			var checkRun github.CreateCheckRunOptions
+			if err := fake.requestBody(createCheckRun, &checkRun); err != nil {
+				t.Fatal(err)
+			}
+			if checkRun.Name != descriptionContext || checkRun.GetConclusion() != test.expectedConclusion {
+				t.Errorf("expected %s check run concluding %s, got %+v", descriptionContext, test.expectedConclusion, checkRun)
+			}
+			if test.expectedSummary != "" && checkRun.GetOutput().GetSummary() != test.expectedSummary {
+				t.Errorf("expected summary %q, got %q", test.expectedSummary, checkRun.GetOutput().GetSummary())
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/notify"
+	"go.uber.org/zap"
+)
+
+const defaultStaleAfter = 72 * time.Hour
+
+// orgDigest summarizes the bot's activity in an organisation
+type orgDigest struct {
+	Org      string
+	From, To time.Time
+	Merged   []recentMerge
+	Blocked  []pendingMerge
+	// Blocked for longer than the stale threshold
+	Stale []pendingMerge
+}
+
+// SendDigests emails a digest of the bot's activity to the recipients of
+// each organisation, daily or weekly at the configured hour. It returns when
+// ctx is done.
+func SendDigests(ctx context.Context, cfg config.Config, logger *zap.Logger) {
+	if cfg.Digest.Schedule == "" {
+		return
+	}
+	for {
+		now := timeNow()
+		next := nextDigest(cfg.Digest, now)
+		logger.Debug("Scheduled digests", zap.Time("at", next))
+		select {
+		case <-time.After(next.Sub(now)):
+		case <-ctx.Done():
+			return
+		}
+
+		period := 24 * time.Hour
+		if cfg.Digest.Schedule == "weekly" {
+			period = 7 * period
+		}
+		// The schedule is kept, but recipients and the mail server are
+		// taken from the current configuration
+		digestCfg := currentConfig().Digest
+		mailer := notify.NewMailer(digestCfg.SMTP)
+		for org, recipients := range digestCfg.Recipients {
+			digest := collectDigest(org, next.Add(-period), next, digestCfg)
+			if err := sendDigest(mailer, recipients, digest); err != nil {
+				logger.Error("Failed to send digest", zap.String("org", org), zap.Error(err))
+			}
+		}
+	}
+}
+
+// nextDigest returns when the next digest is due after now
+func nextDigest(cfg config.DigestConfig, now time.Time) time.Time {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), cfg.Hour, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	if cfg.Schedule == "weekly" {
+		for next.Weekday() != time.Monday {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next
+}
+
+func collectDigest(org string, from, to time.Time, cfg config.DigestConfig) orgDigest {
+	staleAfter := cfg.StaleAfter
+	if staleAfter <= 0 {
+		staleAfter = defaultStaleAfter
+	}
+	inOrg := func(repo string) bool {
+		return strings.HasPrefix(repo, org+"/")
+	}
+
+	digest := orgDigest{Org: org, From: from, To: to}
+	pending, merged := activity.since(from)
+	for _, m := range merged {
+		if inOrg(m.Repo) && !m.Merged.After(to) {
+			digest.Merged = append(digest.Merged, m)
+		}
+	}
+	// Oldest first
+	sort.Slice(digest.Merged, func(i, j int) bool { return digest.Merged[i].Merged.Before(digest.Merged[j].Merged) })
+	for _, p := range pending {
+		if !inOrg(p.Repo) {
+			continue
+		}
+		if to.Sub(p.Since) > staleAfter {
+			digest.Stale = append(digest.Stale, p)
+		} else {
+			digest.Blocked = append(digest.Blocked, p)
+		}
+	}
+	return digest
+}
+
+func sendDigest(mailer *notify.Mailer, recipients []string, digest orgDigest) error {
+	var body bytes.Buffer
+	if err := digestTemplate.Execute(&body, digest); err != nil {
+		return err
+	}
+	subject := "PuRe Bot digest for " + digest.Org + ": " + pluralize(len(digest.Merged), "merge") + ", " + pluralize(len(digest.Blocked)+len(digest.Stale), "blocked PR")
+	return mailer.Send(recipients, subject, body.String())
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
+
+var digestTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.UTC().Format("Mon, 02 Jan 2006 15:04 MST") },
+	"days": func(since time.Time, to time.Time) int { return int(to.Sub(since).Hours() / 24) },
+}).Parse(`Activity of PuRe Bot in {{.Org}} from {{date .From}} to {{date .To}}
+{{with .Merged}}
+Merged pull requests
+--------------------
+{{range .}}
+* {{.Repo}}#{{.Number}} {{.Title}}
+  {{.URL}}
+{{end}}{{else}}
+No pull requests merged.
+{{end}}{{with .Blocked}}
+Approved pull requests waiting to be merged
+-------------------------------------------
+{{range .}}
+* {{.Repo}}#{{.Number}} {{.Title}}
+  Waiting on: {{.WaitingOn}}
+  {{.URL}}
+{{end}}{{end}}{{$to := .To}}{{with .Stale}}
+Stale approved pull requests
+----------------------------
+{{range .}}
+* {{.Repo}}#{{.Number}} {{.Title}}
+  Blocked for {{days .Since $to}} days, waiting on: {{.WaitingOn}}
+  {{.URL}}
+{{end}}{{end}}`))
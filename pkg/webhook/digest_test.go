@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+func TestNextDigest(t *testing.T) {
+	// Wednesday
+	now := time.Date(2018, 3, 7, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		cfg      config.DigestConfig
+		expected time.Time
+	}{
+		{config.DigestConfig{Schedule: "daily", Hour: 12}, time.Date(2018, 3, 7, 12, 0, 0, 0, time.UTC)},
+		{config.DigestConfig{Schedule: "daily", Hour: 10}, time.Date(2018, 3, 8, 10, 0, 0, 0, time.UTC)},
+		{config.DigestConfig{Schedule: "weekly", Hour: 8}, time.Date(2018, 3, 12, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if next := nextDigest(test.cfg, now); !next.Equal(test.expected) {
+			t.Errorf("%+v: expected %v, got %v", test.cfg, test.expected, next)
+		}
+	}
+}
+
+func TestCollectDigest(t *testing.T) {
+	defer func(a *mergeActivity) { activity = a }(activity)
+	activity = newMergeActivity()
+	defer func(clock func() time.Time) { timeNow = clock }(timeNow)
+	now := time.Date(2018, 3, 7, 10, 0, 0, 0, time.UTC)
+	at := func(t time.Time) { timeNow = func() time.Time { return t } }
+
+	pr := func(number int, title string) *github.Issue {
+		return &github.Issue{Number: github.Int(number), Title: github.String(title), HTMLURL: github.String("https://github.com/pr")}
+	}
+	at(now.Add(-5 * 24 * time.Hour))
+	activity.waiting("syndesisio/syndesis", pr(1, "Old"), "on hold")
+	activity.mergedPR("syndesisio/syndesis", pr(2, "Before the period"))
+	at(now.Add(-time.Hour))
+	activity.waiting("syndesisio/syndesis", pr(3, "New"), "statuses and checks: ci (failure)")
+	activity.mergedPR("syndesisio/syndesis", pr(4, "Fix build"))
+	activity.mergedPR("rhuss/pure-bot", pr(5, "Other org"))
+
+	digest := collectDigest("syndesisio", now.Add(-24*time.Hour), now, config.DigestConfig{})
+	if len(digest.Merged) != 1 || digest.Merged[0].Number != 4 {
+		t.Errorf("expected merges of the period and org, got %+v", digest.Merged)
+	}
+	if len(digest.Blocked) != 1 || digest.Blocked[0].Number != 3 || len(digest.Stale) != 1 || digest.Stale[0].Number != 1 {
+		t.Errorf("expected PR 3 blocked and PR 1 stale, got %+v and %+v", digest.Blocked, digest.Stale)
+	}
+
+	var body bytes.Buffer
+	if err := digestTemplate.Execute(&body, digest); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"syndesisio/syndesis#4 Fix build", "Waiting on: statuses and checks: ci (failure)", "Blocked for 5 days, waiting on: on hold"} {
+		if !strings.Contains(body.String(), expected) {
+			t.Errorf("expected digest to contain %q, got\n%s", expected, body.String())
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
 	"github.com/syndesisio/pure-bot/pkg/config"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
@@ -33,7 +34,7 @@ func (h *dismissReview) EventTypesHandled() []string {
 	return []string{"pull_request"}
 }
 
-func (h *dismissReview) HandleEvent(eventObject interface{}, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *dismissReview) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
 	event, ok := eventObject.(*github.PullRequestEvent)
 	if !ok {
 		return errors.New("wrong event eventObject type")
@@ -43,14 +44,14 @@ func (h *dismissReview) HandleEvent(eventObject interface{}, gh *github.Client,
 		return nil
 	}
 
-	reviews, _, err := gh.PullRequests.ListReviews(context.Background(), event.Repo.Owner.GetLogin(), event.Repo.GetName(), event.PullRequest.GetNumber(), &github.ListOptions{})
+	reviews, _, err := gh.PullRequests.ListReviews(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), event.PullRequest.GetNumber(), &github.ListOptions{})
 	if err != nil {
 		return errors.Wrap(err, "failed to get pull request")
 	}
 
 	var multiErr error
 	for _, review := range reviews {
-		_, _, err = gh.PullRequests.DismissReview(context.Background(), event.Repo.Owner.GetLogin(), event.Repo.GetName(), int(event.PullRequest.GetID()), review.GetID(), &github.PullRequestReviewDismissalRequest{
+		_, _, err = gh.PullRequests.DismissReview(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), int(event.PullRequest.GetID()), review.GetID(), &github.PullRequestReviewDismissalRequest{
 			Message: &dismissMessage,
 		})
 		multiErr = multierr.Combine(multiErr, err)
@@ -0,0 +1,88 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/syndesisio/pure-bot/pkg/audit"
+	"github.com/syndesisio/pure-bot/pkg/github/graphql"
+	"go.uber.org/zap"
+)
+
+// dryRunTransport logs mutating GitHub API requests instead of sending them
+// and answers them with an empty success response. Read requests pass
+// through, so that handlers take the same decisions as for real.
+type dryRunTransport struct {
+	tr     http.RoundTripper
+	logger *zap.Logger
+}
+
+var _ http.RoundTripper = &dryRunTransport{}
+
+func dryRun(logger *zap.Logger) func(http.RoundTripper) http.RoundTripper {
+	return func(tr http.RoundTripper) http.RoundTripper {
+		return &dryRunTransport{tr, logger}
+	}
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	mutating, err := isMutatingRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if !mutating {
+		return t.tr.RoundTrip(req)
+	}
+
+	t.logger.Info("Dry run, skipping request",
+		zap.String("method", req.Method),
+		zap.String("path", req.URL.Path),
+		zap.Any("inputs", audit.InputsFromContext(req.Context())))
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		Request:    req,
+	}, nil
+}
+
+// isMutatingRequest returns true if a request changes anything on GitHub.
+// GraphQL requests are POSTed, but only mutations change anything.
+func isMutatingRequest(req *http.Request) (bool, error) {
+	switch {
+	case req.Method == http.MethodGet || req.Method == http.MethodHead:
+		return false, nil
+	case req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/graphql") || req.Body == nil:
+		return true, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return false, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return graphql.IsMutation(body), nil
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/github/graphql"
+	"go.uber.org/zap"
+)
+
+func TestDryRun(t *testing.T) {
+	fake := newFakeGitHub(t, map[string]interface{}{
+		getIssue:        issueJSON(fixturePR, "approved"),
+		"POST /graphql": map[string]interface{}{"data": map[string]interface{}{}},
+	})
+	defer fake.close()
+
+	gh := github.NewClient(&http.Client{Transport: dryRun(zap.NewNop())(http.DefaultTransport)})
+	gh.BaseURL, _ = url.Parse(fake.server.URL + "/")
+
+	ctx := context.Background()
+	if issue, _, err := gh.Issues.Get(ctx, "syndesisio", "syndesis-rest", fixturePR); err != nil || issue.GetNumber() != fixturePR {
+		t.Errorf("read request not passed through: %v, %v", issue, err)
+	}
+	if _, _, err := gh.PullRequests.Merge(ctx, "syndesisio", "syndesis-rest", fixturePR, "", nil); err != nil {
+		t.Errorf("skipped request failed: %v", err)
+	}
+	gql := graphql.New(gh)
+	var query struct {
+		Viewer struct {
+			Login string
+		}
+	}
+	if err := gql.Query(ctx, &query, nil); err != nil {
+		t.Errorf("GraphQL query failed: %v", err)
+	}
+	var mutation struct {
+		ResolveReviewThread struct {
+			ClientMutationID string
+		} `graphql:"resolveReviewThread(input: $input)"`
+	}
+	if err := gql.Mutate(ctx, &mutation, githubv4.ResolveReviewThreadInput{ThreadID: "1"}, nil); err != nil {
+		t.Errorf("skipped GraphQL mutation failed: %v", err)
+	}
+
+	if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, []string{"POST /graphql"}) {
+		t.Errorf("expected only the GraphQL query to be sent, got %v", calls)
+	}
+}
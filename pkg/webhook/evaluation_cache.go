@@ -0,0 +1,138 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// evaluationCache keeps the statuses, check runs and required contexts
+// fetched during a single merge evaluation, so that pull requests sharing a
+// commit or a base branch don't fetch them again. It is not safe for
+// concurrent use.
+type evaluationCache struct {
+	statuses map[string]*github.CombinedStatus
+	checks   map[string]*github.ListCheckRunsResults
+	required map[string][]string
+	reviews  map[string]int
+}
+
+func newEvaluationCache() *evaluationCache {
+	return &evaluationCache{
+		statuses: make(map[string]*github.CombinedStatus),
+		checks:   make(map[string]*github.ListCheckRunsResults),
+		required: make(map[string][]string),
+		reviews:  make(map[string]int),
+	}
+}
+
+func (c *evaluationCache) combinedStatus(ctx context.Context, gh *github.Client, owner, repository, commitSHA string) (*github.CombinedStatus, error) {
+	key := owner + "/" + repository + "@" + commitSHA
+	if statuses, found := c.statuses[key]; found {
+		return statuses, nil
+	}
+
+	// The combined state covers all statuses, but the statuses are paginated
+	var statuses *github.CombinedStatus
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := gh.Repositories.GetCombinedStatus(ctx, owner, repository, commitSHA, opts)
+		if err != nil {
+			return nil, err
+		}
+		if statuses == nil {
+			statuses = page
+		} else {
+			statuses.Statuses = append(statuses.Statuses, page.Statuses...)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	c.statuses[key] = statuses
+	return statuses, nil
+}
+
+func (c *evaluationCache) checkRuns(ctx context.Context, gh *github.Client, owner, repository, commitSHA string) (*github.ListCheckRunsResults, error) {
+	key := owner + "/" + repository + "@" + commitSHA
+	if checks, found := c.checks[key]; found {
+		return checks, nil
+	}
+
+	var checks *github.ListCheckRunsResults
+	opts := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := gh.Checks.ListCheckRunsForRef(ctx, owner, repository, commitSHA, opts)
+		if err != nil {
+			return nil, err
+		}
+		if checks == nil {
+			checks = page
+		} else {
+			checks.CheckRuns = append(checks.CheckRuns, page.CheckRuns...)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	c.checks[key] = checks
+	return checks, nil
+}
+
+// requiredContexts returns the status checks required by the protection of
+// a branch, nil if the branch isn't protected
+func (c *evaluationCache) requiredContexts(ctx context.Context, gh *github.Client, owner, repository, branch string) ([]string, error) {
+	key := owner + "/" + repository + ":" + branch
+	if contexts, found := c.required[key]; found {
+		return contexts, nil
+	}
+
+	contexts, _, err := gh.Repositories.ListRequiredStatusChecksContexts(ctx, owner, repository, branch)
+	if err != nil {
+		if errResp, ok := err.(*github.ErrorResponse); !ok || errResp.Response.StatusCode != http.StatusNotFound {
+			return nil, errors.Wrapf(err, "failed to get protection of branch %s", branch)
+		}
+	}
+	c.required[key] = contexts
+	return contexts, nil
+}
+
+// requiredApprovals returns the number of approving reviews required by the
+// protection of a branch, 0 if the branch doesn't require reviews
+func (c *evaluationCache) requiredApprovals(ctx context.Context, gh *github.Client, owner, repository, branch string) (int, error) {
+	key := owner + "/" + repository + ":" + branch
+	if count, found := c.reviews[key]; found {
+		return count, nil
+	}
+
+	enforcement, _, err := gh.Repositories.GetPullRequestReviewEnforcement(ctx, owner, repository, branch)
+	if err != nil {
+		if errResp, ok := err.(*github.ErrorResponse); !ok || errResp.Response.StatusCode != http.StatusNotFound {
+			return 0, errors.Wrapf(err, "failed to get required reviews of branch %s", branch)
+		}
+	}
+	count := 0
+	if enforcement != nil {
+		count = enforcement.RequiredApprovingReviewCount
+	}
+	c.reviews[key] = count
+	return count, nil
+}
@@ -0,0 +1,253 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/queue"
+)
+
+const (
+	// Time to process an event before another replica may claim it again
+	eventLease = 10 * time.Minute
+	// Delay before a failed event is processed again, doubled with every
+	// further attempt up to the maximum
+	eventRetryDelay    = time.Minute
+	maxEventRetryDelay = time.Hour
+
+	queuePollInterval  = 5 * time.Second
+	queuePurgeInterval = time.Hour
+)
+
+var (
+	// Holds webhook deliveries until they are processed
+	eventQueue   queue.Store
+	queueOptions queue.Options
+
+	// Signals the consumer's workers that an event got queued
+	queueWakeup = make(chan struct{}, 1)
+	// Closing it stops the consumer, which closes consumerStopped then
+	stopConsumer    = make(chan struct{})
+	consumerStopped = make(chan struct{})
+)
+
+// enqueueDelivery persists a webhook delivery for consumeQueue. Deliveries
+// no handler is interested in are dropped.
+func enqueueDelivery(messageType string, deliveryID string, payload []byte) error {
+	if len(handlersFor(messageType)) == 0 {
+		return nil
+	}
+	event, err := parseWebHook(messageType, payload)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse webhook")
+	}
+	// Events without repository are processed in order, too
+	repo, _ := extractRepository(event)
+
+	err = eventQueue.Enqueue(queue.Event{
+		DeliveryID: deliveryID,
+		Type:       messageType,
+		Repository: repo.GetFullName(),
+		Payload:    payload,
+		Received:   time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	wakeUpConsumer()
+	return nil
+}
+
+// wakeUpConsumer lets an idle worker look for a claimable event
+func wakeUpConsumer() {
+	select {
+	case queueWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// consumeQueue processes queued events with the configured number of
+// workers until the bot shuts down. Events of one repository are processed
+// one after another, so that a slow repository doesn't hold up the others.
+func consumeQueue(logger *zap.Logger) {
+	defer close(consumerStopped)
+	var workers sync.WaitGroup
+	for i := 0; i < queueOptions.Workers; i++ {
+		workers.Add(1)
+		go func(worker int) {
+			defer workers.Done()
+			consumeEvents(logger.With(zap.Int("worker", worker)))
+		}(i)
+	}
+
+	purge := time.NewTicker(queuePurgeInterval)
+	defer purge.Stop()
+	for {
+		select {
+		case <-stopConsumer:
+			workers.Wait()
+			return
+		case <-purge.C:
+			if err := eventQueue.Purge(time.Now().Add(-queueOptions.Retention)); err != nil {
+				logger.Error("Purging processed events failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// consumeEvents is a worker of consumeQueue
+func consumeEvents(logger *zap.Logger) {
+	for {
+		select {
+		case <-stopConsumer:
+			if !queueOptions.Durable {
+				drainQueue(logger)
+			}
+			return
+		default:
+		}
+
+		processed, err := processQueuedEvent(eventQueue, queueOptions, logger)
+		if err != nil {
+			logger.Error("Processing queued event failed", zap.Error(err))
+		}
+		if processed {
+			continue
+		}
+
+		select {
+		case <-stopConsumer:
+			if !queueOptions.Durable {
+				drainQueue(logger)
+			}
+			return
+		case <-queueWakeup:
+		case <-time.After(queuePollInterval):
+		}
+	}
+}
+
+// drainQueue processes the pending events of a store which doesn't survive
+// the shutdown. Events waiting for a retry are lost.
+func drainQueue(logger *zap.Logger) {
+	for {
+		processed, err := processQueuedEvent(eventQueue, queueOptions, logger)
+		if err != nil {
+			logger.Error("Processing queued event failed", zap.Error(err))
+		}
+		if !processed {
+			return
+		}
+	}
+}
+
+// processQueuedEvent handles the oldest pending event, if any. Failed events
+// are retried with backoff until the maximum number of attempts is reached,
+// then they become dead letters. Retries only call the handlers which
+// failed.
+func processQueuedEvent(store queue.Store, opts queue.Options, logger *zap.Logger) (bool, error) {
+	event, err := store.Claim(eventLease)
+	if err != nil || event == nil {
+		return false, err
+	}
+	// Further events may be claimable by idle workers
+	wakeUpConsumer()
+
+	logger = logger.With(zap.String("delivery", event.DeliveryID), zap.Int("attempt", event.Attempts))
+	if event.Attempts > opts.MaxAttempts {
+		// Queued again by deferred work which kept failing
+		logger.Error("Deferred work of queued event keeps failing, moving it to the dead letters", zap.String("type", event.Type), zap.Strings("handlers", event.Handlers), zap.String("error", event.Error))
+		return true, store.Fail(event.ID, event.Error, event.Handlers)
+	}
+	// Handlers which succeeded on an earlier attempt aren't called again
+	failed, err := processDelivery(event.Type, event.DeliveryID, event.Payload, event, logger)
+	if err == nil {
+		return true, store.Done(event.ID)
+	}
+	if len(failed) == 0 {
+		// Failed before calling any handler
+		failed = event.Handlers
+	}
+
+	if event.Attempts >= opts.MaxAttempts {
+		logger.Error("Queued event keeps failing, moving it to the dead letters", zap.String("type", event.Type), zap.Strings("handlers", failed), zap.String("error", fmt.Sprintf("%+v", err)))
+		return true, store.Fail(event.ID, err.Error(), failed)
+	}
+	logger.Warn("Queued event failed, retrying", zap.String("type", event.Type), zap.Strings("handlers", failed), zap.Error(err))
+	return true, store.Retry(event.ID, retryDelay(event.Attempts), err.Error(), failed)
+}
+
+// retryDelay returns the exponential backoff after a failed attempt
+func retryDelay(attempts int) time.Duration {
+	delay := eventRetryDelay
+	for i := 1; i < attempts && delay < maxEventRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxEventRetryDelay {
+		return maxEventRetryDelay
+	}
+	return delay
+}
+
+type queuedDeliveryKey struct{}
+
+// queuedDelivery is the queued event a handler is processing
+type queuedDelivery struct {
+	event   queue.Event
+	handler string
+}
+
+func withQueuedDelivery(ctx context.Context, event queue.Event, handler string) context.Context {
+	return context.WithValue(ctx, queuedDeliveryKey{}, &queuedDelivery{event, handler})
+}
+
+// queuedDeliveryFrom returns the queued event a handler is processing, nil
+// if the delivery isn't queued
+func queuedDeliveryFrom(ctx context.Context) *queuedDelivery {
+	delivery, _ := ctx.Value(queuedDeliveryKey{}).(*queuedDelivery)
+	return delivery
+}
+
+// requeue queues the delivery again for the handler whose deferred work
+// failed after the handler returned. Attempts continue to count, so that
+// the delivery becomes a dead letter once they are exhausted.
+func (d *queuedDelivery) requeue(reason error, logger *zap.Logger) {
+	if d == nil || eventQueue == nil {
+		return
+	}
+	err := eventQueue.Enqueue(queue.Event{
+		DeliveryID: fmt.Sprintf("%s/%s/%d", d.event.DeliveryID, d.handler, d.event.Attempts),
+		Type:       d.event.Type,
+		Repository: d.event.Repository,
+		Payload:    d.event.Payload,
+		Received:   time.Now(),
+		Attempts:   d.event.Attempts,
+		Error:      reason.Error(),
+		Handlers:   []string{d.handler},
+	})
+	if err != nil {
+		logger.Error("Queueing delivery again failed", zap.String("delivery", d.event.DeliveryID), zap.Error(err))
+		return
+	}
+	logger.Warn("Deferred work failed, queued delivery again", zap.String("delivery", d.event.DeliveryID), zap.String("handler", d.handler), zap.Error(reason))
+	wakeUpConsumer()
+}
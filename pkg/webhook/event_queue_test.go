@@ -0,0 +1,237 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/queue"
+	"go.uber.org/zap"
+)
+
+// fakeStore holds a single queued event and records what happened to it
+type fakeStore struct {
+	event *queue.Event
+	calls []string
+	// Handlers to call again, as given by the last retry or failure
+	handlers []string
+}
+
+func (s *fakeStore) Enqueue(event queue.Event) error {
+	s.event = &event
+	return nil
+}
+
+func (s *fakeStore) Claim(lease time.Duration) (*queue.Event, error) {
+	event := s.event
+	s.event = nil
+	return event, nil
+}
+
+func (s *fakeStore) Done(id int64) error {
+	s.calls = append(s.calls, "done")
+	return nil
+}
+
+func (s *fakeStore) Retry(id int64, delay time.Duration, reason string, handlers []string) error {
+	s.calls = append(s.calls, "retry "+delay.String())
+	s.handlers = handlers
+	return nil
+}
+
+func (s *fakeStore) Fail(id int64, reason string, handlers []string) error {
+	s.calls = append(s.calls, "fail")
+	s.handlers = handlers
+	return nil
+}
+
+func (s *fakeStore) DeadLetters() ([]queue.Event, error) { return nil, nil }
+
+func (s *fakeStore) Redrive(id int64) error { return nil }
+
+func (s *fakeStore) Purge(before time.Time) error { return nil }
+
+func (s *fakeStore) Pending() (int, error) { return 0, nil }
+
+func (s *fakeStore) RecordAssignment(repository string, reviewer string, at time.Time) error {
+	return nil
+}
+
+func (s *fakeStore) LastAssignments(repository string) (map[string]time.Time, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) Close() error { return nil }
+
+func TestProcessQueuedEvent(t *testing.T) {
+	defer UpdateConfig(currentConfig())
+	UpdateConfig(config.Config{Repos: map[string]config.RepoConfig{"syndesis-rest": {Disabled: true}}})
+
+	payload, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "status.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := queue.Options{MaxAttempts: 3}
+
+	tests := []struct {
+		name          string
+		event         *queue.Event
+		expectedCalls []string
+	}{
+		{
+			name:  "empty queue",
+			event: nil,
+		},
+		{
+			name:          "processed",
+			event:         &queue.Event{Type: "status", Payload: payload, Attempts: 1},
+			expectedCalls: []string{"done"},
+		},
+		{
+			name:          "failed",
+			event:         &queue.Event{Type: "status", Payload: []byte("{"), Attempts: 2},
+			expectedCalls: []string{"retry 2m0s"},
+		},
+		{
+			name:          "failed again",
+			event:         &queue.Event{Type: "status", Payload: []byte("{"), Attempts: 1},
+			expectedCalls: []string{"retry 1m0s"},
+		},
+		{
+			name:          "dead letter",
+			event:         &queue.Event{Type: "status", Payload: []byte("{"), Attempts: 3},
+			expectedCalls: []string{"fail"},
+		},
+		{
+			name:          "deferred work kept failing",
+			event:         &queue.Event{Type: "status", Payload: payload, Attempts: 4, Handlers: []string{"autoMerge"}},
+			expectedCalls: []string{"fail"},
+		},
+	}
+
+	for _, test := range tests {
+		store := &fakeStore{event: test.event}
+		processed, err := processQueuedEvent(store, opts, zap.NewNop())
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if processed != (test.event != nil) {
+			t.Errorf("%s: expected processed to be %v", test.name, test.event != nil)
+		}
+		if !reflect.DeepEqual(store.calls, test.expectedCalls) {
+			t.Errorf("%s: expected calls %v, got %v", test.name, test.expectedCalls, store.calls)
+		}
+	}
+}
+
+// countingHandler handles status events, failing as long as err is set
+type countingHandler struct {
+	calls int
+	err   error
+}
+
+func (h *countingHandler) EventTypesHandled() []string {
+	return []string{"status"}
+}
+
+func (h *countingHandler) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	h.calls++
+	return h.err
+}
+
+func TestProcessQueuedEventRetriesFailedHandlers(t *testing.T) {
+	defer UpdateConfig(currentConfig())
+	fake := newFakeGitHub(t, nil)
+	defer fake.close()
+	UpdateConfig(config.Config{GitHubApp: config.GitHubAppConfig{BaseURL: fake.server.URL}})
+	defer func() { localToken = "" }()
+	localToken = "abc"
+
+	succeeding := &countingHandler{}
+	failing := &countingHandler{err: errors.New("boom")}
+	handlersMu.Lock()
+	builtin := handlerMap["status"]
+	handlerMap["status"] = []namedHandler{{"succeeding", succeeding}, {"failing", failing}}
+	handlersMu.Unlock()
+	defer func() {
+		handlersMu.Lock()
+		handlerMap["status"] = builtin
+		handlersMu.Unlock()
+	}()
+
+	payload, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "status.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := queue.Options{MaxAttempts: 3}
+	store := &fakeStore{event: &queue.Event{Type: "status", Payload: payload, Attempts: 1}}
+	if _, err := processQueuedEvent(store, opts, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(store.handlers, []string{"failing"}) {
+		t.Fatalf("expected only the failing handler to be retried, got %v", store.handlers)
+	}
+
+	failing.err = nil
+	store.event = &queue.Event{Type: "status", Payload: payload, Attempts: 2, Handlers: store.handlers}
+	if _, err := processQueuedEvent(store, opts, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(store.calls, []string{"retry 1m0s", "done"}) {
+		t.Errorf("expected the retry to succeed, got calls %v", store.calls)
+	}
+	if succeeding.calls != 1 || failing.calls != 2 {
+		t.Errorf("expected the succeeding handler to be called once and the failing one twice, got %d and %d", succeeding.calls, failing.calls)
+	}
+}
+
+func TestDebounceRequeuesFailedEvaluation(t *testing.T) {
+	defer func(store queue.Store) { eventQueue = store }(eventQueue)
+	var err error
+	if eventQueue, _, err = queue.New(config.QueueConfig{}, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+
+	event := queue.Event{DeliveryID: "72d3162e", Type: "status", Repository: "syndesisio/syndesis", Payload: []byte("{}"), Attempts: 2}
+	ctx := withQueuedDelivery(context.Background(), event, "autoMerge")
+	err = debounce(ctx, "syndesisio/syndesis@requeue", time.Millisecond, zap.NewNop(), func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+	if err != nil {
+		t.Fatalf("expected the scheduled evaluation not to fail the handler, got %v", err)
+	}
+
+	var requeued *queue.Event
+	for i := 0; i < 100 && requeued == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		requeued, _ = eventQueue.Claim(time.Minute)
+	}
+	if requeued == nil {
+		t.Fatal("expected the delivery to be queued again")
+	}
+	if requeued.Type != "status" || string(requeued.Payload) != "{}" || requeued.Attempts != 3 || requeued.Error != "boom" || !reflect.DeepEqual(requeued.Handlers, []string{"autoMerge"}) {
+		t.Errorf("expected the third attempt of the auto merge handler, got %+v", requeued)
+	}
+
+	err = debounce(ctx, "syndesisio/syndesis@now", -1, zap.NewNop(), func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+	if err == nil {
+		t.Error("expected an evaluation without window to fail the handler")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts, expected := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 4: 8 * time.Minute, 20: time.Hour} {
+		if delay := retryDelay(attempts); delay != expected {
+			t.Errorf("expected delay %v after %d attempts, got %v", expected, attempts, delay)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// Webhook events which are not known to the vendored go-github version or
+// whose repository isn't a github.Repository. Like go-github's events they
+// must carry a Repo and an Installation field.
+var customEventTypes = map[string]func() interface{}{
+	"push":                       func() interface{} { return &pushEvent{} },
+	"pull_request_review_thread": func() interface{} { return &pullRequestReviewThreadEvent{} },
+	"workflow_run":               func() interface{} { return &workflowRunEvent{} },
+	"release":                    func() interface{} { return &releaseEvent{} },
+}
+
+// pushEvent is sent when commits are pushed to a branch. go-github's
+// PushEvent has its own repository type, so it is reduced to what the
+// handlers need.
+type pushEvent struct {
+	Ref          string               `json:"ref"`
+	After        string               `json:"after"`
+	Deleted      bool                 `json:"deleted"`
+	Repo         *github.Repository   `json:"repository,omitempty"`
+	Installation *github.Installation `json:"installation,omitempty"`
+}
+
+// pullRequestReviewThreadEvent is sent when a review conversation gets
+// resolved or unresolved.
+type pullRequestReviewThreadEvent struct {
+	Action       *string              `json:"action,omitempty"`
+	PullRequest  *github.PullRequest  `json:"pull_request,omitempty"`
+	Repo         *github.Repository   `json:"repository,omitempty"`
+	Sender       *github.User         `json:"sender,omitempty"`
+	Installation *github.Installation `json:"installation,omitempty"`
+}
+
+func (e *pullRequestReviewThreadEvent) GetAction() string {
+	if e == nil || e.Action == nil {
+		return ""
+	}
+	return *e.Action
+}
+
+func parseWebHook(messageType string, payload []byte) (interface{}, error) {
+	newEvent, ok := customEventTypes[messageType]
+	if !ok {
+		return github.ParseWebHook(messageType, payload)
+	}
+
+	event := newEvent()
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s event", messageType)
+	}
+	return event, nil
+}
+
+// baseChanged returns true if an edited pull request got retargeted to another
+// base branch. The vendored go-github version doesn't know about base changes,
+// but as an edit changes either the title, the body or the base, an edit
+// without title and body change must be a base change.
+func baseChanged(event *github.PullRequestEvent) bool {
+	changes := event.Changes
+	return changes != nil && changes.Title == nil && changes.Body == nil
+}
+
+// workflowRunEvent is sent when a GitHub Actions workflow run is requested
+// or completed.
+type workflowRunEvent struct {
+	Action       *string              `json:"action,omitempty"`
+	WorkflowRun  *workflowRun         `json:"workflow_run,omitempty"`
+	Repo         *github.Repository   `json:"repository,omitempty"`
+	Sender       *github.User         `json:"sender,omitempty"`
+	Installation *github.Installation `json:"installation,omitempty"`
+}
+
+type workflowRun struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	HeadBranch string `json:"head_branch"`
+	HeadSHA    string `json:"head_sha"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	HTMLURL    string `json:"html_url"`
+	// Only filled for pull requests from the same repository
+	PullRequests []*github.PullRequest `json:"pull_requests"`
+}
+
+func (e *workflowRunEvent) GetAction() string {
+	if e == nil || e.Action == nil {
+		return ""
+	}
+	return *e.Action
+}
+
+// releaseEvent is sent when a release gets published, edited or deleted.
+type releaseEvent struct {
+	Action       *string                   `json:"action,omitempty"`
+	Release      *github.RepositoryRelease `json:"release,omitempty"`
+	Repo         *github.Repository        `json:"repository,omitempty"`
+	Sender       *github.User              `json:"sender,omitempty"`
+	Installation *github.Installation      `json:"installation,omitempty"`
+}
+
+func (e *releaseEvent) GetAction() string {
+	if e == nil || e.Action == nil {
+		return ""
+	}
+	return *e.Action
+}
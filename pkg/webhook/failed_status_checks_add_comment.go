@@ -21,6 +21,7 @@ import (
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
 	"github.com/syndesisio/pure-bot/pkg/config"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
@@ -32,7 +33,7 @@ func (h *failedStatusCheckAddComment) EventTypesHandled() []string {
 	return []string{"status"}
 }
 
-func (h *failedStatusCheckAddComment) HandleEvent(eventObject interface{}, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *failedStatusCheckAddComment) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
 	event, ok := eventObject.(*github.StatusEvent)
 	if !ok {
 		return errors.New("wrong event eventObject type")
@@ -51,7 +52,7 @@ func (h *failedStatusCheckAddComment) HandleEvent(eventObject interface{}, gh *g
 
 	commitSHA := event.GetSHA()
 	query := fmt.Sprintf("type:pr state:open repo:%s %s", event.Repo.GetFullName(), commitSHA)
-	searchResult, _, err := gh.Search.Issues(context.Background(), query, nil)
+	searchResult, _, err := gh.Search.Issues(ctx, query, nil)
 	if err != nil {
 		return errors.Wrapf(err, "failed to find PR using query %s", query)
 	}
@@ -66,7 +67,7 @@ func (h *failedStatusCheckAddComment) HandleEvent(eventObject interface{}, gh *g
 
 		prNumber := issue.GetNumber()
 
-		existingComments, _, err := gh.Issues.ListComments(context.Background(), owner, repo, prNumber, &github.IssueListCommentsOptions{
+		existingComments, _, err := gh.Issues.ListComments(ctx, owner, repo, prNumber, &github.IssueListCommentsOptions{
 			Sort:      "updated",
 			Direction: "desc",
 		})
@@ -86,7 +87,7 @@ func (h *failedStatusCheckAddComment) HandleEvent(eventObject interface{}, gh *g
 			continue
 		}
 
-		_, _, err = gh.Issues.CreateComment(context.Background(), owner, repo, prNumber, &github.IssueComment{
+		_, _, err = gh.Issues.CreateComment(ctx, owner, repo, prNumber, &github.IssueComment{
 			Body: &message,
 		})
 		if err != nil {
@@ -0,0 +1,264 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/github/graphql"
+	"go.uber.org/zap"
+)
+
+// Fixtures of the harness are recorded webhook payloads of this repository
+const (
+	fixtureRepo = "/repos/syndesisio/syndesis-rest"
+	fixturePR   = 276
+	fixtureSHA  = "f3fcd127156321437e78f1d57ba3ecb0b39bbabc"
+)
+
+// scenario sends a recorded webhook payload to a handler, backed by a fake
+// GitHub API, and checks the mutating calls the handler makes
+type scenario struct {
+	name string
+	// Webhook event type and payload file in the top-level testdata directory
+	eventType string
+	fixture   string
+	handler   Handler
+	config    config.RepoConfig
+	// Responses of the GitHub API by "METHOD /path". Read requests without
+	// a response fail the scenario, mutating requests succeed with an empty
+	// body unless a response is given.
+	responses map[string]interface{}
+	// Mutating requests as "METHOD /path", in order
+	expectedCalls []string
+}
+
+// fakeResponse answers a request with a status other than 200 or with
+// additional headers
+type fakeResponse struct {
+	status int
+	body   interface{}
+	header map[string]string
+}
+
+var notFound = fakeResponse{status: http.StatusNotFound, body: map[string]string{"message": "Not Found"}}
+
+// nextPage answers with a body and a link to a next page
+func nextPage(body interface{}) fakeResponse {
+	return fakeResponse{status: http.StatusOK, body: body, header: map[string]string{"Link": `<https://api.github.com/next?page=2>; rel="next"`}}
+}
+
+// fakeSequence answers successive requests with successive responses,
+// repeating the last one
+type fakeSequence []interface{}
+
+func runScenarios(t *testing.T, scenarios []scenario) {
+	for _, s := range scenarios {
+		s := s
+		t.Run(s.name, s.run)
+	}
+}
+
+func (s scenario) run(t *testing.T) {
+	fake := newFakeGitHub(t, s.responses)
+	defer fake.close()
+
+	payload, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", s.fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := parseWebHook(s.eventType, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.handler.HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), s.config, zap.NewNop()); err != nil {
+		t.Errorf("handler failed: %+v", err)
+	}
+
+	if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, s.expectedCalls) {
+		t.Errorf("expected calls %v, got %v", s.expectedCalls, calls)
+	}
+}
+
+// fakeGitHub serves canned GitHub API responses and records all mutating
+// requests
+type fakeGitHub struct {
+	t         *testing.T
+	server    *httptest.Server
+	responses map[string]interface{}
+
+	mu    sync.Mutex
+	calls []string
+	// Requests served so far per call
+	served map[string]int
+	// Body of the last request per call
+	bodies map[string][]byte
+}
+
+func newFakeGitHub(t *testing.T, responses map[string]interface{}) *fakeGitHub {
+	f := &fakeGitHub{t: t, responses: responses, served: make(map[string]int), bodies: make(map[string][]byte)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeGitHub) serve(w http.ResponseWriter, r *http.Request) {
+	call := r.Method + " " + r.URL.Path
+	body, _ := ioutil.ReadAll(r.Body)
+	f.mu.Lock()
+	f.bodies[call] = body
+	if r.Method != http.MethodGet {
+		f.calls = append(f.calls, call)
+	}
+	served := f.served[call]
+	f.served[call]++
+	f.mu.Unlock()
+
+	response, found := f.responses[call]
+	if !found {
+		if r.Method == http.MethodGet {
+			// Repositories have no configuration file unless given
+			if !strings.HasSuffix(r.URL.Path, "/contents/"+config.RepoConfigFile) {
+				f.t.Errorf("unexpected request %s", call)
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}
+		return
+	}
+
+	if sequence, ok := response.(fakeSequence); ok {
+		if served >= len(sequence) {
+			served = len(sequence) - 1
+		}
+		response = sequence[served]
+	}
+	status := http.StatusOK
+	if fr, ok := response.(fakeResponse); ok {
+		status, response = fr.status, fr.body
+		for name, value := range fr.header {
+			w.Header().Set(name, value)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		f.t.Errorf("failed to encode response to %s: %v", call, err)
+	}
+}
+
+func (f *fakeGitHub) client() *github.Client {
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(f.server.URL + "/")
+	return gh
+}
+
+func (f *fakeGitHub) graphQL() *githubv4.Client {
+	return graphql.New(f.client())
+}
+
+func (f *fakeGitHub) mutatingCalls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// requestBody decodes the body of the last request of a call
+func (f *fakeGitHub) requestBody(call string, v interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return json.Unmarshal(f.bodies[call], v)
+}
+
+func (f *fakeGitHub) close() {
+	f.server.Close()
+}
+
+// Response bodies of the GitHub API, reduced to the fields used by the bot
+
+func issueJSON(number int, labels ...string) map[string]interface{} {
+	labelObjects := make([]map[string]interface{}, 0, len(labels))
+	for _, label := range labels {
+		labelObjects = append(labelObjects, map[string]interface{}{"name": label})
+	}
+	return map[string]interface{}{
+		"number":       number,
+		"state":        "open",
+		"html_url":     "https://github.com/syndesisio/syndesis-rest/pull/" + strconv.Itoa(number),
+		"labels":       labelObjects,
+		"pull_request": map[string]interface{}{"url": "https://api.github.com/repos/syndesisio/syndesis-rest/pulls/" + strconv.Itoa(number)},
+	}
+}
+
+func pullRequestJSON(number int, headSHA string, mergeableState string) map[string]interface{} {
+	return map[string]interface{}{
+		"number":          number,
+		"state":           "open",
+		"html_url":        "https://github.com/syndesisio/syndesis-rest/pull/" + strconv.Itoa(number),
+		"head":            map[string]interface{}{"sha": headSHA, "ref": "feature"},
+		"base":            map[string]interface{}{"sha": "0000000000000000000000000000000000000000", "ref": "master"},
+		"mergeable":       mergeableState != "dirty",
+		"mergeable_state": mergeableState,
+	}
+}
+
+func draft(pullRequest map[string]interface{}) map[string]interface{} {
+	pullRequest["draft"] = true
+	pullRequest["mergeable_state"] = "draft"
+	return pullRequest
+}
+
+// pullRequestEvent is a minimal event for the fixture PR with head commit
+// fixtureSHA
+func pullRequestEvent(action string) *github.PullRequestEvent {
+	return &github.PullRequestEvent{
+		Action: github.String(action),
+		PullRequest: &github.PullRequest{
+			Number: github.Int(fixturePR),
+			Head:   &github.PullRequestBranch{SHA: github.String(fixtureSHA)},
+		},
+		Repo: &github.Repository{Name: github.String("syndesis-rest"), Owner: &github.User{Login: github.String("syndesisio")}},
+	}
+}
+
+func titled(pullRequest map[string]interface{}, title string) map[string]interface{} {
+	pullRequest["title"] = title
+	return pullRequest
+}
+
+func reviewJSON(login string, state string) map[string]interface{} {
+	return map[string]interface{}{"state": state, "user": map[string]interface{}{"login": login}}
+}
+
+// combinedStatusJSON creates a combined status from a map of context to state
+func combinedStatusJSON(states map[string]string) map[string]interface{} {
+	statuses := make([]map[string]interface{}, 0, len(states))
+	for context, state := range states {
+		statuses = append(statuses, map[string]interface{}{"context": context, "state": state})
+	}
+	return map[string]interface{}{"sha": fixtureSHA, "statuses": statuses}
+}
+
+// checkRunsJSON creates a list of check runs from a map of name to conclusion
+func checkRunsJSON(conclusions map[string]string) map[string]interface{} {
+	runs := make([]map[string]interface{}, 0, len(conclusions))
+	for name, conclusion := range conclusions {
+		runs = append(runs, map[string]interface{}{"name": name, "status": "completed", "conclusion": conclusion, "head_sha": fixtureSHA})
+	}
+	return map[string]interface{}{"total_count": len(runs), "check_runs": runs}
+}
+
+func searchJSON(issues ...map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"total_count": len(issues), "incomplete_results": false, "items": issues}
+}
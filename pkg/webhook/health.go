@@ -0,0 +1,125 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+const (
+	// A successful GitHub check is reused for this long, so that frequent
+	// probes don't use up the rate limit of the apps
+	githubCheckTTL = time.Minute
+	// Maximum time of the readiness checks
+	readinessTimeout = 10 * time.Second
+)
+
+var githubCheck struct {
+	mu      sync.Mutex
+	checked time.Time
+}
+
+// Healthz serves the liveness probe, it answers as long as the bot serves
+// requests at all
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// NewReadinessHandler serves the readiness probe. The bot is ready when all
+// configured apps can mint installation tokens and the event queue is
+// reachable. It becomes unready when shutting down, so that no more webhooks
+// are routed to it.
+func NewReadinessHandler(logger *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inFlight.closing() {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		checks := []struct {
+			name  string
+			check func() error
+		}{
+			{"github", func() error { return checkGitHub(ctx, currentConfig()) }},
+			{"queue", checkQueue},
+		}
+		status := http.StatusOK
+		var report string
+		for _, c := range checks {
+			if err := c.check(); err != nil {
+				logger.Warn("readiness check failed", zap.String("check", c.name), zap.Error(err))
+				status = http.StatusServiceUnavailable
+				report += fmt.Sprintf("%s: %v\n", c.name, err)
+				continue
+			}
+			report += c.name + ": ok\n"
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprint(w, report)
+	})
+}
+
+// checkGitHub mints an installation token with each configured app, which
+// verifies the app's credentials and GitHub's reachability
+func checkGitHub(ctx context.Context, cfg config.Config) error {
+	githubCheck.mu.Lock()
+	defer githubCheck.mu.Unlock()
+	if timeNow().Sub(githubCheck.checked) < githubCheckTTL {
+		return nil
+	}
+
+	for _, appCfg := range cfg.Apps() {
+		appClient, err := newAppClient(appCfg)
+		if err != nil {
+			return err
+		}
+		installations, _, err := appClient.Apps.ListInstallations(ctx, &github.ListOptions{PerPage: 1})
+		if err != nil {
+			return errors.Wrapf(err, "failed to list installations of app %d", appCfg.AppID)
+		}
+		// An app without installations can't mint tokens, but its
+		// credentials are valid
+		if len(installations) == 0 {
+			continue
+		}
+		if _, _, err := appClient.Apps.CreateInstallationToken(ctx, installations[0].GetID()); err != nil {
+			return errors.Wrapf(err, "failed to create installation token of app %d", appCfg.AppID)
+		}
+	}
+	githubCheck.checked = timeNow()
+	return nil
+}
+
+// checkQueue verifies that the event queue backend is reachable
+func checkQueue() error {
+	if eventQueue == nil {
+		return errors.New("event queue not running")
+	}
+	_, err := eventQueue.Pending()
+	return err
+}
@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/queue"
+	"go.uber.org/zap"
+)
+
+func TestReadiness(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pure-bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key := writePrivateKey(t, dir)
+
+	tokens := 0
+	tokenStatus := http.StatusCreated
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v3/app/installations":
+			w.Write([]byte(`[{"id":1}]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v3/app/installations/1/access_tokens":
+			tokens++
+			w.WriteHeader(tokenStatus)
+			w.Write([]byte(`{"token":"secret"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer app.Close()
+
+	defer func(cfg config.Config) { UpdateConfig(cfg) }(currentConfig())
+	UpdateConfig(config.Config{GitHubApp: config.GitHubAppConfig{AppID: 10, PrivateKeyFile: key, BaseURL: app.URL}})
+	defer func(store queue.Store) { eventQueue = store }(eventQueue)
+	eventQueue, _, _ = queue.New(config.QueueConfig{}, zap.NewNop())
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	githubCheck.checked = time.Time{}
+
+	probe := func() (int, string) {
+		w := httptest.NewRecorder()
+		NewReadinessHandler(zap.NewNop()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code, w.Body.String()
+	}
+
+	if status, body := probe(); status != http.StatusOK || body != "github: ok\nqueue: ok\n" {
+		t.Errorf("expected ready, got %d %q", status, body)
+	}
+	// Successful GitHub checks are reused for a while
+	tokenStatus = http.StatusUnauthorized
+	if status, _ := probe(); status != http.StatusOK || tokens != 1 {
+		t.Errorf("expected cached GitHub check, got %d after %d tokens", status, tokens)
+	}
+	now = now.Add(githubCheckTTL)
+	if status, body := probe(); status != http.StatusServiceUnavailable || !strings.Contains(body, "github: failed to create installation token of app 10") {
+		t.Errorf("expected unready without installation token, got %d %q", status, body)
+	}
+
+	w := httptest.NewRecorder()
+	Healthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected to be alive, got %d", w.Code)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+var (
+	holdCommand = Command{
+		Help:            "Adds the hold label, preventing an automatic merge",
+		PullRequestOnly: true,
+		Run:             runHold,
+	}
+	unholdCommand = Command{
+		Help:            "Removes the hold label",
+		PullRequestOnly: true,
+		Run:             runUnhold,
+	}
+)
+
+func runHold(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	label := config.Labels.Hold
+	if label == "" {
+		return cmd.Reply(ctx, gh, "no hold label is configured for this repository.")
+	}
+	if containsLabel(cmd.Event.Issue.Labels, label) {
+		return nil
+	}
+	_, _, err := gh.Issues.AddLabelsToIssue(ctx, cmd.Owner(), cmd.Repo(), cmd.Number(), []string{label})
+	return errors.Wrapf(err, "failed to add label %s to %s", label, cmd.Event.Issue.GetHTMLURL())
+}
+
+// runUnhold removes the hold label and evaluates the pull request for
+// merging, as removing a label doesn't trigger the automatic merge
+func runUnhold(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	label := config.Labels.Hold
+	if label == "" {
+		return cmd.Reply(ctx, gh, "no hold label is configured for this repository.")
+	}
+	if !containsLabel(cmd.Event.Issue.Labels, label) {
+		return nil
+	}
+	owner, repository := cmd.Owner(), cmd.Repo()
+	if _, err := gh.Issues.RemoveLabelForIssue(ctx, owner, repository, cmd.Number(), label); err != nil {
+		return errors.Wrapf(err, "failed to remove label %s from %s", label, cmd.Event.Issue.GetHTMLURL())
+	}
+	if config.Labels.Approved == "" || !config.HandlerEnabled("autoMerge") || config.MergeStrategy == nativeMergeStrategy {
+		return nil
+	}
+
+	issue, _, err := gh.Issues.Get(ctx, owner, repository, cmd.Number())
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pull request %s", cmd.Event.Issue.GetHTMLURL())
+	}
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repository, cmd.Number())
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pull request %s", cmd.Event.Issue.GetHTMLURL())
+	}
+	_, err = mergePR(ctx, issue, pr, owner, repository, gh, gql, "", newEvaluationCache(), config, logger)
+	return err
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestHoldCommands(t *testing.T) {
+	defer func(clock func() time.Time) { timeNow = clock }(timeNow)
+	timeNow = func() time.Time { return time.Date(2018, 3, 7, 10, 0, 0, 0, time.UTC) }
+
+	holdConfig := autoMergeConfig
+	holdConfig.Labels.Hold = "do-not-merge/hold"
+	addLabels := "POST " + fixtureRepo + "/issues/276/labels"
+	removeHold := "DELETE " + fixtureRepo + "/issues/276/labels/do-not-merge/hold"
+
+	tests := []struct {
+		name          string
+		comment       string
+		labels        []string
+		config        config.RepoConfig
+		expectedCalls []string
+	}{
+		{
+			name:          "hold",
+			comment:       "/hold",
+			labels:        []string{"approved"},
+			config:        holdConfig,
+			expectedCalls: []string{addLabels},
+		},
+		{
+			name:    "hold again",
+			comment: "/hold",
+			labels:  []string{"approved", "do-not-merge/hold"},
+			config:  holdConfig,
+		},
+		{
+			name:          "unhold merges",
+			comment:       "/unhold",
+			labels:        []string{"approved", "do-not-merge/hold"},
+			config:        holdConfig,
+			expectedCalls: []string{removeHold, mergePullRequest},
+		},
+		{
+			name:    "unhold without hold",
+			comment: "/unhold",
+			labels:  []string{"approved"},
+			config:  holdConfig,
+		},
+		{
+			name:          "no hold label",
+			comment:       "/hold",
+			config:        autoMergeConfig,
+			expectedCalls: []string{createComment},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, mergeableResponses(map[string]interface{}{
+				"GET " + fixtureRepo + "/collaborators/roland/permission": permissionJSON("write"),
+			}))
+			defer fake.close()
+
+			event := commentEvent(test.comment, "roland", "User", true)
+			for _, label := range test.labels {
+				event.Issue.Labels = append(event.Issue.Labels, github.Label{Name: github.String(label)})
+			}
+			if err := (&slashCommands{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("handler failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Number of responses kept by the conditional request cache
+const httpCacheSize = 1000
+
+// httpCache keeps GET responses of all installations together with their
+// validators. Answers to conditional requests for unchanged resources don't
+// count against the rate limit.
+type httpCache struct {
+	mu        sync.Mutex
+	order     *list.List
+	responses map[string]*list.Element
+}
+
+type cachedResponse struct {
+	key string
+	// Response dumped with httputil.DumpResponse
+	dump         []byte
+	etag         string
+	lastModified string
+}
+
+var responseCache = &httpCache{order: list.New(), responses: make(map[string]*list.Element)}
+
+func (c *httpCache) get(key string) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, found := c.responses[key]; found {
+		c.order.MoveToFront(e)
+		return e.Value.(*cachedResponse)
+	}
+	return nil
+}
+
+func (c *httpCache) put(cached *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, found := c.responses[cached.key]; found {
+		c.order.Remove(e)
+	}
+	c.responses[cached.key] = c.order.PushFront(cached)
+	if c.order.Len() > httpCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.responses, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// forgetRepository drops all responses of a repository's API
+func (c *httpCache) forgetRepository(fullName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.responses {
+		// Keys end with the request URL
+		u, err := url.Parse(key[strings.LastIndex(key, " ")+1:])
+		if err == nil && strings.Contains(u.Path+"/", "/repos/"+fullName+"/") {
+			c.order.Remove(e)
+			delete(c.responses, key)
+		}
+	}
+}
+
+// cachingTransport revalidates GET requests with the validators of a cached
+// response and serves the cached response if it's still fresh
+type cachingTransport struct {
+	tr             http.RoundTripper
+	installationID int64
+}
+
+var _ http.RoundTripper = &cachingTransport{}
+
+func conditionalCache(installationID int64) func(http.RoundTripper) http.RoundTripper {
+	return func(tr http.RoundTripper) http.RoundTripper {
+		return &cachingTransport{tr, installationID}
+	}
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.tr.RoundTrip(req)
+	}
+
+	// Installations see different data and preview media types change
+	// responses
+	key := strconv.FormatInt(t.installationID, 10) + " " + req.Header.Get("Accept") + " " + req.URL.String()
+	cached := responseCache.get(key)
+	if cached != nil {
+		req = cloneRequest(req)
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := t.tr.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		metrics.Add(cachedResponsesServed, 1)
+		fresh, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(cached.dump)), req)
+		if err != nil {
+			return nil, err
+		}
+		// The rate limit is only known from the current response
+		for _, header := range []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"} {
+			fresh.Header.Set(header, resp.Header.Get(header))
+		}
+		return fresh, nil
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode == http.StatusOK && (etag != "" || lastModified != "") {
+		dump, err := httputil.DumpResponse(resp, true)
+		if err != nil {
+			return nil, err
+		}
+		responseCache.put(&cachedResponse{key, dump, etag, lastModified})
+	}
+	return resp, nil
+}
+
+func cloneRequest(req *http.Request) *http.Request {
+	clone := req.WithContext(req.Context())
+	clone.Header = make(http.Header, len(req.Header))
+	for name, values := range req.Header {
+		clone.Header[name] = append([]string(nil), values...)
+	}
+	return clone
+}
@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestConditionalCache(t *testing.T) {
+	var fullResponses, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fullResponses++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"number":276,"title":"Fix connector lookup"}`))
+	}))
+	defer server.Close()
+	defer func() { responseCache.responses = make(map[string]*list.Element); responseCache.order.Init() }()
+
+	gh := github.NewClient(&http.Client{Transport: conditionalCache(44)(http.DefaultTransport)})
+	gh.BaseURL, _ = url.Parse(server.URL + "/")
+
+	for i := 0; i < 3; i++ {
+		issue, _, err := gh.Issues.Get(context.Background(), "syndesisio", "syndesis-rest", fixturePR)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if issue.GetTitle() != "Fix connector lookup" {
+			t.Errorf("unexpected issue %+v", issue)
+		}
+	}
+	if fullResponses != 1 || notModified != 2 {
+		t.Errorf("expected 1 full and 2 not modified responses, got %d and %d", fullResponses, notModified)
+	}
+
+	// Other installations don't share the cache
+	other := github.NewClient(&http.Client{Transport: conditionalCache(45)(http.DefaultTransport)})
+	other.BaseURL = gh.BaseURL
+	if _, _, err := other.Issues.Get(context.Background(), "syndesisio", "syndesis-rest", fixturePR); err != nil {
+		t.Fatal(err)
+	}
+	if fullResponses != 2 {
+		t.Errorf("expected another installation to get a full response, got %d", fullResponses)
+	}
+}
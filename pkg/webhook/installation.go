@@ -0,0 +1,166 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	labelSyncBootstrap        = "labelSync"
+	branchProtectionBootstrap = "branchProtection"
+)
+
+// installationLifecycle bootstraps repositories the app gets installed on
+// and forgets everything about repositories it gets removed from
+type installationLifecycle struct{}
+
+func (h *installationLifecycle) EventTypesHandled() []string {
+	return []string{"installation", "installation_repositories"}
+}
+
+// Installation events are not bound to a single repository, so the per
+// repository configuration is looked up for every repository separately
+func (h *installationLifecycle) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, _ config.RepoConfig, logger *zap.Logger) error {
+	switch event := eventObject.(type) {
+	case *github.InstallationEvent:
+		switch strings.ToLower(event.GetAction()) {
+		case "created":
+			return bootstrapRepositories(ctx, event.Repositories, gh, logger)
+		case "deleted":
+			forgetRepositories(event.Repositories, logger)
+		}
+	case *github.InstallationRepositoriesEvent:
+		switch strings.ToLower(event.GetAction()) {
+		case "added":
+			return bootstrapRepositories(ctx, event.RepositoriesAdded, gh, logger)
+		case "removed":
+			forgetRepositories(event.RepositoriesRemoved, logger)
+		}
+	default:
+		return errors.New("wrong event eventObject type")
+	}
+	return nil
+}
+
+func bootstrapRepositories(ctx context.Context, repos []*github.Repository, gh *github.Client, logger *zap.Logger) error {
+	var multiErr error
+	for _, repo := range repos {
+		owner, name, err := splitFullName(repo.GetFullName())
+		if err != nil {
+			multiErr = multierr.Combine(multiErr, err)
+			continue
+		}
+
+		repoConfig := extractRepoConfigWithDefaults(repo, currentConfig())
+		if repoConfig.Disabled || len(repoConfig.Bootstrap) == 0 {
+			continue
+		}
+
+		logger.Info("Bootstrapping repository", zap.String("repo", repo.GetFullName()), zap.Strings("actions", repoConfig.Bootstrap))
+		for _, action := range repoConfig.Bootstrap {
+			switch action {
+			case labelSyncBootstrap:
+				_, err = syncLabels(ctx, owner, name, gh, repoConfig.LabelSync, logger)
+			case branchProtectionBootstrap:
+				err = protectBranch(ctx, owner, name, gh, repoConfig.BranchProtection)
+			default:
+				err = errors.Errorf("unknown bootstrap action '%s' for %s", action, repo.GetFullName())
+			}
+			multiErr = multierr.Combine(multiErr, err)
+		}
+	}
+	return multiErr
+}
+
+// protectBranch applies the configured protection to a branch, the default
+// branch if none is configured
+func protectBranch(ctx context.Context, owner, repository string, gh *github.Client, cfg config.BranchProtectionConfig) error {
+	branch := cfg.Branch
+	if branch == "" {
+		repo, _, err := gh.Repositories.Get(ctx, owner, repository)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get repository %s/%s", owner, repository)
+		}
+		branch = repo.GetDefaultBranch()
+	}
+
+	protection := &github.ProtectionRequest{
+		RequiredStatusChecks: &github.RequiredStatusChecks{
+			Strict:   cfg.Strict,
+			Contexts: cfg.RequiredContexts,
+		},
+	}
+	if protection.RequiredStatusChecks.Contexts == nil {
+		protection.RequiredStatusChecks.Contexts = []string{}
+	}
+	if cfg.RequiredApprovals > 0 {
+		protection.RequiredPullRequestReviews = &github.PullRequestReviewsEnforcementRequest{
+			RequiredApprovingReviewCount: cfg.RequiredApprovals,
+		}
+	}
+
+	_, _, err := gh.Repositories.UpdateBranchProtection(ctx, owner, repository, branch, protection)
+	return errors.Wrapf(err, "failed to protect branch %s of %s/%s", branch, owner, repository)
+}
+
+// forgetRepositories drops all in-memory state and cached responses of the
+// given repositories and cancels their scheduled evaluations and merges
+func forgetRepositories(repos []*github.Repository, logger *zap.Logger) {
+	for _, repo := range repos {
+		fullName := repo.GetFullName()
+		if fullName == "" {
+			continue
+		}
+		logger.Info("Forgetting repository", zap.String("repo", fullName))
+
+		cancelScheduled(fullName + "@")
+		cancelDeferredMerges(fullName + "#")
+		activity.forgetRepository(fullName)
+		responseCache.forgetRepository(fullName)
+
+		reviewerAssignmentMu.Lock()
+		delete(roundRobinPositions, fullName)
+		reviewerAssignmentMu.Unlock()
+
+		retestCountersMu.Lock()
+		for key := range retestCounters {
+			if strings.HasPrefix(key, fullName+"@") {
+				delete(retestCounters, key)
+			}
+		}
+		retestCountersMu.Unlock()
+
+		labelSyncResultsMu.Lock()
+		delete(labelSyncResults, fullName)
+		labelSyncResultsMu.Unlock()
+	}
+}
+
+func splitFullName(fullName string) (string, string, error) {
+	parts := strings.SplitN(fullName, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.Errorf("invalid repository name '%s'", fullName)
+	}
+	return parts[0], parts[1], nil
+}
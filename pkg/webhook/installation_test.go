@@ -0,0 +1,186 @@
+package webhook
+
+import (
+	"container/list"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestInstallationBootstrap(t *testing.T) {
+	defer UpdateConfig(currentConfig())
+
+	bootstrapConfig := config.Config{DefaultRepo: config.RepoConfig{
+		Bootstrap: []string{labelSyncBootstrap, branchProtectionBootstrap},
+		LabelSync: config.LabelSyncConfig{Labels: []config.LabelDefinition{
+			{Name: "kind/bug", Color: "ee0701", Aliases: []string{"bug"}},
+		}},
+		BranchProtection: config.BranchProtectionConfig{RequiredContexts: []string{"ci"}},
+	}}
+	disabledConfig := bootstrapConfig
+	disabledConfig.Repos = map[string]config.RepoConfig{"syndesis-rest": {Disabled: true}}
+
+	repos := []*github.Repository{{
+		Name:     github.String("syndesis-rest"),
+		FullName: github.String("syndesisio/syndesis-rest"),
+		Owner:    &github.User{Login: github.String("syndesisio")},
+	}}
+	bootstrapped := []string{"PATCH " + fixtureRepo + "/labels/bug", "PUT " + fixtureRepo + "/branches/master/protection"}
+
+	tests := []struct {
+		name          string
+		event         interface{}
+		config        config.Config
+		expectedCalls []string
+	}{
+		{
+			name:          "repositories added",
+			event:         &github.InstallationRepositoriesEvent{Action: github.String("added"), RepositoriesAdded: repos},
+			config:        bootstrapConfig,
+			expectedCalls: bootstrapped,
+		},
+		{
+			name:          "installation created",
+			event:         &github.InstallationEvent{Action: github.String("created"), Repositories: repos},
+			config:        bootstrapConfig,
+			expectedCalls: bootstrapped,
+		},
+		{
+			name:   "repository disabled",
+			event:  &github.InstallationRepositoriesEvent{Action: github.String("added"), RepositoriesAdded: repos},
+			config: disabledConfig,
+		},
+		{
+			name:  "no bootstrap actions",
+			event: &github.InstallationRepositoriesEvent{Action: github.String("added"), RepositoriesAdded: repos},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, map[string]interface{}{
+				"GET " + fixtureRepo + "/labels": []interface{}{map[string]interface{}{"name": "bug", "color": "ee0701"}},
+				"GET " + fixtureRepo:             map[string]interface{}{"default_branch": "master"},
+			})
+			defer fake.close()
+
+			UpdateConfig(test.config)
+			if err := (&installationLifecycle{}).HandleEvent(context.Background(), test.event, fake.client(), fake.graphQL(), config.RepoConfig{}, zap.NewNop()); err != nil {
+				t.Fatalf("bootstrap failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestInstallationForgetsRepositories(t *testing.T) {
+	defer func(positions map[string]int) { roundRobinPositions = positions }(roundRobinPositions)
+	defer func(counters map[string]*retestCounter) { retestCounters = counters }(retestCounters)
+	defer func(results map[string]labelSyncResult) { labelSyncResults = results }(labelSyncResults)
+	defer func(a *mergeActivity) { activity = a }(activity)
+	defer func(c *httpCache) { responseCache = c }(responseCache)
+
+	const removed, kept = "syndesisio/syndesis-rest", "syndesisio/syndesis-rest-ui"
+	repos := []*github.Repository{{FullName: github.String(removed)}}
+
+	tests := []struct {
+		name  string
+		event interface{}
+	}{
+		{
+			name:  "repositories removed",
+			event: &github.InstallationRepositoriesEvent{Action: github.String("removed"), RepositoriesRemoved: repos},
+		},
+		{
+			name:  "installation deleted",
+			event: &github.InstallationEvent{Action: github.String("deleted"), Repositories: repos},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			roundRobinPositions = map[string]int{removed: 1, kept: 1}
+			retestCounters = map[string]*retestCounter{
+				removed + "@" + fixtureSHA: {count: 1, updated: time.Now()},
+				kept + "@" + fixtureSHA:    {count: 1, updated: time.Now()},
+			}
+			labelSyncResults = map[string]labelSyncResult{removed: {}, kept: {}}
+			activity = newMergeActivity()
+			responseCache = &httpCache{order: list.New(), responses: make(map[string]*list.Element)}
+			for _, repo := range []string{removed, kept} {
+				activity.waiting(repo, &github.Issue{Number: github.Int(fixturePR)}, "checks")
+				for _, path := range []string{"/repos/" + repo, "/repos/" + repo + "/pulls/276"} {
+					responseCache.put(&cachedResponse{key: "1 application/json https://api.github.com" + path})
+				}
+			}
+
+			scheduled := make(map[string]chan struct{})
+			deferred := make(map[string]*time.Timer)
+			pendingEvaluationsMu.Lock()
+			deferredMergesMu.Lock()
+			for _, repo := range []string{removed, kept} {
+				scheduled[repo] = make(chan struct{})
+				pendingEvaluations[repo+"@"+fixtureSHA] = scheduled[repo]
+				deferred[repo] = time.AfterFunc(time.Hour, func() {})
+				deferredMerges[repo+"#276"] = &deferredMerge{timer: deferred[repo]}
+			}
+			deferredMergesMu.Unlock()
+			pendingEvaluationsMu.Unlock()
+			defer cancelScheduled(kept + "@")
+			defer cancelDeferredMerges(kept + "#")
+
+			fake := newFakeGitHub(t, nil)
+			defer fake.close()
+			if err := (&installationLifecycle{}).HandleEvent(context.Background(), test.event, fake.client(), fake.graphQL(), config.RepoConfig{}, zap.NewNop()); err != nil {
+				t.Fatalf("forgetting repositories failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); len(calls) > 0 {
+				t.Errorf("expected no calls, got %v", calls)
+			}
+
+			if _, found := roundRobinPositions[removed]; found || len(roundRobinPositions) != 1 {
+				t.Errorf("expected only the round-robin position of %s, got %v", kept, roundRobinPositions)
+			}
+			if _, found := retestCounters[removed+"@"+fixtureSHA]; found || len(retestCounters) != 1 {
+				t.Errorf("expected only the retest counter of %s, got %v", kept, retestCounters)
+			}
+			if _, found := labelSyncResults[removed]; found || len(labelSyncResults) != 1 {
+				t.Errorf("expected only the label sync result of %s, got %v", kept, labelSyncResults)
+			}
+			if pending, _ := activity.snapshot(); len(pending) != 1 || pending[0].Repo != kept {
+				t.Errorf("expected only the pending merge of %s, got %+v", kept, pending)
+			}
+			if len(responseCache.responses) != 2 || responseCache.get("1 application/json https://api.github.com/repos/"+kept) == nil {
+				t.Errorf("expected only the cached responses of %s, got %v", kept, responseCache.responses)
+			}
+
+			select {
+			case <-scheduled[removed]:
+			default:
+				t.Errorf("expected the scheduled evaluation of %s to be cancelled", removed)
+			}
+			select {
+			case <-scheduled[kept]:
+				t.Errorf("expected the scheduled evaluation of %s to be kept", kept)
+			default:
+			}
+			deferredMergesMu.Lock()
+			_, removedDeferred := deferredMerges[removed+"#276"]
+			_, keptDeferred := deferredMerges[kept+"#276"]
+			deferredMergesMu.Unlock()
+			if removedDeferred || deferred[removed].Stop() {
+				t.Errorf("expected the deferred merge of %s to be cancelled", removed)
+			}
+			if !keptDeferred {
+				t.Errorf("expected the deferred merge of %s to be kept", kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// Label commands let triagers without write access label issues, but only
+// with the labels allowed by the "triageLabels" config
+var (
+	labelCommand = Command{
+		Help:       "Adds labels allowed for triage",
+		Usage:      "<label> ...",
+		Permission: "read",
+		Run:        runLabel,
+	}
+	removeLabelCommand = Command{
+		Help:       "Removes labels allowed for triage",
+		Usage:      "<label> ...",
+		Permission: "read",
+		Run:        runRemoveLabel,
+	}
+)
+
+// triageLabelAllowed tells whether a label is listed in the allowed labels or
+// starts with one of the listed prefixes
+func triageLabelAllowed(allowed []string, label string) bool {
+	for _, a := range allowed {
+		if strings.HasSuffix(a, "/") {
+			if len(label) > len(a) && strings.EqualFold(label[:len(a)], a) {
+				return true
+			}
+		} else if strings.EqualFold(label, a) {
+			return true
+		}
+	}
+	return false
+}
+
+func runLabel(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if len(config.TriageLabels) == 0 {
+		return cmd.Reply(ctx, gh, "no labels can be added by command in this repository.")
+	}
+	owner, repository := cmd.Owner(), cmd.Repo()
+
+	var add, rejected, unknown []string
+	for _, label := range cmd.Args {
+		switch {
+		case containsLabel(cmd.Event.Issue.Labels, label):
+		case !triageLabelAllowed(config.TriageLabels, label):
+			rejected = append(rejected, label)
+		default:
+			// Adding a label creates it if it doesn't exist
+			existing, _, err := gh.Issues.GetLabel(ctx, owner, repository, label)
+			if err != nil {
+				if errResp, ok := err.(*github.ErrorResponse); ok && errResp.Response.StatusCode == http.StatusNotFound {
+					unknown = append(unknown, label)
+					continue
+				}
+				return errors.Wrapf(err, "failed to get label %s of %s/%s", label, owner, repository)
+			}
+			add = append(add, existing.GetName())
+		}
+	}
+
+	if len(add) > 0 {
+		if _, _, err := gh.Issues.AddLabelsToIssue(ctx, owner, repository, cmd.Number(), add); err != nil {
+			return errors.Wrapf(err, "failed to add labels %s to %s", strings.Join(add, ", "), cmd.Event.Issue.GetHTMLURL())
+		}
+	}
+	return replyRejectedLabels(ctx, gh, cmd, config, rejected, unknown)
+}
+
+func runRemoveLabel(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if len(config.TriageLabels) == 0 {
+		return cmd.Reply(ctx, gh, "no labels can be removed by command in this repository.")
+	}
+
+	var rejected []string
+	var err error
+	for _, label := range cmd.Args {
+		switch {
+		case !containsLabel(cmd.Event.Issue.Labels, label):
+		case !triageLabelAllowed(config.TriageLabels, label):
+			rejected = append(rejected, label)
+		default:
+			_, removeErr := gh.Issues.RemoveLabelForIssue(ctx, cmd.Owner(), cmd.Repo(), cmd.Number(), label)
+			err = multierr.Combine(err, errors.Wrapf(removeErr, "failed to remove label %s from %s", label, cmd.Event.Issue.GetHTMLURL()))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return replyRejectedLabels(ctx, gh, cmd, config, rejected, nil)
+}
+
+func replyRejectedLabels(ctx context.Context, gh *github.Client, cmd CommandEvent, config config.RepoConfig, rejected, unknown []string) error {
+	var problems []string
+	if len(rejected) > 0 {
+		problems = append(problems, "these labels can't be changed by command: "+strings.Join(rejected, ", ")+" (allowed are "+strings.Join(config.TriageLabels, ", ")+")")
+	}
+	if len(unknown) > 0 {
+		problems = append(problems, "these labels don't exist: "+strings.Join(unknown, ", "))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return cmd.Reply(ctx, gh, strings.Join(problems, "; ")+".")
+}
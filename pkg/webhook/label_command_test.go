@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestTriageLabelAllowed(t *testing.T) {
+	allowed := []string{"kind/", "good first issue"}
+	for label, expected := range map[string]bool{
+		"kind/bug":         true,
+		"Kind/Bug":         true,
+		"kind/":            false,
+		"good first issue": true,
+		"priority/high":    false,
+		"kindness":         false,
+	} {
+		if triageLabelAllowed(allowed, label) != expected {
+			t.Errorf("expected %s to be allowed: %v", label, expected)
+		}
+	}
+}
+
+func TestLabelCommands(t *testing.T) {
+	addLabels := "POST " + fixtureRepo + "/issues/276/labels"
+	triageConfig := config.RepoConfig{TriageLabels: []string{"kind/", "area/"}}
+
+	tests := []struct {
+		name          string
+		comment       string
+		labels        []string
+		config        config.RepoConfig
+		expectedCalls []string
+		added         []string
+		reply         string
+	}{
+		{
+			name:          "allowed labels",
+			comment:       "/label kind/bug area/ui",
+			config:        triageConfig,
+			expectedCalls: []string{addLabels},
+			added:         []string{"kind/bug", "area/ui"},
+		},
+		{
+			name:          "rejected and unknown labels",
+			comment:       "/label approved kind/bug kind/nonsense",
+			config:        triageConfig,
+			expectedCalls: []string{addLabels, createComment},
+			added:         []string{"kind/bug"},
+			reply:         "@roland these labels can't be changed by command: approved (allowed are kind/, area/); these labels don't exist: kind/nonsense.",
+		},
+		{
+			name:          "remove label",
+			comment:       "/remove-label kind/bug kind/feature",
+			labels:        []string{"kind/bug"},
+			config:        triageConfig,
+			expectedCalls: []string{"DELETE " + fixtureRepo + "/issues/276/labels/kind/bug"},
+		},
+		{
+			name:          "no triage labels",
+			comment:       "/label kind/bug",
+			expectedCalls: []string{createComment},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, map[string]interface{}{
+				"GET " + fixtureRepo + "/collaborators/roland/permission": permissionJSON("read"),
+				"GET " + fixtureRepo + "/labels/kind/bug":                 map[string]interface{}{"name": "kind/bug"},
+				"GET " + fixtureRepo + "/labels/area/ui":                  map[string]interface{}{"name": "area/ui"},
+				"GET " + fixtureRepo + "/labels/kind/nonsense":            notFound,
+			})
+			defer fake.close()
+
+			event := commentEvent(test.comment, "roland", "User", false)
+			for _, label := range test.labels {
+				event.Issue.Labels = append(event.Issue.Labels, github.Label{Name: github.String(label)})
+			}
+			if err := (&slashCommands{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("handler failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Fatalf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if test.added != nil {
+				var added []string
+				if err := fake.requestBody(addLabels, &added); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(added, test.added) {
+					t.Errorf("expected labels %v, got %v", test.added, added)
+				}
+			}
+			if test.reply != "" {
+				var comment github.IssueComment
+				if err := fake.requestBody(createComment, &comment); err != nil {
+					t.Fatal(err)
+				}
+				if comment.GetBody() != test.reply {
+					t.Errorf("expected reply %q, got %q", test.reply, comment.GetBody())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	labelCreate = "create"
+	labelUpdate = "update"
+	labelRename = "rename"
+	labelDelete = "delete"
+)
+
+// labelChange is a single modification applied during label synchronization
+type labelChange struct {
+	Action      string `json:"action"`
+	Name        string `json:"name"`
+	From        string `json:"from,omitempty"`
+	Color       string `json:"color,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// labelSyncResult is the outcome of the last synchronization of a repository
+type labelSyncResult struct {
+	Time    time.Time     `json:"time"`
+	Changes []labelChange `json:"changes"`
+	Error   string        `json:"error,omitempty"`
+}
+
+var (
+	labelSyncResultsMu sync.Mutex
+	labelSyncResults   = make(map[string]labelSyncResult)
+)
+
+type labelSync struct{}
+
+func (h *labelSync) EventTypesHandled() []string {
+	return []string{"repository"}
+}
+
+func (h *labelSync) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	event, ok := eventObject.(*github.RepositoryEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+
+	if len(config.LabelSync.Labels) == 0 || strings.ToLower(event.GetAction()) != "created" {
+		return nil
+	}
+
+	_, err := syncLabels(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), gh, config.LabelSync, logger)
+	return err
+}
+
+// SyncLabels periodically applies the label definitions to all repositories
+// of all installations. It returns when ctx is done or the bot is shutting
+// down.
+func SyncLabels(ctx context.Context, cfg config.Config, logger *zap.Logger) {
+	if cfg.LabelSyncInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cfg.LabelSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if !inFlight.begin() {
+			return
+		}
+		logger.Debug("Synchronizing labels")
+		_, err := syncAllLabels(shutdownCtx, currentConfig(), "", logger)
+		inFlight.done()
+		if err != nil {
+			logger.Error("Synchronizing labels failed", zap.Error(err))
+		}
+	}
+}
+
+// syncAllLabels synchronizes the labels of all repositories of all
+// installations, or only those of the repository with the given full name,
+// and returns the results by repository
+func syncAllLabels(ctx context.Context, cfg config.Config, only string, logger *zap.Logger) (map[string]labelSyncResult, error) {
+	results := make(map[string]labelSyncResult)
+	err := forEachRepository(ctx, cfg, logger, labelSyncer(results, only))
+	return results, err
+}
+
+// labelSyncer returns a repositoryFunc synchronizing the labels of the
+// repositories with label definitions into results
+func labelSyncer(results map[string]labelSyncResult, only string) repositoryFunc {
+	return func(ctx context.Context, repo *github.Repository, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+		if only != "" && !strings.EqualFold(repo.GetFullName(), only) {
+			return nil
+		}
+		if len(config.LabelSync.Labels) == 0 || !config.HandlerEnabled("labelSync") {
+			return nil
+		}
+		result, err := syncLabels(ctx, repo.Owner.GetLogin(), repo.GetName(), gh, config.LabelSync, logger)
+		results[repo.GetFullName()] = result
+		return err
+	}
+}
+
+// lastLabelSyncs returns the result of the last synchronization of each
+// repository
+func lastLabelSyncs() map[string]labelSyncResult {
+	labelSyncResultsMu.Lock()
+	defer labelSyncResultsMu.Unlock()
+	results := make(map[string]labelSyncResult, len(labelSyncResults))
+	for repo, result := range labelSyncResults {
+		results[repo] = result
+	}
+	return results
+}
+
+// syncLabels brings the labels of a repository in line with the configured
+// label definitions. The result is kept as the last synchronization of the
+// repository.
+func syncLabels(ctx context.Context, owner, repository string, gh *github.Client, cfg config.LabelSyncConfig, logger *zap.Logger) (labelSyncResult, error) {
+	result := labelSyncResult{Time: time.Now(), Changes: []labelChange{}}
+	existing, err := listAllLabels(ctx, owner, repository, gh)
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+
+	changes := computeLabelChanges(existing, cfg)
+	var multiErr error
+	for _, change := range changes {
+		logger.Info("Synchronizing label", zap.String("repo", owner+"/"+repository), zap.String("action", change.Action), zap.String("label", change.Name), zap.String("from", change.From))
+		if err := applyLabelChange(ctx, owner, repository, gh, change); err != nil {
+			multiErr = multierr.Combine(multiErr, err)
+			continue
+		}
+		result.Changes = append(result.Changes, change)
+	}
+
+	if multiErr != nil {
+		result.Error = multiErr.Error()
+	}
+	labelSyncResultsMu.Lock()
+	labelSyncResults[owner+"/"+repository] = result
+	labelSyncResultsMu.Unlock()
+
+	return result, multiErr
+}
+
+func listAllLabels(ctx context.Context, owner, repository string, gh *github.Client) ([]*github.Label, error) {
+	var labels []*github.Label
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := gh.Issues.ListLabels(ctx, owner, repository, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list labels of %s/%s", owner, repository)
+		}
+		labels = append(labels, page...)
+		if resp.NextPage == 0 {
+			return labels, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func applyLabelChange(ctx context.Context, owner, repository string, gh *github.Client, change labelChange) error {
+	label := &github.Label{
+		Name:        github.String(change.Name),
+		Color:       github.String(change.Color),
+		Description: github.String(change.Description),
+	}
+
+	var err error
+	switch change.Action {
+	case labelCreate:
+		_, _, err = gh.Issues.CreateLabel(ctx, owner, repository, label)
+	case labelUpdate:
+		_, _, err = gh.Issues.EditLabel(ctx, owner, repository, change.Name, label)
+	case labelRename:
+		_, _, err = gh.Issues.EditLabel(ctx, owner, repository, change.From, label)
+	case labelDelete:
+		_, err = gh.Issues.DeleteLabel(ctx, owner, repository, change.Name)
+	}
+	return errors.Wrapf(err, "failed to %s label '%s' in %s/%s", change.Action, change.Name, owner, repository)
+}
+
+// computeLabelChanges calculates which changes are needed to turn the
+// existing labels into the defined ones. Labels are only deleted when pruning
+// is enabled.
+func computeLabelChanges(existing []*github.Label, cfg config.LabelSyncConfig) []labelChange {
+	byName := make(map[string]*github.Label, len(existing))
+	for _, label := range existing {
+		byName[strings.ToLower(label.GetName())] = label
+	}
+
+	var changes []labelChange
+	matched := make(map[string]bool)
+	for _, def := range cfg.Labels {
+		color := normalizeColor(def.Color)
+		if label, found := byName[strings.ToLower(def.Name)]; found {
+			matched[strings.ToLower(def.Name)] = true
+			if normalizeColor(label.GetColor()) != color || label.GetDescription() != def.Description {
+				changes = append(changes, labelChange{Action: labelUpdate, Name: label.GetName(), Color: color, Description: def.Description})
+			}
+			continue
+		}
+
+		change := labelChange{Action: labelCreate, Name: def.Name, Color: color, Description: def.Description}
+		for _, alias := range def.Aliases {
+			if label, found := byName[strings.ToLower(alias)]; found && !matched[strings.ToLower(alias)] {
+				matched[strings.ToLower(alias)] = true
+				change.Action = labelRename
+				change.From = label.GetName()
+				break
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	if cfg.Prune {
+		for _, label := range existing {
+			if !matched[strings.ToLower(label.GetName())] {
+				changes = append(changes, labelChange{Action: labelDelete, Name: label.GetName()})
+			}
+		}
+	}
+	return changes
+}
+
+func normalizeColor(color string) string {
+	return strings.ToLower(strings.TrimPrefix(color, "#"))
+}
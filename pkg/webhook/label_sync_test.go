@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func label(name, color, description string) *github.Label {
+	return &github.Label{Name: &name, Color: &color, Description: &description}
+}
+
+func TestComputeLabelChanges(t *testing.T) {
+	cfg := config.LabelSyncConfig{
+		Labels: []config.LabelDefinition{
+			{Name: "kind/bug", Color: "#EE0701", Description: "Something isn't working", Aliases: []string{"bug", "defect"}},
+			{Name: "kind/feature", Color: "84b6eb", Aliases: []string{"enhancement"}},
+			{Name: "triage", Color: "ffffff"},
+			{Name: "area/docs", Color: "000000", Aliases: []string{"docs"}},
+		},
+	}
+	existing := []*github.Label{
+		label("Bug", "fc2929", ""),
+		label("enhancement", "84b6eb", ""),
+		label("triage", "cccccc", ""),
+		label("area/docs", "000000", ""),
+		label("docs", "000000", ""),
+		label("question", "cc317c", ""),
+	}
+
+	expected := []labelChange{
+		{Action: labelRename, Name: "kind/bug", From: "Bug", Color: "ee0701", Description: "Something isn't working"},
+		{Action: labelRename, Name: "kind/feature", From: "enhancement", Color: "84b6eb"},
+		{Action: labelUpdate, Name: "triage", Color: "ffffff"},
+	}
+	if changes := computeLabelChanges(existing, cfg); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+
+	cfg.Prune = true
+	expected = append(expected,
+		labelChange{Action: labelDelete, Name: "docs"},
+		labelChange{Action: labelDelete, Name: "question"},
+	)
+	if changes := computeLabelChanges(existing, cfg); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes with pruning %+v", changes)
+	}
+}
+
+func TestComputeLabelChangesCreatesMissing(t *testing.T) {
+	cfg := config.LabelSyncConfig{
+		Labels: []config.LabelDefinition{{Name: "kind/bug", Color: "ee0701", Aliases: []string{"bug"}}},
+	}
+	expected := []labelChange{{Action: labelCreate, Name: "kind/bug", Color: "ee0701"}}
+	if changes := computeLabelChanges(nil, cfg); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+}
+
+func TestLabelSyncer(t *testing.T) {
+	defer func(results map[string]labelSyncResult) { labelSyncResults = results }(labelSyncResults)
+	labelSyncResults = make(map[string]labelSyncResult)
+
+	repositories := map[string]interface{}{
+		"total_count": 1,
+		"repositories": []map[string]interface{}{{
+			"name":      "syndesis-rest",
+			"full_name": "syndesisio/syndesis-rest",
+			"owner":     map[string]interface{}{"login": "syndesisio"},
+		}},
+	}
+	labelSync := config.LabelSyncConfig{Labels: []config.LabelDefinition{
+		{Name: "kind/bug", Color: "ee0701", Aliases: []string{"bug"}},
+		{Name: "triage", Color: "ffffff"},
+	}}
+
+	tests := []struct {
+		name            string
+		config          config.Config
+		only            string
+		expectedCalls   []string
+		expectedChanges []labelChange
+	}{
+		{
+			name:          "labels synchronized",
+			config:        config.Config{DefaultRepo: config.RepoConfig{LabelSync: labelSync}},
+			expectedCalls: []string{"PATCH " + fixtureRepo + "/labels/bug", "POST " + fixtureRepo + "/labels"},
+			expectedChanges: []labelChange{
+				{Action: labelRename, Name: "kind/bug", From: "bug", Color: "ee0701"},
+				{Action: labelCreate, Name: "triage", Color: "ffffff"},
+			},
+		},
+		{
+			name:   "other repository requested",
+			config: config.Config{DefaultRepo: config.RepoConfig{LabelSync: labelSync}},
+			only:   "syndesisio/syndesis-ui",
+		},
+		{
+			name:   "label sync switched off",
+			config: config.Config{DefaultRepo: config.RepoConfig{LabelSync: labelSync, Handlers: map[string]bool{"labelSync": false}}},
+		},
+		{
+			name:   "no label definitions",
+			config: config.Config{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, map[string]interface{}{
+				"GET /installation/repositories": repositories,
+				"GET " + fixtureRepo + "/labels": []interface{}{map[string]interface{}{"name": "bug", "color": "fc2929"}},
+			})
+			defer fake.close()
+
+			results := make(map[string]labelSyncResult)
+			if err := forEachInstallationRepository(context.Background(), fake.client(), test.config, zap.NewNop(), labelSyncer(results, test.only)); err != nil {
+				t.Fatalf("label sync failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			result, synced := results["syndesisio/syndesis-rest"]
+			if synced != (test.expectedChanges != nil) || (synced && !reflect.DeepEqual(result.Changes, test.expectedChanges)) {
+				t.Errorf("expected changes %+v, got %+v", test.expectedChanges, results)
+			}
+		})
+	}
+
+	// The last result of each repository is reported by the admin endpoint
+	r := httptest.NewRequest(http.MethodGet, "/admin/label-sync", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	NewAdminHandler("secret", zap.NewNop()).ServeHTTP(w, r)
+	var last map[string]labelSyncResult
+	if err := json.NewDecoder(w.Body).Decode(&last); err != nil {
+		t.Fatal(err)
+	}
+	if len(last) != 1 || len(last["syndesisio/syndesis-rest"].Changes) != 2 {
+		t.Errorf("expected the last label sync to be reported, got %+v", last)
+	}
+}
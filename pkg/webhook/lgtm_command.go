@@ -0,0 +1,115 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+// The permission is checked by the command itself, as members of the
+// approver teams may use it as well
+var lgtmCommand = Command{
+	Help:            "Adds the approved label, `/lgtm cancel` removes it again",
+	Usage:           "[cancel]",
+	Permission:      "none",
+	PullRequestOnly: true,
+	Run:             runLgtm,
+}
+
+func runLgtm(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	label := config.Labels.Approved
+	if label == "" {
+		return cmd.Reply(ctx, gh, "no approved label is configured for this repository.")
+	}
+	if cmd.IsAuthor() {
+		logger.Debug("Ignoring /lgtm of the pull request's author")
+		return nil
+	}
+
+	allowed, err := mayApprove(ctx, gh, cmd, config.ApproverTeams)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		logger.Info("command rejected, neither write permission nor member of an approver team")
+		return cmd.Reply(ctx, gh, "you need write permission on this repository or membership in an approver team to use `/lgtm`.")
+	}
+
+	owner, repository, number := cmd.Owner(), cmd.Repo(), cmd.Number()
+	labeled := containsLabel(cmd.Event.Issue.Labels, label)
+	if len(cmd.Args) > 0 && strings.EqualFold(cmd.Args[0], "cancel") {
+		if !labeled {
+			return nil
+		}
+		_, err := gh.Issues.RemoveLabelForIssue(ctx, owner, repository, number, label)
+		return errors.Wrapf(err, "failed to remove label %s from %s", label, cmd.Event.Issue.GetHTMLURL())
+	}
+	if labeled {
+		return nil
+	}
+	_, _, err = gh.Issues.AddLabelsToIssue(ctx, owner, repository, number, []string{label})
+	return errors.Wrapf(err, "failed to add label %s to %s", label, cmd.Event.Issue.GetHTMLURL())
+}
+
+// mayApprove tells whether the commenter has write permission on the
+// repository or is an active member of one of the teams
+func mayApprove(ctx context.Context, gh *github.Client, cmd CommandEvent, teams []string) (bool, error) {
+	permission, err := commenterPermission(ctx, gh, cmd)
+	if err != nil {
+		return false, err
+	}
+	if permissionRanks[permission] >= permissionRanks["write"] {
+		return true, nil
+	}
+	if len(teams) == 0 {
+		return false, nil
+	}
+
+	org := cmd.Owner()
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := gh.Teams.ListTeams(ctx, org, opts)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to list teams of %s", org)
+		}
+		for _, team := range page {
+			if !containsIgnoreCase(teams, team.GetSlug()) {
+				continue
+			}
+			membership, _, err := gh.Teams.GetTeamMembership(ctx, team.GetID(), cmd.Commenter())
+			if err != nil {
+				if errResp, ok := err.(*github.ErrorResponse); ok && errResp.Response.StatusCode == http.StatusNotFound {
+					continue
+				}
+				return false, errors.Wrapf(err, "failed to get membership of %s in team %s/%s", cmd.Commenter(), org, team.GetSlug())
+			}
+			if membership.GetState() == "active" {
+				return true, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return false, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
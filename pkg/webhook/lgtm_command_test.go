@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestLgtmCommand(t *testing.T) {
+	getPermission := "GET " + fixtureRepo + "/collaborators/roland/permission"
+	listTeams := "GET /orgs/syndesisio/teams"
+	getMembership := "GET /teams/7/memberships/roland"
+	addLabels := "POST " + fixtureRepo + "/issues/276/labels"
+	removeApproved := "DELETE " + fixtureRepo + "/issues/276/labels/approved"
+
+	teamConfig := autoMergeConfig
+	teamConfig.ApproverTeams = []string{"reviewers"}
+
+	tests := []struct {
+		name          string
+		comment       string
+		author        string
+		labels        []string
+		config        config.RepoConfig
+		responses     map[string]interface{}
+		expectedCalls []string
+	}{
+		{
+			name:          "write permission",
+			comment:       "/lgtm",
+			config:        autoMergeConfig,
+			responses:     map[string]interface{}{getPermission: permissionJSON("write")},
+			expectedCalls: []string{addLabels},
+		},
+		{
+			name:          "cancel",
+			comment:       "/lgtm cancel",
+			labels:        []string{"approved"},
+			config:        autoMergeConfig,
+			responses:     map[string]interface{}{getPermission: permissionJSON("admin")},
+			expectedCalls: []string{removeApproved},
+		},
+		{
+			name:    "author",
+			comment: "/lgtm",
+			author:  "roland",
+			config:  autoMergeConfig,
+		},
+		{
+			name:          "read permission",
+			comment:       "/lgtm",
+			config:        autoMergeConfig,
+			responses:     map[string]interface{}{getPermission: permissionJSON("read")},
+			expectedCalls: []string{createComment},
+		},
+		{
+			name:    "approver team",
+			comment: "/lgtm",
+			config:  teamConfig,
+			responses: map[string]interface{}{
+				getPermission: permissionJSON("read"),
+				listTeams:     []map[string]interface{}{{"id": 3, "slug": "admins"}, {"id": 7, "slug": "reviewers"}},
+				getMembership: map[string]interface{}{"state": "active", "role": "member"},
+			},
+			expectedCalls: []string{addLabels},
+		},
+		{
+			name:    "not in approver team",
+			comment: "/lgtm",
+			config:  teamConfig,
+			responses: map[string]interface{}{
+				getPermission: permissionJSON("read"),
+				listTeams:     []map[string]interface{}{{"id": 7, "slug": "reviewers"}},
+				getMembership: notFound,
+			},
+			expectedCalls: []string{createComment},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			event := commentEvent(test.comment, "roland", "User", true)
+			event.Issue.User = &github.User{Login: github.String("kurt")}
+			if test.author != "" {
+				event.Issue.User.Login = github.String(test.author)
+			}
+			for _, label := range test.labels {
+				event.Issue.Labels = append(event.Issue.Labels, github.Label{Name: github.String(label)})
+			}
+			if err := (&slashCommands{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("handler failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	// Number of merges shown on the dashboard
+	recentMergesSize = 50
+	// Merges are kept for a weekly digest, but not more than maxMerges
+	mergeRetention = 8 * 24 * time.Hour
+	maxMerges      = 5000
+)
+
+// pendingMerge is an approved pull request the bot waits to merge
+type pendingMerge struct {
+	Repo      string
+	Number    int
+	Title     string
+	URL       string
+	WaitingOn string
+	// When the pull request was found blocked first and last
+	Since   time.Time
+	Updated time.Time
+}
+
+// recentMerge is a pull request merged by the bot
+type recentMerge struct {
+	Repo   string
+	Number int
+	Title  string
+	URL    string
+	Merged time.Time
+}
+
+// mergeActivity tracks the outcome of merge evaluations for the dashboard
+type mergeActivity struct {
+	mu      sync.Mutex
+	pending map[string]*pendingMerge
+	merged  []recentMerge
+}
+
+var activity = newMergeActivity()
+
+func newMergeActivity() *mergeActivity {
+	return &mergeActivity{pending: make(map[string]*pendingMerge)}
+}
+
+func pullRequestKey(repo string, number int) string {
+	return repo + "#" + strconv.Itoa(number)
+}
+
+// waiting records why an approved pull request isn't merged yet
+func (a *mergeActivity) waiting(repo string, issue *github.Issue, reason string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := pullRequestKey(repo, issue.GetNumber())
+	now := timeNow()
+	p, found := a.pending[key]
+	if !found {
+		p = &pendingMerge{Repo: repo, Number: issue.GetNumber(), Since: now}
+		a.pending[key] = p
+	}
+	p.Title, p.URL, p.WaitingOn, p.Updated = issue.GetTitle(), issue.GetHTMLURL(), reason, now
+}
+
+// mergedPR records a merge by the bot
+func (a *mergeActivity) mergedPR(repo string, issue *github.Issue) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.pending, pullRequestKey(repo, issue.GetNumber()))
+	now := timeNow()
+	a.merged = append(a.merged, recentMerge{repo, issue.GetNumber(), issue.GetTitle(), issue.GetHTMLURL(), now})
+	expired := 0
+	for expired < len(a.merged) && now.Sub(a.merged[expired].Merged) > mergeRetention {
+		expired++
+	}
+	if len(a.merged)-expired > maxMerges {
+		expired = len(a.merged) - maxMerges
+	}
+	a.merged = a.merged[expired:]
+}
+
+// forget removes a pull request which isn't to be merged any longer, e.g.
+// because it lost its approval or got closed
+func (a *mergeActivity) forget(repo string, number int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.pending, pullRequestKey(repo, number))
+}
+
+// forgetRepository removes all pull requests of a repository the bot got
+// removed from
+func (a *mergeActivity) forgetRepository(repo string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, p := range a.pending {
+		if p.Repo == repo {
+			delete(a.pending, key)
+		}
+	}
+}
+
+// waitingOn returns why a pull request isn't merged yet, empty if it isn't
+// waiting
+func (a *mergeActivity) waitingOn(repo string, number int) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if p, found := a.pending[pullRequestKey(repo, number)]; found {
+		return p.WaitingOn
+	}
+	return ""
+}
+
+// snapshot returns the pending pull requests by repository and number and
+// the recent merges, the most recent first
+func (a *mergeActivity) snapshot() ([]pendingMerge, []recentMerge) {
+	pending, merged := a.since(time.Time{})
+	if len(merged) > recentMergesSize {
+		merged = merged[:recentMergesSize]
+	}
+	return pending, merged
+}
+
+// since returns the pending pull requests like snapshot and all merges
+// after the given time, the most recent first
+func (a *mergeActivity) since(t time.Time) ([]pendingMerge, []recentMerge) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pending := make([]pendingMerge, 0, len(a.pending))
+	for _, p := range a.pending {
+		pending = append(pending, *p)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].Repo != pending[j].Repo {
+			return pending[i].Repo < pending[j].Repo
+		}
+		return pending[i].Number < pending[j].Number
+	})
+	merged := []recentMerge{}
+	for i := len(a.merged) - 1; i >= 0 && a.merged[i].Merged.After(t); i-- {
+		merged = append(merged, a.merged[i])
+	}
+	return pending, merged
+}
+
+// describeBlockedContexts lists the blocking statuses and checks with their
+// state, e.g. "ci (failure), e2e (missing)"
+func describeBlockedContexts(blocked []blockedContext) string {
+	descriptions := make([]string, 0, len(blocked))
+	for _, c := range blocked {
+		descriptions = append(descriptions, c.Name+" ("+c.State+")")
+	}
+	return strings.Join(descriptions, ", ")
+}
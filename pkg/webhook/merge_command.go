@@ -0,0 +1,87 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+var mergeCommand = Command{
+	Help:            "Merges the pull request if it is mergeable now, without the approved label",
+	PullRequestOnly: true,
+	Run:             runMerge,
+}
+
+type mergeRequestKey struct{}
+
+// withMergeRequest marks a merge evaluation as requested by a user, so that
+// it doesn't need the approved label
+func withMergeRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, mergeRequestKey{}, true)
+}
+
+// mergeRequested tells whether a user requested the merge evaluation
+func mergeRequested(ctx context.Context) bool {
+	requested, _ := ctx.Value(mergeRequestKey{}).(bool)
+	return requested
+}
+
+// runMerge evaluates and merges a pull request like an approved one. All
+// other conditions, like passing checks, the hold label and merge windows,
+// still apply.
+func runMerge(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	owner, repository := cmd.Owner(), cmd.Repo()
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repository, cmd.Number())
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pull request %s/%s#%d", owner, repository, cmd.Number())
+	}
+	if pr.GetMerged() || pr.GetState() != "open" {
+		return cmd.Reply(ctx, gh, "this pull request isn't open.")
+	}
+	issue, _, err := gh.Issues.Get(ctx, owner, repository, cmd.Number())
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pull request %s", pr.GetHTMLURL())
+	}
+
+	result, err := mergePR(withMergeRequest(ctx), issue, pr, owner, repository, gh, gql, "", newEvaluationCache(), config, logger)
+	if result.merged {
+		// Following up on the merge, e.g. deleting the branch, might have
+		// failed nevertheless
+		return multierr.Combine(err, cmd.Reply(ctx, gh, "merged."))
+	}
+	if err != nil {
+		if replyErr := cmd.Reply(ctx, gh, fmt.Sprintf("merging failed: %v", errors.Cause(err))); replyErr != nil {
+			logger.Warn("Failed to report failed merge", zap.Error(replyErr))
+		}
+		return err
+	}
+	if result.deferred {
+		return cmd.Reply(ctx, gh, fmt.Sprintf("will merge this pull request once the merge window opens (%s).", result.reason))
+	}
+
+	if config.Labels.Approved != "" && !containsLabel(issue.Labels, config.Labels.Approved) {
+		// Nothing merges it later without the approved label
+		activity.forget(owner+"/"+repository, cmd.Number())
+	}
+	return cmd.Reply(ctx, gh, fmt.Sprintf("can't merge this pull request now (%s).", result.reason))
+}
@@ -0,0 +1,128 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestMergeCommand(t *testing.T) {
+	defer func(clock func() time.Time) { timeNow = clock }(timeNow)
+	timeNow = func() time.Time { return time.Date(2018, 3, 7, 10, 0, 0, 0, time.UTC) }
+	getPermission := "GET " + fixtureRepo + "/collaborators/roland/permission"
+
+	tests := []struct {
+		name          string
+		changes       map[string]interface{}
+		expectedCalls []string
+		reply         string
+	}{
+		{
+			name:          "without approved label",
+			changes:       map[string]interface{}{getIssue: issueJSON(fixturePR)},
+			expectedCalls: []string{mergePullRequest, createComment},
+			reply:         "@roland merged.",
+		},
+		{
+			name: "failing check",
+			changes: map[string]interface{}{
+				getIssue:     issueJSON(fixturePR),
+				getCheckRuns: checkRunsJSON(map[string]string{"build": "failure"}),
+			},
+			expectedCalls: []string{createComment},
+			reply:         "@roland can't merge this pull request now (statuses and checks: build (failure)).",
+		},
+		{
+			name:          "missing permission",
+			changes:       map[string]interface{}{getPermission: permissionJSON("read")},
+			expectedCalls: []string{createComment},
+			reply:         "@roland you need write permission on this repository to use `/merge`.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			responses := mergeableResponses(map[string]interface{}{getPermission: permissionJSON("write")})
+			for call, response := range test.changes {
+				responses[call] = response
+			}
+			fake := newFakeGitHub(t, responses)
+			defer fake.close()
+
+			event := commentEvent("/merge", "roland", "User", true)
+			if err := (&slashCommands{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), autoMergeConfig, zap.NewNop()); err != nil {
+				t.Fatalf("handler failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Fatalf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			var comment github.IssueComment
+			if err := fake.requestBody(createComment, &comment); err != nil {
+				t.Fatal(err)
+			}
+			if comment.GetBody() != test.reply {
+				t.Errorf("expected reply %q, got %q", test.reply, comment.GetBody())
+			}
+			if reason := activity.waitingOn("syndesisio/syndesis-rest", fixturePR); reason != "" {
+				t.Errorf("expected the pull request not to wait for a merge, waiting on %s", reason)
+			}
+		})
+	}
+}
+
+func TestMergeCommandOutsideMergeWindow(t *testing.T) {
+	defer func(clock func() time.Time) { timeNow = clock }(timeNow)
+	var mu sync.Mutex
+	// Shortly before the weekend
+	now := time.Date(2018, 3, 9, 23, 59, 59, 900000000, time.UTC)
+	timeNow = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	defer cancelDeferredMerges("syndesisio/syndesis-rest#")
+
+	responses := mergeableResponses(map[string]interface{}{
+		"GET " + fixtureRepo + "/collaborators/roland/permission": permissionJSON("write"),
+		getIssue: issueJSON(fixturePR),
+	})
+	fake := newFakeGitHub(t, responses)
+	defer fake.close()
+	repoConfig := config.RepoConfig{
+		Labels:         autoMergeConfig.Labels,
+		DebounceWindow: autoMergeConfig.DebounceWindow,
+		MergeWindows:   []string{"Sat-Sun 00:00-24:00"},
+	}
+
+	event := commentEvent("/merge", "roland", "User", true)
+	if err := (&slashCommands{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), repoConfig, zap.NewNop()); err != nil {
+		t.Fatalf("handler failed: %+v", err)
+	}
+	var comment github.IssueComment
+	if err := fake.requestBody(createComment, &comment); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "@roland will merge this pull request once the merge window opens (merge window opening Sat, 10 Mar 2018 00:00:00 UTC)."; comment.GetBody() != expected {
+		t.Errorf("expected reply %q, got %q", expected, comment.GetBody())
+	}
+
+	// The deferred evaluation merges without the approved label, too
+	mu.Lock()
+	now = now.Add(time.Second)
+	mu.Unlock()
+	expectedCalls := []string{createComment, mergePullRequest}
+	for i := 0; i < 100 && len(fake.mutatingCalls()) < len(expectedCalls); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Let the evaluation finish before restoring the clock
+	inFlight.running.Wait()
+	if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, expectedCalls) {
+		t.Fatalf("expected calls %v, got %v", expectedCalls, calls)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+// mergeCommitData is available to the templates of merge commits and
+// post-merge comments
+type mergeCommitData struct {
+	Title  string
+	Number int
+	Body   string
+	Author string
+}
+
+// renderMergeCommit renders the configured title and message of the commit
+// created by merging a pull request. Empty results leave the choice to
+// GitHub.
+func renderMergeCommit(cfg config.MergeCommitConfig, pr *github.PullRequest) (title string, message string, err error) {
+	data := mergeCommitData{
+		Title:  pr.GetTitle(),
+		Number: pr.GetNumber(),
+		Body:   pr.GetBody(),
+		Author: pr.User.GetLogin(),
+	}
+
+	if title, err = renderTemplate("title", cfg.Title, data); err != nil {
+		return "", "", err
+	}
+	if message, err = renderTemplate("message", cfg.Message, data); err != nil {
+		return "", "", err
+	}
+	// A title spans a single line only
+	return strings.TrimSpace(strings.Replace(title, "\n", " ", -1)), message, nil
+}
+
+func renderTemplate(name string, text string, data interface{}) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid merge commit %s template", name)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "failed to render merge commit %s template", name)
+	}
+	return buf.String(), nil
+}
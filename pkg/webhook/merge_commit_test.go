@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestRenderMergeCommit(t *testing.T) {
+	pr := &github.PullRequest{
+		Number: github.Int(276),
+		Title:  github.String("Fix connector lookup"),
+		Body:   github.String("Fixes #274"),
+		User:   &github.User{Login: github.String("jimmidyson")},
+	}
+
+	tests := []struct {
+		name            string
+		cfg             config.MergeCommitConfig
+		expectedTitle   string
+		expectedMessage string
+		expectErr       bool
+	}{
+		{
+			name: "no templates",
+		},
+		{
+			name: "title and message",
+			cfg: config.MergeCommitConfig{
+				Title:   "{{.Title}} (#{{.Number}})",
+				Message: "{{.Body}}\n\nAuthored-by: {{.Author}}",
+			},
+			expectedTitle:   "Fix connector lookup (#276)",
+			expectedMessage: "Fixes #274\n\nAuthored-by: jimmidyson",
+		},
+		{
+			name:          "multi-line title",
+			cfg:           config.MergeCommitConfig{Title: "{{.Title}}\n{{.Body}}\n"},
+			expectedTitle: "Fix connector lookup Fixes #274",
+		},
+		{
+			name:      "invalid template",
+			cfg:       config.MergeCommitConfig{Message: "{{.Title"},
+			expectErr: true,
+		},
+		{
+			name:      "unknown field",
+			cfg:       config.MergeCommitConfig{Title: "{{.Labels}}"},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		title, message, err := renderMergeCommit(test.cfg, pr)
+		if (err != nil) != test.expectErr {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if title != test.expectedTitle || message != test.expectedMessage {
+			t.Errorf("%s: expected %q / %q, got %q / %q", test.name, test.expectedTitle, test.expectedMessage, title, message)
+		}
+	}
+}
+
+func TestMergeCommitTitleSentToGitHub(t *testing.T) {
+	pr := pullRequestJSON(fixturePR, fixtureSHA, "clean")
+	pr["title"] = "Fix connector lookup"
+	fake := newFakeGitHub(t, mergeableResponses(map[string]interface{}{getPullRequest: pr}))
+	defer fake.close()
+
+	payload, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "pull_request_review.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := parseWebHook("pull_request_review", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := autoMergeConfig
+	cfg.MergeCommit = config.MergeCommitConfig{Title: "{{.Title}} (#{{.Number}})"}
+	if err := (&autoMerger{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), cfg, zap.NewNop()); err != nil {
+		t.Fatalf("handler failed: %+v", err)
+	}
+
+	var merge struct {
+		CommitTitle string `json:"commit_title"`
+	}
+	if err := fake.requestBody(mergePullRequest, &merge); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Fix connector lookup (#276)"; merge.CommitTitle != expected {
+		t.Errorf("expected commit title %q, got %q", expected, merge.CommitTitle)
+	}
+}
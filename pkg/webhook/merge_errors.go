@@ -0,0 +1,130 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const defaultMergeAttempts = 3
+
+// Delay before the first retry of a refused merge, doubled for every further
+// retry
+var mergeRetryBackoff = 2 * time.Second
+
+// Mergeable states reported by GitHub which prevent a merge, mapped to a
+// human readable reason.
+var blockingMergeableStates = map[string]string{
+	"dirty":   "the pull request has merge conflicts with its base branch",
+	"blocked": "merging is blocked by branch protection",
+	"behind":  "the head branch is behind its base branch",
+	"draft":   "the pull request is still a draft",
+}
+
+// mergeBlocker returns why GitHub won't merge the given pull request or an
+// empty string if no such reason is known. "unstable" is not considered
+// blocking, as it only signals failing checks which are not required.
+func mergeBlocker(pr *github.PullRequest) string {
+	return blockingMergeableStates[pr.GetMergeableState()]
+}
+
+// isDraft returns true for draft pull requests. The vendored go-github
+// version doesn't know the draft flag, but GitHub reports drafts with the
+// mergeable state "draft".
+func isDraft(pr *github.PullRequest) bool {
+	return pr.GetMergeableState() == "draft"
+}
+
+// mergeNotAllowedError is returned when GitHub refuses to merge a pull
+// request (HTTP 405), e.g. because of unmet branch protection rules.
+type mergeNotAllowedError struct {
+	url     string
+	message string
+}
+
+func (e *mergeNotAllowedError) Error() string {
+	return fmt.Sprintf("merge of pull request %s not allowed: %s", e.url, e.message)
+}
+
+// headChangedError is returned when the head of a pull request moved away
+// from the SHA which was evaluated for merging (HTTP 409).
+type headChangedError struct {
+	url     string
+	message string
+}
+
+func (e *headChangedError) Error() string {
+	return fmt.Sprintf("head of pull request %s changed: %s", e.url, e.message)
+}
+
+// mergeError translates the error responses of the merge API into typed
+// errors.
+func mergeError(err error, prURL string) error {
+	if errResp, ok := err.(*github.ErrorResponse); ok && errResp.Response != nil {
+		switch errResp.Response.StatusCode {
+		case http.StatusMethodNotAllowed:
+			return &mergeNotAllowedError{prURL, errResp.Message}
+		case http.StatusConflict:
+			return &headChangedError{prURL, errResp.Message}
+		}
+	}
+	return errors.Wrapf(err, "failed to merge pull request %s", prURL)
+}
+
+// mergeWithRetry merges a pull request. GitHub often refuses merges
+// transiently right after the checks turned green ("Base branch was
+// modified"), so refused merges are retried with exponential backoff.
+func mergeWithRetry(ctx context.Context, gh *github.Client, owner, repository string, issue *github.Issue, commitMessage string, options *github.PullRequestOptions, attempts int, logger *zap.Logger) error {
+	if attempts <= 0 {
+		attempts = defaultMergeAttempts
+	}
+
+	backoff := mergeRetryBackoff
+	for attempt := 1; ; attempt++ {
+		_, _, err := gh.PullRequests.Merge(ctx, owner, repository, issue.GetNumber(), commitMessage, options)
+		if err == nil {
+			return nil
+		}
+		err = mergeError(err, issue.GetHTMLURL())
+		if !isMergeNotAllowed(err) || attempt >= attempts {
+			return err
+		}
+
+		logger.Info("Merge refused, retrying", zap.Int("pr", issue.GetNumber()), zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+func isMergeNotAllowed(err error) bool {
+	_, ok := errors.Cause(err).(*mergeNotAllowedError)
+	return ok
+}
+
+func isHeadChanged(err error) bool {
+	_, ok := errors.Cause(err).(*headChangedError)
+	return ok
+}
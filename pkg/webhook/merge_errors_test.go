@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestMergeError(t *testing.T) {
+	errResp := func(code int) error {
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: code}, Message: "nope"}
+	}
+
+	if err := mergeError(errResp(http.StatusMethodNotAllowed), "pr"); !isMergeNotAllowed(err) || isHeadChanged(err) {
+		t.Errorf("405 not mapped to mergeNotAllowedError: %v", err)
+	}
+	if err := mergeError(errResp(http.StatusConflict), "pr"); !isHeadChanged(err) || isMergeNotAllowed(err) {
+		t.Errorf("409 not mapped to headChangedError: %v", err)
+	}
+	if err := mergeError(errResp(http.StatusInternalServerError), "pr"); isHeadChanged(err) || isMergeNotAllowed(err) {
+		t.Errorf("500 must not be mapped to a typed error: %v", err)
+	}
+	if err := mergeError(errors.New("boom"), "pr"); isHeadChanged(err) || isMergeNotAllowed(err) {
+		t.Errorf("plain error must not be mapped to a typed error: %v", err)
+	}
+}
+
+func TestMergeBlocker(t *testing.T) {
+	for state, blocked := range map[string]bool{
+		"dirty":    true,
+		"blocked":  true,
+		"behind":   true,
+		"draft":    true,
+		"unstable": false,
+		"clean":    false,
+		"unknown":  false,
+	} {
+		pr := &github.PullRequest{MergeableState: &state}
+		if (mergeBlocker(pr) != "") != blocked {
+			t.Errorf("mergeable state %s: expected blocked=%t", state, blocked)
+		}
+	}
+}
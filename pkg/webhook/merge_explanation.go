@@ -0,0 +1,91 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// Marks the comment explaining why a PR isn't merged, so that it gets
+// updated instead of adding new comments
+const blockedMergeMarker = "<!-- pure-bot:blocked-merge -->"
+
+// explainBlockedMerge creates or updates a single comment listing the
+// statuses and checks which prevent merging an approved pull request
+func explainBlockedMerge(ctx context.Context, gh *github.Client, owner, repository string, issue *github.Issue, commitSHA string, blocked []blockedContext) error {
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "%s\nThis pull request is approved, but can't be merged yet as these statuses or checks of %s are missing or not passing:\n\n", blockedMergeMarker, commitSHA)
+	body.WriteString("| Status / Check | State |\n| --- | --- |\n")
+	for _, c := range blocked {
+		fmt.Fprintf(&body, "| %s | %s |\n", c.Name, c.State)
+	}
+	return updateBlockedMergeComment(ctx, gh, owner, repository, issue, body.String())
+}
+
+// explainMergePolicyDenial creates or updates the comment explaining a
+// blocked merge with the reason given by the merge policy
+func explainMergePolicyDenial(ctx context.Context, gh *github.Client, owner, repository string, issue *github.Issue, reason string) error {
+	body := fmt.Sprintf("%s\nThis pull request is approved, but the merge policy doesn't allow merging it: %s\n", blockedMergeMarker, reason)
+	return updateBlockedMergeComment(ctx, gh, owner, repository, issue, body)
+}
+
+// updateBlockedMergeComment creates or updates the single comment explaining
+// why a pull request isn't merged
+func updateBlockedMergeComment(ctx context.Context, gh *github.Client, owner, repository string, issue *github.Issue, body string) error {
+	comment, err := findComment(ctx, gh, owner, repository, issue.GetNumber(), blockedMergeMarker)
+	if err != nil {
+		return err
+	}
+	if comment == nil {
+		_, _, err = gh.Issues.CreateComment(ctx, owner, repository, issue.GetNumber(), &github.IssueComment{
+			Body: github.String(body),
+		})
+		return errors.Wrapf(err, "failed to explain blocked merge of pull request %s", issue.GetHTMLURL())
+	}
+	if comment.GetBody() == body {
+		return nil
+	}
+	_, _, err = gh.Issues.EditComment(ctx, owner, repository, comment.GetID(), &github.IssueComment{
+		Body: github.String(body),
+	})
+	return errors.Wrapf(err, "failed to update explanation of blocked merge of pull request %s", issue.GetHTMLURL())
+}
+
+// findComment returns the first comment of an issue containing marker, nil
+// if there is none
+func findComment(ctx context.Context, gh *github.Client, owner, repository string, number int, marker string) (*github.IssueComment, error) {
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := gh.Issues.ListComments(ctx, owner, repository, number, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list comments of pull request %s/%s#%d", owner, repository, number)
+		}
+		for _, comment := range comments {
+			if strings.Contains(comment.GetBody(), marker) {
+				return comment, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
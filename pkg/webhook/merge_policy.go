@@ -0,0 +1,142 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+const defaultMergePolicyTimeout = 10 * time.Second
+
+// mergePolicyInput is the input document of a merge policy decision
+type mergePolicyInput struct {
+	Repository  string        `json:"repository"`
+	PullRequest mergePolicyPR `json:"pullRequest"`
+	Labels      []string      `json:"labels"`
+	// Latest verdict per reviewer, "approved", "changes_requested" or
+	// "dismissed"
+	Reviews map[string]string `json:"reviews"`
+	// Conclusion, or status while running, per check run
+	Checks map[string]string `json:"checks"`
+	// State per commit status context
+	Statuses map[string]string `json:"statuses"`
+}
+
+type mergePolicyPR struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Author string `json:"author"`
+	Base   string `json:"base"`
+	Head   string `json:"head"`
+	SHA    string `json:"sha"`
+}
+
+// mergeVerdict is the result of a merge policy decision
+type mergeVerdict struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+// evaluateMergePolicy asks the merge policy whether a pull request may be
+// merged. An undefined decision denies merging.
+func evaluateMergePolicy(ctx context.Context, cfg config.MergePolicyConfig, gh *github.Client, owner, repository string, issue *github.Issue, pr *github.PullRequest, statuses *github.CombinedStatus, checks *github.ListCheckRunsResults) (*mergeVerdict, error) {
+	reviews, err := latestReviewVerdicts(ctx, gh, owner, repository, issue.GetNumber())
+	if err != nil {
+		return nil, err
+	}
+	input := mergePolicyInput{
+		Repository: owner + "/" + repository,
+		PullRequest: mergePolicyPR{
+			Number: pr.GetNumber(),
+			Title:  pr.GetTitle(),
+			Body:   pr.GetBody(),
+			Author: pr.User.GetLogin(),
+			Base:   pr.Base.GetRef(),
+			Head:   pr.Head.GetRef(),
+			SHA:    pr.Head.GetSHA(),
+		},
+		Labels:   make([]string, 0, len(issue.Labels)),
+		Reviews:  reviews,
+		Checks:   make(map[string]string),
+		Statuses: make(map[string]string),
+	}
+	for _, label := range issue.Labels {
+		input.Labels = append(input.Labels, label.GetName())
+	}
+	for _, status := range statuses.Statuses {
+		input.Statuses[status.GetContext()] = status.GetState()
+	}
+	for _, check := range checks.CheckRuns {
+		if check.Conclusion != nil {
+			input.Checks[check.GetName()] = check.GetConclusion()
+		} else {
+			input.Checks[check.GetName()] = check.GetStatus()
+		}
+	}
+	return queryMergePolicy(ctx, cfg, input)
+}
+
+// queryMergePolicy requests a decision with the Data API of Open Policy Agent
+func queryMergePolicy(ctx context.Context, cfg config.MergePolicyConfig, input mergePolicyInput) (*mergeVerdict, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode merge policy input")
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultMergePolicyTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create merge policy request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to evaluate merge policy")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return nil, errors.Errorf("merge policy evaluation failed with status %d: %s", resp.StatusCode, msg)
+	}
+
+	var decision struct {
+		Result *mergeVerdict `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return nil, errors.Wrap(err, "invalid merge policy decision")
+	}
+	if decision.Result == nil {
+		return &mergeVerdict{Reason: "the merge policy decision is undefined"}, nil
+	}
+	if !decision.Result.Allow && decision.Result.Reason == "" {
+		decision.Result.Reason = "no reason given"
+	}
+	return decision.Result, nil
+}
@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+// fakePolicy answers Data API requests of Open Policy Agent with a fixed
+// decision and records the input
+type fakePolicy struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	decision interface{}
+	input    mergePolicyInput
+}
+
+func newFakePolicy(t *testing.T) *fakePolicy {
+	p := &fakePolicy{}
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input mergePolicyInput `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.input = req.Input
+		json.NewEncoder(w).Encode(p.decision)
+	}))
+	return p
+}
+
+func (p *fakePolicy) decide(decision interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.decision = decision
+}
+
+func TestMergePolicy(t *testing.T) {
+	policy := newFakePolicy(t)
+	defer policy.server.Close()
+	policyConfig := config.RepoConfig{
+		Labels:              autoMergeConfig.Labels,
+		DebounceWindow:      autoMergeConfig.DebounceWindow,
+		MergePolicy:         config.MergePolicyConfig{URL: policy.server.URL + "/v1/data/purebot/merge"},
+		ExplainBlockedMerge: true,
+	}
+	responses := func(changes map[string]interface{}) map[string]interface{} {
+		changes[listPRReviews] = []interface{}{map[string]interface{}{"state": "APPROVED", "user": map[string]interface{}{"login": "rhuss"}}}
+		return mergeableResponses(changes)
+	}
+
+	policy.decide(map[string]interface{}{"result": map[string]interface{}{"allow": true}})
+	runScenarios(t, []scenario{{
+		name:          "merge allowed by policy",
+		eventType:     "pull_request_review",
+		fixture:       "pull_request_review.json",
+		handler:       &autoMerger{},
+		config:        policyConfig,
+		responses:     responses(map[string]interface{}{}),
+		expectedCalls: []string{mergePullRequest},
+	}})
+
+	expected := mergePolicyInput{
+		Repository: "syndesisio/syndesis-rest",
+		Labels:     []string{"approved"},
+		Reviews:    map[string]string{"rhuss": "approved"},
+		Checks:     map[string]string{"build": "success"},
+		Statuses:   map[string]string{"default": "success"},
+	}
+	if policy.input.Repository != expected.Repository || policy.input.PullRequest.Number != fixturePR ||
+		!reflect.DeepEqual(policy.input.Labels, expected.Labels) || !reflect.DeepEqual(policy.input.Reviews, expected.Reviews) ||
+		!reflect.DeepEqual(policy.input.Checks, expected.Checks) || !reflect.DeepEqual(policy.input.Statuses, expected.Statuses) {
+		t.Errorf("unexpected policy input %+v", policy.input)
+	}
+
+	policy.decide(map[string]interface{}{"result": map[string]interface{}{"allow": false, "reason": "needs a second approval"}})
+	runScenarios(t, []scenario{{
+		name:          "merge denied by policy",
+		eventType:     "pull_request_review",
+		fixture:       "pull_request_review.json",
+		handler:       &autoMerger{},
+		config:        policyConfig,
+		responses:     responses(map[string]interface{}{listComments: []interface{}{}}),
+		expectedCalls: []string{createComment},
+	}})
+
+	policy.decide(map[string]interface{}{})
+	policyConfig.ExplainBlockedMerge = false
+	runScenarios(t, []scenario{{
+		name:      "undefined policy decision",
+		eventType: "pull_request_review",
+		fixture:   "pull_request_review.json",
+		handler:   &autoMerger{},
+		config:    policyConfig,
+		responses: responses(map[string]interface{}{}),
+	}})
+}
@@ -0,0 +1,121 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GitHub computes the mergeability of a pull request in the background
+	// after its base branch changed
+	mergeabilityRetries       = 5
+	mergeabilityRetryInterval = 2 * time.Second
+)
+
+// mergeQueue serializes the merge evaluations of a repository, so that each
+// pull request is validated against the base branch as left by the previous
+// merge. Waiting evaluations are served in FIFO order.
+type mergeQueue struct {
+	mu      sync.Mutex
+	busy    bool
+	waiting []chan struct{}
+}
+
+var (
+	mergeQueuesMu sync.Mutex
+	mergeQueues   = make(map[string]*mergeQueue)
+)
+
+func mergeQueueFor(repo string) *mergeQueue {
+	mergeQueuesMu.Lock()
+	defer mergeQueuesMu.Unlock()
+
+	queue, found := mergeQueues[repo]
+	if !found {
+		queue = &mergeQueue{}
+		mergeQueues[repo] = queue
+	}
+	return queue
+}
+
+// acquire blocks until it's the caller's turn. It returns true if the caller
+// had to wait for other evaluations, which might have changed the base branch.
+func (q *mergeQueue) acquire(ctx context.Context) (bool, error) {
+	q.mu.Lock()
+	if !q.busy {
+		q.busy = true
+		q.mu.Unlock()
+		return false, nil
+	}
+	turn := make(chan struct{})
+	q.waiting = append(q.waiting, turn)
+	q.mu.Unlock()
+
+	select {
+	case <-turn:
+		return true, nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		for i, t := range q.waiting {
+			if t == turn {
+				q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+				q.mu.Unlock()
+				return false, errors.Wrap(ctx.Err(), "gave up waiting in merge queue")
+			}
+		}
+		q.mu.Unlock()
+		// It got our turn in the meantime, pass it on
+		q.release()
+		return false, errors.Wrap(ctx.Err(), "gave up waiting in merge queue")
+	}
+}
+
+// release hands over to the next waiting evaluation
+func (q *mergeQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.waiting) == 0 {
+		q.busy = false
+		return
+	}
+	next := q.waiting[0]
+	q.waiting = q.waiting[1:]
+	close(next)
+}
+
+// getMergeablePullRequest fetches a pull request, waiting for GitHub to
+// compute its mergeability. If it's still unknown after a few retries, the
+// pull request is returned as is.
+func getMergeablePullRequest(ctx context.Context, gh *github.Client, owner, repository string, number int) (*github.PullRequest, error) {
+	for i := 0; ; i++ {
+		pr, _, err := gh.PullRequests.Get(ctx, owner, repository, number)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get pull request %s/%s#%d", owner, repository, number)
+		}
+		if pr.Mergeable != nil || i == mergeabilityRetries {
+			return pr, nil
+		}
+		if err := waitFor(ctx, mergeabilityRetryInterval); err != nil {
+			return nil, errors.Wrapf(err, "stopped waiting for the mergeability of pull request %s", pr.GetHTMLURL())
+		}
+	}
+}
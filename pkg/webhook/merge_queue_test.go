@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestMergeQueueOrder(t *testing.T) {
+	queue := &mergeQueue{}
+	if waited, _ := queue.acquire(context.Background()); waited {
+		t.Fatal("first evaluation must not wait")
+	}
+
+	var mu sync.Mutex
+	var order []int
+	var done sync.WaitGroup
+	for i := 1; i <= 3; i++ {
+		done.Add(1)
+		go func(i int) {
+			defer done.Done()
+			if waited, _ := queue.acquire(context.Background()); !waited {
+				t.Errorf("evaluation %d didn't wait", i)
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			queue.release()
+		}(i)
+		// Let the evaluation enqueue before the next one
+		for {
+			queue.mu.Lock()
+			waiting := len(queue.waiting)
+			queue.mu.Unlock()
+			if waiting == i {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	queue.release()
+	done.Wait()
+	if !reflect.DeepEqual(order, []int{1, 2, 3}) {
+		t.Errorf("unexpected order %v", order)
+	}
+}
+
+func TestMergeQueueCancel(t *testing.T) {
+	queue := &mergeQueue{}
+	queue.acquire(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := queue.acquire(ctx); err == nil {
+		t.Error("expected cancelled evaluation to give up")
+	}
+
+	queue.release()
+	if waited, _ := queue.acquire(context.Background()); waited {
+		t.Error("cancelled evaluation still blocks the queue")
+	}
+}
+
+func TestGetMergeablePullRequestCancelled(t *testing.T) {
+	pr := pullRequestJSON(fixturePR, "ad0d22f1ea0b4fca5e8c7ca1f5b0bbf2a1b44ed9", "unknown")
+	delete(pr, "mergeable")
+	fake := newFakeGitHub(t, map[string]interface{}{getPullRequest: pr})
+	defer fake.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := getMergeablePullRequest(ctx, fake.client(), "syndesisio", "syndesis-rest", fixturePR)
+	if errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("expected the deadline to stop waiting, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= mergeabilityRetryInterval {
+		t.Errorf("expected to stop waiting right away, took %v", elapsed)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/audit"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Allows tests to travel in time
+var timeNow = time.Now
+
+// mergeWindow is a recurring weekly time span in which merging is allowed
+type mergeWindow struct {
+	days map[time.Weekday]bool
+	// Minutes since midnight
+	start, end int
+	location   *time.Location
+}
+
+// parseMergeWindow parses a window like "Mon-Thu,Sat 08:00-18:00 Europe/Berlin".
+// The location is optional and defaults to UTC.
+func parseMergeWindow(spec string) (*mergeWindow, error) {
+	fields := strings.Fields(spec)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, errors.Errorf("invalid merge window %q, expected \"<days> <hh:mm>-<hh:mm> [<location>]\"", spec)
+	}
+
+	window := &mergeWindow{days: make(map[time.Weekday]bool), location: time.UTC}
+	for _, days := range strings.Split(fields[0], ",") {
+		bounds := strings.SplitN(days, "-", 2)
+		first, found := weekdays[strings.ToLower(bounds[0])]
+		if !found {
+			return nil, errors.Errorf("invalid day %q in merge window %q", bounds[0], spec)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, found = weekdays[strings.ToLower(bounds[1])]; !found {
+				return nil, errors.Errorf("invalid day %q in merge window %q", bounds[1], spec)
+			}
+		}
+		// Ranges may wrap around the end of the week, e.g. "Sat-Mon"
+		for day := first; ; day = (day + 1) % 7 {
+			window.days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+
+	times := strings.SplitN(fields[1], "-", 2)
+	if len(times) != 2 {
+		return nil, errors.Errorf("invalid time span %q in merge window %q", fields[1], spec)
+	}
+	var err error
+	if window.start, err = parseClock(times[0]); err != nil {
+		return nil, errors.Wrapf(err, "invalid merge window %q", spec)
+	}
+	if window.end, err = parseClock(times[1]); err != nil {
+		return nil, errors.Wrapf(err, "invalid merge window %q", spec)
+	}
+	if window.end <= window.start {
+		return nil, errors.Errorf("merge window %q ends before it starts", spec)
+	}
+
+	if len(fields) == 3 {
+		if window.location, err = time.LoadLocation(fields[2]); err != nil {
+			return nil, errors.Wrapf(err, "invalid location in merge window %q", spec)
+		}
+	}
+	return window, nil
+}
+
+// parseClock returns the minutes since midnight of a time like "08:30", up
+// to "24:00"
+func parseClock(clock string) (int, error) {
+	parts := strings.SplitN(clock, ":", 2)
+	if len(parts) != 2 {
+		return 0, errors.Errorf("invalid time %q", clock)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, errors.Errorf("invalid time %q", clock)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, errors.Errorf("invalid time %q", clock)
+	}
+	return hours*60 + minutes, nil
+}
+
+func (w *mergeWindow) contains(t time.Time) bool {
+	t = t.In(w.location)
+	minute := t.Hour()*60 + t.Minute()
+	return w.days[t.Weekday()] && minute >= w.start && minute < w.end
+}
+
+// nextOpening returns the first start of the window after t
+func (w *mergeWindow) nextOpening(t time.Time) time.Time {
+	t = t.In(w.location)
+	for day := 0; day <= 7; day++ {
+		opening := time.Date(t.Year(), t.Month(), t.Day()+day, w.start/60, w.start%60, 0, 0, w.location)
+		if w.days[opening.Weekday()] && opening.After(t) {
+			return opening
+		}
+	}
+	// Not reached, as every window contains at least one day
+	return time.Time{}
+}
+
+// mergeWindowOpening returns the time when merging is allowed again, the zero
+// time if merging is allowed at t. Without windows merging is always allowed.
+func mergeWindowOpening(specs []string, t time.Time) (time.Time, error) {
+	var opening time.Time
+	for _, spec := range specs {
+		window, err := parseMergeWindow(spec)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if window.contains(t) {
+			return time.Time{}, nil
+		}
+		if next := window.nextOpening(t); opening.IsZero() || next.Before(opening) {
+			opening = next
+		}
+	}
+	return opening, nil
+}
+
+var (
+	deferredMergesMu sync.Mutex
+	// Merges waiting for a merge window, keyed by pull request
+	deferredMerges = make(map[string]*deferredMerge)
+)
+
+type deferredMerge struct {
+	timer *time.Timer
+	// whether the merge was requested with /merge
+	requested bool
+}
+
+// deferMerge evaluates a pull request again once the merge window opens. A
+// merge requested with /merge stays requested for the deferred evaluation.
+// The timer isn't waited for on shutdown, as the window may open only days
+// later. Deferred merges get lost on restart, approved pull requests are
+// evaluated again by the reconciler then.
+func deferMerge(ctx context.Context, issue *github.Issue, owner, repository string, gh *github.Client, gql *githubv4.Client, opening time.Time, config config.RepoConfig, logger *zap.Logger) error {
+	number := issue.GetNumber()
+	key := owner + "/" + repository + "#" + strconv.Itoa(number)
+	requested := mergeRequested(ctx)
+
+	deferredMergesMu.Lock()
+	defer deferredMergesMu.Unlock()
+	if deferred, ok := deferredMerges[key]; ok {
+		if deferred.requested || !requested {
+			logger.Debug("Merge already deferred", zap.Int("pr", number))
+			return nil
+		}
+		// Replace the deferred merge which doesn't know about the request
+		deferred.timer.Stop()
+	}
+	logger.Info("Outside of merge windows, deferring merge", zap.Int("pr", number), zap.Time("opening", opening))
+
+	trigger := audit.TriggerFromContext(ctx)
+	deferred := &deferredMerge{requested: requested}
+	deferred.timer = time.AfterFunc(opening.Sub(timeNow()), func() {
+		deferredMergesMu.Lock()
+		current := deferredMerges[key] == deferred
+		if current {
+			delete(deferredMerges, key)
+		}
+		deferredMergesMu.Unlock()
+		if !current {
+			return
+		}
+		scheduled := goAsync(func(ctx context.Context) {
+			ctx = audit.WithTrigger(ctx, trigger)
+			if requested {
+				ctx = withMergeRequest(ctx)
+			}
+			metrics.Add(mergeEvaluationsPerformed, 1)
+			if err := evaluateDeferredMerge(ctx, owner, repository, number, gh, gql, config, logger); err != nil {
+				logger.Error("Deferred merge failed", zap.Int("pr", number), zap.Error(err))
+			}
+		})
+		if !scheduled {
+			logger.Info("Shutting down, leaving deferred merge to the reconciler", zap.Int("pr", number))
+		}
+	})
+	deferredMerges[key] = deferred
+	return nil
+}
+
+func evaluateDeferredMerge(ctx context.Context, owner, repository string, number int, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	issue, _, err := gh.Issues.Get(ctx, owner, repository, number)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pull request %s/%s#%d", owner, repository, number)
+	}
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repository, number)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pull request %s", issue.GetHTMLURL())
+	}
+	_, err = mergePR(ctx, issue, pr, owner, repository, gh, gql, "", newEvaluationCache(), config, logger)
+	return err
+}
+
+// cancelDeferredMerges cancels the deferred merges of all pull requests whose
+// key starts with the given prefix
+func cancelDeferredMerges(prefix string) {
+	deferredMergesMu.Lock()
+	defer deferredMergesMu.Unlock()
+
+	for key, deferred := range deferredMerges {
+		if strings.HasPrefix(key, prefix) {
+			deferred.timer.Stop()
+			delete(deferredMerges, key)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestParseMergeWindow(t *testing.T) {
+	invalid := []string{
+		"",
+		"Mon-Fri",
+		"Mon-Fri 08:00",
+		"Mon-Fr 08:00-18:00",
+		"Mon-Fri 8-18",
+		"Mon-Fri 18:00-08:00",
+		"Mon-Fri 08:00-24:01",
+		"Mon-Fri 08:00-18:00 Mars/Olympus",
+		"Mon-Fri 08:00-18:00 UTC extra",
+	}
+	for _, spec := range invalid {
+		if _, err := parseMergeWindow(spec); err == nil {
+			t.Errorf("expected error for merge window %q", spec)
+		}
+	}
+
+	window, err := parseMergeWindow("Fri-Mon,Wed 08:30-24:00 Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for day, expected := range map[time.Weekday]bool{time.Friday: true, time.Saturday: true, time.Sunday: true, time.Monday: true, time.Tuesday: false, time.Wednesday: true, time.Thursday: false} {
+		if window.days[day] != expected {
+			t.Errorf("expected %s to be %v", day, expected)
+		}
+	}
+	if window.start != 8*60+30 || window.end != 24*60 {
+		t.Errorf("unexpected time span %d-%d", window.start, window.end)
+	}
+}
+
+func TestMergeWindowOpening(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	windows := []string{"Mon-Thu 08:00-18:00 Europe/Berlin", "Fri 08:00-12:00 Europe/Berlin"}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		expected time.Time
+	}{
+		{"within window", time.Date(2018, 3, 5, 10, 0, 0, 0, berlin), time.Time{}},
+		{"start of window", time.Date(2018, 3, 5, 8, 0, 0, 0, berlin), time.Time{}},
+		{"before window", time.Date(2018, 3, 5, 7, 0, 0, 0, berlin), time.Date(2018, 3, 5, 8, 0, 0, 0, berlin)},
+		{"end of window", time.Date(2018, 3, 5, 18, 0, 0, 0, berlin), time.Date(2018, 3, 6, 8, 0, 0, 0, berlin)},
+		{"short friday", time.Date(2018, 3, 9, 13, 0, 0, 0, berlin), time.Date(2018, 3, 12, 8, 0, 0, 0, berlin)},
+		{"other location", time.Date(2018, 3, 8, 17, 30, 0, 0, time.UTC), time.Date(2018, 3, 9, 8, 0, 0, 0, berlin)},
+	}
+	for _, test := range tests {
+		opening, err := mergeWindowOpening(windows, test.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !opening.Equal(test.expected) {
+			t.Errorf("%s: expected opening %v, got %v", test.name, test.expected, opening)
+		}
+	}
+
+	if opening, err := mergeWindowOpening(nil, time.Now()); err != nil || !opening.IsZero() {
+		t.Errorf("merging must always be allowed without windows, got %v, %v", opening, err)
+	}
+}
+
+func TestDeferMergeDoesNotBlockShutdown(t *testing.T) {
+	defer cancelDeferredMerges("syndesisio/syndesis-rest#")
+	issue := &github.Issue{Number: github.Int(fixturePR)}
+	opening := timeNow().Add(48 * time.Hour)
+	for i := 0; i < 2; i++ {
+		if err := deferMerge(context.Background(), issue, "syndesisio", "syndesis-rest", nil, nil, opening, config.RepoConfig{}, zap.NewNop()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deferredMergesMu.Lock()
+	deferred := len(deferredMerges)
+	deferredMergesMu.Unlock()
+	if deferred != 1 {
+		t.Errorf("expected one deferred merge, got %d", deferred)
+	}
+
+	// A /merge request replaces the deferred merge
+	if err := deferMerge(withMergeRequest(context.Background()), issue, "syndesisio", "syndesis-rest", nil, nil, opening, config.RepoConfig{}, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	deferredMergesMu.Lock()
+	requested := deferredMerges["syndesisio/syndesis-rest#"+strconv.Itoa(fixturePR)].requested
+	deferredMergesMu.Unlock()
+	if !requested {
+		t.Error("expected the deferred merge to be requested")
+	}
+
+	// Draining doesn't wait for the merge window
+	drained := make(chan struct{})
+	go func() {
+		inFlight.running.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("expected deferred merges not to be waited for")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"expvar"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Counters published at /debug/vars
+const (
+	// Status and workflow run events which could trigger a merge
+	mergeEventsReceived = "mergeEventsReceived"
+	// Evaluations run after coalescing these events
+	mergeEvaluationsPerformed = "mergeEvaluationsPerformed"
+	// Pull requests checked for being mergeable
+	pullRequestsEvaluated = "pullRequestsEvaluated"
+	// Requests delayed because the GitHub rate limit was used up
+	rateLimitWaits = "rateLimitWaits"
+	// GET requests answered from the cache as their resource didn't change
+	cachedResponsesServed = "cachedResponsesServed"
+)
+
+var metrics = expvar.NewMap("webhook")
+
+// Metrics exposed to Prometheus at /metrics, next to the process and Go
+// runtime metrics of the default registry
+var (
+	eventsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pure_bot_events_received_total",
+		Help: "Webhook deliveries received, by event type.",
+	}, []string{"type"})
+	handlerCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pure_bot_handler_calls_total",
+		Help: "Handler calls, by handler and result (success or failure).",
+	}, []string{"handler", "result"})
+	handlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pure_bot_handler_duration_seconds",
+		Help:    "Time handlers took for an event.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"handler"})
+	mergesPerformed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pure_bot_merges_total",
+		Help: "Pull requests merged by the bot.",
+	})
+	rateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pure_bot_github_rate_limit_remaining",
+		Help: "Remaining GitHub API requests of an installation, as of its last response.",
+	}, []string{"installation"})
+)
+
+func init() {
+	prometheus.MustRegister(queueDepth{})
+}
+
+// queueDepth collects the number of deliveries waiting in the event queue.
+// It is left out while the queue can't be read.
+type queueDepth struct{}
+
+var queueDepthDesc = prometheus.NewDesc("pure_bot_queue_depth",
+	"Webhook deliveries waiting to be processed, excluding dead letters.", nil, nil)
+
+func (queueDepth) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+}
+
+func (queueDepth) Collect(ch chan<- prometheus.Metric) {
+	pending := 0
+	if eventQueue != nil {
+		var err error
+		if pending, err = eventQueue.Pending(); err != nil {
+			return
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(pending))
+}
+
+// MetricsHandler serves the metrics in the Prometheus text format
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
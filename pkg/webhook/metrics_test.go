@@ -0,0 +1,31 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	eventsReceived.WithLabelValues("ping").Inc()
+	handlerCalls.WithLabelValues("wip", "success").Inc()
+
+	recorder := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		`pure_bot_events_received_total{type="ping"}`,
+		`pure_bot_handler_calls_total{handler="wip",result="success"}`,
+		"pure_bot_queue_depth 0",
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %s in metrics, got:\n%s", expected, body)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/tracing"
+	"go.uber.org/zap"
+)
+
+// HandleFunc handles an event like Handler.HandleEvent
+type HandleFunc func(ctx context.Context, eventObject interface{}, client *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error
+
+// Middleware decorates the handling of events by the named handler, e.g. for
+// logging or metrics. It calls next to continue with the chain.
+type Middleware func(name string, next HandleFunc) HandleFunc
+
+// Per handler counters and durations published at /debug/vars, as
+// "<handler>.calls", "<handler>.errors", "<handler>.panics" and
+// "<handler>.milliseconds"
+var handlerMetrics = expvar.NewMap("handlers")
+
+// Middlewares wrapping every handler, the first one outermost
+var middlewares = []Middleware{traceHandling, logHandling, measureHandling, alertFailures, recoverPanics}
+
+// UseMiddleware adds middleware wrapping all handlers. Middlewares are applied
+// in the order they are added, inside the built-in ones for logging, metrics
+// and panic recovery.
+func UseMiddleware(middleware Middleware) {
+	if middleware == nil {
+		panic("webhook: UseMiddleware called with nil middleware")
+	}
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	middlewares = append(middlewares, middleware)
+}
+
+// withMiddleware wraps the handling function of a handler with all
+// middlewares
+func withMiddleware(name string, handle HandleFunc) HandleFunc {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handle = middlewares[i](name, handle)
+	}
+	return handle
+}
+
+// traceHandling records a span for each call of a handler
+func traceHandling(name string, next HandleFunc) HandleFunc {
+	return func(ctx context.Context, eventObject interface{}, client *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+		ctx, span := tracer.Start(ctx, "handler "+name)
+		defer span.End()
+		err := next(ctx, eventObject, client, gql, config, logger)
+		tracing.SetError(span, err)
+		return err
+	}
+}
+
+// logHandling logs calls of a handler and their outcome
+func logHandling(name string, next HandleFunc) HandleFunc {
+	return func(ctx context.Context, eventObject interface{}, client *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+		logger = logger.With(zap.String("handler", name))
+		logger.Debug("call handler")
+		start := time.Now()
+		err := next(ctx, eventObject, client, gql, config, logger)
+		logger.Debug("handler finished", zap.Duration("duration", time.Since(start)), zap.Error(err))
+		return err
+	}
+}
+
+// measureHandling counts calls and errors of a handler and records its
+// processing time, for /debug/vars and Prometheus
+func measureHandling(name string, next HandleFunc) HandleFunc {
+	return func(ctx context.Context, eventObject interface{}, client *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+		start := time.Now()
+		err := next(ctx, eventObject, client, gql, config, logger)
+		duration := time.Since(start)
+		handlerMetrics.Add(name+".calls", 1)
+		handlerMetrics.AddFloat(name+".milliseconds", duration.Seconds()*1000)
+		handlerDuration.WithLabelValues(name).Observe(duration.Seconds())
+		if err != nil {
+			handlerMetrics.Add(name+".errors", 1)
+			handlerCalls.WithLabelValues(name, "failure").Inc()
+		} else {
+			handlerCalls.WithLabelValues(name, "success").Inc()
+		}
+		return err
+	}
+}
+
+// recoverPanics turns a panicking handler into a failing one, so that it
+// doesn't take down the bot
+func recoverPanics(name string, next HandleFunc) HandleFunc {
+	return func(ctx context.Context, eventObject interface{}, client *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				handlerMetrics.Add(name+".panics", 1)
+				logger.Error("handler panicked", zap.String("handler", name), zap.Any("panic", r), zap.Stack("stack"))
+				err = errors.Errorf("handler %s panicked: %v", name, r)
+			}
+		}()
+		return next(ctx, eventObject, client, gql, config, logger)
+	}
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestMiddleware(t *testing.T) {
+	defer func(m []Middleware) { middlewares = m }(middlewares)
+
+	var calls []string
+	trace := func(label string) Middleware {
+		return func(name string, next HandleFunc) HandleFunc {
+			return func(ctx context.Context, eventObject interface{}, client *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+				calls = append(calls, label+" "+name)
+				return next(ctx, eventObject, client, gql, config, logger)
+			}
+		}
+	}
+	UseMiddleware(trace("first"))
+	UseMiddleware(trace("second"))
+
+	handle := withMiddleware("panicking", func(ctx context.Context, eventObject interface{}, client *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+		calls = append(calls, "handler")
+		panic("boom")
+	})
+	err := handle(context.Background(), nil, nil, nil, config.RepoConfig{}, zap.NewNop())
+	if err == nil || !strings.Contains(err.Error(), "handler panicking panicked: boom") {
+		t.Errorf("expected panic to be turned into an error, got %v", err)
+	}
+	if expected := []string{"first panicking", "second panicking", "handler"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected calls %v, got %v", expected, calls)
+	}
+	for _, metric := range []string{"panicking.calls", "panicking.errors", "panicking.panics"} {
+		if v := handlerMetrics.Get(metric); v == nil || v.String() != "1" {
+			t.Errorf("expected %s to be 1, got %v", metric, v)
+		}
+	}
+}
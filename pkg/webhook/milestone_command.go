@@ -0,0 +1,84 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+var milestoneCommand = Command{
+	Help:       "Sets the milestone with the given title",
+	Usage:      "<milestone>",
+	Permission: "triage",
+	Run:        runMilestone,
+}
+
+func runMilestone(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	title := strings.Join(cmd.Args, " ")
+	if title == "" {
+		return cmd.Reply(ctx, gh, "please name the milestone, like `/milestone v1.9`.")
+	}
+	owner, repository := cmd.Owner(), cmd.Repo()
+
+	milestones, err := listMilestones(ctx, gh, owner, repository)
+	if err != nil {
+		return err
+	}
+	var milestone *github.Milestone
+	var titles []string
+	for _, m := range milestones {
+		if strings.EqualFold(m.GetTitle(), title) {
+			milestone = m
+		}
+		titles = append(titles, m.GetTitle())
+	}
+	if milestone == nil {
+		reply := "there is no open milestone " + title + "."
+		if len(titles) > 0 {
+			reply += " Open milestones are " + strings.Join(titles, ", ") + "."
+		}
+		return cmd.Reply(ctx, gh, reply)
+	}
+	if cmd.Event.Issue.Milestone.GetNumber() == milestone.GetNumber() {
+		return nil
+	}
+
+	_, _, err = gh.Issues.Edit(ctx, owner, repository, cmd.Number(), &github.IssueRequest{Milestone: milestone.Number})
+	return errors.Wrapf(err, "failed to set milestone %s on %s", milestone.GetTitle(), cmd.Event.Issue.GetHTMLURL())
+}
+
+// listMilestones returns all open milestones of a repository
+func listMilestones(ctx context.Context, gh *github.Client, owner, repository string) ([]*github.Milestone, error) {
+	var milestones []*github.Milestone
+	opts := &github.MilestoneListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := gh.Issues.ListMilestones(ctx, owner, repository, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list milestones of %s/%s", owner, repository)
+		}
+		milestones = append(milestones, page...)
+		if resp.NextPage == 0 {
+			return milestones, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestMilestoneCommand(t *testing.T) {
+	editIssue := "PATCH " + fixtureRepo + "/issues/276"
+	triager := map[string]interface{}{"permission": "read", "role_name": "triage"}
+
+	tests := []struct {
+		name          string
+		comment       string
+		permission    interface{}
+		expectedCalls []string
+		reply         string
+	}{
+		{
+			name:          "set milestone",
+			comment:       "/milestone V1.9",
+			permission:    triager,
+			expectedCalls: []string{editIssue},
+		},
+		{
+			name:          "unknown milestone",
+			comment:       "/milestone v2.0",
+			permission:    triager,
+			expectedCalls: []string{createComment},
+			reply:         "@roland there is no open milestone v2.0. Open milestones are v1.8, v1.9.",
+		},
+		{
+			name:          "read permission",
+			comment:       "/milestone v1.9",
+			permission:    permissionJSON("read"),
+			expectedCalls: []string{createComment},
+			reply:         "@roland you need triage permission on this repository to use `/milestone`.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, map[string]interface{}{
+				"GET " + fixtureRepo + "/collaborators/roland/permission": test.permission,
+				"GET " + fixtureRepo + "/milestones": []map[string]interface{}{
+					{"number": 4, "title": "v1.8"},
+					{"number": 5, "title": "v1.9"},
+				},
+			})
+			defer fake.close()
+
+			event := commentEvent(test.comment, "roland", "User", false)
+			if err := (&slashCommands{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), config.RepoConfig{}, zap.NewNop()); err != nil {
+				t.Fatalf("handler failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Fatalf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if test.reply == "" {
+				var request map[string]interface{}
+				if err := fake.requestBody(editIssue, &request); err != nil {
+					t.Fatal(err)
+				}
+				if request["milestone"] != float64(5) {
+					t.Errorf("expected milestone 5, got %v", request["milestone"])
+				}
+				return
+			}
+			var comment github.IssueComment
+			if err := fake.requestBody(createComment, &comment); err != nil {
+				t.Fatal(err)
+			}
+			if comment.GetBody() != test.reply {
+				t.Errorf("expected reply %q, got %q", test.reply, comment.GetBody())
+			}
+		})
+	}
+}
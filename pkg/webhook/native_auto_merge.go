@@ -0,0 +1,90 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const (
+	// Merge strategy which leaves merging to GitHub's auto-merge
+	nativeMergeStrategy = "native"
+
+	unlabeledEvent = "unlabeled"
+)
+
+type enableAutoMergeMutation struct {
+	EnablePullRequestAutoMerge struct {
+		ClientMutationID string
+	} `graphql:"enablePullRequestAutoMerge(input: $input)"`
+}
+
+type disableAutoMergeMutation struct {
+	DisablePullRequestAutoMerge struct {
+		ClientMutationID string
+	} `graphql:"disablePullRequestAutoMerge(input: $input)"`
+}
+
+// handleNativeAutoMerge enables GitHub's auto-merge when the approved label
+// is added and disables it when the label is removed. GitHub then merges the
+// PR as soon as branch protection allows it.
+func (h *autoMerger) handleNativeAutoMerge(ctx context.Context, event *github.PullRequestEvent, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if !strings.EqualFold(event.GetLabel().GetName(), config.Labels.Approved) {
+		return nil
+	}
+	pr := event.PullRequest
+
+	switch strings.ToLower(event.GetAction()) {
+	case labeledEvent:
+		if isDraft(pr) {
+			logger.Debug("not enabling auto-merge for draft PR", zap.Int("pr", pr.GetNumber()))
+			return nil
+		}
+		if config.Labels.Hold != "" && labelsContainsLabel(pr.Labels, config.Labels.Hold) {
+			logger.Debug("not enabling auto-merge because PR is on hold", zap.String("label", config.Labels.Hold), zap.Int("pr", pr.GetNumber()))
+			return nil
+		}
+		logger.Info("Enabling auto-merge", zap.Int("pr", pr.GetNumber()))
+		method := nativeMergeMethod(config.MergeMethod)
+		err := gql.Mutate(ctx, &enableAutoMergeMutation{}, githubv4.EnablePullRequestAutoMergeInput{
+			PullRequestID: pr.GetNodeID(),
+			MergeMethod:   &method,
+		}, nil)
+		return errors.Wrapf(err, "failed to enable auto-merge for pull request %s", pr.GetHTMLURL())
+	case unlabeledEvent:
+		logger.Info("Disabling auto-merge", zap.Int("pr", pr.GetNumber()))
+		err := gql.Mutate(ctx, &disableAutoMergeMutation{}, githubv4.DisablePullRequestAutoMergeInput{
+			PullRequestID: pr.GetNodeID(),
+		}, nil)
+		return errors.Wrapf(err, "failed to disable auto-merge for pull request %s", pr.GetHTMLURL())
+	}
+	return nil
+}
+
+// nativeMergeMethod maps the configured merge method onto GitHub's GraphQL
+// enum
+func nativeMergeMethod(method string) githubv4.PullRequestMergeMethod {
+	if method == "" {
+		return githubv4.PullRequestMergeMethodMerge
+	}
+	return githubv4.PullRequestMergeMethod(strings.ToUpper(method))
+}
@@ -0,0 +1,143 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// GitHub computes the mergeability of a PR in the background after a push,
+// so an unknown state is checked again after this delay. A negative delay
+// checks again right away.
+var mergeableRecheckDelay = 10 * time.Second
+
+const mergeableRechecks = 3
+
+// needsRebase labels PRs which conflict with their base branch
+type needsRebase struct{}
+
+func (h *needsRebase) EventTypesHandled() []string {
+	return []string{"pull_request", "push"}
+}
+
+func (h *needsRebase) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if config.Labels.NeedsRebase == "" {
+		return nil
+	}
+
+	switch event := eventObject.(type) {
+	case *github.PullRequestEvent:
+		action := strings.ToLower(event.GetAction())
+		if action != "opened" && action != "reopened" && action != "synchronize" {
+			return nil
+		}
+		return checkMergeConflict(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), event.PullRequest.GetNumber(), gh, config, logger, mergeableRechecks)
+	case *pushEvent:
+		// A push to a base branch can put any PR targeting it into conflict
+		if event.Deleted || !strings.HasPrefix(event.Ref, "refs/heads/") {
+			return nil
+		}
+		return checkBranchMergeConflicts(ctx, event.Repo, strings.TrimPrefix(event.Ref, "refs/heads/"), gh, config, logger)
+	default:
+		return errors.New("wrong event eventObject type")
+	}
+}
+
+func checkBranchMergeConflicts(ctx context.Context, repo *github.Repository, branch string, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+	owner, repository := repo.Owner.GetLogin(), repo.GetName()
+
+	var multiErr error
+	opts := &github.PullRequestListOptions{State: "open", Base: branch, ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		prs, resp, err := gh.PullRequests.List(ctx, owner, repository, opts)
+		if err != nil {
+			return multierr.Combine(multiErr, errors.Wrapf(err, "failed to list pull requests of %s against %s", repo.GetFullName(), branch))
+		}
+		for _, pr := range prs {
+			multiErr = multierr.Combine(multiErr, checkMergeConflict(ctx, owner, repository, pr.GetNumber(), gh, config, logger, mergeableRechecks))
+		}
+		if resp.NextPage == 0 {
+			return multiErr
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// checkMergeConflict adds the needs-rebase label and a comment to a conflicting
+// PR and removes the label again once the conflict is resolved
+func checkMergeConflict(ctx context.Context, owner, repository string, number int, gh *github.Client, config config.RepoConfig, logger *zap.Logger, rechecks int) error {
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repository, number)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pull request %s/%s#%d", owner, repository, number)
+	}
+	if pr.GetState() != "open" {
+		return nil
+	}
+
+	if pr.Mergeable == nil {
+		if rechecks == 0 {
+			logger.Debug("mergeability of PR still unknown, giving up", zap.Int("pr", number))
+			return nil
+		}
+		if mergeableRecheckDelay < 0 {
+			return checkMergeConflict(ctx, owner, repository, number, gh, config, logger, rechecks-1)
+		}
+		scheduled := goAsync(func(ctx context.Context) {
+			select {
+			case <-time.After(mergeableRecheckDelay):
+			case <-ctx.Done():
+				return
+			}
+			if err := checkMergeConflict(ctx, owner, repository, number, gh, config, logger, rechecks-1); err != nil {
+				logger.Error("Merge conflict check failed", zap.Int("pr", number), zap.Error(err))
+			}
+		})
+		if !scheduled {
+			logger.Debug("Shutting down, not checking the mergeability of PR again", zap.Int("pr", number))
+		}
+		return nil
+	}
+
+	label := config.Labels.NeedsRebase
+	labelled := labelsContainsLabel(pr.Labels, label)
+	conflicting := !pr.GetMergeable() || pr.GetMergeableState() == "dirty"
+
+	if conflicting && !labelled {
+		logger.Info("PR conflicts with its base branch", zap.Int("pr", number), zap.String("base", pr.Base.GetRef()))
+		if _, _, err := gh.Issues.AddLabelsToIssue(ctx, owner, repository, number, []string{label}); err != nil {
+			return errors.Wrapf(err, "failed to add label %s to PR %s", label, pr.GetHTMLURL())
+		}
+		comment := fmt.Sprintf("This pull request conflicts with `%s`, please rebase it.", pr.Base.GetRef())
+		if _, _, err := gh.Issues.CreateComment(ctx, owner, repository, number, &github.IssueComment{Body: &comment}); err != nil {
+			return errors.Wrapf(err, "failed to comment on PR %s", pr.GetHTMLURL())
+		}
+	} else if !conflicting && labelled {
+		logger.Info("PR conflict resolved", zap.Int("pr", number))
+		if _, err := gh.Issues.RemoveLabelForIssue(ctx, owner, repository, number, label); err != nil {
+			return errors.Wrapf(err, "failed to remove label %s from PR %s", label, pr.GetHTMLURL())
+		}
+	}
+	return nil
+}
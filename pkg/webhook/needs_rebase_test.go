@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+func TestNeedsRebase(t *testing.T) {
+	defer func(delay time.Duration) { mergeableRecheckDelay = delay }(mergeableRecheckDelay)
+	mergeableRecheckDelay = -1
+
+	var (
+		listPulls         = "GET " + fixtureRepo + "/pulls"
+		addLabels         = "POST " + fixtureRepo + "/issues/" + strconv.Itoa(fixturePR) + "/labels"
+		removeNeedsRebase = "DELETE " + fixtureRepo + "/issues/" + strconv.Itoa(fixturePR) + "/labels/needs-rebase"
+	)
+	needsRebaseConfig := config.RepoConfig{
+		Labels: config.LabelConfig{
+			Approved:    "approved",
+			NeedsRebase: "needs-rebase",
+		},
+		DebounceWindow: -1,
+	}
+	unknown := pullRequestJSON(fixturePR, fixtureSHA, "unknown")
+	delete(unknown, "mergeable")
+
+	runScenarios(t, []scenario{
+		{
+			name:      "conflicting PR labelled",
+			eventType: "pull_request",
+			fixture:   "pull_request_synchronize.json",
+			handler:   &needsRebase{},
+			config:    needsRebaseConfig,
+			responses: map[string]interface{}{
+				getPullRequest: pullRequestJSON(fixturePR, fixtureSHA, "dirty"),
+			},
+			expectedCalls: []string{addLabels, createComment},
+		},
+		{
+			name:      "conflicting PR already labelled",
+			eventType: "pull_request",
+			fixture:   "pull_request_synchronize.json",
+			handler:   &needsRebase{},
+			config:    needsRebaseConfig,
+			responses: map[string]interface{}{
+				getPullRequest: withLabels(pullRequestJSON(fixturePR, fixtureSHA, "dirty"), "needs-rebase"),
+			},
+		},
+		{
+			name:      "resolved conflict unlabelled",
+			eventType: "pull_request",
+			fixture:   "pull_request_synchronize.json",
+			handler:   &needsRebase{},
+			config:    needsRebaseConfig,
+			responses: map[string]interface{}{
+				getPullRequest: withLabels(pullRequestJSON(fixturePR, fixtureSHA, "clean"), "needs-rebase"),
+			},
+			expectedCalls: []string{removeNeedsRebase},
+		},
+		{
+			name:      "mergeability checked again while unknown",
+			eventType: "pull_request",
+			fixture:   "pull_request_synchronize.json",
+			handler:   &needsRebase{},
+			config:    needsRebaseConfig,
+			responses: map[string]interface{}{
+				getPullRequest: fakeSequence{unknown, pullRequestJSON(fixturePR, fixtureSHA, "dirty")},
+			},
+			expectedCalls: []string{addLabels, createComment},
+		},
+		{
+			name:      "push to base branch checks its PRs",
+			eventType: "push",
+			fixture:   "push.json",
+			handler:   &needsRebase{},
+			config:    needsRebaseConfig,
+			responses: map[string]interface{}{
+				listPulls:      []interface{}{pullRequestJSON(fixturePR, fixtureSHA, "unknown")},
+				getPullRequest: pullRequestJSON(fixturePR, fixtureSHA, "dirty"),
+			},
+			expectedCalls: []string{addLabels, createComment},
+		},
+		{
+			name:      "not configured",
+			eventType: "pull_request",
+			fixture:   "pull_request_synchronize.json",
+			handler:   &needsRebase{},
+			config:    autoMergeConfig,
+		},
+		{
+			name:      "auto-merge refused while needing a rebase",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &autoMerger{},
+			config:    needsRebaseConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				getIssue: issueJSON(fixturePR, "approved", "needs-rebase"),
+			}),
+		},
+	})
+}
+
+func withLabels(pullRequest map[string]interface{}, labels ...string) map[string]interface{} {
+	labelObjects := make([]map[string]interface{}, 0, len(labels))
+	for _, label := range labels {
+		labelObjects = append(labelObjects, map[string]interface{}{"name": label})
+	}
+	pullRequest["labels"] = labelObjects
+	return pullRequest
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
 	"github.com/syndesisio/pure-bot/pkg/config"
 	"go.uber.org/zap"
 )
@@ -16,7 +17,7 @@ func (h *newIssueLabel) EventTypesHandled() []string {
 	return []string{"issues"}
 }
 
-func (h *newIssueLabel) HandleEvent(eventObject interface{}, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *newIssueLabel) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
 	event, ok := eventObject.(*github.IssuesEvent)
 	if !ok {
 		return errors.New("wrong event eventObject type")
@@ -32,6 +33,6 @@ func (h *newIssueLabel) HandleEvent(eventObject interface{}, gh *github.Client,
 		return nil
 	}
 
-	_, _, err := gh.Issues.AddLabelsToIssue(context.Background(), event.Repo.Owner.GetLogin(), event.Repo.GetName(), event.Issue.GetNumber(), labelConfig.NewIssues)
+	_, _, err := gh.Issues.AddLabelsToIssue(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), event.Issue.GetNumber(), labelConfig.NewIssues)
 	return err
 }
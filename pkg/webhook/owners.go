@@ -0,0 +1,429 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ownersFileName        = "OWNERS"
+	ownersAliasesFileName = "OWNERS_ALIASES"
+	ownersCheckName       = "pure-bot/approvals"
+)
+
+var approveCommandRE = regexp.MustCompile(`(?mi)^/approve(\s+cancel)?\s*$`)
+
+// owners is the content of an OWNERS file
+type owners struct {
+	Approvers []string      `yaml:"approvers"`
+	Reviewers []string      `yaml:"reviewers"`
+	Options   ownersOptions `yaml:"options"`
+}
+
+type ownersOptions struct {
+	// Approvers of parent directories can't approve the directory
+	NoParentOwners bool `yaml:"no_parent_owners"`
+}
+
+// ownersAliases is the content of the OWNERS_ALIASES file in the root
+// directory, mapping alias names to users
+type ownersAliases struct {
+	Aliases map[string][]string `yaml:"aliases"`
+}
+
+// directoryApproval tells whether the files owned by an OWNERS file are
+// approved
+type directoryApproval struct {
+	Dir string
+	// Approvers of the directory, including those of parent directories
+	Approvers  []string
+	ApprovedBy string
+}
+
+// approval is a sign-off given or withdrawn by a user
+type approval struct {
+	login    string
+	approved bool
+	time     time.Time
+}
+
+// ownersApproval applies the approved label once every directory touched by
+// a pull request is approved by one of its OWNERS
+type ownersApproval struct{}
+
+func (h *ownersApproval) EventTypesHandled() []string {
+	return []string{"pull_request", "pull_request_review", "issue_comment"}
+}
+
+func (h *ownersApproval) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if !config.OwnersApproval || config.Labels.Approved == "" {
+		return nil
+	}
+
+	switch event := eventObject.(type) {
+	case *github.PullRequestEvent:
+		action := strings.ToLower(event.GetAction())
+		if action != "opened" && action != "reopened" && action != "synchronize" {
+			return nil
+		}
+		return evaluateOwnersApproval(ctx, event.Repo, event.PullRequest.GetNumber(), false, gh, config, logger)
+	case *github.PullRequestReviewEvent:
+		state := strings.ToLower(event.Review.GetState())
+		revoked := strings.ToLower(event.GetAction()) == "dismissed" || state == changesRequestedReviewState
+		if state != approvedReviewState && !revoked {
+			return nil
+		}
+		return evaluateOwnersApproval(ctx, event.Repo, event.PullRequest.GetNumber(), revoked, gh, config, logger)
+	case *github.IssueCommentEvent:
+		if strings.ToLower(event.GetAction()) != "created" || event.Issue.PullRequestLinks == nil {
+			return nil
+		}
+		match := approveCommandRE.FindStringSubmatch(event.Comment.GetBody())
+		if match == nil {
+			return nil
+		}
+		return evaluateOwnersApproval(ctx, event.Repo, event.Issue.GetNumber(), match[1] != "", gh, config, logger)
+	default:
+		return nil
+	}
+}
+
+// evaluateOwnersApproval reports which directories of a pull request still
+// need an approval and applies the approved label once all are approved. If
+// an approval got revoked, the label is removed again.
+func evaluateOwnersApproval(ctx context.Context, repo *github.Repository, number int, revoked bool, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+	owner, repository := repo.Owner.GetLogin(), repo.GetName()
+
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repository, number)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pull request %s/%s#%d", owner, repository, number)
+	}
+	prURL := pr.GetHTMLURL()
+
+	directories, err := ownersApprovalOf(ctx, gh, owner, repository, pr)
+	if err != nil {
+		return err
+	}
+	missing := len(unapprovedDirectories(directories))
+	logger.Debug("Evaluated OWNERS approvals", zap.String("pr", prURL), zap.Int("directories", len(directories)), zap.Int("missing", missing))
+
+	if err := reportOwnersApproval(ctx, gh, owner, repository, pr, directories, missing); err != nil {
+		return err
+	}
+
+	approvedLabel := config.Labels.Approved
+	labeled := labelsContainsLabel(pr.Labels, approvedLabel)
+	switch {
+	case missing == 0 && !labeled:
+		_, _, err = gh.Issues.AddLabelsToIssue(ctx, owner, repository, number, []string{approvedLabel})
+		return errors.Wrapf(err, "failed to add label '%s' to PR %s", approvedLabel, prURL)
+	case missing > 0 && labeled && revoked:
+		_, err = gh.Issues.RemoveLabelForIssue(ctx, owner, repository, number, approvedLabel)
+		return errors.Wrapf(err, "failed to remove label '%s' from PR %s", approvedLabel, prURL)
+	}
+	return nil
+}
+
+// ownersApprovalOf resolves the OWNERS of the directories touched by a pull
+// request and checks which of them are approved
+func ownersApprovalOf(ctx context.Context, gh *github.Client, owner, repository string, pr *github.PullRequest) ([]directoryApproval, error) {
+	files, err := listPullRequestFiles(ctx, gh, owner, repository, pr.GetNumber())
+	if err != nil {
+		return nil, err
+	}
+	ownersByDir, err := loadOwners(ctx, gh, owner, repository, pr.Base.GetRef(), files)
+	if err != nil {
+		return nil, err
+	}
+	approvals, err := listApprovals(ctx, gh, owner, repository, pr.GetNumber())
+	if err != nil {
+		return nil, err
+	}
+	return directoryApprovals(files, ownersByDir, approvals), nil
+}
+
+// unapprovedDirectories returns the directories still missing an approval,
+// as absolute paths
+func unapprovedDirectories(directories []directoryApproval) []string {
+	var unapproved []string
+	for _, dir := range directories {
+		if dir.ApprovedBy == "" {
+			unapproved = append(unapproved, "/"+dir.Dir)
+		}
+	}
+	return unapproved
+}
+
+func listPullRequestFiles(ctx context.Context, gh *github.Client, owner, repository string, number int) ([]string, error) {
+	var files []string
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := gh.PullRequests.ListFiles(ctx, owner, repository, number, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list files of pull request %s/%s#%d", owner, repository, number)
+		}
+		for _, file := range page {
+			files = append(files, file.GetFilename())
+		}
+		if resp.NextPage == 0 {
+			return files, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// loadOwners fetches the OWNERS files of all directories containing the
+// given files and of their parents, as of the given ref. Directories without
+// OWNERS file map to nil.
+func loadOwners(ctx context.Context, gh *github.Client, owner, repository, ref string, files []string) (map[string]*owners, error) {
+	var aliases ownersAliases
+	content, err := getFileContent(ctx, gh, owner, repository, ref, ownersAliasesFileName)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal([]byte(content), &aliases); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s of %s/%s", ownersAliasesFileName, owner, repository)
+	}
+
+	ownersByDir := make(map[string]*owners)
+	for _, file := range files {
+		for _, dir := range parentDirs(file) {
+			if _, found := ownersByDir[dir]; found {
+				continue
+			}
+			content, err := getFileContent(ctx, gh, owner, repository, ref, path.Join(dir, ownersFileName))
+			if err != nil {
+				return nil, err
+			}
+			if content == "" {
+				ownersByDir[dir] = nil
+				continue
+			}
+
+			var o owners
+			if err := yaml.Unmarshal([]byte(content), &o); err != nil {
+				return nil, errors.Wrapf(err, "failed to parse %s of %s/%s", path.Join(dir, ownersFileName), owner, repository)
+			}
+			o.Approvers = expandAliases(o.Approvers, aliases)
+			o.Reviewers = expandAliases(o.Reviewers, aliases)
+			ownersByDir[dir] = &o
+		}
+	}
+	return ownersByDir, nil
+}
+
+// getFileContent returns the content of a file, an empty string if it
+// doesn't exist
+func getFileContent(ctx context.Context, gh *github.Client, owner, repository, ref, file string) (string, error) {
+	content, _, _, err := gh.Repositories.GetContents(ctx, owner, repository, file, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		if errResp, ok := err.(*github.ErrorResponse); ok && errResp.Response.StatusCode == http.StatusNotFound {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "failed to get %s of %s/%s", file, owner, repository)
+	}
+	if content == nil {
+		// A directory
+		return "", nil
+	}
+	decoded, err := content.GetContent()
+	return decoded, errors.Wrapf(err, "failed to decode %s of %s/%s", file, owner, repository)
+}
+
+// listApprovals returns the users who currently approve a pull request, by
+// review or by "/approve" comment. Requesting changes, a dismissed review and
+// "/approve cancel" withdraw an earlier approval.
+func listApprovals(ctx context.Context, gh *github.Client, owner, repository string, number int) (map[string]bool, error) {
+	var history []approval
+
+	reviewOpts := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := gh.PullRequests.ListReviews(ctx, owner, repository, number, reviewOpts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list reviews of pull request %s/%s#%d", owner, repository, number)
+		}
+		for _, review := range reviews {
+			switch strings.ToLower(review.GetState()) {
+			case approvedReviewState:
+				history = append(history, approval{review.User.GetLogin(), true, review.GetSubmittedAt()})
+			case changesRequestedReviewState, "dismissed":
+				history = append(history, approval{review.User.GetLogin(), false, review.GetSubmittedAt()})
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		reviewOpts.Page = resp.NextPage
+	}
+
+	commentOpts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := gh.Issues.ListComments(ctx, owner, repository, number, commentOpts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list comments of pull request %s/%s#%d", owner, repository, number)
+		}
+		for _, comment := range comments {
+			if match := approveCommandRE.FindStringSubmatch(comment.GetBody()); match != nil {
+				history = append(history, approval{comment.User.GetLogin(), match[1] == "", comment.GetCreatedAt()})
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		commentOpts.Page = resp.NextPage
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].time.Before(history[j].time)
+	})
+	approvals := make(map[string]bool)
+	for _, a := range history {
+		approvals[strings.ToLower(a.login)] = a.approved
+	}
+	return approvals, nil
+}
+
+// directoryApprovals groups the files by their nearest OWNERS file and checks
+// whether any approver of that directory or of one of its parents approved.
+// Parents above an OWNERS file with the no_parent_owners option don't count.
+func directoryApprovals(files []string, ownersByDir map[string]*owners, approvals map[string]bool) []directoryApproval {
+	byDir := make(map[string]*directoryApproval)
+	var dirs []string
+	for _, file := range files {
+		var nearest string
+		var approvers []string
+		found := false
+		for _, dir := range parentDirs(file) {
+			o := ownersByDir[dir]
+			if o == nil {
+				continue
+			}
+			if !found {
+				nearest, found = dir, true
+			}
+			approvers = append(approvers, o.Approvers...)
+			if o.Options.NoParentOwners {
+				break
+			}
+		}
+		if _, seen := byDir[nearest]; seen {
+			continue
+		}
+
+		d := &directoryApproval{Dir: nearest, Approvers: uniqueLogins(approvers)}
+		for _, approver := range d.Approvers {
+			if approvals[approver] {
+				d.ApprovedBy = approver
+				break
+			}
+		}
+		byDir[nearest] = d
+		dirs = append(dirs, nearest)
+	}
+
+	sort.Strings(dirs)
+	result := make([]directoryApproval, 0, len(dirs))
+	for _, dir := range dirs {
+		result = append(result, *byDir[dir])
+	}
+	return result
+}
+
+func reportOwnersApproval(ctx context.Context, gh *github.Client, owner, repository string, pr *github.PullRequest, directories []directoryApproval, missing int) error {
+	var summary []string
+	for _, dir := range directories {
+		name := "/" + dir.Dir
+		switch {
+		case dir.ApprovedBy != "":
+			summary = append(summary, fmt.Sprintf("- [x] `%s` approved by @%s", name, dir.ApprovedBy))
+		case len(dir.Approvers) == 0:
+			summary = append(summary, fmt.Sprintf("- [ ] `%s` has no approvers, add an %s file", name, ownersFileName))
+		default:
+			summary = append(summary, fmt.Sprintf("- [ ] `%s` needs approval from one of @%s", name, strings.Join(dir.Approvers, ", @")))
+		}
+	}
+	summary = append(summary, "", "Approve with an approving review or by commenting `/approve`.")
+
+	opts := github.CreateCheckRunOptions{
+		Name:    ownersCheckName,
+		HeadSHA: pr.Head.GetSHA(),
+		Output: &github.CheckRunOutput{
+			Summary: github.String(strings.Join(summary, "\n")),
+		},
+	}
+	if missing == 0 {
+		opts.Status = github.String("completed")
+		opts.Conclusion = github.String("success")
+		opts.Output.Title = github.String("Approved by all OWNERS")
+	} else {
+		opts.Status = github.String("in_progress")
+		opts.Output.Title = github.String(fmt.Sprintf("Approval missing for %d of %d directories", missing, len(directories)))
+	}
+
+	_, _, err := gh.Checks.CreateCheckRun(ctx, owner, repository, opts)
+	return errors.Wrapf(err, "failed to create check run %s for PR %s", ownersCheckName, pr.GetHTMLURL())
+}
+
+// parentDirs returns the directory of a file and all its parents up to the
+// root directory, which is the empty string
+func parentDirs(file string) []string {
+	var dirs []string
+	dir := path.Dir(file)
+	for dir != "." && dir != "/" {
+		dirs = append(dirs, dir)
+		dir = path.Dir(dir)
+	}
+	return append(dirs, "")
+}
+
+func expandAliases(logins []string, aliases ownersAliases) []string {
+	var expanded []string
+	for _, login := range logins {
+		if members, found := aliases.Aliases[login]; found {
+			expanded = append(expanded, members...)
+		} else {
+			expanded = append(expanded, login)
+		}
+	}
+	return expanded
+}
+
+func uniqueLogins(logins []string) []string {
+	seen := make(map[string]bool, len(logins))
+	var unique []string
+	for _, login := range logins {
+		login = strings.ToLower(login)
+		if !seen[login] {
+			seen[login] = true
+			unique = append(unique, login)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+func TestDirectoryApprovals(t *testing.T) {
+	ownersByDir := map[string]*owners{
+		"":            {Approvers: []string{"root"}},
+		"pkg":         nil,
+		"pkg/webhook": {Approvers: []string{"Hook", "other"}},
+		"cmd":         nil,
+	}
+	files := []string{"pkg/webhook/webhook.go", "pkg/webhook/owners.go", "pkg/http/server.go", "cmd/run.go"}
+
+	directories := directoryApprovals(files, ownersByDir, map[string]bool{"hook": true, "root": false})
+	expected := []directoryApproval{
+		{Dir: "", Approvers: []string{"root"}},
+		{Dir: "pkg/webhook", Approvers: []string{"hook", "other", "root"}, ApprovedBy: "hook"},
+	}
+	if !reflect.DeepEqual(directories, expected) {
+		t.Errorf("expected %v, got %v", expected, directories)
+	}
+
+	directories = directoryApprovals(files, ownersByDir, map[string]bool{"root": true})
+	for _, dir := range directories {
+		if dir.ApprovedBy != "root" {
+			t.Errorf("expected %s to be approved by the root approver", dir.Dir)
+		}
+	}
+}
+
+func TestDirectoryApprovalsWithoutParentOwners(t *testing.T) {
+	ownersByDir := map[string]*owners{
+		"":    {Approvers: []string{"root"}},
+		"pkg": {Approvers: []string{"pkg"}, Options: ownersOptions{NoParentOwners: true}},
+	}
+	directories := directoryApprovals([]string{"pkg/webhook/owners.go"}, ownersByDir, map[string]bool{"root": true})
+	expected := []directoryApproval{{Dir: "pkg", Approvers: []string{"pkg"}}}
+	if !reflect.DeepEqual(directories, expected) {
+		t.Errorf("expected %v, got %v", expected, directories)
+	}
+}
+
+func TestExpandAliases(t *testing.T) {
+	aliases := ownersAliases{Aliases: map[string][]string{"webhook-approvers": {"alice", "bob"}}}
+	expanded := expandAliases([]string{"webhook-approvers", "carol"}, aliases)
+	if !reflect.DeepEqual(expanded, []string{"alice", "bob", "carol"}) {
+		t.Errorf("unexpected expansion %v", expanded)
+	}
+}
+
+func fileContentJSON(content string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "file",
+		"encoding": "base64",
+		"content":  base64.StdEncoding.EncodeToString([]byte(content)),
+	}
+}
+
+func TestOwnersApproval(t *testing.T) {
+	ownersConfig := config.RepoConfig{
+		Labels: config.LabelConfig{
+			Approved: "approved",
+		},
+		OwnersApproval: true,
+	}
+	prPath := fixtureRepo + "/pulls/" + strconv.Itoa(fixturePR)
+	responses := func(rootOwners string) map[string]interface{} {
+		return map[string]interface{}{
+			getPullRequest:             pullRequestJSON(fixturePR, fixtureSHA, "clean"),
+			"GET " + prPath + "/files": []map[string]interface{}{{"filename": "src/main/java/App.java"}},
+			"GET " + fixtureRepo + "/contents/OWNERS_ALIASES":       fileContentJSON("aliases:\n  rest-approvers:\n  - jimmidyson\n"),
+			"GET " + fixtureRepo + "/contents/src/main/java/OWNERS": notFound,
+			"GET " + fixtureRepo + "/contents/src/main/OWNERS":      notFound,
+			"GET " + fixtureRepo + "/contents/src/OWNERS":           notFound,
+			"GET " + fixtureRepo + "/contents/OWNERS":               fileContentJSON(rootOwners),
+			"GET " + prPath + "/reviews": []map[string]interface{}{
+				{"state": "APPROVED", "user": map[string]interface{}{"login": "jimmidyson"}, "submitted_at": "2017-04-05T08:00:00Z"},
+			},
+			"GET " + fixtureRepo + "/issues/" + strconv.Itoa(fixturePR) + "/comments": []map[string]interface{}{},
+		}
+	}
+
+	runScenarios(t, []scenario{
+		{
+			name:      "approved by alias of root OWNERS",
+			eventType: "pull_request_review",
+			fixture:   "pull_request_review.json",
+			handler:   &ownersApproval{},
+			config:    ownersConfig,
+			responses: responses("approvers:\n- rest-approvers\n"),
+			expectedCalls: []string{
+				"POST " + fixtureRepo + "/check-runs",
+				"POST " + fixtureRepo + "/issues/" + strconv.Itoa(fixturePR) + "/labels",
+			},
+		},
+		{
+			name:          "approved by non-owner",
+			eventType:     "pull_request_review",
+			fixture:       "pull_request_review.json",
+			handler:       &ownersApproval{},
+			config:        ownersConfig,
+			responses:     responses("approvers:\n- rhuss\n"),
+			expectedCalls: []string{"POST " + fixtureRepo + "/check-runs"},
+		},
+	})
+}
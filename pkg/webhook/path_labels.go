@@ -0,0 +1,175 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// pathLabels labels pull requests by the paths of their changed files. The
+// labels are re-evaluated on every push, labels of rules which no longer
+// match are removed.
+type pathLabels struct{}
+
+func (h *pathLabels) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *pathLabels) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if len(config.PathLabels) == 0 && config.LabelerFile == "" {
+		return nil
+	}
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	action := strings.ToLower(event.GetAction())
+	if action != "opened" && action != "reopened" && action != "synchronize" {
+		return nil
+	}
+
+	pr := event.PullRequest
+	owner, repository, number := event.Repo.Owner.GetLogin(), event.Repo.GetName(), pr.GetNumber()
+	rules := config.PathLabels
+	if config.LabelerFile != "" {
+		fileRules, err := loadLabelerFile(ctx, gh, owner, repository, config.LabelerFile)
+		if err != nil {
+			return err
+		}
+		rules = append(rules[:len(rules):len(rules)], fileRules...)
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	files, err := listPullRequestFiles(ctx, gh, owner, repository, number)
+	if err != nil {
+		return err
+	}
+	matching, err := matchPathLabels(rules, files)
+	if err != nil {
+		return err
+	}
+
+	var add []string
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		label := rule.Label
+		if seen[label] {
+			continue
+		}
+		seen[label] = true
+		labelled := labelsContainsLabel(pr.Labels, label)
+		if matching[label] && !labelled {
+			add = append(add, label)
+		} else if !matching[label] && labelled {
+			logger.Info("Removing path label", zap.Int("pr", number), zap.String("label", label))
+			if _, err := gh.Issues.RemoveLabelForIssue(ctx, owner, repository, number, label); err != nil {
+				return errors.Wrapf(err, "failed to remove label %s from PR %s", label, pr.GetHTMLURL())
+			}
+		}
+	}
+	if len(add) == 0 {
+		return nil
+	}
+	logger.Info("Adding path labels", zap.Int("pr", number), zap.Strings("labels", add))
+	_, _, err = gh.Issues.AddLabelsToIssue(ctx, owner, repository, number, add)
+	return errors.Wrapf(err, "failed to add labels %v to PR %s", add, pr.GetHTMLURL())
+}
+
+// matchPathLabels returns the labels of the rules matching any of the files
+func matchPathLabels(rules []config.PathLabel, files []string) (map[string]bool, error) {
+	matching := make(map[string]bool)
+	for _, rule := range rules {
+		for _, glob := range rule.Paths {
+			re, err := globRegexp(glob)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid path %q of label %s", glob, rule.Label)
+			}
+			for _, file := range files {
+				if re.MatchString(file) {
+					matching[rule.Label] = true
+				}
+			}
+		}
+	}
+	return matching, nil
+}
+
+// globRegexp translates a glob into a regular expression. "*" and "?" don't
+// match "/", "**" matches any number of path segments.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	return regexp.Compile("^" + globPattern(glob) + "$")
+}
+
+// globPattern returns the unanchored regular expression of a glob
+func globPattern(glob string) string {
+	var re bytes.Buffer
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return re.String()
+}
+
+// loadLabelerFile reads path labels from a file in the default branch of a
+// repository, mapping labels to lists of globs like
+//
+//	area/docs:
+//	  - docs/**
+func loadLabelerFile(ctx context.Context, gh *github.Client, owner, repository, file string) ([]config.PathLabel, error) {
+	content, err := getFileContent(ctx, gh, owner, repository, "", file)
+	if err != nil || content == "" {
+		return nil, err
+	}
+	var globs map[string][]string
+	if err := yaml.Unmarshal([]byte(content), &globs); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s of %s/%s", file, owner, repository)
+	}
+
+	labels := make([]string, 0, len(globs))
+	for label := range globs {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	rules := make([]config.PathLabel, 0, len(labels))
+	for _, label := range labels {
+		rules = append(rules, config.PathLabel{Label: label, Paths: globs[label]})
+	}
+	return rules, nil
+}
@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		glob    string
+		path    string
+		matches bool
+	}{
+		{"docs/**", "docs/index.md", true},
+		{"docs/**", "docs/api/index.md", true},
+		{"docs/**", "pkg/docs/index.md", false},
+		{"*.md", "README.md", true},
+		{"*.md", "docs/README.md", false},
+		{"**/*.md", "docs/README.md", true},
+		{"**/*.md", "README.md", true},
+		{"pkg/**/*_test.go", "pkg/webhook/wip_test.go", true},
+		{"pkg/**/*_test.go", "pkg/webhook/wip.go", false},
+		{"Makefile", "Makefile", true},
+		{"v?.go", "v1.go", true},
+	}
+	for _, test := range tests {
+		re, err := globRegexp(test.glob)
+		if err != nil {
+			t.Fatalf("invalid glob %s: %v", test.glob, err)
+		}
+		if matches := re.MatchString(test.path); matches != test.matches {
+			t.Errorf("expected %s matching %s to be %v", test.glob, test.path, test.matches)
+		}
+	}
+}
+
+func TestPathLabels(t *testing.T) {
+	listFiles := "GET " + fixtureRepo + "/pulls/276/files"
+	labelerFile := "GET " + fixtureRepo + "/contents/.github/labeler.yml"
+	addLabels := "POST " + fixtureRepo + "/issues/276/labels"
+	removeLabel := "DELETE " + fixtureRepo + "/issues/276/labels/area/webhook"
+	pathConfig := config.RepoConfig{PathLabels: []config.PathLabel{
+		{Label: "area/docs", Paths: []string{"docs/**", "*.md"}},
+		{Label: "area/webhook", Paths: []string{"pkg/webhook/**"}},
+	}}
+	files := func(names ...string) []map[string]interface{} {
+		page := make([]map[string]interface{}, 0, len(names))
+		for _, name := range names {
+			page = append(page, map[string]interface{}{"filename": name})
+		}
+		return page
+	}
+
+	tests := []struct {
+		name           string
+		event          *github.PullRequestEvent
+		config         config.RepoConfig
+		responses      map[string]interface{}
+		expectedLabels []string
+		expectedCalls  []string
+	}{
+		{
+			name:           "matching paths labeled",
+			event:          sizedPullRequestEvent("opened", 1, 1, 2),
+			config:         pathConfig,
+			responses:      map[string]interface{}{listFiles: files("README.md", "pkg/webhook/wip.go")},
+			expectedLabels: []string{"area/docs", "area/webhook"},
+			expectedCalls:  []string{addLabels},
+		},
+		{
+			name:          "label of paths no longer changed removed",
+			event:         sizedPullRequestEvent("synchronize", 1, 1, 1, "area/docs", "area/webhook"),
+			config:        pathConfig,
+			responses:     map[string]interface{}{listFiles: files("docs/index.md")},
+			expectedCalls: []string{removeLabel},
+		},
+		{
+			name:      "labels up to date",
+			event:     sizedPullRequestEvent("synchronize", 1, 1, 1, "area/docs"),
+			config:    pathConfig,
+			responses: map[string]interface{}{listFiles: files("docs/index.md")},
+		},
+		{
+			name:   "labeler file",
+			event:  sizedPullRequestEvent("opened", 1, 1, 1),
+			config: config.RepoConfig{LabelerFile: ".github/labeler.yml"},
+			responses: map[string]interface{}{
+				labelerFile: fileContentJSON("area/ci:\n  - .github/**\n"),
+				listFiles:   files(".github/workflows/build.yml"),
+			},
+			expectedLabels: []string{"area/ci"},
+			expectedCalls:  []string{addLabels},
+		},
+		{
+			name:      "no labeler file",
+			event:     sizedPullRequestEvent("opened", 1, 1, 1),
+			config:    config.RepoConfig{LabelerFile: ".github/labeler.yml"},
+			responses: map[string]interface{}{labelerFile: notFound},
+		},
+		{
+			name:  "not configured",
+			event: sizedPullRequestEvent("opened", 1, 1, 1),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			if err := (&pathLabels{}).HandleEvent(context.Background(), test.event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Errorf("labeling failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if test.expectedLabels != nil {
+				var labels []string
+				if err := fake.requestBody(addLabels, &labels); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(labels, test.expectedLabels) {
+					t.Errorf("expected labels %v, got %v", test.expectedLabels, labels)
+				}
+			}
+		})
+	}
+}
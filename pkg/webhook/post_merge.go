@@ -0,0 +1,92 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// afterMerge performs the configured follow-up actions on a merged pull
+// request. All actions are tried, even if some of them fail.
+func afterMerge(ctx context.Context, issue *github.Issue, pr *github.PullRequest, owner, repository string, gh *github.Client, approvedLabel string, config config.RepoConfig, logger *zap.Logger) error {
+	cfg := config.PostMerge
+	number := issue.GetNumber()
+
+	var multiErr error
+	if cfg.RemoveApprovedLabel && approvedLabel != "" && containsLabel(issue.Labels, approvedLabel) {
+		if _, err := gh.Issues.RemoveLabelForIssue(ctx, owner, repository, number, approvedLabel); err != nil {
+			multiErr = multierr.Combine(multiErr, errors.Wrapf(err, "failed to remove label %s from merged pull request %s", approvedLabel, issue.GetHTMLURL()))
+		}
+	}
+
+	if cfg.Label != "" && !containsLabel(issue.Labels, cfg.Label) {
+		if _, _, err := gh.Issues.AddLabelsToIssue(ctx, owner, repository, number, []string{cfg.Label}); err != nil {
+			multiErr = multierr.Combine(multiErr, errors.Wrapf(err, "failed to add label %s to merged pull request %s", cfg.Label, issue.GetHTMLURL()))
+		}
+	}
+
+	if cfg.AssignMilestone && issue.Milestone == nil {
+		multiErr = multierr.Combine(multiErr, assignCurrentMilestone(ctx, issue, owner, repository, gh, logger))
+	}
+
+	if cfg.Comment != "" {
+		body, err := renderTemplate("comment", cfg.Comment, mergeCommitData{
+			Title:  pr.GetTitle(),
+			Number: pr.GetNumber(),
+			Body:   pr.GetBody(),
+			Author: pr.User.GetLogin(),
+		})
+		if err == nil {
+			_, _, err = gh.Issues.CreateComment(ctx, owner, repository, number, &github.IssueComment{Body: github.String(body)})
+		}
+		if err != nil {
+			multiErr = multierr.Combine(multiErr, errors.Wrapf(err, "failed to comment on merged pull request %s", issue.GetHTMLURL()))
+		}
+	}
+
+	return multiErr
+}
+
+// assignCurrentMilestone assigns the open milestone which is due next
+func assignCurrentMilestone(ctx context.Context, issue *github.Issue, owner, repository string, gh *github.Client, logger *zap.Logger) error {
+	milestones, _, err := gh.Issues.ListMilestones(ctx, owner, repository, &github.MilestoneListOptions{
+		State:     "open",
+		Sort:      "due_on",
+		Direction: "asc",
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list milestones of %s/%s", owner, repository)
+	}
+
+	var current *github.Milestone
+	for _, milestone := range milestones {
+		if milestone.DueOn != nil && (current == nil || milestone.DueOn.Before(*current.DueOn)) {
+			current = milestone
+		}
+	}
+	if current == nil {
+		logger.Debug("No open milestone with due date, not assigning a milestone", zap.Int("pr", issue.GetNumber()))
+		return nil
+	}
+
+	_, _, err = gh.Issues.Edit(ctx, owner, repository, issue.GetNumber(), &github.IssueRequest{Milestone: current.Number})
+	return errors.Wrapf(err, "failed to assign milestone %s to merged pull request %s", current.GetTitle(), issue.GetHTMLURL())
+}
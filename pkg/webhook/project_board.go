@@ -0,0 +1,263 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const defaultProjectField = "Status"
+
+type projectQuery struct {
+	RepositoryOwner struct {
+		ProjectV2Owner struct {
+			ProjectV2 *projectV2 `graphql:"projectV2(number: $number)"`
+		} `graphql:"... on ProjectV2Owner"`
+	} `graphql:"repositoryOwner(login: $owner)"`
+}
+
+type projectV2 struct {
+	ID    string
+	Field struct {
+		SingleSelectField struct {
+			ID      string
+			Options []struct {
+				ID   string
+				Name string
+			}
+		} `graphql:"... on ProjectV2SingleSelectField"`
+	} `graphql:"field(name: $field)"`
+}
+
+type projectItem struct {
+	ID      string
+	Content struct {
+		PullRequest struct {
+			Repository struct {
+				NameWithOwner string
+			}
+		} `graphql:"... on PullRequest"`
+	}
+	FieldValueByName struct {
+		SingleSelectValue struct {
+			OptionID string
+		} `graphql:"... on ProjectV2ItemFieldSingleSelectValue"`
+	} `graphql:"fieldValueByName(name: $field)"`
+}
+
+type projectItemsQuery struct {
+	Node struct {
+		ProjectV2 struct {
+			Items struct {
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   string
+				}
+				Nodes []projectItem
+			} `graphql:"items(first: 100, after: $cursor)"`
+		} `graphql:"... on ProjectV2"`
+	} `graphql:"node(id: $project)"`
+}
+
+type addProjectItemMutation struct {
+	AddProjectV2ItemByID struct {
+		Item struct {
+			ID string
+		}
+	} `graphql:"addProjectV2ItemById(input: $input)"`
+}
+
+type updateProjectItemMutation struct {
+	UpdateProjectV2ItemFieldValue struct {
+		ClientMutationID string
+	} `graphql:"updateProjectV2ItemFieldValue(input: $input)"`
+}
+
+// projectBoard moves pull requests through the stages of a GitHub project:
+// opened, approved, merged and, once a release got published, released
+type projectBoard struct{}
+
+func (h *projectBoard) EventTypesHandled() []string {
+	return []string{"pull_request", "release"}
+}
+
+func (h *projectBoard) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if config.Project.Owner == "" || config.Project.Number <= 0 {
+		return nil
+	}
+	switch event := eventObject.(type) {
+	case *github.PullRequestEvent:
+		return h.handlePullRequestEvent(ctx, event, gql, config, logger)
+	case *releaseEvent:
+		return h.handleReleaseEvent(ctx, event, gql, config.Project, logger)
+	}
+	return errors.New("wrong event eventObject type")
+}
+
+func (h *projectBoard) handlePullRequestEvent(ctx context.Context, event *github.PullRequestEvent, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	pr := event.PullRequest
+	stage := projectStage(event, config)
+	if stage == "" {
+		return nil
+	}
+
+	project, err := findProject(ctx, gql, config.Project)
+	if err != nil {
+		return err
+	}
+	option := project.option(stage)
+	if option == "" {
+		logger.Warn("Project field has no such option", zap.String("field", projectField(config.Project)), zap.String("option", stage))
+		return nil
+	}
+
+	var added addProjectItemMutation
+	err = gql.Mutate(ctx, &added, githubv4.AddProjectV2ItemByIdInput{
+		ProjectID: githubv4.ID(project.ID),
+		ContentID: githubv4.ID(pr.GetNodeID()),
+	}, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to add pull request %s to project", pr.GetHTMLURL())
+	}
+
+	logger.Info("Moving pull request on project", zap.Int("pr", pr.GetNumber()), zap.String("option", stage))
+	err = setProjectOption(ctx, gql, project, added.AddProjectV2ItemByID.Item.ID, option)
+	return errors.Wrapf(err, "failed to move pull request %s to %s", pr.GetHTMLURL(), stage)
+}
+
+// handleReleaseEvent moves all merged pull requests of the repository to
+// the released stage
+func (h *projectBoard) handleReleaseEvent(ctx context.Context, event *releaseEvent, gql *githubv4.Client, cfg config.ProjectConfig, logger *zap.Logger) error {
+	if event.GetAction() != "published" || event.Release.GetPrerelease() || cfg.Merged == "" || cfg.Released == "" {
+		return nil
+	}
+
+	project, err := findProject(ctx, gql, cfg)
+	if err != nil {
+		return err
+	}
+	merged, released := project.option(cfg.Merged), project.option(cfg.Released)
+	if merged == "" || released == "" {
+		logger.Warn("Project field misses the merged or released option", zap.String("field", projectField(cfg)))
+		return nil
+	}
+
+	repository := event.Repo.GetFullName()
+	variables := map[string]interface{}{
+		"project": githubv4.ID(project.ID),
+		"field":   githubv4.String(projectField(cfg)),
+		"cursor":  (*githubv4.String)(nil),
+	}
+	for {
+		var result projectItemsQuery
+		if err := gql.Query(ctx, &result, variables); err != nil {
+			return errors.Wrapf(err, "failed to list items of project %s/%d", cfg.Owner, cfg.Number)
+		}
+
+		items := result.Node.ProjectV2.Items
+		for _, item := range items.Nodes {
+			if item.FieldValueByName.SingleSelectValue.OptionID != merged || !strings.EqualFold(item.Content.PullRequest.Repository.NameWithOwner, repository) {
+				continue
+			}
+			if err := setProjectOption(ctx, gql, project, item.ID, released); err != nil {
+				return errors.Wrapf(err, "failed to move project item %s to %s", item.ID, cfg.Released)
+			}
+		}
+
+		if !items.PageInfo.HasNextPage {
+			return nil
+		}
+		variables["cursor"] = githubv4.NewString(githubv4.String(items.PageInfo.EndCursor))
+	}
+}
+
+// projectStage returns the option a pull request event moves the pull
+// request to, or "" if it stays where it is
+func projectStage(event *github.PullRequestEvent, config config.RepoConfig) string {
+	pr := event.PullRequest
+	switch strings.ToLower(event.GetAction()) {
+	case "opened", "reopened", "ready_for_review":
+		return config.Project.Opened
+	case labeledEvent:
+		if config.Labels.Approved != "" && pr.GetState() == "open" && strings.EqualFold(event.GetLabel().GetName(), config.Labels.Approved) {
+			return config.Project.Approved
+		}
+	case unlabeledEvent:
+		if config.Labels.Approved != "" && pr.GetState() == "open" && strings.EqualFold(event.GetLabel().GetName(), config.Labels.Approved) {
+			return config.Project.Opened
+		}
+	case "closed":
+		if pr.GetMerged() {
+			return config.Project.Merged
+		}
+	}
+	return ""
+}
+
+func projectField(cfg config.ProjectConfig) string {
+	if cfg.Field == "" {
+		return defaultProjectField
+	}
+	return cfg.Field
+}
+
+// findProject looks up the project and its single select field
+func findProject(ctx context.Context, gql *githubv4.Client, cfg config.ProjectConfig) (*projectV2, error) {
+	var result projectQuery
+	err := gql.Query(ctx, &result, map[string]interface{}{
+		"owner":  githubv4.String(cfg.Owner),
+		"number": githubv4.Int(cfg.Number),
+		"field":  githubv4.String(projectField(cfg)),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to look up project %s/%d", cfg.Owner, cfg.Number)
+	}
+	project := result.RepositoryOwner.ProjectV2Owner.ProjectV2
+	if project == nil {
+		return nil, errors.Errorf("no project %s/%d", cfg.Owner, cfg.Number)
+	}
+	if project.Field.SingleSelectField.ID == "" {
+		return nil, errors.Errorf("project %s/%d has no single select field %q", cfg.Owner, cfg.Number, projectField(cfg))
+	}
+	return project, nil
+}
+
+// option returns the ID of the field's option with the given name
+func (p *projectV2) option(name string) string {
+	for _, option := range p.Field.SingleSelectField.Options {
+		if strings.EqualFold(option.Name, name) {
+			return option.ID
+		}
+	}
+	return ""
+}
+
+func setProjectOption(ctx context.Context, gql *githubv4.Client, project *projectV2, item, option string) error {
+	return gql.Mutate(ctx, &updateProjectItemMutation{}, githubv4.UpdateProjectV2ItemFieldValueInput{
+		ProjectID: githubv4.ID(project.ID),
+		ItemID:    githubv4.ID(item),
+		FieldID:   githubv4.ID(project.Field.SingleSelectField.ID),
+		Value: githubv4.ProjectV2FieldValue{
+			SingleSelectOptionID: githubv4.NewString(githubv4.String(option)),
+		},
+	}, nil)
+}
@@ -0,0 +1,191 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+var projectConfig = config.RepoConfig{
+	Labels: config.LabelConfig{Approved: "approved"},
+	Project: config.ProjectConfig{
+		Owner:    "syndesisio",
+		Number:   3,
+		Opened:   "In review",
+		Approved: "Approved",
+		Merged:   "Done",
+		Released: "Released",
+	},
+}
+
+func graphQLData(data map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"data": data}
+}
+
+var projectJSON = graphQLData(map[string]interface{}{
+	"repositoryOwner": map[string]interface{}{
+		"projectV2": map[string]interface{}{
+			"id": "project",
+			"field": map[string]interface{}{
+				"id": "status",
+				"options": []interface{}{
+					map[string]interface{}{"id": "o1", "name": "In review"},
+					map[string]interface{}{"id": "o2", "name": "Approved"},
+					map[string]interface{}{"id": "o3", "name": "Done"},
+					map[string]interface{}{"id": "o4", "name": "Released"},
+				},
+			},
+		},
+	},
+})
+
+func projectItemJSON(id, repository, option string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":               id,
+		"content":          map[string]interface{}{"repository": map[string]interface{}{"nameWithOwner": repository}},
+		"fieldValueByName": map[string]interface{}{"optionId": option},
+	}
+}
+
+func TestProjectStage(t *testing.T) {
+	labeled := func(action, label, state string) *github.PullRequestEvent {
+		event := pullRequestEvent(action)
+		event.Label = &github.Label{Name: github.String(label)}
+		event.PullRequest.State = github.String(state)
+		return event
+	}
+	merged := pullRequestEvent("closed")
+	merged.PullRequest.Merged = github.Bool(true)
+
+	tests := []struct {
+		name     string
+		event    *github.PullRequestEvent
+		expected string
+	}{
+		{"opened", pullRequestEvent("opened"), "In review"},
+		{"ready for review", pullRequestEvent("ready_for_review"), "In review"},
+		{"approved", labeled("labeled", "Approved", "open"), "Approved"},
+		{"approval revoked", labeled("unlabeled", "approved", "open"), "In review"},
+		{"other label", labeled("labeled", "bug", "open"), ""},
+		{"approved after close", labeled("labeled", "approved", "closed"), ""},
+		{"merged", merged, "Done"},
+		{"closed", pullRequestEvent("closed"), ""},
+		{"synchronized", pullRequestEvent("synchronize"), ""},
+	}
+	for _, test := range tests {
+		if stage := projectStage(test.event, projectConfig); stage != test.expected {
+			t.Errorf("%s: expected stage %q, got %q", test.name, test.expected, stage)
+		}
+	}
+}
+
+func TestProjectBoard(t *testing.T) {
+	const graphQLCall = "POST /graphql"
+	approved := pullRequestEvent("labeled")
+	approved.Label = &github.Label{Name: github.String("approved")}
+	approved.PullRequest.State = github.String("open")
+	approved.PullRequest.NodeID = github.String("pr")
+
+	repo := &github.Repository{
+		Name:     github.String("syndesis-rest"),
+		FullName: github.String("syndesisio/syndesis-rest"),
+		Owner:    &github.User{Login: github.String("syndesisio")},
+	}
+	released := &releaseEvent{Action: github.String("published"), Release: &github.RepositoryRelease{}, Repo: repo}
+	prereleased := &releaseEvent{Action: github.String("published"), Release: &github.RepositoryRelease{Prerelease: github.Bool(true)}, Repo: repo}
+
+	tests := []struct {
+		name          string
+		event         interface{}
+		config        config.RepoConfig
+		responses     fakeSequence
+		expectedCalls []string
+		expected      map[string]interface{}
+	}{
+		{
+			name:   "approved",
+			config: projectConfig,
+			event:  approved,
+			responses: fakeSequence{
+				projectJSON,
+				graphQLData(map[string]interface{}{"addProjectV2ItemById": map[string]interface{}{"item": map[string]interface{}{"id": "item"}}}),
+				graphQLData(map[string]interface{}{}),
+			},
+			expectedCalls: []string{graphQLCall, graphQLCall, graphQLCall},
+			expected: map[string]interface{}{"input": map[string]interface{}{
+				"projectId": "project",
+				"itemId":    "item",
+				"fieldId":   "status",
+				"value":     map[string]interface{}{"singleSelectOptionId": "o2"},
+			}},
+		},
+		{
+			name:   "released",
+			config: projectConfig,
+			event:  released,
+			responses: fakeSequence{
+				projectJSON,
+				graphQLData(map[string]interface{}{"node": map[string]interface{}{"items": map[string]interface{}{
+					"pageInfo": map[string]interface{}{"hasNextPage": false},
+					"nodes": []interface{}{
+						projectItemJSON("merged", "syndesisio/syndesis-rest", "o3"),
+						projectItemJSON("other repository", "syndesisio/syndesis-ui", "o3"),
+						projectItemJSON("approved", "syndesisio/syndesis-rest", "o2"),
+					},
+				}}}),
+				graphQLData(map[string]interface{}{}),
+			},
+			expectedCalls: []string{graphQLCall, graphQLCall, graphQLCall},
+			expected: map[string]interface{}{"input": map[string]interface{}{
+				"projectId": "project",
+				"itemId":    "merged",
+				"fieldId":   "status",
+				"value":     map[string]interface{}{"singleSelectOptionId": "o4"},
+			}},
+		},
+		{
+			name:   "prerelease",
+			config: projectConfig,
+			event:  prereleased,
+		},
+		{
+			name:   "no project",
+			event:  approved,
+			config: config.RepoConfig{Labels: projectConfig.Labels},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			responses := make(map[string]interface{})
+			if test.responses != nil {
+				responses[graphQLCall] = test.responses
+			}
+			fake := newFakeGitHub(t, responses)
+			defer fake.close()
+
+			if err := (&projectBoard{}).HandleEvent(context.Background(), test.event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("handler failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if test.expected == nil {
+				return
+			}
+			var request struct {
+				Variables map[string]interface{} `json:"variables"`
+			}
+			if err := fake.requestBody(graphQLCall, &request); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(request.Variables, test.expected) {
+				t.Errorf("expected variables %v, got %v", test.expected, request.Variables)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// GitHub asks for at least a second between mutating requests, to not
+	// trigger its secondary rate limits
+	mutationInterval = time.Second
+	// Requests which would have to wait longer for the rate limit to reset
+	// fail right away
+	maxRateLimitWait = 15 * time.Minute
+)
+
+// waitFor blocks for the given duration, unless ctx is done or the bot is
+// shutting down
+var waitFor = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-shutdownCtx.Done():
+		return shutdownCtx.Err()
+	}
+}
+
+// rateLimit tracks the API quota of an installation, shared by all clients
+// created for it
+type rateLimit struct {
+	mu           sync.Mutex
+	installation string
+	// Remaining requests until reset, -1 if not known yet
+	remaining int
+	reset     time.Time
+	// Set by secondary rate limits
+	blockedUntil time.Time
+
+	// Serializes mutating requests
+	mutations    sync.Mutex
+	lastMutation time.Time
+}
+
+var (
+	rateLimitsMu sync.Mutex
+	rateLimits   = make(map[int64]*rateLimit)
+)
+
+func rateLimitOf(installationID int64) *rateLimit {
+	rateLimitsMu.Lock()
+	defer rateLimitsMu.Unlock()
+	limit, found := rateLimits[installationID]
+	if !found {
+		limit = &rateLimit{installation: strconv.FormatInt(installationID, 10), remaining: -1}
+		rateLimits[installationID] = limit
+	}
+	return limit
+}
+
+// rateLimitTransport delays requests while the quota of an installation is
+// used up, paces mutating requests and retries requests rejected by a rate
+// limit once
+type rateLimitTransport struct {
+	tr     http.RoundTripper
+	limit  *rateLimit
+	logger *zap.Logger
+}
+
+var _ http.RoundTripper = &rateLimitTransport{}
+
+func rateLimited(installationID int64, logger *zap.Logger) func(http.RoundTripper) http.RoundTripper {
+	return func(tr http.RoundTripper) http.RoundTripper {
+		return &rateLimitTransport{tr, rateLimitOf(installationID), logger}
+	}
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	mutating, err := isMutatingRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if mutating {
+		t.limit.mutations.Lock()
+		defer t.limit.mutations.Unlock()
+		if wait := mutationInterval - timeNow().Sub(t.limit.lastMutation); wait > 0 {
+			if err := waitFor(req.Context(), wait); err != nil {
+				return nil, err
+			}
+		}
+		defer func() { t.limit.lastMutation = timeNow() }()
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := t.waitForQuota(req); err != nil {
+			return nil, err
+		}
+		resp, err := t.tr.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		if !t.update(resp) || attempt > 1 || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		t.logger.Warn("Request hit the rate limit, retrying", zap.String("method", req.Method), zap.String("path", req.URL.Path))
+		resp.Body.Close()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.WithContext(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// waitForQuota waits until the installation may send requests again
+func (t *rateLimitTransport) waitForQuota(req *http.Request) error {
+	t.limit.mu.Lock()
+	until := t.limit.blockedUntil
+	if t.limit.remaining == 0 && t.limit.reset.After(until) {
+		until = t.limit.reset
+	}
+	t.limit.mu.Unlock()
+
+	wait := until.Sub(timeNow())
+	if wait <= 0 {
+		return nil
+	}
+	if wait > maxRateLimitWait {
+		return errors.Errorf("GitHub rate limit exceeded until %s", until.Format(time.RFC3339))
+	}
+	metrics.Add(rateLimitWaits, 1)
+	t.logger.Info("Waiting for GitHub rate limit", zap.Duration("wait", wait), zap.String("path", req.URL.Path))
+	return waitFor(req.Context(), wait)
+}
+
+// update records the quota reported by a response and returns true if the
+// request has been rejected by a rate limit
+func (t *rateLimitTransport) update(resp *http.Response) bool {
+	t.limit.mu.Lock()
+	defer t.limit.mu.Unlock()
+
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		t.limit.remaining = remaining
+		rateLimitRemaining.WithLabelValues(t.limit.installation).Set(float64(remaining))
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		t.limit.reset = time.Unix(reset, 0)
+	}
+
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+	if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		t.limit.blockedUntil = timeNow().Add(time.Duration(retryAfter) * time.Second)
+		return true
+	}
+	return t.limit.remaining == 0
+}
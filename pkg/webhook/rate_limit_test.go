@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"go.uber.org/zap"
+)
+
+func TestRateLimitTransport(t *testing.T) {
+	now := time.Date(2018, 3, 7, 10, 0, 0, 0, time.UTC)
+	defer func(clock func() time.Time) { timeNow = clock }(timeNow)
+	timeNow = func() time.Time { return now }
+	var waits []time.Duration
+	defer func(wait func(context.Context, time.Duration) error) { waitFor = wait }(waitFor)
+	waitFor = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	reset := strconv.FormatInt(now.Add(10*time.Minute).Unix(), 10)
+	fake := newFakeGitHub(t, map[string]interface{}{
+		getIssue: fakeSequence{
+			fakeResponse{status: http.StatusForbidden, body: map[string]string{"message": "secondary rate limit"}, header: map[string]string{"Retry-After": "30"}},
+			fakeResponse{status: http.StatusOK, body: issueJSON(fixturePR), header: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": reset}},
+		},
+	})
+	defer fake.close()
+	gh := github.NewClient(&http.Client{Transport: rateLimited(42, zap.NewNop())(http.DefaultTransport)})
+	gh.BaseURL = fake.client().BaseURL
+	defer delete(rateLimits, 42)
+
+	// Retried after the secondary rate limit
+	if _, _, err := gh.Issues.Get(context.Background(), "syndesisio", "syndesis-rest", fixturePR); err != nil {
+		t.Fatal(err)
+	}
+	// Waits for the reset of the used up quota
+	if _, _, err := gh.Issues.Get(context.Background(), "syndesisio", "syndesis-rest", fixturePR); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []time.Duration{30 * time.Second, 10 * time.Minute}; !reflect.DeepEqual(waits, expected) {
+		t.Errorf("expected waits %v, got %v", expected, waits)
+	}
+
+	// Mutating requests are paced
+	waits = nil
+	rateLimitOf(42).remaining = -1
+	rateLimitOf(42).blockedUntil = time.Time{}
+	for i := 0; i < 2; i++ {
+		if _, _, err := gh.Issues.AddLabelsToIssue(context.Background(), "syndesisio", "syndesis-rest", fixturePR, []string{"approved"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected := []time.Duration{mutationInterval}; !reflect.DeepEqual(waits, expected) {
+		t.Errorf("expected waits %v, got %v", expected, waits)
+	}
+}
+
+func TestRateLimitExceeded(t *testing.T) {
+	limit := rateLimitOf(43)
+	defer delete(rateLimits, 43)
+	limit.remaining = 0
+	limit.reset = timeNow().Add(time.Hour)
+
+	gh := github.NewClient(&http.Client{Transport: rateLimited(43, zap.NewNop())(http.DefaultTransport)})
+	if _, _, err := gh.Issues.Get(context.Background(), "syndesisio", "syndesis-rest", fixturePR); err == nil {
+		t.Error("expected request to fail instead of waiting an hour")
+	}
+}
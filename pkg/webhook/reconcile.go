@@ -0,0 +1,180 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/github/graphql"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// Reconcile periodically evaluates all open, approved pull requests of all
+// installations, so that PRs whose last webhook delivery got lost are merged
+// nevertheless. It returns when ctx is done or the bot is shutting down.
+func Reconcile(ctx context.Context, cfg config.Config, logger *zap.Logger) {
+	if cfg.ReconcileInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cfg.ReconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		// A shutdown waits for a running reconciliation like for webhooks
+		if !inFlight.begin() {
+			return
+		}
+		logger.Debug("Reconciling approved pull requests")
+		// The interval is kept, but repositories are evaluated with the
+		// current configuration. Stopping the reconciler doesn't abort a
+		// running reconciliation, a shutdown drains it instead.
+		err := reconcile(shutdownCtx, currentConfig(), logger)
+		inFlight.done()
+		if err != nil {
+			logger.Error("Reconciliation failed", zap.Error(err))
+		}
+	}
+}
+
+func reconcile(ctx context.Context, cfg config.Config, logger *zap.Logger) error {
+	return forEachRepository(ctx, cfg, logger, reconcileRepository)
+}
+
+// reconcileInstallation evaluates the approved pull requests of all
+// repositories of an installation which are merged by the bot
+func reconcileInstallation(ctx context.Context, gh *github.Client, cfg config.Config, logger *zap.Logger) error {
+	return forEachInstallationRepository(ctx, gh, cfg, logger, reconcileRepository)
+}
+
+// repositoryFunc is called with each repository of the installations and its
+// configuration
+type repositoryFunc func(ctx context.Context, repo *github.Repository, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error
+
+// forEachRepository calls fn for all repositories of all installations of all
+// apps which aren't disabled by their configuration
+func forEachRepository(ctx context.Context, cfg config.Config, logger *zap.Logger, fn repositoryFunc) error {
+	var multiErr error
+	for _, appCfg := range cfg.Apps() {
+		multiErr = multierr.Combine(multiErr, forEachAppRepository(ctx, appCfg, cfg, logger.With(zap.Int64("app", appCfg.AppID)), fn))
+	}
+	return multiErr
+}
+
+func forEachAppRepository(ctx context.Context, appCfg config.GitHubAppConfig, cfg config.Config, logger *zap.Logger, fn repositoryFunc) error {
+	appClient, err := newAppClient(appCfg)
+	if err != nil {
+		return err
+	}
+
+	var multiErr error
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		installations, resp, err := appClient.Apps.ListInstallations(ctx, opts)
+		if err != nil {
+			return multierr.Combine(multiErr, errors.Wrap(err, "failed to list installations"))
+		}
+		for _, installation := range installations {
+			installationApps.remember(installation.GetID(), appCfg)
+			gh, err := newGitHubClient(appCfg, installation.GetID(), "", logger)
+			if err != nil {
+				multiErr = multierr.Combine(multiErr, err)
+				continue
+			}
+			multiErr = multierr.Combine(multiErr, forEachInstallationRepository(ctx, gh, cfg, logger.With(zap.Int64("installation", installation.GetID())), fn))
+		}
+		if resp.NextPage == 0 {
+			return multiErr
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func forEachInstallationRepository(ctx context.Context, gh *github.Client, cfg config.Config, logger *zap.Logger, fn repositoryFunc) error {
+	var multiErr error
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		repos, resp, err := gh.Apps.ListRepos(ctx, opts)
+		if err != nil {
+			return multierr.Combine(multiErr, errors.Wrap(err, "failed to list repositories of installation"))
+		}
+		for _, repo := range repos {
+			repoConfig, err := withRepoConfigFile(ctx, extractRepoConfigWithDefaults(repo, cfg), repo, gh, logger)
+			if err != nil {
+				multiErr = multierr.Combine(multiErr, err)
+				continue
+			}
+			if repoConfig.Disabled {
+				continue
+			}
+			multiErr = multierr.Combine(multiErr, fn(ctx, repo, gh, *repoConfig, logger))
+		}
+		if resp.NextPage == 0 {
+			return multiErr
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// reconcileRepository evaluates the approved pull requests of a repository
+// whose pull requests are merged by the bot
+func reconcileRepository(ctx context.Context, repo *github.Repository, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if !config.HandlerEnabled("autoMerge") || config.Labels.Approved == "" || config.MergeStrategy == nativeMergeStrategy {
+		return nil
+	}
+	owner, repository := repo.Owner.GetLogin(), repo.GetName()
+	gql := graphql.New(gh)
+	cache := newEvaluationCache()
+
+	var multiErr error
+	opts := &github.IssueListByRepoOptions{
+		State:       "open",
+		Labels:      []string{config.Labels.Approved},
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		issues, resp, err := gh.Issues.ListByRepo(ctx, owner, repository, opts)
+		if err != nil {
+			return multierr.Combine(multiErr, errors.Wrapf(err, "failed to list approved pull requests of %s", repo.GetFullName()))
+		}
+		for _, issue := range issues {
+			if issue.PullRequestLinks == nil {
+				continue
+			}
+			pr, _, err := gh.PullRequests.Get(ctx, owner, repository, issue.GetNumber())
+			if err != nil {
+				multiErr = multierr.Combine(multiErr, errors.Wrapf(err, "failed to get pull request %s", issue.GetHTMLURL()))
+				continue
+			}
+			metrics.Add(mergeEvaluationsPerformed, 1)
+			_, err = mergePR(ctx, issue, pr, owner, repository, gh, gql, "", cache, config, logger)
+			multiErr = multierr.Combine(multiErr, err)
+		}
+		if resp.NextPage == 0 {
+			return multiErr
+		}
+		opts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestReconcileInstallation(t *testing.T) {
+	const (
+		listInstallationRepos = "GET /installation/repositories"
+		listRepoIssues        = "GET " + fixtureRepo + "/issues"
+	)
+	repositories := map[string]interface{}{
+		"total_count": 1,
+		"repositories": []map[string]interface{}{{
+			"name":      "syndesis-rest",
+			"full_name": "syndesisio/syndesis-rest",
+			"owner":     map[string]interface{}{"login": "syndesisio"},
+		}},
+	}
+	botConfig := config.Config{DefaultRepo: autoMergeConfig}
+
+	tests := []struct {
+		name          string
+		config        config.Config
+		responses     map[string]interface{}
+		expectedCalls []string
+	}{
+		{
+			name:   "approved PR merged",
+			config: botConfig,
+			responses: mergeableResponses(map[string]interface{}{
+				listInstallationRepos: repositories,
+				listRepoIssues:        []interface{}{issueJSON(fixturePR, "approved")},
+			}),
+			expectedCalls: []string{mergePullRequest},
+		},
+		{
+			name:   "issues skipped",
+			config: botConfig,
+			responses: map[string]interface{}{
+				listInstallationRepos: repositories,
+				listRepoIssues:        []interface{}{withoutPullRequest(issueJSON(fixturePR, "approved"))},
+			},
+		},
+		{
+			name:   "automerging switched off",
+			config: config.Config{DefaultRepo: autoMergeConfig, Repos: map[string]config.RepoConfig{"syndesis-rest": {Handlers: map[string]bool{"autoMerge": false}}}},
+			responses: map[string]interface{}{
+				listInstallationRepos: repositories,
+			},
+		},
+		{
+			name:   "repository disabled",
+			config: config.Config{DefaultRepo: autoMergeConfig, Repos: map[string]config.RepoConfig{"syndesis-rest": {Disabled: true}}},
+			responses: map[string]interface{}{
+				listInstallationRepos: repositories,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			if err := reconcileInstallation(context.Background(), fake.client(), test.config, zap.NewNop()); err != nil {
+				t.Errorf("reconciliation failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func withoutPullRequest(issue map[string]interface{}) map[string]interface{} {
+	delete(issue, "pull_request")
+	return issue
+}
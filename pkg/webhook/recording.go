@@ -0,0 +1,133 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/audit"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/notify"
+)
+
+// Recording is a webhook delivery as it has been received, for replaying it
+// when debugging the bot's decisions
+type Recording struct {
+	DeliveryID string      `json:"deliveryId"`
+	Event      string      `json:"event"`
+	Received   time.Time   `json:"received"`
+	Headers    http.Header `json:"headers"`
+	// The raw body, as it has been signed
+	Payload string `json:"payload"`
+}
+
+// recordDelivery writes a validated webhook delivery to the record
+// directory, named after its delivery ID
+func recordDelivery(dir string, r *http.Request, payload []byte) error {
+	rec := Recording{
+		DeliveryID: r.Header.Get(deliveryHeader),
+		Event:      r.Header.Get("X-GitHub-Event"),
+		Received:   time.Now(),
+		Headers:    r.Header,
+		Payload:    string(payload),
+	}
+	name := rec.DeliveryID
+	if name == "" {
+		name = strconv.FormatInt(rec.Received.UnixNano(), 10)
+	}
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode recording")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrapf(err, "failed to create record directory %s", dir)
+	}
+	file := filepath.Join(dir, name+".json")
+	return errors.Wrapf(ioutil.WriteFile(file, data, 0600), "failed to write recording %s", file)
+}
+
+// LoadRecording reads a recorded delivery. The argument is either the path
+// of a recording or the delivery ID of one in the record directory.
+func LoadRecording(fileOrDeliveryID string, recordDir string) (*Recording, error) {
+	file := fileOrDeliveryID
+	if _, err := os.Stat(file); os.IsNotExist(err) && recordDir != "" {
+		file = filepath.Join(recordDir, fileOrDeliveryID+".json")
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read recording")
+	}
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse recording %s", file)
+	}
+	return &rec, nil
+}
+
+// Replay feeds a recorded delivery through the handlers, like when it was
+// received, and waits for the actions they schedule
+func Replay(rec *Recording, cfg config.Config, logger *zap.Logger) error {
+	logger.Info("Replaying delivery", zap.String("delivery", rec.DeliveryID), zap.String("event", rec.Event), zap.Time("received", rec.Received))
+	return processLocally(rec.Event, "replay-"+rec.DeliveryID, []byte(rec.Payload), cfg, logger)
+}
+
+// processLocally handles a delivery outside of the webhook server and waits
+// for the actions the handlers schedule
+func processLocally(eventType string, deliveryID string, payload []byte, cfg config.Config, logger *zap.Logger) error {
+	if err := initLocally(cfg, logger); err != nil {
+		return err
+	}
+	err := handleLocalDelivery(eventType, deliveryID, payload, logger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.DrainTimeout)
+	defer cancel()
+	if shutdownErr := Shutdown(ctx); shutdownErr != nil {
+		logger.Error("failed to wait for scheduled actions", zap.Error(shutdownErr))
+	}
+	return err
+}
+
+// initLocally sets up processing deliveries outside of the webhook server
+func initLocally(cfg config.Config, logger *zap.Logger) error {
+	var err error
+	if auditLog, err = audit.New(cfg.Audit, logger.Named("audit")); err != nil {
+		return err
+	}
+	notifier = notify.New(cfg.Notifications, logger.Named("notify"))
+	slack = notify.NewSlack(cfg.Slack, logger.Named("slack"))
+	matrix = notify.NewMatrix(cfg.Matrix, logger.Named("matrix"))
+	UpdateConfig(cfg)
+	return nil
+}
+
+func handleLocalDelivery(eventType string, deliveryID string, payload []byte, logger *zap.Logger) error {
+	if !inFlight.begin() {
+		return errors.New("shutting down")
+	}
+	defer inFlight.done()
+	return handleDelivery(eventType, deliveryID, payload, logger)
+}
@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pure-bot-recordings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	payload := `{"action":"labeled"}`
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+	r.Header.Set(deliveryHeader, "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	r.Header.Set("X-GitHub-Event", "pull_request")
+	if err := recordDelivery(dir, r, []byte(payload)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, arg := range []string{"72d3162e-cc78-11e3-81ab-4c9367dc0958", filepath.Join(dir, "72d3162e-cc78-11e3-81ab-4c9367dc0958.json")} {
+		rec, err := LoadRecording(arg, dir)
+		if err != nil {
+			t.Fatalf("%s: %v", arg, err)
+		}
+		if rec.Event != "pull_request" || rec.DeliveryID != "72d3162e-cc78-11e3-81ab-4c9367dc0958" || string(rec.Payload) != payload {
+			t.Errorf("%s: unexpected recording %+v", arg, rec)
+		}
+	}
+
+	if _, err := LoadRecording("unknown", dir); err == nil {
+		t.Error("expected unknown delivery to fail")
+	}
+}
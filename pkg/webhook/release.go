@@ -0,0 +1,213 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSemverLabelPrefix = "semver/"
+	defaultVersionTagPrefix  = "v"
+)
+
+// Version bumps, most significant first
+var semverBumps = []string{"major", "minor", "patch"}
+
+var semverRE = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)$`)
+
+// semver is a released version, pre-releases aren't considered
+type semver [3]int
+
+func parseSemver(s string) (semver, bool) {
+	match := semverRE.FindStringSubmatch(s)
+	if match == nil {
+		return semver{}, false
+	}
+	var v semver
+	for i := range v {
+		v[i], _ = strconv.Atoi(match[i+1])
+	}
+	return v, true
+}
+
+func (v semver) less(other semver) bool {
+	for i := range v {
+		if v[i] != other[i] {
+			return v[i] < other[i]
+		}
+	}
+	return false
+}
+
+// bump returns the next version, resetting the less significant parts
+func (v semver) bump(kind string) semver {
+	for i, b := range semverBumps {
+		if b == kind {
+			next := semver{}
+			copy(next[:i], v[:i])
+			next[i] = v[i] + 1
+			return next
+		}
+	}
+	return v
+}
+
+func (v semver) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// semverRelease tags and releases the next version when a pull request
+// labeled with the kind of version bump is merged into the default branch.
+// The release notes are those drafted by the releaseNotes handler if it is
+// enabled, otherwise just the merged pull request.
+type semverRelease struct{}
+
+func (h *semverRelease) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *semverRelease) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if !config.Release.Enabled {
+		return nil
+	}
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	pr := event.PullRequest
+	if strings.ToLower(event.GetAction()) != "closed" || !pr.GetMerged() || pr.Base.GetRef() != event.Repo.GetDefaultBranch() {
+		return nil
+	}
+	bump := releaseBump(config.Release, pr.Labels)
+	if bump == "" {
+		return nil
+	}
+
+	section, entry, err := releaseNotesEntry(config.ReleaseNotes, pr)
+	if err != nil {
+		return err
+	}
+	categories := releaseNotesCategories(config.ReleaseNotes)
+	notes := func(body string) string {
+		if releaseNotesExcluded(config.ReleaseNotes, pr.Labels) {
+			return body
+		}
+		return addReleaseNotesEntry(body, categories, section, entry)
+	}
+
+	releaseNotesMu.Lock()
+	defer releaseNotesMu.Unlock()
+
+	owner, repository := event.Repo.Owner.GetLogin(), event.Repo.GetName()
+	tagPrefix := config.Release.TagPrefix
+	if tagPrefix == "" {
+		tagPrefix = defaultVersionTagPrefix
+	}
+	latest, released, err := latestVersion(ctx, gh, owner, repository, tagPrefix, pr.GetMergeCommitSHA())
+	if err != nil {
+		return err
+	}
+	if released {
+		logger.Debug("Merge commit is released already", zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+	tag := tagPrefix + latest.bump(bump).String()
+
+	release := &github.RepositoryRelease{
+		TagName:         github.String(tag),
+		TargetCommitish: github.String(pr.GetMergeCommitSHA()),
+		Name:            github.String(tag),
+		Draft:           github.Bool(false),
+	}
+	if config.ReleaseNotes.Enabled {
+		draft, err := findDraftRelease(ctx, gh, owner, repository, draftReleaseName(config.ReleaseNotes))
+		if err != nil {
+			return err
+		}
+		if draft != nil {
+			release.Body = github.String(notes(draft.GetBody()))
+			if _, _, err := gh.Repositories.EditRelease(ctx, owner, repository, draft.GetID(), release); err != nil {
+				return errors.Wrapf(err, "failed to publish draft release as %s of %s/%s", tag, owner, repository)
+			}
+			logger.Info("Published draft release", zap.String("tag", tag), zap.Int("pr", pr.GetNumber()))
+			return nil
+		}
+	}
+
+	release.Body = github.String(notes(""))
+	if _, _, err := gh.Repositories.CreateRelease(ctx, owner, repository, release); err != nil {
+		return errors.Wrapf(err, "failed to create release %s of %s/%s", tag, owner, repository)
+	}
+	logger.Info("Created release", zap.String("tag", tag), zap.Int("pr", pr.GetNumber()))
+	return nil
+}
+
+// releaseBump returns the most significant version bump the labels ask for,
+// an empty string if none
+func releaseBump(cfg config.ReleaseConfig, labels []*github.Label) string {
+	prefix := cfg.LabelPrefix
+	if prefix == "" {
+		prefix = defaultSemverLabelPrefix
+	}
+	for _, bump := range semverBumps {
+		if labelsContainsLabel(labels, prefix+bump) {
+			return bump
+		}
+	}
+	return ""
+}
+
+// latestVersion returns the highest version tagged in the repository, and
+// whether a version tag points to the given commit already
+func latestVersion(ctx context.Context, gh *github.Client, owner, repository, tagPrefix, sha string) (semver, bool, error) {
+	var latest semver
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		tags, resp, err := gh.Repositories.ListTags(ctx, owner, repository, opts)
+		if err != nil {
+			return latest, false, errors.Wrapf(err, "failed to list tags of %s/%s", owner, repository)
+		}
+		for _, tag := range tags {
+			if !strings.HasPrefix(tag.GetName(), tagPrefix) {
+				continue
+			}
+			version, ok := parseSemver(strings.TrimPrefix(tag.GetName(), tagPrefix))
+			if !ok {
+				continue
+			}
+			if tag.Commit.GetSHA() == sha {
+				return version, true, nil
+			}
+			if latest.less(version) {
+				latest = version
+			}
+		}
+		if resp.NextPage == 0 {
+			return latest, false, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
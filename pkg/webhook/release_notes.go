@@ -0,0 +1,240 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultReleaseName          = "Next release"
+	defaultReleaseNotesTemplate = "- {{ .Title }} (#{{ .Number }}) @{{ .Author }}"
+	otherChangesTitle           = "Other changes"
+)
+
+var defaultReleaseNotesCategories = []config.ReleaseNotesCategory{
+	{Title: "Features", Labels: []string{"feature", "enhancement", "kind/feature"}},
+	{Title: "Bug fixes", Labels: []string{"bug", "fix", "kind/bug"}},
+	{Title: "Chores", Labels: []string{"chore", "dependencies", "kind/chore"}},
+}
+
+// Serializes the updates of the draft releases, so that pull requests merged
+// at the same time don't overwrite each other's entries
+var releaseNotesMu sync.Mutex
+
+// releaseNotes adds pull requests merged into the default branch to a draft
+// release, so that cutting a release means publishing the draft
+type releaseNotes struct{}
+
+func (h *releaseNotes) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *releaseNotes) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	cfg := config.ReleaseNotes
+	if !cfg.Enabled {
+		return nil
+	}
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	pr := event.PullRequest
+	if strings.ToLower(event.GetAction()) != "closed" || !pr.GetMerged() || pr.Base.GetRef() != event.Repo.GetDefaultBranch() {
+		return nil
+	}
+	if releaseNotesExcluded(cfg, pr.Labels) {
+		logger.Debug("Leaving pull request out of the release notes", zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+
+	// Releasing publishes the draft with the entry added, a new draft would
+	// list the pull request again
+	if config.Release.Enabled && releaseBump(config.Release, pr.Labels) != "" {
+		return nil
+	}
+
+	section, entry, err := releaseNotesEntry(cfg, pr)
+	if err != nil {
+		return err
+	}
+
+	releaseNotesMu.Lock()
+	defer releaseNotesMu.Unlock()
+
+	owner, repository := event.Repo.Owner.GetLogin(), event.Repo.GetName()
+	name := draftReleaseName(cfg)
+	draft, err := findDraftRelease(ctx, gh, owner, repository, name)
+	if err != nil {
+		return err
+	}
+	categories := releaseNotesCategories(cfg)
+	if draft == nil {
+		body := addReleaseNotesEntry("", categories, section, entry)
+		_, _, err := gh.Repositories.CreateRelease(ctx, owner, repository, &github.RepositoryRelease{
+			TagName:         github.String(strings.ToLower(strings.Replace(name, " ", "-", -1))),
+			TargetCommitish: github.String(event.Repo.GetDefaultBranch()),
+			Name:            github.String(name),
+			Body:            github.String(body),
+			Draft:           github.Bool(true),
+		})
+		return errors.Wrapf(err, "failed to create draft release %s of %s/%s", name, owner, repository)
+	}
+
+	body := addReleaseNotesEntry(draft.GetBody(), categories, section, entry)
+	if body == draft.GetBody() {
+		return nil
+	}
+	_, _, err = gh.Repositories.EditRelease(ctx, owner, repository, draft.GetID(), &github.RepositoryRelease{Body: github.String(body)})
+	return errors.Wrapf(err, "failed to update draft release %s of %s/%s", name, owner, repository)
+}
+
+// releaseNotesEntry renders the entry of a pull request and returns the
+// title of the section it belongs to
+func releaseNotesEntry(cfg config.ReleaseNotesConfig, pr *github.PullRequest) (section string, entry string, err error) {
+	tmpl := cfg.Template
+	if tmpl == "" {
+		tmpl = defaultReleaseNotesTemplate
+	}
+	entry, err = renderTemplate("releaseNotes", tmpl, mergeCommitData{
+		Title:  pr.GetTitle(),
+		Number: pr.GetNumber(),
+		Body:   pr.GetBody(),
+		Author: pr.User.GetLogin(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return releaseNotesSection(releaseNotesCategories(cfg), pr.Labels), strings.TrimSpace(entry), nil
+}
+
+func releaseNotesExcluded(cfg config.ReleaseNotesConfig, labels []*github.Label) bool {
+	for _, label := range cfg.ExcludeLabels {
+		if labelsContainsLabel(labels, label) {
+			return true
+		}
+	}
+	return false
+}
+
+func releaseNotesCategories(cfg config.ReleaseNotesConfig) []config.ReleaseNotesCategory {
+	if len(cfg.Categories) == 0 {
+		return defaultReleaseNotesCategories
+	}
+	return cfg.Categories
+}
+
+func draftReleaseName(cfg config.ReleaseNotesConfig) string {
+	if cfg.Name == "" {
+		return defaultReleaseName
+	}
+	return cfg.Name
+}
+
+// findDraftRelease returns the draft release with the given name, nil if
+// there is none. Drafts are listed before the published releases.
+func findDraftRelease(ctx context.Context, gh *github.Client, owner, repository, name string) (*github.RepositoryRelease, error) {
+	releases, _, err := gh.Repositories.ListReleases(ctx, owner, repository, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list releases of %s/%s", owner, repository)
+	}
+	for _, release := range releases {
+		if release.GetDraft() && release.GetName() == name {
+			return release, nil
+		}
+	}
+	return nil, nil
+}
+
+// releaseNotesSection returns the title of the first category with one of
+// the labels
+func releaseNotesSection(categories []config.ReleaseNotesCategory, labels []*github.Label) string {
+	for _, category := range categories {
+		for _, label := range category.Labels {
+			if labelsContainsLabel(labels, label) {
+				return category.Title
+			}
+		}
+	}
+	return otherChangesTitle
+}
+
+// addReleaseNotesEntry appends an entry to a section of the release notes.
+// Missing sections are added in the order of the categories, "Other changes"
+// last. Text written by hand is kept, entries present already aren't added
+// again.
+func addReleaseNotesEntry(body string, categories []config.ReleaseNotesCategory, section string, entry string) string {
+	lines := strings.Split(strings.Replace(body, "\r\n", "\n", -1), "\n")
+	for _, line := range lines {
+		if strings.TrimSpace(line) == entry {
+			return body
+		}
+	}
+
+	order := make(map[string]int, len(categories)+1)
+	for i, category := range categories {
+		order[category.Title] = i
+	}
+	order[otherChangesTitle] = len(categories)
+
+	heading := "## " + section
+	for i, line := range lines {
+		if strings.TrimSpace(line) != heading {
+			continue
+		}
+		// After the last non-blank line of the section
+		end := i + 1
+		for j := i + 1; j < len(lines) && !strings.HasPrefix(lines[j], "## "); j++ {
+			if strings.TrimSpace(lines[j]) != "" {
+				end = j + 1
+			}
+		}
+		return joinLines(lines[:end], []string{entry}, lines[end:])
+	}
+
+	if strings.TrimSpace(body) == "" {
+		return heading + "\n" + entry + "\n"
+	}
+	insert := len(lines)
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "## ") {
+			continue
+		}
+		if position, known := order[strings.TrimSpace(line[3:])]; known && position > order[section] {
+			insert = i
+			break
+		}
+	}
+	if insert == len(lines) {
+		return strings.TrimRight(body, "\n") + "\n\n" + heading + "\n" + entry + "\n"
+	}
+	return joinLines(lines[:insert], []string{heading, entry, ""}, lines[insert:])
+}
+
+func joinLines(parts ...[]string) string {
+	var lines []string
+	for _, part := range parts {
+		lines = append(lines, part...)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestAddReleaseNotesEntry(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		section  string
+		expected string
+	}{
+		{
+			name:     "empty draft",
+			section:  "Bug fixes",
+			expected: "## Bug fixes\n- Fix sync (#2)\n",
+		},
+		{
+			name:     "existing section",
+			body:     "Highlights go here.\n\n## Bug fixes\n- Fix login (#1)\n\n## Other changes\n- Docs (#3)\n",
+			section:  "Bug fixes",
+			expected: "Highlights go here.\n\n## Bug fixes\n- Fix login (#1)\n- Fix sync (#2)\n\n## Other changes\n- Docs (#3)\n",
+		},
+		{
+			name:     "section inserted in order",
+			body:     "## Features\n- Labels (#1)\n\n## Other changes\n- Docs (#3)\n",
+			section:  "Bug fixes",
+			expected: "## Features\n- Labels (#1)\n\n## Bug fixes\n- Fix sync (#2)\n\n## Other changes\n- Docs (#3)\n",
+		},
+		{
+			name:     "section appended",
+			body:     "## Features\n- Labels (#1)",
+			section:  "Bug fixes",
+			expected: "## Features\n- Labels (#1)\n\n## Bug fixes\n- Fix sync (#2)\n",
+		},
+		{
+			name:     "entry present",
+			body:     "## Features\n- Fix sync (#2)\n",
+			section:  "Bug fixes",
+			expected: "## Features\n- Fix sync (#2)\n",
+		},
+	}
+	for _, test := range tests {
+		if body := addReleaseNotesEntry(test.body, defaultReleaseNotesCategories, test.section, "- Fix sync (#2)"); body != test.expected {
+			t.Errorf("%s: expected\n%q\ngot\n%q", test.name, test.expected, body)
+		}
+	}
+}
+
+func TestReleaseNotes(t *testing.T) {
+	listReleases := "GET " + fixtureRepo + "/releases"
+	createRelease := "POST " + fixtureRepo + "/releases"
+	editRelease := "PATCH " + fixtureRepo + "/releases/7"
+	notesConfig := config.RepoConfig{ReleaseNotes: config.ReleaseNotesConfig{Enabled: true, ExcludeLabels: []string{"skip-changelog"}}}
+	published := map[string]interface{}{"id": 6, "name": "v1.3.0", "draft": false, "body": "## Features\n- Old (#1)\n"}
+
+	tests := []struct {
+		name          string
+		base          string
+		labels        []string
+		config        config.RepoConfig
+		responses     map[string]interface{}
+		expectedCalls []string
+		expectedBody  string
+	}{
+		{
+			name:   "draft created",
+			labels: []string{"kind/bug"},
+			config: notesConfig,
+			responses: map[string]interface{}{
+				listReleases: []interface{}{published},
+			},
+			expectedCalls: []string{createRelease},
+			expectedBody:  "## Bug fixes\n- Fix label sync (#276) @roland\n",
+		},
+		{
+			name:   "draft updated",
+			config: notesConfig,
+			responses: map[string]interface{}{
+				listReleases: []interface{}{
+					map[string]interface{}{"id": 7, "name": "Next release", "draft": true, "body": "## Features\n- Size labels (#270) @rhuss\n"},
+					published,
+				},
+			},
+			expectedCalls: []string{editRelease},
+			expectedBody:  "## Features\n- Size labels (#270) @rhuss\n\n## Other changes\n- Fix label sync (#276) @roland\n",
+		},
+		{
+			name:   "excluded",
+			labels: []string{"skip-changelog"},
+			config: notesConfig,
+		},
+		{
+			name:   "released right away",
+			labels: []string{"semver/minor"},
+			config: config.RepoConfig{ReleaseNotes: notesConfig.ReleaseNotes, Release: config.ReleaseConfig{Enabled: true}},
+		},
+		{
+			name:   "merged into other branch",
+			base:   "release-1.3",
+			config: notesConfig,
+		},
+		{
+			name: "not enabled",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			event := mergedPullRequestEvent("closed", test.labels...)
+			event.Repo.DefaultBranch = github.String("master")
+			event.PullRequest.User = &github.User{Login: github.String("roland")}
+			event.PullRequest.Base = &github.PullRequestBranch{Ref: github.String("master")}
+			if test.base != "" {
+				event.PullRequest.Base.Ref = github.String(test.base)
+			}
+			if err := (&releaseNotes{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("updating release notes failed: %+v", err)
+			}
+			calls := fake.mutatingCalls()
+			if !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Fatalf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if len(calls) == 0 {
+				return
+			}
+			var release struct {
+				Name  string `json:"name"`
+				Body  string `json:"body"`
+				Draft bool   `json:"draft"`
+			}
+			if err := fake.requestBody(calls[0], &release); err != nil {
+				t.Fatal(err)
+			}
+			if release.Body != test.expectedBody {
+				t.Errorf("expected body\n%q\ngot\n%q", test.expectedBody, release.Body)
+			}
+			if calls[0] == createRelease && (release.Name != "Next release" || !release.Draft) {
+				t.Errorf("expected draft release named Next release, got %+v", release)
+			}
+		})
+	}
+}
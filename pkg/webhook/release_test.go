@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestSemverBump(t *testing.T) {
+	tests := []struct {
+		version  string
+		bump     string
+		expected string
+	}{
+		{"1.2.3", "patch", "1.2.4"},
+		{"1.2.3", "minor", "1.3.0"},
+		{"1.2.3", "major", "2.0.0"},
+		{"0.9.12", "minor", "0.10.0"},
+	}
+	for _, test := range tests {
+		version, ok := parseSemver(test.version)
+		if !ok {
+			t.Fatalf("failed to parse %s", test.version)
+		}
+		if next := version.bump(test.bump).String(); next != test.expected {
+			t.Errorf("expected %s bump of %s to be %s, got %s", test.bump, test.version, test.expected, next)
+		}
+	}
+	for _, invalid := range []string{"1.2", "1.2.3-rc1", "v1.2.3"} {
+		if _, ok := parseSemver(invalid); ok {
+			t.Errorf("expected %s not to be a release version", invalid)
+		}
+	}
+}
+
+func TestSemverRelease(t *testing.T) {
+	listTags := "GET " + fixtureRepo + "/tags"
+	listReleases := "GET " + fixtureRepo + "/releases"
+	createRelease := "POST " + fixtureRepo + "/releases"
+	editRelease := "PATCH " + fixtureRepo + "/releases/7"
+	tags := []interface{}{
+		map[string]interface{}{"name": "v1.2.3", "commit": map[string]interface{}{"sha": "1e2d3c4"}},
+		map[string]interface{}{"name": "v1.10.0", "commit": map[string]interface{}{"sha": "5a69788"}},
+		map[string]interface{}{"name": "v2.0.0-rc1", "commit": map[string]interface{}{"sha": "796a5b4"}},
+		map[string]interface{}{"name": "nightly", "commit": map[string]interface{}{"sha": "c3d2e1f"}},
+	}
+	releaseConfig := config.RepoConfig{Release: config.ReleaseConfig{Enabled: true}}
+	withNotes := config.RepoConfig{Release: releaseConfig.Release, ReleaseNotes: config.ReleaseNotesConfig{Enabled: true}}
+
+	tests := []struct {
+		name          string
+		labels        []string
+		config        config.RepoConfig
+		responses     map[string]interface{}
+		expectedCalls []string
+		expectedTag   string
+		expectedBody  string
+	}{
+		{
+			name:          "minor release",
+			labels:        []string{"semver/minor", "kind/feature"},
+			config:        releaseConfig,
+			responses:     map[string]interface{}{listTags: tags},
+			expectedCalls: []string{createRelease},
+			expectedTag:   "v1.11.0",
+			expectedBody:  "## Features\n- Fix label sync (#276) @roland\n",
+		},
+		{
+			name:   "draft published",
+			labels: []string{"semver/patch", "semver/major"},
+			config: withNotes,
+			responses: map[string]interface{}{
+				listTags: tags,
+				listReleases: []interface{}{
+					map[string]interface{}{"id": 7, "name": "Next release", "draft": true, "body": "## Features\n- Size labels (#270) @rhuss\n"},
+				},
+			},
+			expectedCalls: []string{editRelease},
+			expectedTag:   "v2.0.0",
+			expectedBody:  "## Features\n- Size labels (#270) @rhuss\n\n## Other changes\n- Fix label sync (#276) @roland\n",
+		},
+		{
+			name:   "first release",
+			labels: []string{"semver/patch"},
+			config: config.RepoConfig{Release: config.ReleaseConfig{Enabled: true, TagPrefix: "release-"}},
+			responses: map[string]interface{}{
+				listTags: tags,
+			},
+			expectedCalls: []string{createRelease},
+			expectedTag:   "release-0.0.1",
+			expectedBody:  "## Other changes\n- Fix label sync (#276) @roland\n",
+		},
+		{
+			name:   "released already",
+			labels: []string{"semver/minor"},
+			config: releaseConfig,
+			responses: map[string]interface{}{
+				listTags: append(tags, map[string]interface{}{"name": "v1.11.0", "commit": map[string]interface{}{"sha": mergeSHA}}),
+			},
+		},
+		{
+			name:   "no version label",
+			labels: []string{"kind/feature"},
+			config: releaseConfig,
+		},
+		{
+			name:   "not enabled",
+			labels: []string{"semver/minor"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			event := mergedPullRequestEvent("closed", test.labels...)
+			event.Repo.DefaultBranch = github.String("master")
+			event.PullRequest.User = &github.User{Login: github.String("roland")}
+			event.PullRequest.Base = &github.PullRequestBranch{Ref: github.String("master")}
+			if err := (&semverRelease{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("release failed: %+v", err)
+			}
+			calls := fake.mutatingCalls()
+			if !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Fatalf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if len(calls) == 0 {
+				return
+			}
+			var release struct {
+				TagName         string `json:"tag_name"`
+				TargetCommitish string `json:"target_commitish"`
+				Body            string `json:"body"`
+				Draft           bool   `json:"draft"`
+			}
+			if err := fake.requestBody(calls[0], &release); err != nil {
+				t.Fatal(err)
+			}
+			if release.TagName != test.expectedTag || release.TargetCommitish != mergeSHA || release.Draft {
+				t.Errorf("expected release %s of %s, got %+v", test.expectedTag, mergeSHA, release)
+			}
+			if release.Body != test.expectedBody {
+				t.Errorf("expected body\n%q\ngot\n%q", test.expectedBody, release.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/imdario/mergo"
+	"github.com/pkg/errors"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+// How long the configuration file of a repository is cached. Pushes to the
+// default branch drop it from the cache right away.
+var repoConfigFileTTL = 5 * time.Minute
+
+type repoConfigFileEntry struct {
+	// nil if the repository has no configuration file
+	config  *config.RepoConfig
+	fetched time.Time
+}
+
+var repoConfigFiles = struct {
+	sync.Mutex
+	entries map[string]repoConfigFileEntry
+}{entries: make(map[string]repoConfigFileEntry)}
+
+// withRepoConfigFile merges the configuration file of a repository over the
+// server-side configuration. An invalid file is reported and ignored, so that
+// a broken commit doesn't stop the bot for the repository.
+func withRepoConfigFile(ctx context.Context, repoConfig *config.RepoConfig, repo *github.Repository, gh *github.Client, logger *zap.Logger) (*config.RepoConfig, error) {
+	if repo == nil {
+		return repoConfig, nil
+	}
+	fileConfig, err := loadRepoConfigFile(ctx, repo, gh, logger)
+	if err != nil || fileConfig == nil {
+		return repoConfig, err
+	}
+
+	if err := mergo.Merge(repoConfig, *fileConfig, mergo.WithOverride); err != nil {
+		return nil, errors.Wrapf(err, "failed to merge %s of %s", config.RepoConfigFile, repo.GetFullName())
+	}
+	return repoConfig, nil
+}
+
+// loadRepoConfigFile returns the parsed configuration file from the default
+// branch of a repository, nil if there is none
+func loadRepoConfigFile(ctx context.Context, repo *github.Repository, gh *github.Client, logger *zap.Logger) (*config.RepoConfig, error) {
+	fullName := repo.GetFullName()
+	repoConfigFiles.Lock()
+	entry, found := repoConfigFiles.entries[fullName]
+	repoConfigFiles.Unlock()
+	if found && timeNow().Sub(entry.fetched) < repoConfigFileTTL {
+		return entry.config, nil
+	}
+
+	content, err := getFileContent(ctx, gh, repo.Owner.GetLogin(), repo.GetName(), "", config.RepoConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	entry = repoConfigFileEntry{fetched: timeNow()}
+	if content != "" {
+		fileConfig, err := config.ParseRepoConfig([]byte(content))
+		if err == nil {
+			err = validateRepoConfig(config.RepoConfigFile, fileConfig)
+		}
+		if err != nil {
+			logger.Warn("Ignoring invalid configuration file", zap.String("repo", fullName), zap.String("file", config.RepoConfigFile), zap.Error(err))
+		} else {
+			entry.config = &fileConfig
+		}
+	}
+
+	repoConfigFiles.Lock()
+	repoConfigFiles.entries[fullName] = entry
+	repoConfigFiles.Unlock()
+	return entry.config, nil
+}
+
+// forgetRepoConfigFile drops the cached configuration file of a repository
+// when its default branch changes
+func forgetRepoConfigFile(event *pushEvent) {
+	if event.Repo == nil || event.Ref != "refs/heads/"+event.Repo.GetDefaultBranch() {
+		return
+	}
+	repoConfigFiles.Lock()
+	delete(repoConfigFiles.entries, event.Repo.GetFullName())
+	repoConfigFiles.Unlock()
+}
@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestWithRepoConfigFile(t *testing.T) {
+	const configFile = "GET " + fixtureRepo + "/contents/" + config.RepoConfigFile
+	repo := &github.Repository{
+		Name:          github.String("syndesis-rest"),
+		FullName:      github.String("syndesisio/syndesis-rest"),
+		Owner:         &github.User{Login: github.String("syndesisio")},
+		DefaultBranch: github.String("master"),
+	}
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	gh := newFakeGitHub(t, map[string]interface{}{
+		configFile: fakeSequence{
+			fileContentJSON("labels:\n  approved: lgtm\nmergeMethod: rebase\nhandlers:\n  wip: false\n"),
+			fileContentJSON("labels: [ broken"),
+		},
+	})
+	defer gh.close()
+	forgetRepoConfigFile(&pushEvent{Ref: "refs/heads/master", Repo: repo})
+	defer forgetRepoConfigFile(&pushEvent{Ref: "refs/heads/master", Repo: repo})
+
+	serverConfig := func() *config.RepoConfig {
+		return &config.RepoConfig{Labels: config.LabelConfig{Approved: "approved", Hold: "hold"}, MergeMethod: "squash"}
+	}
+	c, err := withRepoConfigFile(context.Background(), serverConfig(), repo, gh.client(), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Labels.Approved != "lgtm" || c.Labels.Hold != "hold" || c.MergeMethod != "rebase" || c.HandlerEnabled("wip") {
+		t.Errorf("expected configuration file merged over server config, got %+v", c)
+	}
+
+	// Cached until the default branch changes
+	if c, _ := withRepoConfigFile(context.Background(), serverConfig(), repo, gh.client(), zap.NewNop()); c.Labels.Approved != "lgtm" {
+		t.Errorf("expected cached configuration file, got %+v", c)
+	}
+	forgetRepoConfigFile(&pushEvent{Ref: "refs/heads/feature", Repo: repo})
+	if c, _ := withRepoConfigFile(context.Background(), serverConfig(), repo, gh.client(), zap.NewNop()); c.Labels.Approved != "lgtm" {
+		t.Errorf("expected pushes to other branches to keep the cache, got %+v", c)
+	}
+
+	// Invalid files are ignored
+	forgetRepoConfigFile(&pushEvent{Ref: "refs/heads/master", Repo: repo})
+	c, err = withRepoConfigFile(context.Background(), serverConfig(), repo, gh.client(), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Labels.Approved != "approved" || c.MergeMethod != "squash" {
+		t.Errorf("expected invalid configuration file to be ignored, got %+v", c)
+	}
+}
+
+func TestParseRepoConfigRejectsServerSettings(t *testing.T) {
+	tests := map[string]string{
+		"mergePolicy":      "mergePolicy:\n  url: http://169.254.169.254/\n",
+		"cla.url":          "cla:\n  url: http://internal/\n",
+		"board":            "board:\n  zenhub_token: secret\n",
+		"slackChannel":     "slackChannel: \"#general\"\n",
+		"branchProtection": "branchProtection:\n  requiredApprovals: 0\n",
+		"unknown":          "mergeWindow: []\n",
+	}
+	for name, content := range tests {
+		if _, err := config.ParseRepoConfig([]byte(content)); err == nil {
+			t.Errorf("%s: expected the file to be rejected", name)
+		}
+	}
+
+	c, err := config.ParseRepoConfig([]byte("cla:\n  allowlistFile: CONTRIBUTORS\nlabels:\n  approved: lgtm\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CLA.AllowlistFile != "CONTRIBUTORS" || c.Labels.Approved != "lgtm" {
+		t.Errorf("expected repository settings to be parsed, got %+v", c)
+	}
+}
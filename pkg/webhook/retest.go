@@ -0,0 +1,128 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+var retestCommand = Command{
+	Help:            "Re-runs the failed checks of the pull request",
+	Permission:      "read",
+	PullRequestOnly: true,
+	Run:             runRetest,
+}
+
+// runRetest re-requests the failed check suites of the head commit. Commit
+// statuses can't be re-run through the API, failed ones are reset to pending
+// so that the pull request waits for the CI reacting to the comment.
+func runRetest(ctx context.Context, cmd CommandEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	owner, repository := cmd.Owner(), cmd.Repo()
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repository, cmd.Number())
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pull request %s/%s#%d", owner, repository, cmd.Number())
+	}
+	sha := pr.Head.GetSHA()
+
+	suites, err := failedCheckSuites(ctx, gh, owner, repository, sha)
+	if err != nil {
+		return err
+	}
+	statuses, err := newEvaluationCache().combinedStatus(ctx, gh, owner, repository, sha)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get statuses of %s/%s@%s", owner, repository, sha)
+	}
+
+	var rerun []string
+	for _, suite := range suites {
+		if err := rerequestCheckSuite(ctx, gh, owner, repository, suite.GetID()); err != nil {
+			return errors.Wrapf(err, "failed to re-run check suite %d of %s", suite.GetID(), pr.GetHTMLURL())
+		}
+		rerun = append(rerun, suite.App.GetName())
+	}
+
+	var reset []string
+	description := "Retest requested by @" + cmd.Commenter()
+	for _, status := range statuses.Statuses {
+		state := strings.ToLower(status.GetState())
+		if state != string(failureStatus) && state != "error" {
+			continue
+		}
+		_, _, resetErr := gh.Repositories.CreateStatus(ctx, owner, repository, sha, &github.RepoStatus{
+			State:       github.String(string(pendingStatus)),
+			Context:     status.Context,
+			TargetURL:   status.TargetURL,
+			Description: &description,
+		})
+		if resetErr != nil {
+			err = multierr.Combine(err, errors.Wrapf(resetErr, "failed to reset status %s of %s", status.GetContext(), pr.GetHTMLURL()))
+			continue
+		}
+		reset = append(reset, status.GetContext())
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Retesting pull request", zap.Strings("checkSuites", rerun), zap.Strings("statuses", reset))
+	return cmd.Reply(ctx, gh, retestReply(rerun, reset))
+}
+
+// failedCheckSuites returns the completed check suites of a commit which
+// didn't succeed
+func failedCheckSuites(ctx context.Context, gh *github.Client, owner, repository, sha string) ([]*github.CheckSuite, error) {
+	var failed []*github.CheckSuite
+	opts := &github.ListCheckSuiteOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := gh.Checks.ListCheckSuitesForRef(ctx, owner, repository, sha, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list check suites of %s/%s@%s", owner, repository, sha)
+		}
+		for _, suite := range page.CheckSuites {
+			switch strings.ToLower(suite.GetConclusion()) {
+			case "failure", "timed_out", "cancelled":
+				failed = append(failed, suite)
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return failed, nil
+}
+
+func retestReply(rerun, reset []string) string {
+	if len(rerun) == 0 && len(reset) == 0 {
+		return "there are no failed checks to re-run."
+	}
+	var parts []string
+	if len(rerun) > 0 {
+		parts = append(parts, fmt.Sprintf("re-running the checks of %s", strings.Join(rerun, ", ")))
+	}
+	if len(reset) > 0 {
+		parts = append(parts, fmt.Sprintf("resetting the failed statuses %s to pending", strings.Join(reset, ", ")))
+	}
+	return strings.Join(parts, " and ") + "."
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestRetestCommand(t *testing.T) {
+	fake := newFakeGitHub(t, map[string]interface{}{
+		"GET " + fixtureRepo + "/collaborators/roland/permission": permissionJSON("read"),
+		"GET " + fixtureRepo + "/pulls/276":                       pullRequestJSON(fixturePR, fixtureSHA, "blocked"),
+		"GET " + fixtureRepo + "/commits/" + fixtureSHA + "/check-suites": map[string]interface{}{
+			"total_count": 2,
+			"check_suites": []map[string]interface{}{
+				{"id": 1, "status": "completed", "conclusion": "failure", "app": map[string]interface{}{"name": "Travis CI"}},
+				{"id": 2, "status": "completed", "conclusion": "success", "app": map[string]interface{}{"name": "CircleCI"}},
+			},
+		},
+		"GET " + fixtureRepo + "/commits/" + fixtureSHA + "/status": combinedStatusJSON(map[string]string{"ci/jenkins": "error", "license/cla": "success"}),
+	})
+	defer fake.close()
+
+	event := commentEvent("/retest", "roland", "User", true)
+	if err := (&slashCommands{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), config.RepoConfig{}, zap.NewNop()); err != nil {
+		t.Fatalf("handler failed: %+v", err)
+	}
+
+	comments := "POST " + fixtureRepo + "/issues/276/comments"
+	statuses := "POST " + fixtureRepo + "/statuses/" + fixtureSHA
+	expected := []string{"POST " + fixtureRepo + "/check-suites/1/rerequest", statuses, comments}
+	if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected calls %v, got %v", expected, calls)
+	}
+
+	var status github.RepoStatus
+	if err := fake.requestBody(statuses, &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.GetContext() != "ci/jenkins" || status.GetState() != "pending" || status.GetDescription() != "Retest requested by @roland" {
+		t.Errorf("unexpected status %s: %s (%s)", status.GetContext(), status.GetState(), status.GetDescription())
+	}
+	var comment github.IssueComment
+	if err := fake.requestBody(comments, &comment); err != nil {
+		t.Fatal(err)
+	}
+	if reply := "@roland re-running the checks of Travis CI and resetting the failed statuses ci/jenkins to pending."; comment.GetBody() != reply {
+		t.Errorf("expected reply %q, got %q", reply, comment.GetBody())
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+)
+
+type reviewThread struct {
+	IsResolved bool
+	Path       string
+	Line       int
+	Comments   struct {
+		Nodes []struct {
+			Author struct {
+				Login string
+			}
+		}
+	} `graphql:"comments(first: 1)"`
+}
+
+type reviewThreadsQuery struct {
+	Viewer struct {
+		Login string
+	}
+	Repository struct {
+		PullRequest struct {
+			ReviewThreads struct {
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   string
+				}
+				Nodes []reviewThread
+			} `graphql:"reviewThreads(first: 100, after: $cursor)"`
+		} `graphql:"pullRequest(number: $number)"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+// starter returns the login of the user who started the conversation
+func (t reviewThread) starter() string {
+	if len(t.Comments.Nodes) == 0 {
+		return ""
+	}
+	return t.Comments.Nodes[0].Author.Login
+}
+
+func (t reviewThread) String() string {
+	return fmt.Sprintf("%s:%d", t.Path, t.Line)
+}
+
+// unresolvedReviewThreads returns all unresolved review conversations of a
+// pull request, ignoring those started by the bot itself.
+func unresolvedReviewThreads(ctx context.Context, gql *githubv4.Client, owner, repository string, number int) ([]reviewThread, error) {
+	var unresolved []reviewThread
+	variables := map[string]interface{}{
+		"owner":  githubv4.String(owner),
+		"name":   githubv4.String(repository),
+		"number": githubv4.Int(number),
+		"cursor": (*githubv4.String)(nil),
+	}
+	for {
+		var result reviewThreadsQuery
+		if err := gql.Query(ctx, &result, variables); err != nil {
+			return nil, errors.Wrapf(err, "failed to list review threads of %s/%s#%d", owner, repository, number)
+		}
+
+		botLogin := strings.TrimSuffix(result.Viewer.Login, "[bot]")
+		threads := result.Repository.PullRequest.ReviewThreads
+		for _, thread := range threads.Nodes {
+			if thread.IsResolved || (botLogin != "" && strings.TrimSuffix(thread.starter(), "[bot]") == botLogin) {
+				continue
+			}
+			unresolved = append(unresolved, thread)
+		}
+
+		if !threads.PageInfo.HasNextPage {
+			return unresolved, nil
+		}
+		variables["cursor"] = githubv4.NewString(githubv4.String(threads.PageInfo.EndCursor))
+	}
+}
+
+// describeReviewThreads lists the locations of the first few threads
+func describeReviewThreads(threads []reviewThread, max int) []string {
+	var locations []string
+	for i, thread := range threads {
+		if i == max {
+			break
+		}
+		locations = append(locations, thread.String())
+	}
+	return locations
+}
@@ -0,0 +1,155 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/queue"
+	"go.uber.org/zap"
+)
+
+// reviewerPools requests reviews for new pull requests of repositories
+// without CODEOWNERS file from the configured reviewer pools. The assignments
+// are recorded in the backend of the event queue, so the load is balanced
+// across restarts and replicas.
+type reviewerPools struct{}
+
+// Remembers when reviewers of the pools were last assigned
+var assignments queue.AssignmentStore
+
+func (h *reviewerPools) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *reviewerPools) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	pools := config.ReviewerAssignment.Pools
+	if len(pools) == 0 {
+		return nil
+	}
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	action := strings.ToLower(event.GetAction())
+	if action != "opened" && action != "reopened" {
+		return nil
+	}
+
+	pr := event.PullRequest
+	if len(pr.RequestedReviewers) > 0 {
+		logger.Debug("Reviewers already requested, not assigning", zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+	if assignments == nil {
+		return errors.New("no store to track review assignments")
+	}
+
+	owner, repository := event.Repo.Owner.GetLogin(), event.Repo.GetName()
+	rules, err := loadCodeOwners(ctx, gh, owner, repository)
+	if err != nil {
+		return err
+	}
+	if rules != nil {
+		logger.Debug("Repository has code owners, not assigning from pools", zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+	files, err := listPullRequestFiles(ctx, gh, owner, repository, pr.GetNumber())
+	if err != nil {
+		return err
+	}
+
+	fullName := event.Repo.GetFullName()
+	lastAssigned, err := assignments.LastAssignments(fullName)
+	if err != nil {
+		return err
+	}
+
+	var reviewers []string
+	for _, pool := range pools {
+		if !poolMatches(pool, files) {
+			continue
+		}
+		var candidates []string
+		for _, reviewer := range pool.Reviewers {
+			if strings.EqualFold(reviewer, pr.User.GetLogin()) || containsIgnoreCase(config.ReviewerAssignment.Unavailable, reviewer) || containsIgnoreCase(reviewers, reviewer) {
+				continue
+			}
+			candidates = append(candidates, reviewer)
+		}
+		if reviewer := leastRecentlyAssigned(candidates, lastAssigned); reviewer != "" {
+			reviewers = append(reviewers, reviewer)
+		}
+	}
+	if len(reviewers) == 0 {
+		logger.Debug("No reviewer available in the matching pools", zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+
+	_, _, err = gh.PullRequests.RequestReviewers(ctx, owner, repository, pr.GetNumber(), github.ReviewersRequest{
+		Reviewers: reviewers,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to request reviewers %v for PR %s", reviewers, pr.GetHTMLURL())
+	}
+	now := timeNow()
+	for _, reviewer := range reviewers {
+		if err := assignments.RecordAssignment(fullName, reviewer, now); err != nil {
+			logger.Warn("Failed to record review assignment", zap.String("reviewer", reviewer), zap.Error(err))
+		}
+	}
+	logger.Debug("Requested reviewers from pools", zap.Int("pr", pr.GetNumber()), zap.Strings("reviewers", reviewers))
+	return nil
+}
+
+// poolMatches returns whether any of the files is below a path prefix of the
+// pool
+func poolMatches(pool config.ReviewerPool, files []string) bool {
+	if len(pool.Paths) == 0 {
+		return true
+	}
+	for _, prefix := range pool.Paths {
+		prefix = strings.TrimPrefix(prefix, "/")
+		for _, file := range files {
+			if strings.HasPrefix(file, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// leastRecentlyAssigned picks the candidate assigned longest ago, preferring
+// those never assigned and, on ties, the earlier ones of the pool
+func leastRecentlyAssigned(candidates []string, lastAssigned map[string]time.Time) string {
+	var picked string
+	var pickedAt time.Time
+	for _, candidate := range candidates {
+		at, assigned := lastAssigned[strings.ToLower(candidate)]
+		if !assigned {
+			return candidate
+		}
+		if picked == "" || at.Before(pickedAt) {
+			picked, pickedAt = candidate, at
+		}
+	}
+	return picked
+}
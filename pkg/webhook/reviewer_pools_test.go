@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/syndesisio/pure-bot/pkg/queue"
+	"go.uber.org/zap"
+)
+
+func TestLeastRecentlyAssigned(t *testing.T) {
+	earlier := time.Date(2018, 3, 7, 10, 0, 0, 0, time.UTC)
+	lastAssigned := map[string]time.Time{"roland": earlier.Add(time.Hour), "jimmi": earlier}
+
+	tests := []struct {
+		candidates []string
+		expected   string
+	}{
+		{[]string{"Roland", "jimmi"}, "jimmi"},
+		{[]string{"roland", "jimmi", "zregvart"}, "zregvart"},
+		{[]string{"zregvart", "gashcrumb"}, "zregvart"},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if picked := leastRecentlyAssigned(test.candidates, lastAssigned); picked != test.expected {
+			t.Errorf("expected %q of %v, got %q", test.expected, test.candidates, picked)
+		}
+	}
+}
+
+func TestReviewerPools(t *testing.T) {
+	defer func(store queue.AssignmentStore) { assignments = store }(assignments)
+	defer func(clock func() time.Time) { timeNow = clock }(timeNow)
+
+	requestReviewers := "POST " + fixtureRepo + "/pulls/276/requested_reviewers"
+	noCodeOwners := map[string]interface{}{
+		"GET " + fixtureRepo + "/contents/.github/CODEOWNERS": notFound,
+		"GET " + fixtureRepo + "/contents/CODEOWNERS":         notFound,
+		"GET " + fixtureRepo + "/contents/docs/CODEOWNERS":    notFound,
+	}
+	withFiles := func(responses map[string]interface{}, names ...string) map[string]interface{} {
+		page := make([]map[string]interface{}, 0, len(names))
+		for _, name := range names {
+			page = append(page, map[string]interface{}{"filename": name})
+		}
+		merged := map[string]interface{}{"GET " + fixtureRepo + "/pulls/276/files": page}
+		for call, response := range responses {
+			merged[call] = response
+		}
+		return merged
+	}
+	poolsConfig := config.RepoConfig{ReviewerAssignment: config.ReviewerAssignmentConfig{
+		Unavailable: []string{"rhuss"},
+		Pools: []config.ReviewerPool{
+			{Paths: []string{"/ui/"}, Reviewers: []string{"gashcrumb", "rhuss", "kahboom"}},
+			{Paths: []string{"rest/", "runtime/"}, Reviewers: []string{"roland", "jimmi", "zregvart"}},
+		},
+	}}
+
+	// Runs in sequence, sharing the assignment history
+	tests := []struct {
+		name      string
+		author    string
+		config    config.RepoConfig
+		responses map[string]interface{}
+		expected  []string
+	}{
+		{
+			name:      "first of each matching pool",
+			author:    "roland",
+			config:    poolsConfig,
+			responses: withFiles(noCodeOwners, "ui/src/app.ts", "rest/pom.xml"),
+			expected:  []string{"gashcrumb", "jimmi"},
+		},
+		{
+			name:      "never assigned preferred",
+			author:    "gashcrumb",
+			config:    poolsConfig,
+			responses: withFiles(noCodeOwners, "runtime/pom.xml", "README.md"),
+			expected:  []string{"roland"},
+		},
+		{
+			name:      "least recently assigned",
+			author:    "roland",
+			config:    poolsConfig,
+			responses: withFiles(noCodeOwners, "ui/pom.xml", "rest/pom.xml"),
+			expected:  []string{"kahboom", "zregvart"},
+		},
+		{
+			name:      "rotated",
+			author:    "zregvart",
+			config:    poolsConfig,
+			responses: withFiles(noCodeOwners, "ui/pom.xml", "rest/pom.xml"),
+			expected:  []string{"gashcrumb", "jimmi"},
+		},
+		{
+			name:      "no matching pool",
+			author:    "roland",
+			config:    poolsConfig,
+			responses: withFiles(noCodeOwners, "README.md"),
+		},
+		{
+			name:   "repository with CODEOWNERS",
+			author: "roland",
+			config: poolsConfig,
+			responses: map[string]interface{}{
+				"GET " + fixtureRepo + "/contents/.github/CODEOWNERS": fileContentJSON("* @roland\n"),
+			},
+		},
+		{
+			name:   "no pools",
+			author: "roland",
+		},
+	}
+
+	assignments, _ = queue.NewAssignmentStore(config.QueueConfig{})
+	now := time.Date(2018, 3, 7, 10, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		now = now.Add(time.Minute)
+		timeNow = func() time.Time { return now }
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			event := pullRequestEvent("opened")
+			event.PullRequest.User = &github.User{Login: github.String(test.author)}
+			if err := (&reviewerPools{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("assigning reviewers failed: %+v", err)
+			}
+			if test.expected == nil {
+				if calls := fake.mutatingCalls(); len(calls) > 0 {
+					t.Errorf("expected no calls, got %v", calls)
+				}
+				return
+			}
+			var request struct {
+				Reviewers []string `json:"reviewers"`
+			}
+			if err := fake.requestBody(requestReviewers, &request); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(request.Reviewers, test.expected) {
+				t.Errorf("expected reviewers %v, got %v", test.expected, request.Reviewers)
+			}
+		})
+	}
+}
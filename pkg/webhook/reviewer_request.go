@@ -2,81 +2,163 @@ package webhook
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
 	"github.com/syndesisio/pure-bot/pkg/config"
 	"go.uber.org/zap"
 )
 
 const (
 	prReviewContext = "pure-bot/pr-review"
+
+	roundRobinStrategy    = "round-robin"
+	loadBalancedStrategy  = "load-balanced"
+	reviewLoadCacheExpiry = 5 * time.Minute
 )
 
+// reviewerRequest requests reviewers for new pull requests and tracks the
+// requested reviews with a label and a status
 type reviewerRequest struct{}
 
+type cachedReviewLoad struct {
+	count   int
+	expires time.Time
+}
+
+var (
+	reviewerAssignmentMu sync.Mutex
+	// next round-robin position per repository
+	roundRobinPositions = make(map[string]int)
+	// open review requests per user
+	reviewLoadCache = make(map[string]cachedReviewLoad)
+)
+
 func (h *reviewerRequest) EventTypesHandled() []string {
 	return []string{"pull_request", "pull_request_review"}
 }
 
-func (h *reviewerRequest) HandleEvent(eventObject interface{}, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *reviewerRequest) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
 
 	labelConfig := config.Labels
-
-	// Only run when label has been configured
 	label := labelConfig.ReviewRequested
-	if label == "" {
-		return nil
-	}
 
 	switch event := eventObject.(type) {
 	case *github.PullRequestEvent:
-		if err := h.checkLabel(event, gh, label, logger); err != nil {
+		if err := h.assignReviewers(ctx, event, gh, config.ReviewerAssignment, logger); err != nil {
 			return err
 		}
-		return updateReviewStatus(event.PullRequest, event.Repo, gh, label, logger)
+		// Only track reviews when label has been configured
+		if label == "" {
+			return nil
+		}
+		if err := h.checkLabel(ctx, event, gh, label, logger); err != nil {
+			return err
+		}
+		return updateReviewStatus(ctx, event.PullRequest, event.Repo, gh, label, logger)
 	case *github.PullRequestReviewEvent:
-		return updateReviewStatus(event.PullRequest, event.Repo, gh, label, logger)
+		if label == "" {
+			return nil
+		}
+		return updateReviewStatus(ctx, event.PullRequest, event.Repo, gh, label, logger)
 	default:
 		return errors.Errorf("wrong event eventObject type %v", event)
 	}
 }
 
-func (h *reviewerRequest) checkLabel(event *github.PullRequestEvent, gh *github.Client, label string, logger *zap.Logger) error {
-	pr, err := fetchPullRequest(event, gh)
+func (h *reviewerRequest) checkLabel(ctx context.Context, event *github.PullRequestEvent, gh *github.Client, label string, logger *zap.Logger) error {
+	pr, err := fetchPullRequest(ctx, event, gh)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get PR %s", event.PullRequest.GetHTMLURL())
 	}
 
 	switch strings.ToLower(*event.Action) {
 	case "review_requested":
-		return handleReviewRequested(event, pr, gh, label, logger)
+		return handleReviewRequested(ctx, event, pr, gh, label, logger)
 	case "review_request_removed":
-		return handleReviewRequestRemoved(event, pr, gh, label, logger)
+		return handleReviewRequestRemoved(ctx, event, pr, gh, label, logger)
 	default:
 		return nil
 	}
 }
 
-func handleReviewRequested(event *github.PullRequestEvent, pr *github.PullRequest, gh *github.Client, label string, logger *zap.Logger) error {
+// assignReviewers requests reviews from the configured candidates when a pull
+// request without reviewers is opened
+func (h *reviewerRequest) assignReviewers(ctx context.Context, event *github.PullRequestEvent, gh *github.Client, cfg config.ReviewerAssignmentConfig, logger *zap.Logger) error {
+	if len(cfg.Candidates) == 0 {
+		return nil
+	}
+
+	action := strings.ToLower(event.GetAction())
+	if action != "opened" && action != "reopened" {
+		return nil
+	}
+
+	pr := event.PullRequest
+	if len(pr.RequestedReviewers) > 0 {
+		logger.Debug("Reviewers already requested, not assigning", zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+
+	candidates := availableReviewers(cfg, pr.User.GetLogin())
+	if len(candidates) == 0 {
+		logger.Debug("No reviewer available", zap.Int("pr", pr.GetNumber()))
+		return nil
+	}
+
+	count := cfg.Count
+	if count <= 0 {
+		count = 1
+	}
+
+	var reviewers []string
+	if cfg.Strategy == loadBalancedStrategy {
+		load, err := reviewLoad(ctx, gh, event.Repo.Owner.GetLogin(), candidates)
+		if err != nil {
+			logger.Warn("Failed to determine review load, falling back to round-robin", zap.Error(err))
+		} else {
+			reviewers = leastLoadedReviewers(candidates, load, count)
+		}
+	}
+	if reviewers == nil {
+		reviewers = roundRobinReviewers(event.Repo.GetFullName(), candidates, count)
+	}
+
+	_, _, err := gh.PullRequests.RequestReviewers(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), pr.GetNumber(), github.ReviewersRequest{
+		Reviewers: reviewers,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to request reviewers %v for PR %s", reviewers, pr.GetHTMLURL())
+	}
+	logger.Debug("Requested reviewers", zap.Int("pr", pr.GetNumber()), zap.Strings("reviewers", reviewers))
+	return nil
+}
+
+func handleReviewRequested(ctx context.Context, event *github.PullRequestEvent, pr *github.PullRequest, gh *github.Client, label string, logger *zap.Logger) error {
 
 	logEvent(logger, "review request", event)
 	if hasLabel(pr, label) {
 		logger.Debug("Label " + label + "already exists")
 		return nil
 	}
-	return addLabel(event, gh, label, logger)
+	return addLabel(ctx, event, gh, label, logger)
 }
 
-func handleReviewRequestRemoved(event *github.PullRequestEvent, pr *github.PullRequest, gh *github.Client, label string, logger *zap.Logger) error {
+func handleReviewRequestRemoved(ctx context.Context, event *github.PullRequestEvent, pr *github.PullRequest, gh *github.Client, label string, logger *zap.Logger) error {
 	logEvent(logger, "review request removed", event)
 	if !hasLabel(pr, label) {
 		logger.Debug("No label assignment, so nothing to remove")
 		return nil
 	}
 
-	found, err := hasReviewersRequestedOrAlreadyReviews(event, gh)
+	found, err := hasReviewersRequestedOrAlreadyReviews(ctx, event, gh)
 
 	if err != nil {
 		return err
@@ -87,7 +169,7 @@ func handleReviewRequestRemoved(event *github.PullRequestEvent, pr *github.PullR
 		return nil
 	}
 
-	return removeLabel(event, gh, label, logger)
+	return removeLabel(ctx, event, gh, label, logger)
 }
 
 func logEvent(logger *zap.Logger, label string, event *github.PullRequestEvent) {
@@ -97,33 +179,117 @@ func logEvent(logger *zap.Logger, label string, event *github.PullRequestEvent)
 	}
 }
 
-func updateReviewStatus(pr *github.PullRequest, repo *github.Repository, gh *github.Client, label string, logger *zap.Logger) error {
+func updateReviewStatus(ctx context.Context, pr *github.PullRequest, repo *github.Repository, gh *github.Client, label string, logger *zap.Logger) error {
 
 	if !hasLabel(pr, label) {
 		logger.Debug("No review requested", zap.Bool("pass", true))
-		return createContextWithSpecifiedStatus(prReviewContext, successStatus, "OK - no review requested", repo, pr, gh)
+		return createContextWithSpecifiedStatus(ctx, prReviewContext, successStatus, "OK - no review requested", repo, pr, gh)
 	}
 
-	reviews, err := listReviews(pr, repo, gh)
+	reviews, err := listReviews(ctx, pr, repo, gh)
 	if err != nil {
 		return err
 	}
 
 	if len(reviews) == 0 {
 		logger.Debug("Review requested but none found", zap.Bool("pass", false))
-		return createContextWithSpecifiedStatus(prReviewContext, pendingStatus, "Pending - reviews requested but none provided", repo, pr, gh)
+		return createContextWithSpecifiedStatus(ctx, prReviewContext, pendingStatus, "Pending - reviews requested but none provided", repo, pr, gh)
 	}
 
 	logger.Debug("Review requested and reviews found", zap.Bool("pass", true), zap.Int("nrReviews", len(reviews)))
-	return createContextWithSpecifiedStatus(prReviewContext, successStatus, "OK - review requested and at least one provided", repo, pr, gh)
+	return createContextWithSpecifiedStatus(ctx, prReviewContext, successStatus, "OK - review requested and at least one provided", repo, pr, gh)
+}
+
+// availableReviewers returns all candidates except the author and those
+// marked as unavailable
+func availableReviewers(cfg config.ReviewerAssignmentConfig, author string) []string {
+	var available []string
+	for _, candidate := range cfg.Candidates {
+		if strings.EqualFold(candidate, author) || containsIgnoreCase(cfg.Unavailable, candidate) {
+			continue
+		}
+		available = append(available, candidate)
+	}
+	return available
+}
+
+func containsIgnoreCase(list []string, s string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func roundRobinReviewers(repo string, candidates []string, count int) []string {
+	reviewerAssignmentMu.Lock()
+	defer reviewerAssignmentMu.Unlock()
+
+	if count > len(candidates) {
+		count = len(candidates)
+	}
+	start := roundRobinPositions[repo]
+	reviewers := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		reviewers = append(reviewers, candidates[(start+i)%len(candidates)])
+	}
+	roundRobinPositions[repo] = (start + count) % len(candidates)
+	return reviewers
+}
+
+// leastLoadedReviewers picks the candidates with the fewest open review
+// requests, breaking ties randomly
+func leastLoadedReviewers(candidates []string, load map[string]int, count int) []string {
+	shuffled := make([]string, len(candidates))
+	for i, p := range rand.Perm(len(candidates)) {
+		shuffled[i] = candidates[p]
+	}
+	sort.SliceStable(shuffled, func(i, j int) bool {
+		return load[shuffled[i]] < load[shuffled[j]]
+	})
+
+	if count > len(shuffled) {
+		count = len(shuffled)
+	}
+	return shuffled[:count]
+}
+
+// reviewLoad returns the number of open pull requests in which review is
+// requested from each of the candidates
+func reviewLoad(ctx context.Context, gh *github.Client, owner string, candidates []string) (map[string]int, error) {
+	load := make(map[string]int, len(candidates))
+	for _, candidate := range candidates {
+		key := owner + "/" + strings.ToLower(candidate)
+
+		reviewerAssignmentMu.Lock()
+		cached, found := reviewLoadCache[key]
+		reviewerAssignmentMu.Unlock()
+		if found && time.Now().Before(cached.expires) {
+			load[candidate] = cached.count
+			continue
+		}
+
+		query := fmt.Sprintf("type:pr state:open user:%s review-requested:%s", owner, candidate)
+		result, _, err := gh.Search.Issues(ctx, query, &github.SearchOptions{ListOptions: github.ListOptions{PerPage: 1}})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to search review requests of %s", candidate)
+		}
+
+		load[candidate] = result.GetTotal()
+		reviewerAssignmentMu.Lock()
+		reviewLoadCache[key] = cachedReviewLoad{result.GetTotal(), time.Now().Add(reviewLoadCacheExpiry)}
+		reviewerAssignmentMu.Unlock()
+	}
+	return load, nil
 }
 
 // ==============================================================================================
 
-func addLabel(event *github.PullRequestEvent, gh *github.Client, label string, logger *zap.Logger) error {
+func addLabel(ctx context.Context, event *github.PullRequestEvent, gh *github.Client, label string, logger *zap.Logger) error {
 	owner, repo, prNumber, prURL := event.Repo.Owner.GetLogin(), event.Repo.GetName(), event.PullRequest.GetNumber(), event.PullRequest.GetHTMLURL()
 
-	_, _, err := gh.Issues.AddLabelsToIssue(context.Background(), owner, repo, prNumber, []string{label})
+	_, _, err := gh.Issues.AddLabelsToIssue(ctx, owner, repo, prNumber, []string{label})
 	if err != nil {
 		return errors.Wrapf(err, "failed to add label '%s' to PR %s", label, prURL)
 	}
@@ -131,11 +297,11 @@ func addLabel(event *github.PullRequestEvent, gh *github.Client, label string, l
 	return nil
 }
 
-func removeLabel(event *github.PullRequestEvent, gh *github.Client, label string, logger *zap.Logger) error {
+func removeLabel(ctx context.Context, event *github.PullRequestEvent, gh *github.Client, label string, logger *zap.Logger) error {
 
 	owner, repo, prNumber, prURL := event.Repo.Owner.GetLogin(), event.Repo.GetName(), event.PullRequest.GetNumber(), event.PullRequest.GetHTMLURL()
 
-	_, err := gh.Issues.RemoveLabelForIssue(context.Background(), owner, repo, prNumber, label)
+	_, err := gh.Issues.RemoveLabelForIssue(ctx, owner, repo, prNumber, label)
 	if err != nil {
 		return errors.Wrapf(err, "failed to remove label '%s' from PR %s", label, prURL)
 	}
@@ -152,9 +318,9 @@ func hasLabel(pr *github.PullRequest, label string) bool {
 	return false
 }
 
-func listReviews(pr *github.PullRequest, repo *github.Repository, gh *github.Client) ([]*github.PullRequestReview, error) {
+func listReviews(ctx context.Context, pr *github.PullRequest, repo *github.Repository, gh *github.Client) ([]*github.PullRequestReview, error) {
 	reviews, _, err := gh.PullRequests.ListReviews(
-		context.Background(),
+		ctx,
 		repo.Owner.GetLogin(),
 		repo.GetName(),
 		pr.GetNumber(),
@@ -167,9 +333,9 @@ func listReviews(pr *github.PullRequest, repo *github.Repository, gh *github.Cli
 	return reviews, nil
 }
 
-func listReviewers(pr *github.PullRequest, repo *github.Repository, gh *github.Client) ([]*github.User, error) {
+func listReviewers(ctx context.Context, pr *github.PullRequest, repo *github.Repository, gh *github.Client) ([]*github.User, error) {
 	reviewers, _, err := gh.PullRequests.ListReviewers(
-		context.Background(),
+		ctx,
 		repo.Owner.GetLogin(),
 		repo.GetName(),
 		pr.GetNumber(),
@@ -186,13 +352,13 @@ func listReviewers(pr *github.PullRequest, repo *github.Repository, gh *github.C
 	return users, nil
 }
 
-func hasReviewersRequestedOrAlreadyReviews(event *github.PullRequestEvent, gh *github.Client) (bool, error) {
-	reviews, err := listReviews(event.PullRequest, event.Repo, gh)
+func hasReviewersRequestedOrAlreadyReviews(ctx context.Context, event *github.PullRequestEvent, gh *github.Client) (bool, error) {
+	reviews, err := listReviews(ctx, event.PullRequest, event.Repo, gh)
 	if err != nil {
 		return false, err
 	}
 
-	reviewers, err := listReviewers(event.PullRequest, event.Repo, gh)
+	reviewers, err := listReviewers(ctx, event.PullRequest, event.Repo, gh)
 	if err != nil {
 		return false, err
 	}
@@ -200,8 +366,8 @@ func hasReviewersRequestedOrAlreadyReviews(event *github.PullRequestEvent, gh *g
 	return len(reviewers) != 0 || len(reviews) != 0, nil
 }
 
-func fetchPullRequest(event *github.PullRequestEvent, gh *github.Client) (*github.PullRequest, error) {
+func fetchPullRequest(ctx context.Context, event *github.PullRequestEvent, gh *github.Client) (*github.PullRequest, error) {
 	owner, repo, prNumber := event.Repo.Owner.GetLogin(), event.Repo.GetName(), event.PullRequest.GetNumber()
-	pr, _, err := gh.PullRequests.Get(context.Background(), owner, repo, prNumber)
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repo, prNumber)
 	return pr, err
 }
@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestReviewerAssignment(t *testing.T) {
+	defer func(positions map[string]int) { roundRobinPositions = positions }(roundRobinPositions)
+	defer func(cache map[string]cachedReviewLoad) { reviewLoadCache = cache }(reviewLoadCache)
+	roundRobinPositions = make(map[string]int)
+	reviewLoadCache = make(map[string]cachedReviewLoad)
+
+	requestReviewers := "POST " + fixtureRepo + "/pulls/276/requested_reviewers"
+	searchTotal := func(total int) map[string]interface{} {
+		return map[string]interface{}{"total_count": total, "items": []interface{}{}}
+	}
+	roundRobinConfig := config.RepoConfig{ReviewerAssignment: config.ReviewerAssignmentConfig{
+		Candidates: []string{"roland", "jimmi", "zregvart"},
+	}}
+	loadBalancedConfig := config.RepoConfig{ReviewerAssignment: config.ReviewerAssignmentConfig{
+		Candidates:  []string{"roland", "jimmi", "zregvart"},
+		Strategy:    loadBalancedStrategy,
+		Unavailable: []string{"zregvart"},
+	}}
+	withLabel := roundRobinConfig
+	withLabel.Labels.ReviewRequested = "review-requested"
+
+	// Runs in sequence, sharing the round-robin positions and the load cache
+	tests := []struct {
+		name      string
+		author    string
+		requested []*github.User
+		config    config.RepoConfig
+		responses map[string]interface{}
+		// Mutating calls besides requesting the expected reviewers
+		expectedCalls []string
+		expected      []string
+	}{
+		{
+			name:     "round-robin first",
+			author:   "gashcrumb",
+			config:   roundRobinConfig,
+			expected: []string{"roland"},
+		},
+		{
+			name:     "round-robin next",
+			author:   "gashcrumb",
+			config:   roundRobinConfig,
+			expected: []string{"jimmi"},
+		},
+		{
+			name:     "author excluded",
+			author:   "zregvart",
+			config:   roundRobinConfig,
+			expected: []string{"roland"},
+		},
+		{
+			name:   "round-robin several",
+			author: "gashcrumb",
+			config: func() config.RepoConfig {
+				cfg := roundRobinConfig
+				cfg.ReviewerAssignment.Count = 2
+				return cfg
+			}(),
+			expected: []string{"jimmi", "zregvart"},
+		},
+		{
+			name:   "least loaded",
+			author: "gashcrumb",
+			config: loadBalancedConfig,
+			responses: map[string]interface{}{
+				searchIssues: fakeSequence{searchTotal(4), searchTotal(1)},
+			},
+			expected: []string{"jimmi"},
+		},
+		{
+			name:     "least loaded from cache",
+			author:   "gashcrumb",
+			config:   loadBalancedConfig,
+			expected: []string{"jimmi"},
+		},
+		{
+			name:   "load unknown falls back to round-robin",
+			author: "roland",
+			config: config.RepoConfig{ReviewerAssignment: config.ReviewerAssignmentConfig{
+				Candidates: []string{"gashcrumb", "kahboom"},
+				Strategy:   loadBalancedStrategy,
+			}},
+			responses: map[string]interface{}{
+				searchIssues: fakeResponse{status: http.StatusInternalServerError, body: map[string]string{"message": "Server Error"}},
+			},
+			expected: []string{"gashcrumb"},
+		},
+		{
+			name:   "no candidate remains",
+			author: "roland",
+			config: config.RepoConfig{ReviewerAssignment: config.ReviewerAssignmentConfig{
+				Candidates:  []string{"roland", "jimmi"},
+				Unavailable: []string{"Jimmi"},
+			}},
+		},
+		{
+			name:      "reviewers already requested",
+			author:    "gashcrumb",
+			requested: []*github.User{{Login: github.String("kahboom")}},
+			config:    roundRobinConfig,
+		},
+		{
+			name:   "no candidates",
+			author: "gashcrumb",
+		},
+		{
+			name:   "review status tracked as well",
+			author: "gashcrumb",
+			config: withLabel,
+			responses: map[string]interface{}{
+				getPullRequest: map[string]interface{}{"number": fixturePR},
+			},
+			expectedCalls: []string{createStatus},
+			expected:      []string{"jimmi"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.responses)
+			defer fake.close()
+
+			event := pullRequestEvent("opened")
+			event.Repo.FullName = github.String("syndesisio/syndesis-rest")
+			event.PullRequest.User = &github.User{Login: github.String(test.author)}
+			event.PullRequest.RequestedReviewers = test.requested
+			if err := (&reviewerRequest{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("assigning reviewers failed: %+v", err)
+			}
+
+			expectedCalls := test.expectedCalls
+			if test.expected != nil {
+				expectedCalls = append([]string{requestReviewers}, expectedCalls...)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, expectedCalls) {
+				t.Fatalf("expected calls %v, got %v", expectedCalls, calls)
+			}
+			if test.expected == nil {
+				return
+			}
+			var request struct {
+				Reviewers []string `json:"reviewers"`
+			}
+			if err := fake.requestBody(requestReviewers, &request); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(request.Reviewers, test.expected) {
+				t.Errorf("expected reviewers %v, got %v", test.expected, request.Reviewers)
+			}
+		})
+	}
+}
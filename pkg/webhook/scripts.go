@@ -0,0 +1,324 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	// Switches all scripts of a repository off in its "handlers" config,
+	// single scripts are switched with "script:<name>"
+	scriptsHandlerName  = "scripts"
+	scriptHandlerPrefix = "script:"
+
+	defaultScriptTimeout = 10 * time.Second
+	// Keeps runaway loops from burning CPU until the timeout expires
+	maxScriptSteps = 10000000
+)
+
+// scriptAction is an action requested by a script. Issue and pull request
+// numbers default to the one of the event.
+type scriptAction struct {
+	// "addLabel", "removeLabel", "comment" or "setStatus"
+	Action string
+	Number int
+	Label  string
+	Body   string
+
+	SHA         string
+	Context     string
+	State       string
+	Description string
+	TargetURL   string
+}
+
+// scriptGlobals are the names predeclared for scripts besides the
+// universal Starlark built-ins
+var scriptGlobals = []string{"event", "eventType", "repository", "addLabel", "removeLabel", "comment", "setStatus"}
+
+func init() {
+	// Scripts are short enough for if statements and loops at top level
+	resolve.AllowGlobalReassign = true
+}
+
+func isScriptGlobal(name string) bool {
+	return containsString(scriptGlobals, name)
+}
+
+// runScripts runs the scripts attached to the event type and repository and
+// applies the actions they request
+func runScripts(ctx context.Context, scripts []config.ScriptConfig, messageType string, payload []byte, event interface{}, repo *github.Repository, gh *github.Client, repoConfig config.RepoConfig, logger *zap.Logger) error {
+	var err error
+	for _, script := range scripts {
+		if !containsIgnoreCase(script.Events, messageType) || !scriptAppliesTo(script, repo) || !scriptEnabled(script, repoConfig) {
+			continue
+		}
+		scriptLogger := logger.With(zap.String("script", script.Name))
+		actions, runErr := runScript(ctx, script, messageType, payload, repo, scriptLogger)
+		if runErr != nil {
+			err = multierr.Combine(err, runErr)
+			continue
+		}
+		for _, action := range actions {
+			scriptLogger.Debug("applying script action", zap.String("action", action.Action))
+			err = multierr.Combine(err, errors.Wrapf(applyScriptAction(ctx, action, event, repo, gh), "script %s", script.Name))
+		}
+	}
+	return err
+}
+
+func scriptAppliesTo(script config.ScriptConfig, repo *github.Repository) bool {
+	if len(script.Repos) == 0 {
+		return true
+	}
+	if repo == nil {
+		return false
+	}
+	return containsIgnoreCase(script.Repos, repo.GetName()) || containsIgnoreCase(script.Repos, repo.GetFullName())
+}
+
+// scriptEnabled tells whether a repository switched a script on or off. Opt-in
+// scripts are off unless switched on.
+func scriptEnabled(script config.ScriptConfig, repoConfig config.RepoConfig) bool {
+	enabled, found := repoConfig.Handlers[scriptHandlerPrefix+script.Name]
+	if !found {
+		return !script.OptIn
+	}
+	return enabled
+}
+
+// runScript executes a Starlark script or a WebAssembly module and returns
+// the actions it requested
+func runScript(ctx context.Context, script config.ScriptConfig, messageType string, payload []byte, repo *github.Repository, logger *zap.Logger) ([]scriptAction, error) {
+	timeout := script.Timeout
+	if timeout <= 0 {
+		timeout = defaultScriptTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if isWasmScript(script) {
+		return runWasmScript(ctx, script, messageType, payload)
+	}
+	return runStarlarkScript(ctx, script, messageType, payload, repo, logger)
+}
+
+// runStarlarkScript executes a Starlark script. Scripts can't load modules
+// or access files, the network or credentials; besides the event they only
+// see the built-ins requesting actions.
+func runStarlarkScript(ctx context.Context, script config.ScriptConfig, messageType string, payload []byte, repo *github.Repository, logger *zap.Logger) ([]scriptAction, error) {
+	event, err := starlarkEvent(payload)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to pass event to script %s", script.Name)
+	}
+
+	var actions []scriptAction
+	request := func(action scriptAction) (starlark.Value, error) {
+		actions = append(actions, action)
+		return starlark.None, nil
+	}
+	predeclared := starlark.StringDict{
+		"event":      event,
+		"eventType":  starlark.String(messageType),
+		"repository": starlark.String(repo.GetFullName()),
+		"addLabel": starlark.NewBuiltin("addLabel", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			action := scriptAction{Action: "addLabel"}
+			if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "label", &action.Label, "number?", &action.Number); err != nil {
+				return nil, err
+			}
+			return request(action)
+		}),
+		"removeLabel": starlark.NewBuiltin("removeLabel", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			action := scriptAction{Action: "removeLabel"}
+			if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "label", &action.Label, "number?", &action.Number); err != nil {
+				return nil, err
+			}
+			return request(action)
+		}),
+		"comment": starlark.NewBuiltin("comment", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			action := scriptAction{Action: "comment"}
+			if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "body", &action.Body, "number?", &action.Number); err != nil {
+				return nil, err
+			}
+			return request(action)
+		}),
+		"setStatus": starlark.NewBuiltin("setStatus", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			action := scriptAction{Action: "setStatus"}
+			if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "sha", &action.SHA, "context", &action.Context, "state", &action.State,
+				"description?", &action.Description, "targetUrl?", &action.TargetURL); err != nil {
+				return nil, err
+			}
+			return request(action)
+		}),
+	}
+
+	// Without a Load function, load statements fail
+	thread := &starlark.Thread{
+		Name: script.Name,
+		Print: func(thread *starlark.Thread, msg string) {
+			logger.Debug(msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(maxScriptSteps)
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-finished:
+		}
+	}()
+
+	if _, err := starlark.ExecFile(thread, script.File, nil, predeclared); err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return nil, errors.Errorf("script %s failed: %s", script.Name, evalErr.Backtrace())
+		}
+		return nil, errors.Wrapf(err, "script %s failed", script.Name)
+	}
+	return actions, nil
+}
+
+// starlarkEvent converts a webhook payload to Starlark dicts and lists
+func starlarkEvent(payload []byte) (starlark.Value, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	// IDs don't fit into a float
+	decoder.UseNumber()
+	var event interface{}
+	if err := decoder.Decode(&event); err != nil {
+		return nil, err
+	}
+	return starlarkValue(event)
+}
+
+func starlarkValue(v interface{}) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return starlark.MakeInt64(i), nil
+		}
+		f, err := v.Float64()
+		return starlark.Float(f), err
+	case []interface{}:
+		elems := make([]starlark.Value, 0, len(v))
+		for _, e := range v {
+			elem, err := starlarkValue(e)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, elem)
+		}
+		return starlark.NewList(elems), nil
+	case map[string]interface{}:
+		dict := starlark.NewDict(len(v))
+		for key, e := range v {
+			value, err := starlarkValue(e)
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(key), value); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	default:
+		return nil, errors.Errorf("unexpected JSON value %T", v)
+	}
+}
+
+// applyScriptAction performs an action on behalf of a script, which is
+// limited to labels, comments and commit statuses of the event's repository
+func applyScriptAction(ctx context.Context, action scriptAction, event interface{}, repo *github.Repository, gh *github.Client) error {
+	if repo == nil {
+		return errors.New("event has no repository")
+	}
+	owner, repository := repo.Owner.GetLogin(), repo.GetName()
+	number := action.Number
+	if number == 0 {
+		number = eventNumber(event)
+	}
+
+	switch action.Action {
+	case "addLabel", "removeLabel", "comment":
+		if number == 0 {
+			return errors.Errorf("action %s needs a number, the event has none", action.Action)
+		}
+	}
+
+	var err error
+	switch action.Action {
+	case "addLabel":
+		_, _, err = gh.Issues.AddLabelsToIssue(ctx, owner, repository, number, []string{action.Label})
+	case "removeLabel":
+		_, err = gh.Issues.RemoveLabelForIssue(ctx, owner, repository, number, action.Label)
+	case "comment":
+		_, _, err = gh.Issues.CreateComment(ctx, owner, repository, number, &github.IssueComment{
+			Body: github.String(action.Body),
+		})
+	case "setStatus":
+		if action.SHA == "" || action.Context == "" {
+			return errors.New("action setStatus needs a sha and a context")
+		}
+		status := &github.RepoStatus{
+			State:       github.String(action.State),
+			Context:     github.String(action.Context),
+			Description: github.String(action.Description),
+		}
+		if action.TargetURL != "" {
+			status.TargetURL = github.String(action.TargetURL)
+		}
+		_, _, err = gh.Repositories.CreateStatus(ctx, owner, repository, action.SHA, status)
+	default:
+		return errors.Errorf("unknown action %q", action.Action)
+	}
+	return errors.Wrapf(err, "failed to %s of %s/%s#%d", action.Action, owner, repository, number)
+}
+
+// eventNumber returns the number of the issue or pull request an event is
+// about, 0 if none
+func eventNumber(event interface{}) int {
+	switch e := event.(type) {
+	case *github.IssuesEvent:
+		return e.Issue.GetNumber()
+	case *github.IssueCommentEvent:
+		return e.Issue.GetNumber()
+	case *github.PullRequestEvent:
+		return e.PullRequest.GetNumber()
+	case *github.PullRequestReviewEvent:
+		return e.PullRequest.GetNumber()
+	case *github.PullRequestReviewCommentEvent:
+		return e.PullRequest.GetNumber()
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestRunScripts(t *testing.T) {
+	payload, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "pull_request_labeled.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := parseWebHook("pull_request", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := extractRepository(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "scripts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := func(name string, source string) string {
+		path := filepath.Join(dir, name+".star")
+		if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	triage := file("triage", `
+if eventType == "pull_request" and event["action"] == "labeled":
+    addLabel("triage")
+    comment("Thanks, " + event["sender"]["login"] + "!")
+`)
+	other := file("other", `addLabel("other")`)
+
+	gh := newFakeGitHub(t, nil)
+	defer gh.close()
+	scripts := []config.ScriptConfig{
+		{Name: "triage", Events: []string{"pull_request"}, File: triage},
+		{Name: "other repository", Events: []string{"pull_request"}, File: other, Repos: []string{"syndesisio/syndesis-ui"}},
+		{Name: "not opted in", Events: []string{"pull_request"}, File: other, OptIn: true},
+		{Name: "switched off", Events: []string{"pull_request"}, File: other},
+		{
+			Name:   "opted in",
+			Events: []string{"pull_request"},
+			File:   file("status", `setStatus(sha = "abc", context = "plugin", state = "success")`),
+			OptIn:  true,
+		},
+		{Name: "other event", Events: []string{"issues"}, File: other},
+	}
+	repoConfig := config.RepoConfig{Handlers: map[string]bool{"script:switched off": false, "script:opted in": true}}
+	if err := runScripts(context.Background(), scripts, "pull_request", payload, event, repo, gh.client(), repoConfig, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+
+	issue := fixtureRepo + "/issues/" + strconv.Itoa(fixturePR)
+	expected := []string{"POST " + issue + "/labels", "POST " + issue + "/comments", "POST " + fixtureRepo + "/statuses/abc"}
+	if calls := gh.mutatingCalls(); !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected calls %v, got %v", expected, calls)
+	}
+	var labels []string
+	if err := gh.requestBody("POST "+issue+"/labels", &labels); err != nil || !reflect.DeepEqual(labels, []string{"triage"}) {
+		t.Errorf("expected triage label, got %v (%v)", labels, err)
+	}
+
+	for name, source := range map[string]string{
+		"unknown function": `exec("rm -rf /")`,
+		"load":             `load("os.star", "run")`,
+		"endless loop":     "def loop():\n    for i in range(1000000000):\n        pass\nloop()",
+		"missing argument": `addLabel()`,
+	} {
+		failing := []config.ScriptConfig{{Name: name, Events: []string{"pull_request"}, File: file("failing", source)}}
+		if err := runScripts(context.Background(), failing, "pull_request", payload, event, repo, gh.client(), config.RepoConfig{}, zap.NewNop()); err == nil {
+			t.Errorf("%s: expected script to fail", name)
+		}
+	}
+}
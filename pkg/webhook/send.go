@@ -0,0 +1,39 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+// Personal access token used instead of the app installation of the event
+// when processing deliveries locally
+var localToken string
+
+// Send feeds a local webhook payload through the handlers, e.g. for trying
+// out handler changes before deploying them. With a token, the bot acts as
+// the token's user instead of as the app installation named in the payload.
+func Send(eventType string, payload []byte, token string, cfg config.Config, logger *zap.Logger) error {
+	localToken = token
+	defer func() { localToken = "" }()
+
+	deliveryID := "send-" + strconv.FormatInt(timeNow().UnixNano(), 10)
+	logger.Info("Sending payload", zap.String("delivery", deliveryID), zap.String("event", eventType), zap.Bool("token", token != ""), zap.Bool("dryRun", cfg.DryRun))
+	return processLocally(eventType, deliveryID, payload, cfg, logger)
+}
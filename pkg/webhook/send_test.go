@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestSendTokenClient(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"login":"octocat"}`))
+	}))
+	defer server.Close()
+
+	defer func() { localToken = "" }()
+	localToken = "abc"
+	cfg := config.Config{GitHubApp: config.GitHubAppConfig{BaseURL: server.URL}}
+	// Payloads sent locally don't need an installation
+	client, err := createClient(context.Background(), cfg, &github.RepositoryEvent{}, "send-1", zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := client.NewRequest(http.MethodGet, "user", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(context.Background(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "token abc" {
+		t.Errorf("expected the token to authenticate requests, got %q", authorization)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// inFlight tracks running webhook handlers and the actions they have
+// scheduled in the background, so that a shutdown can wait for them.
+var inFlight = &tracker{}
+
+// shutdownCtx is cancelled when the drain timeout expires. Background
+// actions should watch it to abort waiting.
+var shutdownCtx, cancelInFlight = context.WithCancel(context.Background())
+
+// How long cancelled handlers and actions may take to return after the drain
+// timeout
+var cancelGracePeriod = 5 * time.Second
+
+type tracker struct {
+	mu      sync.Mutex
+	closed  bool
+	running sync.WaitGroup
+}
+
+// begin registers a new webhook delivery. It returns false when the bot is
+// shutting down and the delivery must be rejected.
+func (t *tracker) begin() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	t.running.Add(1)
+	return true
+}
+
+// closing reports whether the bot is shutting down
+func (t *tracker) closing() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+func (t *tracker) done() {
+	t.running.Done()
+}
+
+// goAsync runs an action in the background which outlives the webhook request
+// it was scheduled from. When the bot is shutting down the action is dropped
+// and false is returned.
+func goAsync(action func(ctx context.Context)) bool {
+	if !inFlight.begin() {
+		return false
+	}
+	go func() {
+		defer inFlight.done()
+		action(shutdownCtx)
+	}()
+	return true
+}
+
+// Shutdown stops accepting new webhook deliveries and waits until all
+// in-flight handlers and scheduled actions have finished. If ctx expires
+// before, the remaining actions are cancelled and an error is returned. The
+// event queue is only closed once the cancelled actions have returned.
+func Shutdown(ctx context.Context) error {
+	inFlight.mu.Lock()
+	inFlight.closed = true
+	inFlight.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		// Queued events may schedule further actions, so the consumer must
+		// have stopped before waiting for them
+		if eventQueue != nil {
+			close(stopConsumer)
+			<-consumerStopped
+		}
+		inFlight.running.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		cancelInFlight()
+		err = errors.Wrap(ctx.Err(), "webhook handlers did not finish in time")
+		// The cancelled handlers and workers still use the stores until they
+		// return
+		select {
+		case <-drained:
+		case <-time.After(cancelGracePeriod):
+			err = multierr.Combine(err, errors.New("cancelled webhook handlers did not return, leaving the event queue open"))
+			return multierr.Combine(err, closeOutputs())
+		}
+	}
+
+	if eventQueue != nil {
+		if queueErr := eventQueue.Close(); queueErr != nil {
+			err = multierr.Combine(err, errors.Wrap(queueErr, "failed to close event queue"))
+		}
+	}
+	if assignments != nil {
+		if assignmentsErr := assignments.Close(); assignmentsErr != nil {
+			err = multierr.Combine(err, errors.Wrap(assignmentsErr, "failed to close assignment store"))
+		}
+	}
+
+	return multierr.Combine(err, closeOutputs())
+}
+
+// closeOutputs flushes the audit log, notifications and spans. They drop
+// whatever is added afterwards.
+func closeOutputs() error {
+	var err error
+	if auditErr := auditLog.Close(); auditErr != nil {
+		err = multierr.Combine(err, errors.Wrap(auditErr, "failed to close audit log"))
+	}
+	notifier.Close()
+	slack.Close()
+	matrix.Close()
+	if tracingErr := tracer.Close(); tracingErr != nil {
+		err = multierr.Combine(err, errors.Wrap(tracingErr, "failed to export remaining spans"))
+	}
+	return err
+}
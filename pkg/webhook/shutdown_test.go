@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestGoAsyncWhileShuttingDown(t *testing.T) {
+	defer func(tracker *tracker) { inFlight = tracker }(inFlight)
+	inFlight = &tracker{closed: true}
+
+	if goAsync(func(ctx context.Context) { t.Error("expected action to be dropped") }) {
+		t.Error("expected goAsync to refuse actions while shutting down")
+	}
+
+	// Debounced evaluations run right away instead
+	evaluated := false
+	err := debounce(context.Background(), "syndesisio/syndesis-rest@shutdown", time.Hour, zap.NewNop(), func(ctx context.Context) error {
+		evaluated = true
+		return nil
+	})
+	if err != nil || !evaluated {
+		t.Errorf("expected evaluation right away, got %v, %v", evaluated, err)
+	}
+	pendingEvaluationsMu.Lock()
+	_, pending := pendingEvaluations["syndesisio/syndesis-rest@shutdown"]
+	pendingEvaluationsMu.Unlock()
+	if pending {
+		t.Error("expected no pending evaluation")
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const defaultSizeLabelPrefix = "size/"
+
+var (
+	sizes             = []string{"XS", "S", "M", "L", "XL", "XXL"}
+	defaultSizeLimits = []int{10, 30, 100, 500, 1000}
+)
+
+// sizeLabels labels pull requests by the number of changed lines and files,
+// replacing the label of the previous size when the PR changes
+type sizeLabels struct{}
+
+func (h *sizeLabels) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *sizeLabels) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if !config.SizeLabels.Enabled {
+		return nil
+	}
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	action := strings.ToLower(event.GetAction())
+	if action != "opened" && action != "reopened" && action != "synchronize" {
+		return nil
+	}
+
+	pr := event.PullRequest
+	owner, repository, number := event.Repo.Owner.GetLogin(), event.Repo.GetName(), pr.GetNumber()
+	prefix := config.SizeLabels.Prefix
+	if prefix == "" {
+		prefix = defaultSizeLabelPrefix
+	}
+	label := prefix + prSize(pr, config.SizeLabels)
+
+	for _, l := range pr.Labels {
+		if name := l.GetName(); name != label && isSizeLabel(name, prefix) {
+			logger.Debug("Removing previous size label", zap.Int("pr", number), zap.String("label", name))
+			if _, err := gh.Issues.RemoveLabelForIssue(ctx, owner, repository, number, name); err != nil {
+				return errors.Wrapf(err, "failed to remove label %s from PR %s", name, pr.GetHTMLURL())
+			}
+		}
+	}
+	if labelsContainsLabel(pr.Labels, label) {
+		return nil
+	}
+	logger.Info("Labeling PR size", zap.Int("pr", number), zap.String("label", label))
+	_, _, err := gh.Issues.AddLabelsToIssue(ctx, owner, repository, number, []string{label})
+	return errors.Wrapf(err, "failed to add label %s to PR %s", label, pr.GetHTMLURL())
+}
+
+// prSize returns the size of a PR, the larger of the sizes by changed lines
+// and by changed files
+func prSize(pr *github.PullRequest, cfg config.SizeLabelsConfig) string {
+	lines := cfg.Lines
+	if len(lines) == 0 {
+		lines = defaultSizeLimits
+	}
+	size := sizeIndex(pr.GetAdditions()+pr.GetDeletions(), lines)
+	if len(cfg.Files) > 0 {
+		if files := sizeIndex(pr.GetChangedFiles(), cfg.Files); files > size {
+			size = files
+		}
+	}
+	return sizes[size]
+}
+
+func sizeIndex(count int, limits []int) int {
+	for i, limit := range limits {
+		if count < limit {
+			return i
+		}
+	}
+	return len(limits)
+}
+
+func isSizeLabel(name, prefix string) bool {
+	for _, size := range sizes {
+		if strings.EqualFold(name, prefix+size) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func sizedPullRequestEvent(action string, additions, deletions, files int, labels ...string) *github.PullRequestEvent {
+	pr := &github.PullRequest{
+		Number:       github.Int(fixturePR),
+		Additions:    github.Int(additions),
+		Deletions:    github.Int(deletions),
+		ChangedFiles: github.Int(files),
+	}
+	for _, label := range labels {
+		pr.Labels = append(pr.Labels, &github.Label{Name: github.String(label)})
+	}
+	return &github.PullRequestEvent{
+		Action:      github.String(action),
+		PullRequest: pr,
+		Repo:        &github.Repository{Name: github.String("syndesis-rest"), Owner: &github.User{Login: github.String("syndesisio")}},
+	}
+}
+
+func TestSizeLabels(t *testing.T) {
+	addLabels := "POST " + fixtureRepo + "/issues/276/labels"
+	removeSizeS := "DELETE " + fixtureRepo + "/issues/276/labels/size/S"
+	sizeConfig := config.RepoConfig{SizeLabels: config.SizeLabelsConfig{Enabled: true}}
+
+	tests := []struct {
+		name          string
+		event         *github.PullRequestEvent
+		config        config.RepoConfig
+		expectedLabel string
+		expectedCalls []string
+	}{
+		{
+			name:          "new PR labeled",
+			event:         sizedPullRequestEvent("opened", 40, 20, 3),
+			config:        sizeConfig,
+			expectedLabel: "size/M",
+			expectedCalls: []string{addLabels},
+		},
+		{
+			name:          "previous size replaced",
+			event:         sizedPullRequestEvent("synchronize", 400, 200, 3, "size/S", "bug"),
+			config:        sizeConfig,
+			expectedLabel: "size/XL",
+			expectedCalls: []string{removeSizeS, addLabels},
+		},
+		{
+			name:   "size unchanged",
+			event:  sizedPullRequestEvent("synchronize", 10, 10, 1, "size/S"),
+			config: sizeConfig,
+		},
+		{
+			name:          "file count",
+			event:         sizedPullRequestEvent("opened", 4, 4, 40, "size/S"),
+			config:        config.RepoConfig{SizeLabels: config.SizeLabelsConfig{Enabled: true, Prefix: "size-", Files: []int{2, 5, 10, 20, 50}}},
+			expectedLabel: "size-XL",
+			expectedCalls: []string{addLabels},
+		},
+		{
+			name:  "not enabled",
+			event: sizedPullRequestEvent("opened", 40, 20, 3),
+		},
+		{
+			name:   "labeled",
+			event:  sizedPullRequestEvent("labeled", 40, 20, 3),
+			config: sizeConfig,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, nil)
+			defer fake.close()
+
+			if err := (&sizeLabels{}).HandleEvent(context.Background(), test.event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Errorf("labeling failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+			if test.expectedLabel != "" {
+				var labels []string
+				if err := fake.requestBody(addLabels, &labels); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(labels, []string{test.expectedLabel}) {
+					t.Errorf("expected label %s, got %v", test.expectedLabel, labels)
+				}
+			}
+		})
+	}
+}
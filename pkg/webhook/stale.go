@@ -0,0 +1,232 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultStaleLabel   = "stale"
+	defaultStaleComment = "This has had no activity for {{ .DaysUntilStale }} days and is marked as stale." +
+		"{{ if .DaysUntilClose }} It will be closed in {{ .DaysUntilClose }} days unless there is new activity.{{ end }}"
+	defaultStaleCloseComment = "Closing this after {{ .DaysUntilClose }} more days without activity."
+)
+
+// staleData is passed to the stale comment templates
+type staleData struct {
+	Author         string
+	Title          string
+	Number         int
+	DaysUntilStale int
+	DaysUntilClose int
+}
+
+// MarkStale periodically labels inactive issues and pull requests of all
+// installations as stale and closes them after a grace period. It returns
+// when ctx is done or the bot is shutting down.
+func MarkStale(ctx context.Context, cfg config.Config, logger *zap.Logger) {
+	if cfg.StaleInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cfg.StaleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if !inFlight.begin() {
+			return
+		}
+		logger.Debug("Checking for stale issues and pull requests")
+		err := forEachRepository(shutdownCtx, currentConfig(), logger, markStaleRepository)
+		inFlight.done()
+		if err != nil {
+			logger.Error("Checking for stale issues and pull requests failed", zap.Error(err))
+		}
+	}
+}
+
+func staleLabel(cfg config.StaleConfig) string {
+	if cfg.Label == "" {
+		return defaultStaleLabel
+	}
+	return cfg.Label
+}
+
+// markStaleRepository marks the open issues and pull requests of a
+// repository which had no activity for too long and closes those marked
+// before, unless they got active again
+func markStaleRepository(ctx context.Context, repo *github.Repository, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+	cfg := config.Stale
+	if cfg.DaysUntilStale <= 0 || !config.HandlerEnabled("stale") {
+		return nil
+	}
+	owner, repository := repo.Owner.GetLogin(), repo.GetName()
+	now := timeNow()
+	staleBefore := now.AddDate(0, 0, -cfg.DaysUntilStale)
+	closeBefore := now.AddDate(0, 0, -cfg.DaysUntilClose)
+	// Anything updated after both cutoffs needs no action
+	inactiveBefore := staleBefore
+	if cfg.DaysUntilClose > 0 && closeBefore.After(staleBefore) {
+		inactiveBefore = closeBefore
+	}
+
+	// Collected first, as marking them changes the order of the pages
+	var inactive []*github.Issue
+	opts := &github.IssueListByRepoOptions{State: "open", Sort: "updated", Direction: "asc", ListOptions: github.ListOptions{PerPage: 100}}
+pages:
+	for {
+		issues, resp, err := gh.Issues.ListByRepo(ctx, owner, repository, opts)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list open issues of %s", repo.GetFullName())
+		}
+		for _, issue := range issues {
+			if !issue.GetUpdatedAt().Before(inactiveBefore) {
+				break pages
+			}
+			inactive = append(inactive, issue)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	label := staleLabel(cfg)
+	var multiErr error
+	for _, issue := range inactive {
+		if labelsExempt(issue.Labels, cfg.ExemptLabels) {
+			continue
+		}
+		issueLogger := logger.With(zap.String("repo", repo.GetFullName()), zap.Int("number", issue.GetNumber()))
+		switch stale := containsLabel(issue.Labels, label); {
+		case !stale && issue.GetUpdatedAt().Before(staleBefore):
+			issueLogger.Info("Marking as stale", zap.Time("updated", issue.GetUpdatedAt()))
+			multiErr = multierr.Combine(multiErr, markStale(ctx, gh, owner, repository, issue, label, cfg))
+		case stale && cfg.DaysUntilClose > 0 && issue.GetUpdatedAt().Before(closeBefore):
+			issueLogger.Info("Closing stale issue", zap.Time("updated", issue.GetUpdatedAt()))
+			multiErr = multierr.Combine(multiErr, closeStale(ctx, gh, owner, repository, issue, cfg))
+		}
+	}
+	return multiErr
+}
+
+func markStale(ctx context.Context, gh *github.Client, owner, repository string, issue *github.Issue, label string, cfg config.StaleConfig) error {
+	if _, _, err := gh.Issues.AddLabelsToIssue(ctx, owner, repository, issue.GetNumber(), []string{label}); err != nil {
+		return errors.Wrapf(err, "failed to add label %s to %s", label, issue.GetHTMLURL())
+	}
+	return staleComment(ctx, gh, owner, repository, issue, cfg.Comment, defaultStaleComment, cfg)
+}
+
+func closeStale(ctx context.Context, gh *github.Client, owner, repository string, issue *github.Issue, cfg config.StaleConfig) error {
+	if err := staleComment(ctx, gh, owner, repository, issue, cfg.CloseComment, defaultStaleCloseComment, cfg); err != nil {
+		return err
+	}
+	_, _, err := gh.Issues.Edit(ctx, owner, repository, issue.GetNumber(), &github.IssueRequest{State: github.String("closed")})
+	return errors.Wrapf(err, "failed to close stale %s", issue.GetHTMLURL())
+}
+
+func staleComment(ctx context.Context, gh *github.Client, owner, repository string, issue *github.Issue, text, defaultText string, cfg config.StaleConfig) error {
+	if text == "" {
+		text = defaultText
+	}
+	body, err := renderTemplate("stale", text, staleData{
+		Author:         issue.User.GetLogin(),
+		Title:          issue.GetTitle(),
+		Number:         issue.GetNumber(),
+		DaysUntilStale: cfg.DaysUntilStale,
+		DaysUntilClose: cfg.DaysUntilClose,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to render stale comment for %s", issue.GetHTMLURL())
+	}
+	_, _, err = gh.Issues.CreateComment(ctx, owner, repository, issue.GetNumber(), &github.IssueComment{Body: &body})
+	return errors.Wrapf(err, "failed to comment on stale %s", issue.GetHTMLURL())
+}
+
+func labelsExempt(labels []github.Label, exempt []string) bool {
+	for _, label := range exempt {
+		if containsLabel(labels, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// staleActivity removes the stale label when an issue or pull request gets
+// active again
+type staleActivity struct{}
+
+func (h *staleActivity) EventTypesHandled() []string {
+	return []string{"issue_comment", "issues", "pull_request", "pull_request_review"}
+}
+
+func (h *staleActivity) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if config.Stale.DaysUntilStale <= 0 {
+		return nil
+	}
+	label := staleLabel(config.Stale)
+
+	var repo *github.Repository
+	var sender *github.User
+	var number int
+	var labeled bool
+	switch event := eventObject.(type) {
+	case *github.IssueCommentEvent:
+		if strings.ToLower(event.GetAction()) != "created" {
+			return nil
+		}
+		repo, sender, number, labeled = event.Repo, event.Sender, event.Issue.GetNumber(), containsLabel(event.Issue.Labels, label)
+	case *github.IssuesEvent:
+		if action := strings.ToLower(event.GetAction()); action != "edited" && action != "reopened" {
+			return nil
+		}
+		repo, sender, number, labeled = event.Repo, event.Sender, event.Issue.GetNumber(), containsLabel(event.Issue.Labels, label)
+	case *github.PullRequestEvent:
+		if action := strings.ToLower(event.GetAction()); action != "synchronize" && action != "edited" && action != "reopened" {
+			return nil
+		}
+		repo, sender, number, labeled = event.Repo, event.Sender, event.PullRequest.GetNumber(), labelsContainsLabel(event.PullRequest.Labels, label)
+	case *github.PullRequestReviewEvent:
+		if strings.ToLower(event.GetAction()) != "submitted" {
+			return nil
+		}
+		repo, sender, number, labeled = event.Repo, event.Sender, event.PullRequest.GetNumber(), labelsContainsLabel(event.PullRequest.Labels, label)
+	default:
+		return nil
+	}
+	// The bot's own comments don't count as activity
+	if !labeled || strings.EqualFold(sender.GetType(), "Bot") {
+		return nil
+	}
+
+	logger.Info("Removing stale label after new activity", zap.String("repo", repo.GetFullName()), zap.Int("number", number))
+	_, err := gh.Issues.RemoveLabelForIssue(ctx, repo.Owner.GetLogin(), repo.GetName(), number, label)
+	return errors.Wrapf(err, "failed to remove label %s from %s#%d", label, repo.GetFullName(), number)
+}
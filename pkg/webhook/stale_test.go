@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func updatedDaysAgo(issue map[string]interface{}, days int) map[string]interface{} {
+	issue["updated_at"] = timeNow().AddDate(0, 0, -days).Format(time.RFC3339)
+	issue["user"] = map[string]interface{}{"login": "roland"}
+	return issue
+}
+
+func TestMarkStaleRepository(t *testing.T) {
+	defer func(clock func() time.Time) { timeNow = clock }(timeNow)
+	timeNow = func() time.Time { return time.Date(2018, 3, 7, 10, 0, 0, 0, time.UTC) }
+
+	listRepoIssues := "GET " + fixtureRepo + "/issues"
+	addLabels := "POST " + fixtureRepo + "/issues/276/labels"
+	editIssue := "PATCH " + fixtureRepo + "/issues/276"
+	staleConfig := config.RepoConfig{Stale: config.StaleConfig{DaysUntilStale: 30, DaysUntilClose: 7, ExemptLabels: []string{"pinned"}}}
+	repo := &github.Repository{
+		Name:     github.String("syndesis-rest"),
+		FullName: github.String("syndesisio/syndesis-rest"),
+		Owner:    &github.User{Login: github.String("syndesisio")},
+	}
+
+	tests := []struct {
+		name          string
+		config        config.RepoConfig
+		issue         map[string]interface{}
+		expectedCalls []string
+	}{
+		{
+			name:          "inactive issue marked",
+			config:        staleConfig,
+			issue:         updatedDaysAgo(issueJSON(fixturePR), 31),
+			expectedCalls: []string{addLabels, createComment},
+		},
+		{
+			name:   "active issue left alone",
+			config: staleConfig,
+			issue:  updatedDaysAgo(issueJSON(fixturePR), 3),
+		},
+		{
+			name:   "stale issue within grace period",
+			config: staleConfig,
+			issue:  updatedDaysAgo(issueJSON(fixturePR, "stale"), 5),
+		},
+		{
+			name:          "stale issue closed",
+			config:        staleConfig,
+			issue:         updatedDaysAgo(issueJSON(fixturePR, "stale"), 8),
+			expectedCalls: []string{createComment, editIssue},
+		},
+		{
+			name:   "exempt issue",
+			config: staleConfig,
+			issue:  updatedDaysAgo(issueJSON(fixturePR, "pinned"), 31),
+		},
+		{
+			name:   "switched off",
+			config: config.RepoConfig{Stale: staleConfig.Stale, Handlers: map[string]bool{"stale": false}},
+			issue:  updatedDaysAgo(issueJSON(fixturePR), 31),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, map[string]interface{}{listRepoIssues: []interface{}{test.issue}})
+			defer fake.close()
+
+			if err := markStaleRepository(context.Background(), repo, fake.client(), test.config, zap.NewNop()); err != nil {
+				t.Errorf("marking stale issues failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestStaleActivity(t *testing.T) {
+	removeLabel := "DELETE " + fixtureRepo + "/issues/276/labels/stale"
+	staleConfig := config.RepoConfig{Stale: config.StaleConfig{DaysUntilStale: 30}}
+	staleComment := func(userType string) *github.IssueCommentEvent {
+		event := commentEvent("Still relevant", "roland", userType, false)
+		event.Issue.Labels = []github.Label{{Name: github.String("stale")}}
+		event.Sender = event.Comment.User
+		return event
+	}
+
+	tests := []struct {
+		name          string
+		event         *github.IssueCommentEvent
+		config        config.RepoConfig
+		expectedCalls []string
+	}{
+		{
+			name:          "comment on stale issue",
+			event:         staleComment("User"),
+			config:        staleConfig,
+			expectedCalls: []string{removeLabel},
+		},
+		{
+			name:   "bot comment",
+			event:  staleComment("Bot"),
+			config: staleConfig,
+		},
+		{
+			name:   "issue not stale",
+			event:  commentEvent("Still relevant", "roland", "User", false),
+			config: staleConfig,
+		},
+		{
+			name:  "not configured",
+			event: staleComment("User"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, nil)
+			defer fake.close()
+
+			if err := (&staleActivity{}).HandleEvent(context.Background(), test.event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Errorf("handling activity failed: %+v", err)
+			}
+			if calls := fake.mutatingCalls(); !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", test.expectedCalls, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+const titleLintContext = "pure-bot/title"
+
+// titleLint sets a failing status while the title of a pull request doesn't
+// match the configured pattern
+type titleLint struct{}
+
+func (h *titleLint) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *titleLint) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	if config.TitleLint.Pattern == "" {
+		return nil
+	}
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	// The status belongs to the head commit, so it's set again on every push
+	action := strings.ToLower(event.GetAction())
+	if action != "opened" && action != "reopened" && action != "edited" && action != "synchronize" {
+		return nil
+	}
+
+	pattern, err := regexp.Compile(config.TitleLint.Pattern)
+	if err != nil {
+		return errors.Wrapf(err, "invalid title pattern %q", config.TitleLint.Pattern)
+	}
+	if pattern.MatchString(event.PullRequest.GetTitle()) {
+		return createContextWithSpecifiedStatus(ctx, titleLintContext, successStatus, "OK - the title matches the required format", event.Repo, event.PullRequest, gh)
+	}
+
+	hint := config.TitleLint.Hint
+	if hint == "" {
+		hint = "must match " + config.TitleLint.Pattern
+	}
+	logger.Debug("PR title doesn't match the pattern", zap.Int("pr", event.PullRequest.GetNumber()), zap.String("pattern", config.TitleLint.Pattern))
+	return createContextWithSpecifiedStatus(ctx, titleLintContext, failureStatus, truncate("Blocked - the title "+hint, maxStatusDescriptionLength), event.Repo, event.PullRequest, gh)
+}
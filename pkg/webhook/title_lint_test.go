@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestTitleLint(t *testing.T) {
+	jiraConfig := config.RepoConfig{TitleLint: config.TitleLintConfig{Pattern: `^ENTESB-[0-9]+: `, Hint: "must start with a JIRA ticket"}}
+
+	tests := []struct {
+		name                string
+		action              string
+		title               string
+		config              config.RepoConfig
+		expectedState       string
+		expectedDescription string
+	}{
+		{
+			name:                "matching title",
+			action:              "opened",
+			title:               "ENTESB-1234: Add size labels",
+			config:              jiraConfig,
+			expectedState:       "success",
+			expectedDescription: "OK - the title matches the required format",
+		},
+		{
+			name:                "title without ticket",
+			action:              "edited",
+			title:               "Add size labels",
+			config:              jiraConfig,
+			expectedState:       "failure",
+			expectedDescription: "Blocked - the title must start with a JIRA ticket",
+		},
+		{
+			name:                "without hint",
+			action:              "synchronize",
+			title:               "Add size labels",
+			config:              config.RepoConfig{TitleLint: config.TitleLintConfig{Pattern: `^ENTESB-[0-9]+: `}},
+			expectedState:       "failure",
+			expectedDescription: "Blocked - the title must match ^ENTESB-[0-9]+: ",
+		},
+		{
+			name:   "labeled",
+			action: "labeled",
+			title:  "Add size labels",
+			config: jiraConfig,
+		},
+		{
+			name:   "not configured",
+			action: "opened",
+			title:  "Add size labels",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, nil)
+			defer fake.close()
+
+			event := pullRequestEvent(test.action)
+			event.PullRequest.Title = github.String(test.title)
+			if err := (&titleLint{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), test.config, zap.NewNop()); err != nil {
+				t.Fatalf("title lint failed: %+v", err)
+			}
+			if test.expectedState == "" {
+				if calls := fake.mutatingCalls(); len(calls) > 0 {
+					t.Errorf("expected no calls, got %v", calls)
+				}
+				return
+			}
+			var status struct {
+				State       string `json:"state"`
+				Context     string `json:"context"`
+				Description string `json:"description"`
+			}
+			if err := fake.requestBody(createStatus, &status); err != nil {
+				t.Fatal(err)
+			}
+			if status.State != test.expectedState || status.Context != titleLintContext || status.Description != test.expectedDescription {
+				t.Errorf("expected %s status %s %q, got %+v", titleLintContext, test.expectedState, test.expectedDescription, status)
+			}
+		})
+	}
+}
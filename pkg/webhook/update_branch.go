@@ -0,0 +1,47 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+const (
+	behindMergeableState = "behind"
+
+	// Preview media type for updating a pull request branch
+	lydianPreviewAcceptHeader = "application/vnd.github.lydian-preview+json"
+)
+
+// updatePullRequestBranch merges the base branch into the head branch of a
+// pull request. The update fails if the head moved away from the evaluated
+// commit in the meantime.
+func updatePullRequestBranch(ctx context.Context, gh *github.Client, owner, repository string, pr *github.PullRequest) error {
+	body := map[string]string{"expected_head_sha": pr.Head.GetSHA()}
+	req, err := gh.NewRequest("PUT", fmt.Sprintf("repos/%s/%s/pulls/%d/update-branch", owner, repository, pr.GetNumber()), body)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Accept", lydianPreviewAcceptHeader)
+
+	if _, err := gh.Do(ctx, req, nil); err != nil {
+		return errors.Wrapf(err, "failed to update branch of pull request %s", pr.GetHTMLURL())
+	}
+	return nil
+}
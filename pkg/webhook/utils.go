@@ -16,6 +16,7 @@ package webhook
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"unicode"
 
@@ -69,11 +70,12 @@ type commitStatus string
 var (
 	pendingStatus commitStatus = "pending"
 	successStatus commitStatus = "success"
+	failureStatus commitStatus = "failure"
 )
 
-func createContextWithSpecifiedStatus(contextName string, status commitStatus, description string, repo *github.Repository, pr *github.PullRequest, gh *github.Client) error {
+func createContextWithSpecifiedStatus(ctx context.Context, contextName string, status commitStatus, description string, repo *github.Repository, pr *github.PullRequest, gh *github.Client) error {
 	if _, _, err := gh.Repositories.CreateStatus(
-		context.Background(),
+		ctx,
 		repo.Owner.GetLogin(),
 		repo.GetName(),
 		pr.Head.GetSHA(),
@@ -94,3 +96,30 @@ func createContextWithSpecifiedStatus(contextName string, status commitStatus, d
 
 	return nil
 }
+
+// searchPullRequestsBySHA returns the open pull requests of a repository
+// containing the given commit
+func searchPullRequestsBySHA(ctx context.Context, gh *github.Client, repo *github.Repository, commitSHA string) ([]github.Issue, error) {
+	query := fmt.Sprintf("type:pr state:open repo:%s %s", repo.GetFullName(), commitSHA)
+	searchResult, _, err := gh.Search.Issues(ctx, query, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to search for open pull requests using query %s", query)
+	}
+
+	var prs []github.Issue
+	for _, issue := range searchResult.Issues {
+		if issue.PullRequestLinks != nil {
+			prs = append(prs, issue)
+		}
+	}
+	return prs, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
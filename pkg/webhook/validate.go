@@ -0,0 +1,386 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.starlark.net/starlark"
+)
+
+// GitHub rejects longer label names
+const maxLabelLength = 50
+
+var labelColorRE = regexp.MustCompile(`^#?[0-9a-fA-F]{6}$`)
+
+// ConfigError lists all problems found in a configuration, each prefixed with
+// the path of the offending setting
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+type configValidator struct {
+	problems []string
+}
+
+func (v *configValidator) addf(path string, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *configValidator) oneOf(path, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.addf(path, "unknown value %q, expected one of %s", value, strings.Join(allowed, ", "))
+}
+
+// ValidateConfig checks a configuration for mistakes which would otherwise
+// only show up when handling events, like unknown handler names or invalid
+// label names
+func ValidateConfig(cfg config.Config) error {
+	v := &configValidator{}
+	v.oneOf("queue.backend", cfg.Queue.Backend, "memory", "postgres")
+	if cfg.Queue.Backend == "postgres" && cfg.Queue.URL == "" {
+		v.addf("queue.url", "required by the postgres backend")
+	}
+	if cfg.Audit.File != "" && cfg.Audit.URL != "" {
+		v.addf("audit", "either a file or an URL can be given")
+	}
+	if cfg.ReconcileInterval < 0 {
+		v.addf("reconcileInterval", "must not be negative")
+	}
+	if cfg.StaleInterval < 0 {
+		v.addf("staleInterval", "must not be negative")
+	}
+	if cfg.LabelSyncInterval < 0 {
+		v.addf("labelSyncInterval", "must not be negative")
+	}
+	if cfg.Tracing.Endpoint != "" {
+		if u, err := url.Parse(cfg.Tracing.Endpoint); err != nil || !u.IsAbs() {
+			v.addf("tracing.endpoint", "%q is not an absolute URL", cfg.Tracing.Endpoint)
+		}
+	}
+	if cfg.HandlerTimeout < 0 {
+		v.addf("handlerTimeout", "must not be negative")
+	}
+	if cfg.Matrix.AccessToken != "" {
+		if u, err := url.Parse(cfg.Matrix.Homeserver); err != nil || !u.IsAbs() {
+			v.addf("matrix.homeserver", "%q is not an absolute URL", cfg.Matrix.Homeserver)
+		}
+	}
+	v.oneOf("digest.schedule", cfg.Digest.Schedule, "daily", "weekly")
+	if cfg.Digest.Schedule != "" {
+		if cfg.Digest.Hour < 0 || cfg.Digest.Hour > 23 {
+			v.addf("digest.hour", "%d is not an hour of the day", cfg.Digest.Hour)
+		}
+		if cfg.Digest.SMTP.Host == "" {
+			v.addf("digest.smtp.host", "missing")
+		}
+		if cfg.Digest.SMTP.From == "" {
+			v.addf("digest.smtp.from", "missing")
+		}
+	}
+	for i, notification := range cfg.Notifications {
+		if u, err := url.Parse(notification.URL); err != nil || !u.IsAbs() {
+			v.addf(fmt.Sprintf("notifications[%d].url", i), "%q is not an absolute URL", notification.URL)
+		}
+	}
+
+	appIDs := make(map[int64]bool)
+	if cfg.GitHubApp != (config.GitHubAppConfig{}) {
+		v.app("github", cfg.GitHubApp, appIDs)
+	}
+	for i, app := range cfg.GitHubApps {
+		v.app(fmt.Sprintf("githubApps[%d]", i), app, appIDs)
+	}
+
+	scriptNames := make(map[string]bool)
+	for i, script := range cfg.Scripts {
+		path := fmt.Sprintf("scripts[%d]", i)
+		if script.Name == "" {
+			v.addf(path+".name", "missing")
+		} else if scriptNames[script.Name] {
+			v.addf(path+".name", "script %q is defined twice", script.Name)
+		}
+		scriptNames[script.Name] = true
+		if len(script.Events) == 0 {
+			v.addf(path+".events", "missing")
+		}
+		if script.File == "" {
+			v.addf(path+".file", "missing")
+		} else if isWasmScript(script) {
+			if err := validateWasmScript(script.File); err != nil {
+				v.addf(path+".file", "%v", err)
+			}
+		} else if _, _, err := starlark.SourceProgram(script.File, nil, isScriptGlobal); err != nil {
+			v.addf(path+".file", "%v", err)
+		}
+	}
+
+	v.repoConfig("defaults", cfg.DefaultRepo)
+	for _, org := range sortedKeys(cfg.Orgs) {
+		v.repoConfig("orgs."+org, cfg.Orgs[org])
+	}
+	for _, repo := range sortedKeys(cfg.Repos) {
+		v.repoConfig("repos."+repo, cfg.Repos[repo])
+	}
+
+	if len(v.problems) > 0 {
+		return &ConfigError{v.problems}
+	}
+	return nil
+}
+
+func (v *configValidator) app(path string, app config.GitHubAppConfig, seen map[int64]bool) {
+	if app.AppID == 0 {
+		v.addf(path+".appId", "missing")
+	} else if seen[app.AppID] {
+		v.addf(path+".appId", "app %d is configured twice", app.AppID)
+	}
+	seen[app.AppID] = true
+	if app.PrivateKeyFile == "" {
+		v.addf(path+".privateKey", "missing")
+	}
+}
+
+// validateRepoConfig checks the configuration of a single repository, e.g.
+// from its configuration file
+func validateRepoConfig(path string, repoConfig config.RepoConfig) error {
+	v := &configValidator{}
+	v.repoConfig(path, repoConfig)
+	if len(v.problems) > 0 {
+		return &ConfigError{v.problems}
+	}
+	return nil
+}
+
+func (v *configValidator) repoConfig(path string, c config.RepoConfig) {
+	handlersMu.RLock()
+	for name := range c.Handlers {
+		if strings.HasPrefix(name, commandHandlerPrefix) {
+			if _, found := lookupCommand(strings.TrimPrefix(name, commandHandlerPrefix)); !found {
+				v.addf(path+".handlers", "unknown command %q", strings.TrimPrefix(name, commandHandlerPrefix))
+			}
+			continue
+		}
+		if !handlerNames[name] && name != scriptsHandlerName && !strings.HasPrefix(name, scriptHandlerPrefix) {
+			v.addf(path+".handlers", "unknown handler %q", name)
+		}
+	}
+	handlersMu.RUnlock()
+
+	v.labels(path+".labels.newIssues", c.Labels.NewIssues...)
+	v.labels(path+".labels.wip", c.Labels.Wip...)
+	v.labels(path+".labels.reviewRequested", c.Labels.ReviewRequested)
+	v.labels(path+".labels.approved", c.Labels.Approved)
+	v.labels(path+".labels.hold", c.Labels.Hold)
+	v.labels(path+".labels.needsRebase", c.Labels.NeedsRebase)
+	v.labels(path+".postMerge.label", c.PostMerge.Label)
+	v.labels(path+".autoRetest.flakeLabel", c.AutoRetest.FlakeLabel)
+	v.labels(path+".dependencyUpdates.needsReviewLabel", c.DependencyUpdates.NeedsReviewLabel)
+	v.labels(path+".triageLabels", c.TriageLabels...)
+	v.labels(path+".stale.label", c.Stale.Label)
+	v.labels(path+".stale.exemptLabels", c.Stale.ExemptLabels...)
+	if c.SizeLabels.Prefix != "" {
+		v.labels(path+".sizeLabels.prefix", c.SizeLabels.Prefix+sizes[len(sizes)-1])
+	}
+
+	v.oneOf(path+".mergeMethod", c.MergeMethod, "merge", "squash", "rebase")
+	v.oneOf(path+".mergeStrategy", c.MergeStrategy, "bot", nativeMergeStrategy)
+	v.oneOf(path+".commitLint.target", c.CommitLint.Target, commitLintCommits, commitLintTitle)
+	v.oneOf(path+".reviewerAssignment.strategy", c.ReviewerAssignment.Strategy, roundRobinStrategy, loadBalancedStrategy)
+	for _, t := range c.DependencyUpdates.AllowedUpdateTypes {
+		v.oneOf(path+".dependencyUpdates.allowedUpdateTypes", t, "major", "minor", "patch")
+	}
+	for _, action := range c.Bootstrap {
+		v.oneOf(path+".bootstrap", action, labelSyncBootstrap, branchProtectionBootstrap)
+	}
+	for i, policy := range c.AuthorPolicies {
+		policyPath := fmt.Sprintf("%s.authorPolicies[%d]", path, i)
+		v.oneOf(policyPath+".mergeMethod", policy.MergeMethod, "merge", "squash", "rebase")
+		v.labels(policyPath+".label", policy.Label)
+	}
+
+	for _, spec := range c.MergeWindows {
+		if _, err := parseMergeWindow(spec); err != nil {
+			v.addf(path+".mergeWindows", "%v", err)
+		}
+	}
+	v.patterns(path+".wipPatterns", c.WipPatterns)
+	for i, rule := range c.PathLabels {
+		rulePath := fmt.Sprintf("%s.pathLabels[%d]", path, i)
+		if rule.Label == "" {
+			v.addf(rulePath+".label", "missing")
+		}
+		v.labels(rulePath+".label", rule.Label)
+		for _, glob := range rule.Paths {
+			if _, err := globRegexp(glob); err != nil {
+				v.addf(rulePath+".paths", "invalid path %q: %v", glob, err)
+			}
+		}
+	}
+	for i, mapping := range c.BranchMilestones {
+		mappingPath := fmt.Sprintf("%s.branchMilestones[%d]", path, i)
+		v.patterns(mappingPath+".branch", []string{mapping.Branch})
+		if mapping.Milestone == "" {
+			v.addf(mappingPath+".milestone", "missing")
+		}
+	}
+	v.patterns(path+".autoRetest.checks", c.AutoRetest.Checks)
+	v.patterns(path+".branchCleanup.exclude", c.BranchCleanup.Exclude)
+	v.patterns(path+".backport.branches", c.Backport.Branches)
+	if c.TitleLint.Pattern != "" {
+		v.patterns(path+".titleLint.pattern", []string{c.TitleLint.Pattern})
+	}
+	if c.MergePolicy.URL != "" {
+		if u, err := url.Parse(c.MergePolicy.URL); err != nil || !u.IsAbs() {
+			v.addf(path+".mergePolicy.url", "%q is not an absolute URL", c.MergePolicy.URL)
+		}
+	}
+	if c.CLA.URL != "" {
+		if u, err := url.Parse(c.CLA.URL); err != nil || !u.IsAbs() {
+			v.addf(path+".cla.url", "%q is not an absolute URL", c.CLA.URL)
+		}
+	}
+	if project := c.Project; project.Opened != "" || project.Approved != "" || project.Merged != "" || project.Released != "" {
+		if project.Owner == "" {
+			v.addf(path+".project.owner", "missing")
+		}
+		if project.Number <= 0 {
+			v.addf(path+".project.number", "must be positive")
+		}
+	}
+	v.template(path+".mergeCommit.title", c.MergeCommit.Title)
+	v.template(path+".mergeCommit.message", c.MergeCommit.Message)
+	v.template(path+".postMerge.comment", c.PostMerge.Comment)
+	v.template(path+".welcome.comment", c.Welcome.Comment)
+	v.template(path+".stale.comment", c.Stale.Comment)
+	v.template(path+".stale.closeComment", c.Stale.CloseComment)
+	v.template(path+".releaseNotes.template", c.ReleaseNotes.Template)
+	for i, category := range c.ReleaseNotes.Categories {
+		if category.Title == "" {
+			v.addf(fmt.Sprintf("%s.releaseNotes.categories[%d].title", path, i), "missing")
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, def := range c.LabelSync.Labels {
+		defPath := fmt.Sprintf("%s.labelSync.labels[%d]", path, i)
+		v.labels(defPath+".name", def.Name)
+		if def.Name == "" {
+			v.addf(defPath+".name", "missing")
+		}
+		if names[strings.ToLower(def.Name)] {
+			v.addf(defPath+".name", "label %q is defined twice", def.Name)
+		}
+		names[strings.ToLower(def.Name)] = true
+		if !labelColorRE.MatchString(def.Color) {
+			v.addf(defPath+".color", "%q is not a hex color like \"d73a4a\"", def.Color)
+		}
+	}
+
+	if c.RequiredApprovals < 0 {
+		v.addf(path+".requiredApprovals", "must not be negative")
+	}
+	if c.MergeAttempts < 0 {
+		v.addf(path+".mergeAttempts", "must not be negative")
+	}
+	if c.ReviewerAssignment.Count < 0 {
+		v.addf(path+".reviewerAssignment.count", "must not be negative")
+	}
+	for i, pool := range c.ReviewerAssignment.Pools {
+		if len(pool.Reviewers) == 0 {
+			v.addf(fmt.Sprintf("%s.reviewerAssignment.pools[%d].reviewers", path, i), "missing")
+		}
+	}
+	if c.Stale.DaysUntilStale < 0 {
+		v.addf(path+".stale.daysUntilStale", "must not be negative")
+	}
+	if c.Stale.DaysUntilClose < 0 {
+		v.addf(path+".stale.daysUntilClose", "must not be negative")
+	}
+	v.sizeLimits(path+".sizeLabels.lines", c.SizeLabels.Lines)
+	v.sizeLimits(path+".sizeLabels.files", c.SizeLabels.Files)
+}
+
+// labels checks label names. Empty names are left out, they switch a feature
+// off.
+func (v *configValidator) labels(path string, names ...string) {
+	for _, name := range names {
+		switch {
+		case strings.TrimSpace(name) != name:
+			v.addf(path, "label %q has leading or trailing whitespace", name)
+		case len(name) > maxLabelLength:
+			v.addf(path, "label %q is longer than %d characters", name, maxLabelLength)
+		}
+	}
+}
+
+// patterns checks the regular expressions matched against PR titles or check
+// names
+func (v *configValidator) patterns(path string, patterns []string) {
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			v.addf(path, "invalid pattern %q: %v", pattern, err)
+		}
+	}
+}
+
+// sizeLimits checks the upper bounds of the sizes below XXL
+func (v *configValidator) sizeLimits(path string, limits []int) {
+	if len(limits) == 0 {
+		return
+	}
+	if len(limits) != len(sizes)-1 {
+		v.addf(path, "expected %d limits for the sizes %s, got %d", len(sizes)-1, strings.Join(sizes[:len(sizes)-1], ", "), len(limits))
+		return
+	}
+	for i, limit := range limits {
+		if limit <= 0 || (i > 0 && limit <= limits[i-1]) {
+			v.addf(path, "limits must be positive and increasing")
+			return
+		}
+	}
+}
+
+func (v *configValidator) template(path string, text string) {
+	if _, err := template.New(path).Parse(text); err != nil {
+		v.addf(path, "invalid template: %v", err)
+	}
+}
+
+func sortedKeys(m map[string]config.RepoConfig) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+func TestValidateConfig(t *testing.T) {
+	if err := ValidateConfig(config.NewWithDefaults()); err != nil {
+		t.Errorf("expected defaults to be valid, got %v", err)
+	}
+
+	cfg := config.Config{
+		GitHubApp:     config.GitHubAppConfig{AppID: 1, PrivateKeyFile: "/secrets/key"},
+		GitHubApps:    []config.GitHubAppConfig{{AppID: 1}},
+		Queue:         config.QueueConfig{Backend: "postgres"},
+		Notifications: []config.NotificationConfig{{URL: "/hooks"}},
+		Matrix:        config.MatrixConfig{AccessToken: "token"},
+		Scripts:       []config.ScriptConfig{{Name: "triage", Events: []string{"issues"}}},
+		Digest:        config.DigestConfig{Schedule: "daily", Hour: 24, SMTP: config.SMTPConfig{Host: "smtp.example.com"}},
+		DefaultRepo: config.RepoConfig{
+			Labels:       config.LabelConfig{Approved: "approved ", Wip: []string{"wip"}},
+			Handlers:     map[string]bool{"automerge": false},
+			MergeMethod:  "fast-forward",
+			MergeWindows: []string{"Mon-Fri 08:00-18:00 Europe/Berlin"},
+		},
+		Repos: map[string]config.RepoConfig{
+			"syndesis": {
+				Handlers:    map[string]bool{"command:hold-on": false},
+				WipPatterns: []string{"(wip"},
+				MergeCommit: config.MergeCommitConfig{Title: "{{ .Title }"},
+				SizeLabels:  config.SizeLabelsConfig{Lines: []int{10, 5, 100, 500, 1000}},
+				LabelSync: config.LabelSyncConfig{Labels: []config.LabelDefinition{
+					{Name: "bug", Color: "d73a4a"},
+					{Name: "Bug", Color: "red"},
+				}},
+			},
+		},
+	}
+	err := ValidateConfig(cfg)
+	configErr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("expected a ConfigError, got %v", err)
+	}
+	expected := []string{
+		"queue.url: required by the postgres backend",
+		`matrix.homeserver: "" is not an absolute URL`,
+		"digest.hour: 24 is not an hour of the day",
+		"digest.smtp.from: missing",
+		`notifications[0].url: "/hooks" is not an absolute URL`,
+		"githubApps[0].appId: app 1 is configured twice",
+		"githubApps[0].privateKey: missing",
+		"scripts[0].file: missing",
+		`defaults.handlers: unknown handler "automerge"`,
+		`defaults.labels.approved: label "approved " has leading or trailing whitespace`,
+		`defaults.mergeMethod: unknown value "fast-forward", expected one of merge, squash, rebase`,
+		`repos.syndesis.handlers: unknown command "hold-on"`,
+		"repos.syndesis.wipPatterns: invalid pattern \"(wip\": error parsing regexp: missing closing ): `(wip`",
+		"repos.syndesis.mergeCommit.title: invalid template: template: repos.syndesis.mergeCommit.title:1: unexpected \"}\" in operand",
+		`repos.syndesis.labelSync.labels[1].name: label "Bug" is defined twice`,
+		`repos.syndesis.labelSync.labels[1].color: "red" is not a hex color like "d73a4a"`,
+		"repos.syndesis.sizeLabels.lines: limits must be positive and increasing",
+	}
+	if !reflect.DeepEqual(configErr.Problems, expected) {
+		t.Errorf("expected problems\n%q\ngot\n%q", expected, configErr.Problems)
+	}
+}
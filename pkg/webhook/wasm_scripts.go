@@ -0,0 +1,169 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+const (
+	// Module providing the functions which request actions
+	wasmHostModule = "pure_bot"
+	// Limits the memory of a module to 32 MiB
+	wasmMemoryLimitPages = 512
+)
+
+// wasmExports are the functions a WebAssembly script must export. The event
+// type and payload are passed to "handle" in memory returned by "alloc".
+var wasmExports = []string{"alloc", "handle"}
+
+// isWasmScript tells whether a script is a WebAssembly module rather than
+// a Starlark file
+func isWasmScript(script config.ScriptConfig) bool {
+	return strings.HasSuffix(script.File, ".wasm")
+}
+
+// runWasmScript instantiates a WebAssembly module and calls its "handle"
+// function with the event. Modules only get the functions of the host
+// module, no WASI, so they can't access files, the network, the clock or
+// credentials.
+func runWasmScript(ctx context.Context, script config.ScriptConfig, messageType string, payload []byte) ([]scriptAction, error) {
+	binary, err := ioutil.ReadFile(script.File)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read script %s", script.Name)
+	}
+
+	// Closing the module when ctx is done stops endless loops
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(wasmMemoryLimitPages))
+	defer runtime.Close(ctx)
+
+	var actions []scriptAction
+	host := runtime.NewHostModuleBuilder(wasmHostModule)
+	host.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, labelPtr, labelLen, number uint32) {
+		actions = append(actions, scriptAction{Action: "addLabel", Label: wasmString(m, labelPtr, labelLen), Number: int(number)})
+	}).Export("addLabel")
+	host.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, labelPtr, labelLen, number uint32) {
+		actions = append(actions, scriptAction{Action: "removeLabel", Label: wasmString(m, labelPtr, labelLen), Number: int(number)})
+	}).Export("removeLabel")
+	host.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, bodyPtr, bodyLen, number uint32) {
+		actions = append(actions, scriptAction{Action: "comment", Body: wasmString(m, bodyPtr, bodyLen), Number: int(number)})
+	}).Export("comment")
+	host.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, shaPtr, shaLen, contextPtr, contextLen, statePtr, stateLen, descriptionPtr, descriptionLen, targetURLPtr, targetURLLen uint32) {
+		actions = append(actions, scriptAction{
+			Action:      "setStatus",
+			SHA:         wasmString(m, shaPtr, shaLen),
+			Context:     wasmString(m, contextPtr, contextLen),
+			State:       wasmString(m, statePtr, stateLen),
+			Description: wasmString(m, descriptionPtr, descriptionLen),
+			TargetURL:   wasmString(m, targetURLPtr, targetURLLen),
+		})
+	}).Export("setStatus")
+	if _, err := host.Instantiate(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to provide host functions")
+	}
+
+	module, err := runtime.InstantiateWithConfig(ctx, binary, wazero.NewModuleConfig().WithName(script.Name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to instantiate script %s", script.Name)
+	}
+	for _, name := range wasmExports {
+		if module.ExportedFunction(name) == nil {
+			return nil, errors.Errorf("script %s doesn't export %s", script.Name, name)
+		}
+	}
+	if module.Memory() == nil {
+		return nil, errors.Errorf("script %s doesn't export its memory", script.Name)
+	}
+
+	typePtr, err := wasmWrite(ctx, module, []byte(messageType))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to pass event to script %s", script.Name)
+	}
+	payloadPtr, err := wasmWrite(ctx, module, payload)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to pass event to script %s", script.Name)
+	}
+	if _, err := module.ExportedFunction("handle").Call(ctx, uint64(typePtr), uint64(len(messageType)), uint64(payloadPtr), uint64(len(payload))); err != nil {
+		return nil, errors.Wrapf(err, "script %s failed", script.Name)
+	}
+	return actions, nil
+}
+
+// wasmWrite copies data into memory allocated by the module
+func wasmWrite(ctx context.Context, module api.Module, data []byte) (uint32, error) {
+	results, err := module.ExportedFunction("alloc").Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+	if len(results) != 1 {
+		return 0, errors.New("alloc doesn't return a pointer")
+	}
+	ptr := uint32(results[0])
+	if !module.Memory().Write(ptr, data) {
+		return 0, errors.Errorf("alloc returned %d, which is out of memory bounds", ptr)
+	}
+	return ptr, nil
+}
+
+// wasmString reads a string argument of a host function. Invalid pointers
+// trap, failing the script.
+func wasmString(m api.Module, ptr, length uint32) string {
+	data, ok := m.Memory().Read(ptr, length)
+	if !ok {
+		panic(errors.Errorf("string at %d with length %d is out of memory bounds", ptr, length))
+	}
+	return string(data)
+}
+
+// validateWasmScript checks that a module only imports host functions and
+// exports the functions and the memory called by pure-bot
+func validateWasmScript(file string) error {
+	binary, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	runtime := wazero.NewRuntime(ctx)
+	defer runtime.Close(ctx)
+	compiled, err := runtime.CompileModule(ctx, binary)
+	if err != nil {
+		return err
+	}
+
+	for _, imported := range compiled.ImportedFunctions() {
+		if module, name, _ := imported.Import(); module != wasmHostModule {
+			return errors.Errorf("imports %s.%s, only %s functions are available", module, name, wasmHostModule)
+		}
+	}
+	exported := compiled.ExportedFunctions()
+	for _, name := range wasmExports {
+		if _, found := exported[name]; !found {
+			return errors.Errorf("doesn't export %s", name)
+		}
+	}
+	if len(compiled.ExportedMemories()) == 0 {
+		return errors.New("doesn't export its memory")
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+// wasmModule assembles a module importing a host function, exporting its
+// memory, a bump allocator and "handle" with the given body
+func wasmModule(importModule string, importName string, handleBody ...byte) []byte {
+	i32 := byte(0x7f)
+	module := []byte("\x00asm\x01\x00\x00\x00")
+	module = append(module, wasmSection(1,
+		[]byte{0x60, 3, i32, i32, i32, 0},
+		[]byte{0x60, 1, i32, 1, i32},
+		[]byte{0x60, 4, i32, i32, i32, i32, 0},
+	)...)
+	module = append(module, wasmSection(2, append(append(wasmName(importModule), wasmName(importName)...), 0x00, 0))...)
+	module = append(module, wasmSection(3, []byte{1}, []byte{2})...)
+	module = append(module, wasmSection(5, []byte{0x00, 1})...)
+	// Heap pointer, starting at 1024
+	module = append(module, wasmSection(6, []byte{i32, 0x01, 0x41, 0x80, 0x08, 0x0b})...)
+	module = append(module, wasmSection(7,
+		append(wasmName("memory"), 0x02, 0),
+		append(wasmName("alloc"), 0x00, 1),
+		append(wasmName("handle"), 0x00, 2),
+	)...)
+	alloc := []byte{0, 0x23, 0, 0x23, 0, 0x20, 0, 0x6a, 0x24, 0, 0x0b}
+	handle := append(append([]byte{0}, handleBody...), 0x0b)
+	module = append(module, wasmSection(10, append([]byte{byte(len(alloc))}, alloc...), append([]byte{byte(len(handle))}, handle...))...)
+	return module
+}
+
+func wasmSection(id byte, entries ...[]byte) []byte {
+	content := []byte{byte(len(entries))}
+	for _, entry := range entries {
+		content = append(content, entry...)
+	}
+	return append([]byte{id, byte(len(content))}, content...)
+}
+
+func wasmName(name string) []byte {
+	return append([]byte{byte(len(name))}, name...)
+}
+
+func TestRunWasmScripts(t *testing.T) {
+	payload, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "pull_request_labeled.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := parseWebHook("pull_request", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := extractRepository(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := func(name string, module []byte) string {
+		path := filepath.Join(dir, name+".wasm")
+		if err := ioutil.WriteFile(path, module, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// Labels the pull request with the event type
+	labelEventType := file("label", wasmModule("pure_bot", "addLabel", 0x20, 0, 0x20, 1, 0x41, 0, 0x10, 0))
+
+	gh := newFakeGitHub(t, nil)
+	defer gh.close()
+	scripts := []config.ScriptConfig{{Name: "label", Events: []string{"pull_request"}, File: labelEventType}}
+	if err := runScripts(context.Background(), scripts, "pull_request", payload, event, repo, gh.client(), config.RepoConfig{}, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	issue := fixtureRepo + "/issues/" + strconv.Itoa(fixturePR)
+	var labels []string
+	if err := gh.requestBody("POST "+issue+"/labels", &labels); err != nil || !reflect.DeepEqual(labels, []string{"pull_request"}) {
+		t.Errorf("expected pull_request label, got %v (%v)", labels, err)
+	}
+
+	for name, module := range map[string][]byte{
+		"trap":         wasmModule("pure_bot", "addLabel", 0x00),
+		"endless loop": wasmModule("pure_bot", "addLabel", 0x03, 0x40, 0x0c, 0, 0x0b),
+		"wasi":         wasmModule("wasi_snapshot_preview1", "fd_write"),
+	} {
+		failing := []config.ScriptConfig{{Name: name, Events: []string{"pull_request"}, File: file("failing", module), Timeout: 100 * time.Millisecond}}
+		if err := runScripts(context.Background(), failing, "pull_request", payload, event, repo, gh.client(), config.RepoConfig{}, zap.NewNop()); err == nil {
+			t.Errorf("%s: expected script to fail", name)
+		}
+	}
+}
+
+func TestValidateWasmScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "plugin.wasm")
+
+	for module, valid := range map[string]bool{
+		string(wasmModule("pure_bot", "comment")):                true,
+		string(wasmModule("wasi_snapshot_preview1", "fd_write")): false,
+		"not a module": false,
+	} {
+		if err := ioutil.WriteFile(path, []byte(module), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := validateWasmScript(path); (err == nil) != valid {
+			t.Errorf("expected module %q to be valid: %v, got %v", module, valid, err)
+		}
+	}
+}
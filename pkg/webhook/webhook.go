@@ -15,69 +15,196 @@
 package webhook
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/multierr"
 
 	"github.com/imdario/mergo"
+	"github.com/syndesisio/pure-bot/pkg/audit"
 	"github.com/syndesisio/pure-bot/pkg/config"
 	"github.com/syndesisio/pure-bot/pkg/github/apps"
+	"github.com/syndesisio/pure-bot/pkg/github/graphql"
+	"github.com/syndesisio/pure-bot/pkg/notify"
+	"github.com/syndesisio/pure-bot/pkg/queue"
+	"github.com/syndesisio/pure-bot/pkg/tracing"
 	"go.uber.org/zap"
 	"reflect"
 	//"github.com/davecgh/go-spew/spew"
 )
 
+const (
+	// Header carrying the unique ID of a webhook delivery
+	deliveryHeader = "X-GitHub-Delivery"
+	// Headers carrying the HMAC-SHA1 and HMAC-SHA256 signatures of a webhook
+	// payload
+	signatureHeader       = "X-Hub-Signature"
+	signatureSHA256Header = "X-Hub-Signature-256"
+)
+
 type GitHubAppsClientFunc func(installationID int) (*github.Client, error)
 
+// Handler reacts on GitHub webhook events. Besides the built-in handlers,
+// additional ones can be compiled in with RegisterHandler.
 type Handler interface {
-	HandleEvent(eventObject interface{}, client *github.Client, config config.RepoConfig, logger *zap.Logger) error
-
+	// HandleEvent is called with the parsed event, e.g. a
+	// *github.PullRequestEvent, REST and GraphQL clients authenticated as
+	// the installation the event belongs to and the configuration of the
+	// event's repository. The GraphQL client sends its requests through the
+	// REST client. ctx is done when the handler timeout expires or the bot
+	// shuts down, GitHub requests should be made with it.
+	HandleEvent(ctx context.Context, eventObject interface{}, client *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error
+
+	// EventTypesHandled returns the webhook event types, like "pull_request"
 	EventTypesHandled() []string
 }
 
+// namedHandler is a handler with the name it got registered with
+type namedHandler struct {
+	name string
+	Handler
+}
+
 var (
-	// List of all handlers used
-	handlers = []Handler{
-		&addLabelOnReviewApproval{},
-		&reviewerRequest{},
-		&autoMerger{},
-		&wip{},
-		&newIssueLabel{},
-		&boardUpdate{},
-		//		&dismissReview{},
-		//		&failedStatusCheckAddComment{},
-	}
-	handlerMap map[string][]Handler
+	handlersMu sync.RWMutex
+	// Registered handlers per event type, in registration order
+	handlerMap   = make(map[string][]namedHandler)
+	handlerNames = make(map[string]bool)
+
+	// Records all mutating actions, nil if auditing is disabled
+	auditLog *audit.Log
+
+	// Notifies downstream systems about actions, nil without endpoints
+	notifier *notify.Notifier
+
+	// Traces webhook processing, nil if tracing is disabled
+	tracer *tracing.Tracer
+
+	// Configuration for handlers which are not bound to a single repository,
+	// replaced when the configuration gets reloaded
+	botConfigMu sync.RWMutex
+	botConfig   config.Config
 )
 
+// UpdateConfig replaces the configuration of the bot. Events which are being
+// processed keep the repository configuration they started with.
+func UpdateConfig(cfg config.Config) {
+	botConfigMu.Lock()
+	defer botConfigMu.Unlock()
+	botConfig = cfg
+}
+
+func currentConfig() config.Config {
+	botConfigMu.RLock()
+	defer botConfigMu.RUnlock()
+	return botConfig
+}
+
 func init() {
-	handlerMap = make(map[string][]Handler)
+	RegisterHandler("approvalLabel", &addLabelOnReviewApproval{})
+	RegisterHandler("reviewerRequest", &reviewerRequest{})
+	RegisterHandler("autoMerge", &autoMerger{})
+	RegisterHandler("wip", &wip{})
+	RegisterHandler("newIssueLabel", &newIssueLabel{})
+	RegisterHandler("boardUpdate", &boardUpdate{})
+	RegisterHandler("labelSync", &labelSync{})
+	RegisterHandler("autoRetest", &autoRetest{})
+	RegisterHandler("descriptionCheck", &descriptionCheck{})
+	RegisterHandler("dependencyUpdates", &dependencyUpdates{})
+	RegisterHandler("installation", &installationLifecycle{})
+	RegisterHandler("owners", &ownersApproval{})
+	RegisterHandler("needsRebase", &needsRebase{})
+	RegisterHandler(commandsHandlerName, &slashCommands{})
+	RegisterHandler("welcome", &welcome{})
+	RegisterHandler("stale", &staleActivity{})
+	RegisterHandler("sizeLabels", &sizeLabels{})
+	RegisterHandler("pathLabels", &pathLabels{})
+	RegisterHandler("dco", &dcoSignOff{})
+	RegisterHandler("cla", &claVerification{})
+	RegisterHandler("commitLint", &commitLint{})
+	RegisterHandler("titleLint", &titleLint{})
+	RegisterHandler("codeOwners", &codeOwnersReviewers{})
+	RegisterHandler("reviewerPools", &reviewerPools{})
+	RegisterHandler("branchCleanup", &branchCleanup{})
+	RegisterHandler("backport", &backportLabels{})
+	RegisterHandler("releaseNotes", &releaseNotes{})
+	RegisterHandler("release", &semverRelease{})
+	RegisterHandler("branchMilestone", &branchMilestone{})
+	RegisterHandler("projectBoard", &projectBoard{})
+	//	RegisterHandler("dismissReview", &dismissReview{})
+	//	RegisterHandler("failedStatusCheckComment", &failedStatusCheckAddComment{})
+}
 
-	// Register handlers per event type
-	for _, handler := range handlers {
-		for _, eventType := range handler.EventTypesHandled() {
-			if handlerMap[eventType] == nil {
-				handlerMap[eventType] = make([]Handler, 0)
-			}
-			handlerMap[eventType] = append(handlerMap[eventType], handler)
-		}
+// RegisterHandler adds a handler for the event types it handles. Handlers
+// are called in the order of registration, so after the built-in ones. It
+// panics if the name is taken already or the handler is nil.
+func RegisterHandler(name string, handler Handler) {
+	if handler == nil {
+		panic("webhook: RegisterHandler called with nil handler " + name)
+	}
+
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	if handlerNames[name] {
+		panic("webhook: RegisterHandler called twice for handler " + name)
 	}
+	handlerNames[name] = true
+	for _, eventType := range handler.EventTypesHandled() {
+		handlerMap[eventType] = append(handlerMap[eventType], namedHandler{name, handler})
+	}
+}
+
+// handlersFor returns the handlers registered for an event type
+func handlersFor(eventType string) []namedHandler {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	return handlerMap[eventType]
 }
 
-func newGitHubClient(appID int64, privateKeyFile string, installationID int64) (*github.Client, error) {
-	key, err := ioutil.ReadFile(privateKeyFile)
+func newGitHubClient(appCfg config.GitHubAppConfig, installationID int64, deliveryID string, logger *zap.Logger) (*github.Client, error) {
+	key, err := ioutil.ReadFile(appCfg.PrivateKeyFile)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to read private key file")
 	}
+	return apps.Client(appEndpoint(appCfg), appCfg.AppID, installationID, key, clientWrappers(installationID, deliveryID, logger)...)
+}
 
-	return apps.Client(appID, installationID, key)
+// clientWrappers decorate the transport of GitHub clients, in this order
+func clientWrappers(installationID int64, deliveryID string, logger *zap.Logger) []func(http.RoundTripper) http.RoundTripper {
+	wrappers := []func(http.RoundTripper) http.RoundTripper{
+		auditLog.Wrap(installationID, deliveryID),
+		notifier.Wrap(installationID, deliveryID),
+		rateLimited(installationID, logger.Named("rate-limit")),
+		conditionalCache(installationID),
+	}
+	if currentConfig().DryRun {
+		// Outermost, so that skipped requests don't show up in the audit log
+		wrappers = append(wrappers, dryRun(logger.Named("dry-run")))
+	}
+	// Spans cover the time spent waiting for the rate limit as well
+	wrappers = append(wrappers, tracer.Wrap())
+	return wrappers
 }
 
-func createClient(appCfg config.GitHubAppConfig, event interface{}) (*github.Client, error) {
+func createClient(ctx context.Context, cfg config.Config, event interface{}, deliveryID string, logger *zap.Logger) (*github.Client, error) {
+	if localToken != "" {
+		return apps.TokenClient(appEndpoint(cfg.GitHubApp), localToken, clientWrappers(0, deliveryID, logger)...)
+	}
 
 	val := reflect.Indirect(reflect.ValueOf(event))
 	// Find installation via inspection
@@ -85,10 +212,14 @@ func createClient(appCfg config.GitHubAppConfig, event interface{}) (*github.Cli
 		return nil, errors.New("event does not contain an installation ID, cannot create github client")
 	}
 	installation := val.FieldByName("Installation").Interface().(*github.Installation)
-	if installation == nil {
+	if installation.GetID() == 0 {
 		return nil, errors.Errorf("no installation in event found, so no GitHub client could be created")
 	}
-	client, err := newGitHubClient(appCfg.AppID, appCfg.PrivateKeyFile, *installation.ID)
+	appCfg, err := appForInstallation(ctx, cfg, installation.GetID())
+	if err != nil {
+		return nil, err
+	}
+	client, err := newGitHubClient(appCfg, installation.GetID(), deliveryID, logger)
 	if err != nil {
 		return nil, errors.New("cannot create github client")
 	}
@@ -96,13 +227,53 @@ func createClient(appCfg config.GitHubAppConfig, event interface{}) (*github.Cli
 }
 
 func NewGithubHTTPHandler(cfg config.WebhookConfig, config config.Config, logger *zap.Logger) (http.HandlerFunc, error) {
-	webhookSecret := ([]byte)(cfg.Secret)
+	var err error
+	auditLog, err = audit.New(config.Audit, logger.Named("audit"))
+	if err != nil {
+		return nil, err
+	}
+	notifier = notify.New(config.Notifications, logger.Named("notify"))
+	slack = notify.NewSlack(config.Slack, logger.Named("slack"))
+	matrix = notify.NewMatrix(config.Matrix, logger.Named("matrix"))
+	if tracer, err = tracing.New(config.Tracing, logger.Named("tracing")); err != nil {
+		return nil, err
+	}
+	UpdateConfig(config)
+
+	if eventQueue, queueOptions, err = queue.New(config.Queue, logger.Named("queue")); err != nil {
+		return nil, err
+	}
+	if assignments, err = queue.NewAssignmentStore(config.Queue); err != nil {
+		return nil, err
+	}
+	recentDeliveries = newDeliveryHistory(cfg.HistorySize)
+	go consumeQueue(logger.Named("queue"))
+
+	secrets := cfg.Secrets
+	if cfg.Secret != "" {
+		secrets = append([]string{cfg.Secret}, secrets...)
+	}
+	deliveries := newLRUDeduplicator(cfg.DedupCacheSize)
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !inFlight.begin() {
+			logger.Info("shutting down, rejecting webhook")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		defer inFlight.done()
+
+		messageType := github.WebHookType(r)
+		deliveryID := r.Header.Get(deliveryHeader)
+		_, span := tracer.StartDelivery(r.Context(), deliveryID, "webhook "+messageType)
+		defer span.End()
+		span.SetAttributes(attribute.String("github.event", messageType), attribute.String("github.delivery", deliveryID))
+
 		var payload []byte
-		if cfg.Secret != "" {
-			pl, err := github.ValidatePayload(r, webhookSecret)
+		if len(secrets) > 0 {
+			pl, err := validatePayload(r, secrets)
 			if err != nil {
 				logger.Error("webhook payload validation failed", zap.Error(err))
+				tracing.SetError(span, err)
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
@@ -111,6 +282,7 @@ func NewGithubHTTPHandler(cfg config.WebhookConfig, config config.Config, logger
 			pl, err := ioutil.ReadAll(r.Body)
 			if err != nil {
 				logger.Error("failed to read payload", zap.Error(err))
+				tracing.SetError(span, err)
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
@@ -118,51 +290,222 @@ func NewGithubHTTPHandler(cfg config.WebhookConfig, config config.Config, logger
 			payload = pl
 		}
 
-		messageType := github.WebHookType(r)
-		event, err := github.ParseWebHook(messageType, payload)
-		if err != nil {
-			logger.Error("failed to parse webhook", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+		if cfg.RecordDir != "" {
+			if err := recordDelivery(cfg.RecordDir, r, payload); err != nil {
+				logger.Error("failed to record webhook", zap.Error(err))
+			}
+		}
+
+		eventsReceived.WithLabelValues(messageType).Inc()
+
+		// GitHub redelivers events and replicas may receive the same one
+		if deliveryID != "" && !deliveries.firstDelivery(deliveryID) {
+			logger.Info("ignoring redelivered webhook", zap.String("delivery", deliveryID))
+			span.SetAttributes(attribute.Bool("github.redelivery", true))
 			return
 		}
 
-		repo, err := extractRepository(event)
-		if err != nil {
-			logger.Error("Invalid payload", zap.Error(err))
+		// Handlers run in the queue consumer, which retries failed events
+		if err := enqueueDelivery(messageType, deliveryID, payload); err != nil {
+			logger.Error("failed to queue webhook", zap.Error(err))
+			tracing.SetError(span, err)
+			deliveries.forget(deliveryID)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		w.WriteHeader(http.StatusAccepted)
+	}, nil
+}
 
-		repoConfig := extractRepoConfigWithDefaults(repo, config)
-		if repo != nil {
-			logger.Debug("Processing event ", zap.String("messageType", messageType), zap.String("repo", *repo.Name))
-		}
-		if repoConfig.Disabled {
-			logger.Info("Disabled by configuration", zap.String("repo", *repo.Name))
-			return
+// validatePayload reads the payload of a webhook delivery and checks that it
+// is signed with one of the secrets. Accepting several secrets allows to
+// rotate them without rejecting deliveries.
+func validatePayload(r *http.Request, secrets []string) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read payload")
+	}
+
+	signature := r.Header.Get(signatureSHA256Header)
+	if signature == "" {
+		signature = r.Header.Get(signatureHeader)
+	}
+	valid := false
+	for _, secret := range secrets {
+		if valid = validSignature(signature, body, []byte(secret)); valid {
+			break
 		}
+	}
+	if !valid {
+		return nil, errors.New("payload signature check failed")
+	}
 
-		client, err := createClient(config.GitHubApp, event)
+	// The signature covers the whole form, the payload is a single field
+	if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(body))
 		if err != nil {
-			logger.Error("failed to create GitHub client", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return nil, errors.Wrap(err, "failed to parse form payload")
 		}
+		return []byte(form.Get("payload")), nil
+	}
+	return body, nil
+}
 
-		// ========================================================================
-		// Call all handlers
-		for _, wh := range handlerMap[messageType] {
-			logger.Debug("call handler", zap.String("type", messageType), zap.String("handler", reflect.TypeOf(wh).String()))
-			err = multierr.Combine(err, wh.HandleEvent(event, client, *repoConfig, logger))
-		}
+// validSignature checks a signature like "sha256=<hex digest>" of the
+// payload. Both the SHA1 and the SHA256 signature of GitHub are supported.
+func validSignature(signature string, payload, secret []byte) bool {
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	var newHash func() hash.Hash
+	switch parts[0] {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	default:
+		return false
+	}
+	expected, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, secret)
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// handlerContext returns the context a handler is called with, derived from
+// the delivery's context. It is cancelled after the configured handler
+// timeout.
+func handlerContext(ctx context.Context, cfg config.Config) (context.Context, context.CancelFunc) {
+	if cfg.HandlerTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, cfg.HandlerTimeout)
+}
 
-		// =========================================================================
+// handleDelivery calls all handlers of a webhook delivery
+func handleDelivery(messageType string, deliveryID string, payload []byte, logger *zap.Logger) error {
+	_, err := processDelivery(messageType, deliveryID, payload, nil, logger)
+	return err
+}
 
+// processDelivery calls the handlers of a webhook delivery. Retries of a
+// queued delivery only call the handlers which failed before, and work the
+// handlers defer queues the delivery again when it fails. It returns the
+// handlers which failed, none if the delivery failed before calling them.
+func processDelivery(messageType string, deliveryID string, payload []byte, queued *queue.Event, logger *zap.Logger) (failed []string, err error) {
+	// Handlers are cancelled when the bot shuts down and traced as part of
+	// the delivery's trace
+	deliveryCtx, span := tracer.Start(tracing.DeliveryContext(shutdownCtx, deliveryID), "process "+messageType)
+	processed := processedDelivery{DeliveryID: deliveryID, Type: messageType, Handlers: []handlerOutcome{}, payload: payload}
+	defer func() {
+		tracing.SetError(span, err)
+		span.End()
+		processed.Processed = time.Now()
 		if err != nil {
-			logger.Error("webhook handler failed", zap.String("error", fmt.Sprintf("%+v", err)))
-			w.WriteHeader(http.StatusInternalServerError)
+			processed.Error = err.Error()
 		}
-	}, nil
+		recentDeliveries.add(processed)
+	}()
+	span.SetAttributes(attribute.String("github.event", messageType), attribute.String("github.delivery", deliveryID))
+
+	event, err := parseWebHook(messageType, payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse webhook")
+	}
+	// Mutating requests of the handlers are audited with the event causing them
+	deliveryCtx = audit.WithTrigger(deliveryCtx, eventTrigger(messageType, event))
+
+	repo, err := extractRepository(event)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid payload")
+	}
+
+	cfg := currentConfig()
+	repoConfig := extractRepoConfigWithDefaults(repo, cfg)
+	if repo != nil {
+		processed.Repo = repo.GetFullName()
+		span.SetAttributes(attribute.String("github.repository", repo.GetFullName()))
+		logger.Debug("Processing event ", zap.String("messageType", messageType), zap.String("repo", *repo.Name))
+	}
+	if repoConfig.Disabled {
+		logger.Info("Disabled by configuration", zap.String("repo", *repo.Name))
+		processed.Skipped = "disabled by configuration"
+		return nil, nil
+	}
+
+	// Loading the client and the repository's config file is limited like
+	// a handler
+	ctx, cancel := handlerContext(deliveryCtx, cfg)
+	defer cancel()
+	client, err := createClient(ctx, cfg, event, deliveryID, logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create GitHub client")
+	}
+	gql := graphql.New(client)
+
+	if push, ok := event.(*pushEvent); ok {
+		forgetRepoConfigFile(push)
+	}
+	if repoConfig, err = withRepoConfigFile(ctx, repoConfig, repo, client, logger); err != nil {
+		return nil, err
+	}
+	if repoConfig.Disabled {
+		logger.Info("Disabled by repository configuration", zap.String("repo", repo.GetName()))
+		processed.Skipped = "disabled by repository configuration"
+		return nil, nil
+	}
+
+	var only []string
+	if queued != nil {
+		only = queued.Handlers
+	}
+
+	// ========================================================================
+	// Call all handlers
+	for _, wh := range handlersFor(messageType) {
+		if len(only) > 0 && !containsString(only, wh.name) {
+			// Succeeded on an earlier attempt
+			processed.Handlers = append(processed.Handlers, handlerOutcome{Name: wh.name, Skipped: true})
+			continue
+		}
+		if !repoConfig.HandlerEnabled(wh.name) {
+			logger.Debug("handler disabled by configuration", zap.String("handler", wh.name))
+			processed.Handlers = append(processed.Handlers, handlerOutcome{Name: wh.name, Disabled: true})
+			continue
+		}
+		handle := withMiddleware(wh.name, wh.HandleEvent)
+		handlerCtx, cancelHandler := handlerContext(deliveryCtx, cfg)
+		if queued != nil {
+			handlerCtx = withQueuedDelivery(handlerCtx, *queued, wh.name)
+		}
+		start := time.Now()
+		handlerErr := handle(handlerCtx, event, client, gql, *repoConfig, logger.With(zap.String("type", messageType)))
+		cancelHandler()
+		processed.Handlers = append(processed.Handlers, newHandlerOutcome(wh.name, start, handlerErr))
+		if handlerErr != nil {
+			failed = append(failed, wh.name)
+			err = multierr.Combine(err, handlerErr)
+		}
+	}
+	if len(cfg.Scripts) > 0 && repoConfig.HandlerEnabled(scriptsHandlerName) && (len(only) == 0 || containsString(only, scriptsHandlerName)) {
+		scriptsCtx, cancelScripts := handlerContext(deliveryCtx, cfg)
+		start := time.Now()
+		scriptsErr := runScripts(scriptsCtx, cfg.Scripts, messageType, payload, event, repo, client, *repoConfig, logger)
+		cancelScripts()
+		processed.Handlers = append(processed.Handlers, newHandlerOutcome(scriptsHandlerName, start, scriptsErr))
+		if scriptsErr != nil {
+			failed = append(failed, scriptsHandlerName)
+			err = multierr.Combine(err, scriptsErr)
+		}
+	}
+
+	// =========================================================================
+
+	return failed, err
 }
 
 func extractRepoConfigWithDefaults(repo *github.Repository, fullConfig config.Config) *config.RepoConfig {
@@ -175,12 +518,18 @@ func extractRepoConfigWithDefaults(repo *github.Repository, fullConfig config.Co
 		return ret
 	}
 
-	var repoSpecificConfig config.RepoConfig
-	if len(fullConfig.Repos) > 0 {
-		repoSpecificConfig = fullConfig.Repos[*repo.Name]
+	// A full name is more specific than a plain name, which may be used by
+	// repositories of different organisations
+	repoSpecificConfig, found := fullConfig.Repos[repo.GetFullName()]
+	if !found {
+		repoSpecificConfig = fullConfig.Repos[repo.GetName()]
 	}
 
+	// Fields set on a more specific level win, maps like labels or handlers
+	// are merged per key. Zero values don't override, so false can't switch
+	// off a bool set on a less specific level.
 	mergo.Merge(ret, fullConfig.DefaultRepo, mergo.WithOverride)
+	mergo.Merge(ret, fullConfig.Orgs[repo.Owner.GetLogin()], mergo.WithOverride)
 	mergo.Merge(ret, repoSpecificConfig, mergo.WithOverride)
 	return ret
 }
@@ -191,8 +540,30 @@ func extractRepository(event interface{}) (*github.Repository, error) {
 
 	val := reflect.Indirect(reflect.ValueOf(event))
 	if _, found := val.Type().FieldByName("Repo"); !found {
+		// Installation events concern multiple repositories
+		if _, found := val.Type().FieldByName("Installation"); found {
+			return nil, nil
+		}
 		return nil, fmt.Errorf("repository not found")
 	}
 
 	return val.FieldByName("Repo").Interface().(*github.Repository), nil
 }
+
+// eventTrigger describes the event for the audit log by its type, action and
+// the user who caused it
+func eventTrigger(messageType string, event interface{}) audit.Trigger {
+	trigger := audit.Trigger{Event: messageType}
+	val := reflect.Indirect(reflect.ValueOf(event))
+	if _, found := val.Type().FieldByName("Action"); found {
+		if action, ok := val.FieldByName("Action").Interface().(*string); ok && action != nil {
+			trigger.Event += "." + *action
+		}
+	}
+	if _, found := val.Type().FieldByName("Sender"); found {
+		if sender, ok := val.FieldByName("Sender").Interface().(*github.User); ok {
+			trigger.Actor = sender.GetLogin()
+		}
+	}
+	return trigger
+}
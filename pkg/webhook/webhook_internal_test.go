@@ -1,9 +1,22 @@
 package webhook
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
 )
 
 func TestIssueRegex(t *testing.T) {
@@ -81,3 +94,143 @@ func TestIssueRegex(t *testing.T) {
 	}
 
 }
+
+type testHandler struct{}
+
+func (h *testHandler) EventTypesHandled() []string {
+	return []string{"test_event"}
+}
+
+func (h *testHandler) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	return nil
+}
+
+func TestRegisterHandler(t *testing.T) {
+	if handlers := handlersFor("pull_request_review"); len(handlers) == 0 || handlers[0].name != "approvalLabel" {
+		t.Errorf("built-in handlers not registered in order: %v", handlers)
+	}
+
+	RegisterHandler("test", &testHandler{})
+	handlers := handlersFor("test_event")
+	if len(handlers) != 1 || handlers[0].name != "test" {
+		t.Fatalf("expected test handler to be registered, got %v", handlers)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a name twice to panic")
+		}
+	}()
+	RegisterHandler("test", &testHandler{})
+}
+
+func TestValidatePayload(t *testing.T) {
+	payload := `{"zen":"Keep it logically awesome."}`
+	sign := func(secret string) string {
+		mac := hmac.New(sha1.New, []byte(secret))
+		mac.Write([]byte(payload))
+		return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	sign256 := func(secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(payload))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name      string
+		signature string
+		sha256    string
+		expectErr bool
+	}{
+		{"current secret", sign("new"), "", false},
+		{"previous secret", sign("old"), "", false},
+		{"unknown secret", sign("other"), "", true},
+		{"unsigned", "", "", true},
+		{"sha256", "", sign256("new"), false},
+		{"sha256 preferred", sign("new"), sign256("other"), true},
+		{"unknown algorithm", "md5=" + sign("new")[5:], "", true},
+		{"malformed", "sha1=zz", "", true},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+		r.Header.Set("Content-Type", "application/json")
+		if test.signature != "" {
+			r.Header.Set(signatureHeader, test.signature)
+		}
+		if test.sha256 != "" {
+			r.Header.Set(signatureSHA256Header, test.sha256)
+		}
+		validated, err := validatePayload(r, []string{"new", "old"})
+		if (err != nil) != test.expectErr {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if err == nil && string(validated) != payload {
+			t.Errorf("%s: expected payload %s, got %s", test.name, payload, validated)
+		}
+	}
+}
+
+func TestExtractRepoConfigWithDefaults(t *testing.T) {
+	cfg := config.Config{
+		DefaultRepo: config.RepoConfig{
+			Labels:      config.LabelConfig{Approved: "approved", Hold: "hold"},
+			MergeMethod: "squash",
+		},
+		Orgs: map[string]config.RepoConfig{
+			"syndesisio": {
+				Labels:   config.LabelConfig{Approved: "status/approved"},
+				Handlers: map[string]bool{"labelSync": false},
+			},
+		},
+		Repos: map[string]config.RepoConfig{
+			"syndesis":            {MergeMethod: "rebase"},
+			"syndesisio/pure-bot": {Handlers: map[string]bool{"wip": false}},
+		},
+	}
+	repo := func(owner, name string) *github.Repository {
+		return &github.Repository{
+			Name:     github.String(name),
+			FullName: github.String(owner + "/" + name),
+			Owner:    &github.User{Login: github.String(owner)},
+		}
+	}
+
+	c := extractRepoConfigWithDefaults(repo("syndesisio", "syndesis"), cfg)
+	if c.Labels.Approved != "status/approved" || c.Labels.Hold != "hold" || c.MergeMethod != "rebase" || c.HandlerEnabled("labelSync") {
+		t.Errorf("expected org defaults overridden by repo, got %+v", c)
+	}
+
+	c = extractRepoConfigWithDefaults(repo("syndesisio", "pure-bot"), cfg)
+	if c.MergeMethod != "squash" || c.HandlerEnabled("labelSync") || c.HandlerEnabled("wip") {
+		t.Errorf("expected handler switches of org and repo merged, got %+v", c)
+	}
+
+	c = extractRepoConfigWithDefaults(repo("rhuss", "syndesis"), cfg)
+	if c.Labels.Approved != "approved" || c.MergeMethod != "rebase" || !c.HandlerEnabled("labelSync") {
+		t.Errorf("expected global defaults for other orgs, got %+v", c)
+	}
+}
+
+func TestHandlerContext(t *testing.T) {
+	ctx, cancel := handlerContext(context.Background(), config.Config{HandlerTimeout: 10 * time.Millisecond})
+	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		t.Fatal("expected handler context to have a deadline")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected handler context to be done after the timeout")
+	}
+
+	ctx, cancel = handlerContext(context.Background(), config.Config{})
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("expected no deadline without a handler timeout")
+	}
+	cancel()
+	if ctx.Err() != context.Canceled {
+		t.Errorf("expected handler context to be cancelled, got %v", ctx.Err())
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+// welcome greets authors of their first pull request in a repository
+type welcome struct{}
+
+func (h *welcome) EventTypesHandled() []string {
+	return []string{"pull_request"}
+}
+
+func (h *welcome) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	event, ok := eventObject.(*github.PullRequestEvent)
+	if !ok {
+		return errors.New("wrong event eventObject type")
+	}
+	if config.Welcome.Comment == "" || strings.ToLower(event.GetAction()) != "opened" {
+		return nil
+	}
+	pr := event.PullRequest
+	author := pr.User.GetLogin()
+	if strings.EqualFold(pr.User.GetType(), "Bot") {
+		return nil
+	}
+
+	query := fmt.Sprintf("repo:%s type:pr is:merged author:%s", event.Repo.GetFullName(), author)
+	result, _, err := gh.Search.Issues(ctx, query, &github.SearchOptions{ListOptions: github.ListOptions{PerPage: 1}})
+	if err != nil {
+		return errors.Wrapf(err, "failed to search merged pull requests of %s", author)
+	}
+	if result.GetTotal() > 0 {
+		logger.Debug("Not welcoming a returning contributor", zap.String("author", author), zap.Int("merged", result.GetTotal()))
+		return nil
+	}
+
+	body, err := renderTemplate("welcome", config.Welcome.Comment, mergeCommitData{
+		Title:  pr.GetTitle(),
+		Number: pr.GetNumber(),
+		Body:   pr.GetBody(),
+		Author: author,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to render welcome comment for %s", pr.GetHTMLURL())
+	}
+	_, _, err = gh.Issues.CreateComment(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), pr.GetNumber(), &github.IssueComment{Body: &body})
+	return errors.Wrapf(err, "failed to welcome %s on %s", author, pr.GetHTMLURL())
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/syndesisio/pure-bot/pkg/config"
+)
+
+func TestWelcome(t *testing.T) {
+	welcomeConfig := config.RepoConfig{
+		Welcome: config.WelcomeConfig{Comment: "Thanks @{{ .Author }}! Please read CONTRIBUTING.md."},
+	}
+
+	runScenarios(t, []scenario{
+		{
+			name:          "first pull request",
+			eventType:     "pull_request",
+			fixture:       "pull_request_opened.json",
+			handler:       &welcome{},
+			config:        welcomeConfig,
+			responses:     map[string]interface{}{searchIssues: searchJSON()},
+			expectedCalls: []string{createComment},
+		},
+		{
+			name:      "returning contributor",
+			eventType: "pull_request",
+			fixture:   "pull_request_opened.json",
+			handler:   &welcome{},
+			config:    welcomeConfig,
+			responses: map[string]interface{}{searchIssues: searchJSON(issueJSON(12))},
+		},
+		{
+			name:      "no welcome comment",
+			eventType: "pull_request",
+			fixture:   "pull_request_opened.json",
+			handler:   &welcome{},
+		},
+		{
+			name:      "synchronized pull request",
+			eventType: "pull_request",
+			fixture:   "pull_request_synchronize.json",
+			handler:   &welcome{},
+			config:    welcomeConfig,
+		},
+	})
+}
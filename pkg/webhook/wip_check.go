@@ -21,6 +21,7 @@ import (
 	"context"
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
 	"github.com/syndesisio/pure-bot/pkg/config"
 	"go.uber.org/zap"
 )
@@ -35,7 +36,7 @@ func (h *wip) EventTypesHandled() []string {
 	return []string{"pull_request"}
 }
 
-func (h *wip) HandleEvent(eventObject interface{}, gh *github.Client, config config.RepoConfig, logger *zap.Logger) error {
+func (h *wip) HandleEvent(ctx context.Context, eventObject interface{}, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
 
 	event, ok := eventObject.(*github.PullRequestEvent)
 	if !ok {
@@ -56,19 +57,21 @@ func (h *wip) HandleEvent(eventObject interface{}, gh *github.Client, config con
 	}
 
 	if wipPatternMatched := titleMatchesWipExpression(config, event.PullRequest.GetTitle()); wipPatternMatched != "" {
-		return createContextWithSpecifiedStatus(wipContext, pendingStatus, "Pending - title marked as work in progress with '"+wipPatternMatched+"'", event.Repo, event.PullRequest, gh)
+		// Failing rather than pending, so that the PR can't be merged even
+		// while other checks are pending
+		return createContextWithSpecifiedStatus(ctx, wipContext, failureStatus, "Blocked - title marked as work in progress with '"+wipPatternMatched+"'", event.Repo, event.PullRequest, gh)
 	}
 
-	wipLabelFound, err := prIsLabelledWithOneOfSpecifiedLabels(event.PullRequest, config.Labels.Wip, event.Repo, gh)
+	wipLabelFound, err := prIsLabelledWithOneOfSpecifiedLabels(ctx, event.PullRequest, config.Labels.Wip, event.Repo, gh)
 	if err != nil {
 		return errors.Wrapf(err, "failed to check for WIP labels on PR %s", event.PullRequest.GetHTMLURL())
 	}
 	if wipLabelFound != "" {
-		return createContextWithSpecifiedStatus(wipContext, pendingStatus, "Pending - labelled as work in progress with '"+wipLabelFound+"'", event.Repo, event.PullRequest, gh)
+		return createContextWithSpecifiedStatus(ctx, wipContext, pendingStatus, "Pending - labelled as work in progress with '"+wipLabelFound+"'", event.Repo, event.PullRequest, gh)
 	}
 
 	// All good
-	return createContextWithSpecifiedStatus(wipContext, successStatus, "OK - this is not a work in progress", event.Repo, event.PullRequest, gh)
+	return createContextWithSpecifiedStatus(ctx, wipContext, successStatus, "OK - this is not a work in progress", event.Repo, event.PullRequest, gh)
 }
 
 func titleMatchesWipExpression(config config.RepoConfig, title string) string {
@@ -84,9 +87,9 @@ func titleMatchesWipExpression(config config.RepoConfig, title string) string {
 	return ""
 }
 
-func prIsLabelledWithOneOfSpecifiedLabels(pr *github.PullRequest, specifiedLabels []string, repo *github.Repository, gh *github.Client) (string, error) {
+func prIsLabelledWithOneOfSpecifiedLabels(ctx context.Context, pr *github.PullRequest, specifiedLabels []string, repo *github.Repository, gh *github.Client) (string, error) {
 	labels, _, err := gh.Issues.ListLabelsByIssue(
-		context.Background(),
+		ctx,
 		repo.Owner.GetLogin(),
 		repo.GetName(),
 		pr.GetNumber(),
@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestWipTitle(t *testing.T) {
+	listLabels := "GET " + fixtureRepo + "/issues/276/labels"
+	wipConfig := config.RepoConfig{WipPatterns: []string{"wip", "do not merge"}}
+
+	tests := []struct {
+		title         string
+		expectedState string
+	}{
+		{"WIP: Add size labels", "failure"},
+		{"[WIP] Add size labels", "failure"},
+		{"Add size labels (do not merge)", "failure"},
+		{"Add size labels", "success"},
+		{"Wipe caches on startup", "success"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.title, func(t *testing.T) {
+			fake := newFakeGitHub(t, map[string]interface{}{listLabels: []interface{}{}})
+			defer fake.close()
+
+			event := &github.PullRequestEvent{
+				Action: github.String("edited"),
+				PullRequest: &github.PullRequest{
+					Number: github.Int(fixturePR),
+					Title:  github.String(test.title),
+					Head:   &github.PullRequestBranch{SHA: github.String(fixtureSHA)},
+				},
+				Repo: &github.Repository{Name: github.String("syndesis-rest"), Owner: &github.User{Login: github.String("syndesisio")}},
+			}
+			if err := (&wip{}).HandleEvent(context.Background(), event, fake.client(), fake.graphQL(), wipConfig, zap.NewNop()); err != nil {
+				t.Fatalf("WIP check failed: %+v", err)
+			}
+			var status struct {
+				State   string `json:"state"`
+				Context string `json:"context"`
+			}
+			if err := fake.requestBody(createStatus, &status); err != nil {
+				t.Fatal(err)
+			}
+			if status.State != test.expectedState || status.Context != wipContext {
+				t.Errorf("expected %s status %s, got %+v", wipContext, test.expectedState, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright © 2017 Syndesis Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/syndesisio/pure-bot/pkg/config"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	workflowContextPrefix = "workflow/"
+
+	// Preview media type for listing the pull requests of a commit
+	grootPreviewAcceptHeader = "application/vnd.github.groot-preview+json"
+)
+
+func (h *autoMerger) handleWorkflowRunEvent(ctx context.Context, event *workflowRunEvent, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	run := event.WorkflowRun
+	if strings.ToLower(event.GetAction()) != "completed" || run == nil {
+		return nil
+	}
+
+	if config.MirrorWorkflowStatus {
+		if err := mirrorWorkflowStatus(ctx, event, gh); err != nil {
+			return err
+		}
+	}
+
+	metrics.Add(mergeEventsReceived, 1)
+	owner, repository := event.Repo.Owner.GetLogin(), event.Repo.GetName()
+	// Shares the key with status events, so that both are evaluated together
+	return debounce(ctx, event.Repo.GetFullName()+"@"+run.HeadSHA, debounceWindow(config), logger, func(ctx context.Context) error {
+		return mergeCommitPRs(ctx, owner, repository, run.HeadSHA, run.PullRequests, gh, gql, config, logger)
+	})
+}
+
+// mergeCommitPRs evaluates the pull requests of a commit for which CI
+// reported a result. The pull requests included in the event are used if
+// present, otherwise they are looked up.
+func mergeCommitPRs(ctx context.Context, owner, repository, commitSHA string, eventPRs []*github.PullRequest, gh *github.Client, gql *githubv4.Client, config config.RepoConfig, logger *zap.Logger) error {
+	numbers := make([]int, 0, len(eventPRs))
+	for _, pr := range eventPRs {
+		numbers = append(numbers, pr.GetNumber())
+	}
+	if len(numbers) == 0 {
+		// Pull requests from forks are not included in the event
+		prs, err := listPullRequestsForCommit(ctx, gh, owner, repository, commitSHA)
+		if err != nil {
+			return err
+		}
+		for _, pr := range prs {
+			if pr.GetState() == "open" {
+				numbers = append(numbers, pr.GetNumber())
+			}
+		}
+	}
+
+	cache := newEvaluationCache()
+	var multiErr error
+	for _, number := range numbers {
+		issue, _, err := gh.Issues.Get(ctx, owner, repository, number)
+		if err != nil {
+			multiErr = multierr.Combine(multiErr, errors.Wrapf(err, "failed to get issue %s/%s#%d", owner, repository, number))
+			continue
+		}
+		pr, _, err := gh.PullRequests.Get(ctx, owner, repository, number)
+		if err != nil {
+			multiErr = multierr.Combine(multiErr, errors.Wrapf(err, "failed to get pull request %s", issue.GetHTMLURL()))
+			continue
+		}
+		_, err = mergePR(ctx, issue, pr, owner, repository, gh, gql, commitSHA, cache, config, logger)
+		multiErr = multierr.Combine(multiErr, err)
+	}
+	return multiErr
+}
+
+// listPullRequestsForCommit returns all pull requests containing a commit
+func listPullRequestsForCommit(ctx context.Context, gh *github.Client, owner, repository, commitSHA string) ([]*github.PullRequest, error) {
+	req, err := gh.NewRequest("GET", fmt.Sprintf("repos/%s/%s/commits/%s/pulls", owner, repository, commitSHA), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Accept", grootPreviewAcceptHeader)
+
+	var prs []*github.PullRequest
+	if _, err := gh.Do(ctx, req, &prs); err != nil {
+		return nil, errors.Wrapf(err, "failed to list pull requests of commit %s in %s/%s", commitSHA, owner, repository)
+	}
+	return prs, nil
+}
+
+// mirrorWorkflowStatus publishes the conclusion of a workflow run as commit
+// status, so that it can be used as required context
+func mirrorWorkflowStatus(ctx context.Context, event *workflowRunEvent, gh *github.Client) error {
+	run := event.WorkflowRun
+	var state string
+	switch strings.ToLower(run.Conclusion) {
+	case "success", "neutral", "skipped":
+		state = "success"
+	case "cancelled", "stale":
+		state = "error"
+	default:
+		state = "failure"
+	}
+
+	statusContext := workflowContextPrefix + run.Name
+	description := fmt.Sprintf("Workflow run concluded with %s", run.Conclusion)
+	_, _, err := gh.Repositories.CreateStatus(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), run.HeadSHA, &github.RepoStatus{
+		State:       &state,
+		Context:     &statusContext,
+		Description: &description,
+		TargetURL:   &run.HTMLURL,
+	})
+	return errors.Wrapf(err, "failed to set status %s on commit %s", statusContext, run.HeadSHA)
+}